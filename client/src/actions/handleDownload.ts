@@ -21,6 +21,24 @@ const MAX_ESTIMATED_PROGRESS = 95;
 const FAKE_PROGRESS_DURATION_MS = 25_000; // 25 seconds
 const FAKE_PROGRESS_TICK_MS = 600;
 
+// Returns the file name suggested by a Content-Disposition header, preferring
+// the UTF-8 filename* parameter (RFC 6266) over the ASCII fallback.
+function fileNameFromDisposition(header: unknown): string | null {
+  if (typeof header !== "string") return null;
+
+  const encoded = /filename\*\s*=\s*UTF-8''([^;]+)/i.exec(header);
+  if (encoded) {
+    try {
+      return decodeURIComponent(encoded[1].trim());
+    } catch {
+      // fall through to the ASCII name
+    }
+  }
+
+  const plain = /filename\s*=\s*"([^"]*)"/i.exec(header);
+  return plain ? plain[1] : null;
+}
+
 interface HandleDownloadParams {
   body: {
     type: VideoInfoResponse["_type"];
@@ -67,6 +85,9 @@ export default async function handleDownload({
     type,
     quality,
     format_note: formatNote,
+    ...(type === "video"
+      ? { video_format_id: format.format_id }
+      : { audio_format_id: format.format_id }),
   };
 
   // ---- progress helpers (monotonic + fake fallback) ----
@@ -112,10 +133,23 @@ export default async function handleDownload({
     startFakeProgress();
 
     const response = await apiInstance.post<Blob>(
-      "/api/video/download",
+      "/api/v1/video/download",
       payload,
       {
         responseType: "blob",
+        // The server sends Content-Length when it knows the exact size;
+        // then real progress replaces the estimate.
+        onDownloadProgress: (event) => {
+          if (!event.total) return;
+
+          stopFakeProgress();
+          emitProgress(
+            Math.min(
+              MAX_ESTIMATED_PROGRESS,
+              Math.round((event.loaded / event.total) * 100),
+            ),
+          );
+        },
       },
     );
 
@@ -129,7 +163,11 @@ export default async function handleDownload({
     const link = document.createElement("a");
 
     link.href = url;
-    link.setAttribute("download", fileName);
+    link.setAttribute(
+      "download",
+      fileNameFromDisposition(response.headers["content-disposition"]) ??
+        fileName,
+    );
 
     document.body.appendChild(link);
 
@@ -14,6 +14,22 @@ interface HandleFetchVideoParams {
   >;
 }
 
+// Loads the thumbnail through the server, so the browser never contacts the
+// upstream CDN. Returns an object URL, or "" when there is none.
+async function fetchThumbnail(videoUrl: string): Promise<string> {
+  try {
+    const response = await apiInstance.get<Blob>("/api/v1/video/thumbnail", {
+      params: { url: videoUrl, width: 480, format: "jpeg" },
+      responseType: "blob",
+    });
+
+    return URL.createObjectURL(response.data);
+  } catch (error) {
+    console.error("Error fetching thumbnail:", error);
+    return "";
+  }
+}
+
 export default async function handleFetchVideo({
   videoUrl,
   setIsLoading,
@@ -28,7 +44,7 @@ export default async function handleFetchVideo({
 
   try {
     const response = await apiInstance.get<VideoInfoResponse>(
-      "/api/video/info",
+      "/api/v1/video/info",
       { params: { url: videoUrl } },
     );
 
@@ -59,9 +75,11 @@ export default async function handleFetchVideo({
       });
     }
 
+    const thumbnail = await fetchThumbnail(videoUrl);
+
     setVideoData({
       title: data.title,
-      thumbnail: data.thumbnail,
+      thumbnail,
       videoFormats,
       audioFormats,
     });
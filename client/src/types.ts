@@ -75,4 +75,6 @@ export interface DownloadRequestPayload {
   type: VideoInfoResponse["_type"];
   quality: number;
   format_note: string;
+  video_format_id?: string;
+  audio_format_id?: string;
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/config"
+	"github.com/gabriel-logan/yt-dlp/server/internal/keys"
+)
+
+// checker prints one line per check and remembers whether any of them failed.
+type checker struct {
+	out    io.Writer
+	failed bool
+}
+
+func (c *checker) ok(name, detail string) {
+	fmt.Fprintf(c.out, "[ OK ] %s: %s\n", name, detail)
+}
+
+func (c *checker) warn(name, detail string) {
+	fmt.Fprintf(c.out, "[WARN] %s: %s\n", name, detail)
+}
+
+func (c *checker) fail(name, detail string) {
+	c.failed = true
+	fmt.Fprintf(c.out, "[FAIL] %s: %s\n", name, detail)
+}
+
+// runCheck validates the deployment and exits non-zero when anything is wrong,
+// so it can gate deploy scripts.
+func runCheck(args []string, out io.Writer) int {
+	envErr := loadEnv()
+
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(out)
+	distPath := fs.String("dist", "", "path to the built client (default: embedded build, or ../client/dist)")
+	scriptsPath := fs.String("scripts", "", "directory containing the yt-dlp binary (default: YT_DLP_PATH, the versions dir, $PATH, then scripts next to the executable)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	c := &checker{out: out}
+
+	if envErr != nil {
+		c.warn(".env", fmt.Sprintf("not loaded (%v); using the process environment", envErr))
+	} else {
+		c.ok(".env", "loaded")
+	}
+
+	if port, err := strconv.Atoi(os.Getenv("SERVER_PORT")); err != nil || port < 1 || port > 65535 {
+		c.fail("SERVER_PORT", fmt.Sprintf("%q is not a valid port", os.Getenv("SERVER_PORT")))
+	} else {
+		c.ok("SERVER_PORT", strconv.Itoa(port))
+	}
+
+	if os.Getenv("VITE_X_API_KEY") == "" {
+		c.fail("VITE_X_API_KEY", "not set; API requests without a key would be accepted")
+	} else {
+		c.ok("VITE_X_API_KEY", "set")
+	}
+
+	if os.Getenv("ADMIN_API_KEY") == "" {
+		c.warn("ADMIN_API_KEY", "not set; admin routes are disabled")
+	} else {
+		c.ok("ADMIN_API_KEY", "set")
+	}
+
+	if yt, err := newYTCore(*scriptsPath); err != nil {
+		c.fail("yt-dlp", err.Error())
+	} else {
+		c.ok("yt-dlp", fmt.Sprintf("%s (%s, from %s)", yt.BinaryVersion, yt.BinaryPath, yt.Source))
+	}
+
+	if path, err := exec.LookPath("ffmpeg"); err != nil {
+		c.fail("ffmpeg", "not found in PATH; merging video and audio streams will fail")
+	} else {
+		c.ok("ffmpeg", path)
+	}
+
+	dist, distSource := clientFS(*distPath)
+	if info, err := iofs.Stat(dist, "index.html"); err != nil || info.IsDir() {
+		c.fail("client dist", fmt.Sprintf("%s does not contain index.html", distSource))
+	} else {
+		c.ok("client dist", distSource)
+	}
+
+	if config.EnvInt64("DOWNLOAD_CACHE_MAX_MB", 2048) > 0 {
+		dir := config.EnvString("DOWNLOAD_CACHE_DIR", filepath.Join(os.TempDir(), "yt-dlp-server-cache"))
+		if err := checkWritableDir(dir); err != nil {
+			c.fail("download cache", err.Error())
+		} else {
+			c.ok("download cache", dir)
+		}
+	}
+
+	if store, err := keys.Open(config.APIKeysFile()); err != nil {
+		c.fail("API keys", err.Error())
+	} else if list, err := store.List(); err != nil {
+		c.fail("API keys", err.Error())
+	} else {
+		c.ok("API keys", fmt.Sprintf("%d stored in %s", len(list), store.Path()))
+	}
+
+	if c.failed {
+		return 1
+	}
+
+	return 0
+}
+
+// Creates dir if needed and verifies that files can be written to it.
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("cannot create %s: %v", dir, err)
+	}
+
+	f, err := os.CreateTemp(dir, ".check-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %v", dir, err)
+	}
+	f.Close()
+
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRunHelpAndUnknownCommand(t *testing.T) {
+	var out, errOut bytes.Buffer
+
+	if code := run([]string{"help"}, &out, &errOut); code != 0 {
+		t.Fatalf("expected exit 0 for help, got %d", code)
+	}
+	if !strings.Contains(out.String(), "serve") {
+		t.Fatalf("expected usage to list commands, got %q", out.String())
+	}
+
+	if code := run([]string{"bogus"}, &out, &errOut); code != 2 {
+		t.Fatalf("expected exit 2 for unknown command, got %d", code)
+	}
+}
+
+func TestRunKeysLifecycle(t *testing.T) {
+	t.Setenv("API_KEYS_FILE", filepath.Join(t.TempDir(), "api_keys.json"))
+
+	var out, errOut bytes.Buffer
+	if code := run([]string{"keys", "create", "-name", "deploy"}, &out, &errOut); code != 0 {
+		t.Fatalf("keys create failed with %d: %s", code, errOut.String())
+	}
+
+	id := regexp.MustCompile(`Created key ([0-9a-f]+)`).FindStringSubmatch(out.String())
+	if id == nil {
+		t.Fatalf("expected created key id in output, got %q", out.String())
+	}
+
+	out.Reset()
+	run([]string{"keys", "list"}, &out, &errOut)
+	if !strings.Contains(out.String(), "deploy") || !strings.Contains(out.String(), "active") {
+		t.Fatalf("expected active key in list, got %q", out.String())
+	}
+
+	if code := run([]string{"keys", "revoke", id[1]}, &out, &errOut); code != 0 {
+		t.Fatalf("keys revoke failed with %d: %s", code, errOut.String())
+	}
+
+	out.Reset()
+	run([]string{"keys", "list"}, &out, &errOut)
+	if !strings.Contains(out.String(), "revoked") {
+		t.Fatalf("expected revoked key in list, got %q", out.String())
+	}
+
+	if code := run([]string{"keys", "revoke", "unknown"}, &out, &errOut); code != 1 {
+		t.Fatalf("expected exit 1 revoking unknown key, got %d", code)
+	}
+}
+
+func TestRunVersionPrintsGoVersion(t *testing.T) {
+	var out, errOut bytes.Buffer
+
+	if code := run([]string{"version", "-scripts", t.TempDir()}, &out, &errOut); code != 0 {
+		t.Fatalf("expected exit 0, got %d", code)
+	}
+
+	if !strings.Contains(out.String(), "go:") || !strings.Contains(out.String(), "yt-dlp:   unavailable") {
+		t.Fatalf("unexpected version output: %q", out.String())
+	}
+}
+
+func TestRunCheckFailsOnBrokenSetup(t *testing.T) {
+	t.Setenv("SERVER_PORT", "not-a-port")
+	t.Setenv("API_KEYS_FILE", filepath.Join(t.TempDir(), "api_keys.json"))
+	t.Setenv("DOWNLOAD_CACHE_MAX_MB", "0")
+
+	var out, errOut bytes.Buffer
+	code := run([]string{"check", "-dist", t.TempDir(), "-scripts", t.TempDir()}, &out, &errOut)
+
+	if code != 1 {
+		t.Fatalf("expected exit 1, got %d: %s", code, out.String())
+	}
+
+	for _, want := range []string{"[FAIL] SERVER_PORT", "[FAIL] yt-dlp", "[FAIL] client dist"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunYTDlpInstallAndRollback(t *testing.T) {
+	t.Setenv("YT_DLP_VERSIONS_DIR", t.TempDir())
+	t.Setenv("YT_DLP_SCRIPT_NAME", "yt-dlp")
+
+	var out, errOut bytes.Buffer
+
+	for _, version := range []string{"2025.01.01", "2025.02.02"} {
+		file := filepath.Join(t.TempDir(), "yt-dlp")
+		if err := os.WriteFile(file, []byte("#!/bin/sh\necho "+version+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if code := run([]string{"yt-dlp", "install", file}, &out, &errOut); code != 0 {
+			t.Fatalf("install failed with %d: %s", code, errOut.String())
+		}
+	}
+
+	if code := run([]string{"yt-dlp", "rollback"}, &out, &errOut); code != 0 {
+		t.Fatalf("rollback failed with %d: %s", code, errOut.String())
+	}
+
+	out.Reset()
+	run([]string{"yt-dlp", "status"}, &out, &errOut)
+	if !strings.Contains(out.String(), "current:  2025.01.01") || !strings.Contains(out.String(), "previous: 2025.02.02") {
+		t.Fatalf("unexpected status output:\n%s", out.String())
+	}
+
+	if code := run([]string{"yt-dlp", "install", "-sha256", "00", filepath.Join(t.TempDir(), "missing")}, &out, &errOut); code != 1 {
+		t.Fatalf("expected exit 1 for a missing file, got %d", code)
+	}
+}
+
+func TestRunOpenAPIPrintsDocument(t *testing.T) {
+	var out, errOut bytes.Buffer
+
+	if code := run([]string{"openapi"}, &out, &errOut); code != 0 {
+		t.Fatalf("openapi failed with %d: %s", code, errOut.String())
+	}
+	if !strings.Contains(out.String(), `"openapi": "3.0.3"`) || !strings.Contains(out.String(), `"/api/v1/video/info"`) {
+		t.Fatalf("unexpected document: %.200s", out.String())
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/config"
+	"github.com/gabriel-logan/yt-dlp/server/internal/keys"
+)
+
+func runKeys(args []string, stdout, stderr io.Writer) int {
+	_ = loadEnv()
+
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "Usage: server keys create [-name NAME] | list | revoke ID")
+		return 2
+	}
+
+	store, err := keys.Open(config.APIKeysFile())
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("keys create", flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		name := fs.String("name", "", "label to recognize the key by")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+
+		key, secret, err := store.Create(*name)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+
+		fmt.Fprintf(stdout, "Created key %s in %s\n", key.ID, store.Path())
+		fmt.Fprintf(stdout, "Secret (shown only once): %s\n", secret)
+
+	case "list":
+		list, err := store.List()
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+
+		tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tCREATED\tSTATUS")
+		for _, k := range list {
+			status := "active"
+			if k.Revoked() {
+				status = "revoked " + k.RevokedAt.Format("2006-01-02")
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s…\t%s\t%s\n", k.ID, k.Name, k.Prefix, k.CreatedAt.Format("2006-01-02"), status)
+		}
+		tw.Flush()
+
+	case "revoke":
+		if len(args) != 2 {
+			fmt.Fprintln(stderr, "Usage: server keys revoke ID")
+			return 2
+		}
+
+		if err := store.Revoke(args[1]); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+
+		fmt.Fprintf(stdout, "Revoked key %s\n", args[1])
+
+	default:
+		fmt.Fprintf(stderr, "unknown keys command %q\n", args[0])
+		return 2
+	}
+
+	return 0
+}
@@ -1,57 +1,102 @@
 package main
 
 import (
-	"log"
-	"net/http"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"time"
+	"strings"
 
-	"github.com/gabriel-logan/yt-dlp/server/internal/api"
-	"github.com/gabriel-logan/yt-dlp/server/internal/config"
 	"github.com/gabriel-logan/yt-dlp/server/internal/core"
-	"github.com/gabriel-logan/yt-dlp/server/internal/middleware"
 	"github.com/gabriel-logan/yt-dlp/server/internal/web"
 	"github.com/joho/godotenv"
 )
 
-const requestsTimeout = 5 * time.Minute
-
 func main() {
-	envPath := filepath.Join(core.Getwd(), "..", ".env")
-	if err := godotenv.Load(envPath); err != nil {
-		log.Fatal("Error loading .env file")
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run dispatches to a subcommand; without one the server is started, as before.
+func run(args []string, stdout, stderr io.Writer) int {
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	config.InitLogger()
+	switch command {
+	case "serve":
+		return runServe(args)
+	case "check":
+		return runCheck(args, stdout)
+	case "version":
+		return runVersion(args, stdout)
+	case "keys":
+		return runKeys(args, stdout, stderr)
+	case "yt-dlp":
+		return runYTDlp(args, stdout, stderr)
+	case "openapi":
+		return runOpenAPI(args, stdout, stderr)
+	case "help":
+		printUsage(stdout)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n", command)
+		printUsage(stderr)
+		return 2
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprint(w, `Usage: server [command] [flags]
+
+Commands:
+  serve     start the HTTP server (default)
+  check     validate configuration, yt-dlp, ffmpeg and directories
+  version   print build information and the yt-dlp version
+  keys      manage API keys: keys create|list|revoke
+  yt-dlp    manage the yt-dlp binary: yt-dlp status|install|rollback
+  openapi   print the OpenAPI document of the API
+  help      show this help
 
-	mux := http.NewServeMux()
+Run "server <command> -h" for the flags of a command.
+`)
+}
 
-	webDistPath := filepath.Join(core.Getwd(), "..", "client", "dist")
+// Loads ../.env relative to the working directory.
+func loadEnv() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
 
-	// SPA Handler
-	web.RegisterSPA(mux, webDistPath)
+	return godotenv.Load(filepath.Join(cwd, "..", ".env"))
+}
 
-	// API Routes
-	api.RegisterAPIRoutes(mux)
+// Returns the yt-dlp core from scriptsDir, or from the default location when it is empty.
+func newYTCore(scriptsDir string) (*core.YTCore, error) {
+	if scriptsDir == "" {
+		return core.InitYTCore()
+	}
 
-	// Global Middleware Stack
-	stack := middleware.CreateChain(
-		middleware.Recover,
-		middleware.Logger,
-		middleware.CORS,
-		middleware.RateLimit,
-		middleware.Auth,
-		middleware.Timeout(requestsTimeout),
-	)
+	return core.InitYTCoreIn(scriptsDir)
+}
+
+func defaultDistPath() string {
+	return filepath.Join("..", "client", "dist")
+}
 
-	serverPort := os.Getenv("SERVER_PORT")
+// Returns the client build to serve and a description of where it comes from:
+// the build embedded with -tags embedspa when distPath is empty, otherwise the
+// directory at distPath (or ../client/dist).
+func clientFS(distPath string) (fs.FS, string) {
+	if distPath == "" {
+		if embedded, ok := web.EmbeddedDist(); ok {
+			return embedded, "embedded"
+		}
 
-	server := http.Server{
-		Addr:    ":" + serverPort,
-		Handler: stack(mux),
+		distPath = defaultDistPath()
 	}
 
-	log.Printf("Starting server on http://localhost:%s", serverPort)
-	log.Fatal(server.ListenAndServe())
+	return os.DirFS(distPath), distPath
 }
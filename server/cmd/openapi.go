@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+)
+
+// runOpenAPI prints the OpenAPI document served at /api/openapi.json, so
+// clients can be generated without a running server.
+func runOpenAPI(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("o", "", "write the document to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	h := api.NewHandlers(nil, api.Options{})
+	defer h.Close()
+
+	spec, err := json.MarshalIndent(h.OpenAPI(), "", "  ")
+	if err != nil {
+		fmt.Fprintln(stderr, "openapi:", err)
+		return 1
+	}
+	spec = append(spec, '\n')
+
+	if *output == "" {
+		stdout.Write(spec)
+		return 0
+	}
+
+	if err := os.WriteFile(*output, spec, 0o644); err != nil {
+		fmt.Fprintln(stderr, "openapi:", err)
+		return 1
+	}
+
+	return 0
+}
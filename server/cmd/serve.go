@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+	"github.com/gabriel-logan/yt-dlp/server/internal/config"
+	"github.com/gabriel-logan/yt-dlp/server/internal/middleware"
+	"github.com/gabriel-logan/yt-dlp/server/internal/web"
+)
+
+const requestsTimeout = 5 * time.Minute
+
+func runServe(args []string) int {
+	// like check, run from the process environment alone when there is no .env
+	if err := loadEnv(); errors.Is(err, os.ErrNotExist) {
+		log.Println("No .env file; using the process environment")
+	} else if err != nil {
+		log.Fatal("Error loading .env file: ", err)
+	}
+
+	config.InitLogger()
+
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	port := fs.String("port", os.Getenv("SERVER_PORT"), "port to listen on (default $SERVER_PORT)")
+	distPath := fs.String("dist", "", "path to the built client (default: embedded build, or ../client/dist)")
+	scriptsPath := fs.String("scripts", "", "directory containing the yt-dlp binary (default: YT_DLP_PATH, the versions dir, $PATH, then scripts next to the executable)")
+	fs.Parse(args)
+
+	yt, err := newYTCore(*scriptsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Using yt-dlp %s from %s (%s)", yt.BinaryVersion, yt.BinaryPath, yt.Source)
+
+	mux := http.NewServeMux()
+
+	// SPA Handler
+	dist, distSource := clientFS(*distPath)
+	log.Printf("Serving client from %s", distSource)
+	web.RegisterSPA(mux, dist, web.SecurityHeadersFromEnv())
+
+	// API Routes
+	handlers := api.NewHandlers(yt, api.OptionsFromEnv())
+	api.RegisterAPIRoutes(mux, handlers)
+
+	server := http.Server{
+		Addr:    ":" + *port,
+		Handler: withMiddleware(mux, handlers, requestsTimeout),
+	}
+
+	log.Printf("Starting server on http://localhost:%s", *port)
+	log.Fatal(server.ListenAndServe())
+
+	return 0
+}
+
+// Returns mux behind the global middleware stack. Requests time out after
+// timeout, except those to the routes of h that stream a file or a download.
+func withMiddleware(mux *http.ServeMux, h *api.Handlers, timeout time.Duration) http.Handler {
+	stack := middleware.CreateChain(
+		middleware.RequestID,
+		middleware.Recover,
+		middleware.Logger,
+		middleware.JSONErrors,
+		middleware.CORS,
+		middleware.RateLimit,
+		middleware.Auth,
+		middleware.TimeoutExcept(timeout, h.Streams),
+	)
+
+	return stack(mux)
+}
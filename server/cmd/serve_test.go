@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/coretest"
+)
+
+func TestMiddlewareLetsDownloadsOutliveTheTimeout(t *testing.T) {
+	t.Setenv("VITE_X_API_KEY", "secret")
+	t.Setenv("API_KEYS_FILE", filepath.Join(t.TempDir(), "api_keys.json"))
+
+	data := bytes.Repeat([]byte("media"), 1000)
+	release := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(release) })
+
+	fake := coretest.NewFake()
+	fake.SetDownload("", coretest.Download{Data: data, Block: release})
+
+	mux := http.NewServeMux()
+	h := api.NewHandlers(fake, api.Options{JobsDir: t.TempDir(), HasFFmpeg: func() bool { return true }})
+	t.Cleanup(h.Close)
+	api.RegisterAPIRoutes(mux, h)
+	srv := httptest.NewServer(withMiddleware(mux, h, 20*time.Millisecond))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("POST", srv.URL+api.VideoDownloadPath, strings.NewReader(`{"url":"https://www.youtube.com/watch?v=dQw4w9WgXcQ","type":"audio"}`))
+	req.Header.Set("X-API-KEY", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(body, data) {
+		t.Fatalf("expected the download despite the timeout, got %d: %.100s", resp.StatusCode, body)
+	}
+}
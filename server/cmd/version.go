@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"runtime/debug"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+func runVersion(args []string, out io.Writer) int {
+	_ = loadEnv()
+
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	fs.SetOutput(out)
+	scriptsPath := fs.String("scripts", "", "directory containing the yt-dlp binary (default: YT_DLP_PATH, the versions dir, $PATH, then scripts next to the executable)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	module, version := "unknown", "(devel)"
+	settings := map[string]string{}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		module, version = bi.Main.Path, bi.Main.Version
+		for _, s := range bi.Settings {
+			settings[s.Key] = s.Value
+		}
+	}
+
+	fmt.Fprintf(out, "server:   %s %s\n", module, version)
+	if rev := settings["vcs.revision"]; rev != "" {
+		if settings["vcs.modified"] == "true" {
+			rev += " (modified)"
+		}
+		fmt.Fprintf(out, "revision: %s\n", rev)
+	}
+	if t := settings["vcs.time"]; t != "" {
+		fmt.Fprintf(out, "built:    %s\n", t)
+	}
+	fmt.Fprintf(out, "go:       %s %s/%s\n", core.GetGoVersion(), core.GetGOOS(), core.GetGOARCH())
+
+	fmt.Fprintf(out, "yt-dlp:   %s\n", ytDlpVersion(*scriptsPath))
+
+	return 0
+}
+
+func ytDlpVersion(scriptsPath string) string {
+	yt, err := newYTCore(scriptsPath)
+	if err != nil {
+		return fmt.Sprintf("unavailable (%v)", err)
+	}
+
+	return fmt.Sprintf("%s (%s)", yt.BinaryVersion, yt.BinaryPath)
+}
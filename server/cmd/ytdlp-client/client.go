@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+)
+
+type client struct {
+	server string
+	apiKey string
+	http   *http.Client
+}
+
+// apiError is returned when the server answers with a non-2xx status.
+type apiError struct {
+	Status    int
+	Code      string // set when the server sent an api.ErrorResponse
+	Message   string
+	RequestID string
+	Retryable bool
+	Fields    []string // rejected request fields, Ex: "url: is required"
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("server returned %d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+	if len(e.Fields) > 0 {
+		msg += " (" + strings.Join(e.Fields, "; ") + ")"
+	}
+	if e.Retryable {
+		msg += " (retryable)"
+	}
+	if e.RequestID != "" {
+		msg += " [request " + e.RequestID + "]"
+	}
+
+	return msg
+}
+
+func newClient(cfg clientConfig) *client {
+	return &client{server: cfg.Server, apiKey: cfg.APIKey, http: &http.Client{}}
+}
+
+func (c *client) info(videoURL string) (*api.VideoInfoResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, c.server+api.VideoInfoPath+"?url="+url.QueryEscape(videoURL), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var info api.VideoInfoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("invalid info response: %v", err)
+	}
+
+	return &info, nil
+}
+
+// Starts a download; the caller must close the response body.
+func (c *client) download(body api.DownloadRequest) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.server+api.VideoDownloadPath, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.do(req)
+}
+
+func (c *client) do(req *http.Request) (*http.Response, error) {
+	if c.apiKey != "" {
+		req.Header.Set("X-API-KEY", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+		var (
+			body    api.ErrorResponse
+			details api.ValidationDetails
+		)
+		body.Error.Details = &details
+
+		if json.Unmarshal(msg, &body) == nil && body.Error.Message != "" {
+			e := body.Error
+			apiErr := &apiError{Status: resp.StatusCode, Code: e.Code, Message: e.Message, RequestID: e.RequestID, Retryable: e.Retryable}
+			for _, fe := range details.Fields {
+				apiErr.Fields = append(apiErr.Fields, fe.Field+": "+fe.Message)
+			}
+
+			return nil, apiErr
+		}
+
+		return nil, &apiError{Status: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+)
+
+// batchError reports a batch download in which some items failed.
+type batchError struct {
+	Failed int
+	Total  int
+}
+
+func (e *batchError) Error() string {
+	return fmt.Sprintf("%d of %d downloads failed", e.Failed, e.Total)
+}
+
+func runInfo(c *client, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("info", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		return usageError{"info expects exactly one URL"}
+	}
+
+	info, err := c.info(positional[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Title:    %s\n", info.Title)
+	fmt.Fprintf(stdout, "ID:       %s\n", info.ID)
+	if info.Uploader != "" {
+		fmt.Fprintf(stdout, "Uploader: %s\n", info.Uploader)
+	}
+	if info.Duration > 0 {
+		fmt.Fprintf(stdout, "Duration: %s\n", time.Duration(info.Duration*float64(time.Second)).Round(time.Second))
+	}
+	fmt.Fprintln(stdout)
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tEXT\tRESOLUTION\tFPS\tVCODEC\tACODEC\tSIZE\tNOTE")
+
+	for _, f := range info.Formats {
+		size := "-"
+		if f.Filesize > 0 {
+			size = formatBytes(f.Filesize)
+		} else if f.FilesizeApprox > 0 {
+			size = "~" + formatBytes(f.FilesizeApprox)
+		}
+
+		fps := "-"
+		if f.FPS > 0 {
+			fps = fmt.Sprintf("%g", f.FPS)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			f.FormatID, f.Ext, orDash(f.Resolution), fps, orDash(f.VCodec), orDash(f.ACodec), size, f.FormatNote)
+	}
+
+	return tw.Flush()
+}
+
+func runDownload(c *client, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("download", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	dType := fs.String("type", "video", "video or audio")
+	quality := fs.Int("quality", 0, "quality index")
+	formatNote := fs.String("format-note", "", "exact format note, e.g. 720p60")
+	formatID := fs.String("format-id", "", "video format_id from info")
+	audioFormatID := fs.String("audio-format-id", "", "audio format_id from info")
+	forceKeyframes := fs.Bool("force-keyframes", false, "cut sections exactly, re-encoding around the cuts")
+	splitChapters := fs.Bool("split-chapters", false, "download a ZIP with one file per chapter")
+	embed := fs.String("embed", "", "comma-separated: metadata, thumbnail, chapters, info-json")
+	metaTitle := fs.String("meta-title", "", "title tag to write")
+	metaArtist := fs.String("meta-artist", "", "artist tag to write")
+	metaAlbum := fs.String("meta-album", "", "album tag to write")
+
+	var sections []api.DownloadSection
+	fs.Func("section", "time range START-END, e.g. 1:30-2:00; END may be left out", func(s string) error {
+		start, end, ok := strings.Cut(s, "-")
+		if !ok {
+			return fmt.Errorf("expected START-END, got %q", s)
+		}
+		sections = append(sections, api.DownloadSection{Start: start, End: end})
+		return nil
+	})
+	fs.Func("chapter", "regular expression matching the title of the chapter to download", func(s string) error {
+		sections = append(sections, api.DownloadSection{Chapter: s})
+		return nil
+	})
+	output := fs.String("o", "", "output file, or output directory with --batch")
+	batch := fs.String("batch", "", "file with one URL per line")
+	quiet := fs.Bool("quiet", false, "do not show the progress bar")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if *dType != "video" && *dType != "audio" {
+		return usageError{"--type must be either video or audio"}
+	}
+
+	embedded := map[string]bool{}
+	for _, name := range strings.Split(*embed, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+		case "metadata", "thumbnail", "chapters", "info-json":
+			embedded[name] = true
+		default:
+			return usageError{fmt.Sprintf("--embed: unknown value %q", name)}
+		}
+	}
+
+	showProgress := !*quiet && isTerminal(stderr)
+
+	newRequest := func(videoURL string) api.DownloadRequest {
+		return api.DownloadRequest{
+			URL: videoURL, Type: *dType, Quality: *quality, FormatNote: *formatNote,
+			VideoFormatID: *formatID, AudioFormatID: *audioFormatID,
+			Sections: sections, ForceKeyframes: *forceKeyframes, SplitChapters: *splitChapters,
+			EmbedMetadata: embedded["metadata"], EmbedThumbnail: embedded["thumbnail"],
+			EmbedChapters: embedded["chapters"], EmbedInfoJSON: embedded["info-json"],
+			MetaTitle: *metaTitle, MetaArtist: *metaArtist, MetaAlbum: *metaAlbum,
+		}
+	}
+
+	if *batch == "" {
+		if len(positional) != 1 {
+			return usageError{"download expects exactly one URL, or --batch FILE"}
+		}
+
+		return downloadOne(c, newRequest(positional[0]), *output, "", showProgress, stdout, stderr)
+	}
+
+	if len(positional) != 0 {
+		return usageError{"download does not accept URLs together with --batch"}
+	}
+
+	videoURLs, err := readBatchFile(*batch)
+	if err != nil {
+		return err
+	}
+
+	outDir := *output
+	if outDir == "" {
+		outDir = "."
+	}
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, videoURL := range videoURLs {
+		if err := downloadOne(c, newRequest(videoURL), "", outDir, showProgress, stdout, stderr); err != nil {
+			fmt.Fprintf(stderr, "error: %s: %v\n", videoURL, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return &batchError{Failed: failed, Total: len(videoURLs)}
+	}
+
+	return nil
+}
+
+// Downloads a single item to outPath, or to a server-suggested name inside outDir.
+func downloadOne(c *client, req api.DownloadRequest, outPath, outDir string, showProgress bool, stdout, stderr io.Writer) error {
+	resp, err := c.download(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// a server-suggested name is reserved so items sharing it never overwrite each other
+	reserved := outPath == ""
+	if reserved {
+		outPath, err = reserveFileName(outDir, suggestedFileName(resp.Header.Get("Content-Disposition")))
+		if err != nil {
+			return err
+		}
+	}
+
+	tmpPath := outPath + ".part"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		if reserved {
+			os.Remove(outPath)
+		}
+		return err
+	}
+
+	var dst io.Writer = f
+	var progress *progressWriter
+	if showProgress {
+		progress = newProgressWriter(stderr, filepath.Base(outPath), resp.ContentLength)
+		dst = io.MultiWriter(f, progress)
+	}
+
+	_, copyErr := io.Copy(dst, resp.Body)
+	closeErr := f.Close()
+
+	if progress != nil {
+		progress.Finish()
+	}
+
+	if copyErr == nil {
+		copyErr = closeErr
+	}
+	if copyErr != nil {
+		os.Remove(tmpPath)
+		if reserved {
+			os.Remove(outPath)
+		}
+		return fmt.Errorf("download interrupted: %v", copyErr)
+	}
+
+	if err := os.Rename(tmpPath, outPath); err != nil {
+		os.Remove(tmpPath)
+		if reserved {
+			os.Remove(outPath)
+		}
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Saved %s\n", outPath)
+
+	return nil
+}
+
+// Creates an empty file named name inside dir, or "name (2).ext", "name (3).ext"
+// and so on when it exists, and returns its path.
+func reserveFileName(dir, name string) (string, error) {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+
+	for n := 1; ; n++ {
+		path := filepath.Join(dir, name)
+		if n > 1 {
+			path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", stem, n, ext))
+		}
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			return path, f.Close()
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+	}
+}
+
+// Returns the file name from a Content-Disposition header, reduced to a safe base name.
+func suggestedFileName(disposition string) string {
+	_, params, err := mime.ParseMediaType(disposition)
+	if err == nil {
+		if name := filepath.Base(filepath.Clean(params["filename"])); name != "." && name != "/" && name != ".." {
+			return name
+		}
+	}
+
+	return "download.bin"
+}
+
+// Reads URLs from path, one per line, skipping blank lines and "#" comments.
+func readBatchFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var videoURLs []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		videoURLs = append(videoURLs, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(videoURLs) == 0 {
+		return nil, usageError{fmt.Sprintf("batch file %s contains no URLs", path)}
+	}
+
+	return videoURLs, nil
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
@@ -0,0 +1,219 @@
+// Command ytdlp-client is a command-line client for the yt-dlp server API.
+//
+// Usage:
+//
+//	ytdlp-client [global flags] info <url>
+//	ytdlp-client [global flags] download <url> [--type video|audio] [--quality N] [--format-note NOTE] [--format-id ID] [--audio-format-id ID] [--section START-END | --chapter REGEX] [-o FILE]
+//	ytdlp-client [global flags] download --batch FILE [-o DIR]
+//
+// The server URL and API key are read from flags, then the YTDLP_SERVER and
+// YTDLP_API_KEY environment variables, then the JSON config file.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Exit codes.
+const (
+	exitOK        = 0
+	exitError     = 1 // network or local I/O failure
+	exitUsage     = 2 // invalid command line
+	exitRejected  = 3 // the server rejected the request (4xx)
+	exitServer    = 4 // the server failed to process the request (5xx)
+	exitPartial   = 5 // some items of a batch failed
+	defaultServer = "http://localhost:8080"
+)
+
+// clientConfig is the on-disk configuration file format.
+type clientConfig struct {
+	Server string `json:"server"`
+	APIKey string `json:"api_key"`
+}
+
+// usageError marks errors caused by invalid arguments.
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("ytdlp-client", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { printUsage(stderr) }
+
+	server := global.String("server", "", "server base URL (env YTDLP_SERVER)")
+	apiKey := global.String("key", "", "API key sent as X-API-KEY (env YTDLP_API_KEY)")
+	configPath := global.String("config", defaultConfigPath(), "path to the JSON config file")
+
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if global.NArg() == 0 {
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(*configPath, *server, *apiKey)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	c := newClient(cfg)
+
+	cmd, cmdArgs := global.Arg(0), global.Args()[1:]
+
+	switch cmd {
+	case "info":
+		err = runInfo(c, cmdArgs, stdout, stderr)
+	case "download":
+		err = runDownload(c, cmdArgs, stdout, stderr)
+	case "help":
+		printUsage(stdout)
+		return exitOK
+	default:
+		err = usageError{fmt.Sprintf("unknown command %q", cmd)}
+	}
+
+	return exitCode(err, stderr)
+}
+
+func exitCode(err error, stderr io.Writer) int {
+	if err == nil {
+		return exitOK
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+
+	fmt.Fprintf(stderr, "error: %v\n", err)
+
+	var uErr usageError
+	var apiErr *apiError
+	var bErr *batchError
+
+	switch {
+	case errors.As(err, &uErr):
+		return exitUsage
+	case errors.As(err, &bErr):
+		return exitPartial
+	case errors.As(err, &apiErr) && apiErr.Status >= 500:
+		return exitServer
+	case errors.As(err, &apiErr):
+		return exitRejected
+	default:
+		return exitError
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprint(w, `Usage: ytdlp-client [--server URL] [--key KEY] [--config FILE] <command> [args]
+
+Commands:
+  info <url>                 show video details and available formats
+  download <url>             download a video or audio track
+  download --batch FILE      download every URL listed in FILE (one per line)
+  help                       show this help
+
+Download flags:
+  --type video|audio         what to download (default video)
+  --quality N                quality index (default 0)
+  --format-note NOTE         exact format note, e.g. 720p60
+  --format-id ID             video format_id from "info"; merged with the best audio if video-only
+  --audio-format-id ID       audio format_id from "info"
+  --section START-END        download only this time range, e.g. 1:30-2:00 or 1:30-
+  --chapter REGEX            download only the chapter whose title matches REGEX
+  --force-keyframes          cut sections exactly, re-encoding around the cuts (slower)
+  --split-chapters           download a ZIP with one file per chapter
+  --embed LIST               write into the file: metadata, thumbnail, chapters, info-json
+  --meta-title, --meta-artist, --meta-album TEXT
+                             tags to write instead of the video's
+  -o PATH                    output file, or output directory in batch mode
+  --quiet                    do not show the progress bar
+
+Exit codes: 0 ok, 1 error, 2 usage, 3 request rejected, 4 server error, 5 batch partially failed
+`)
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "ytdlp-client", "config.json")
+}
+
+// Merges flags, environment variables and the config file, in that order of precedence.
+func loadConfig(path, server, apiKey string) (clientConfig, error) {
+	var cfg clientConfig
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return cfg, fmt.Errorf("failed to read config file: %v", err)
+		}
+
+		if err == nil {
+			if err := json.Unmarshal(data, &cfg); err != nil {
+				return cfg, fmt.Errorf("invalid config file %s: %v", path, err)
+			}
+		}
+	}
+
+	if v := os.Getenv("YTDLP_SERVER"); v != "" {
+		cfg.Server = v
+	}
+	if v := os.Getenv("YTDLP_API_KEY"); v != "" {
+		cfg.APIKey = v
+	}
+
+	if server != "" {
+		cfg.Server = server
+	}
+	if apiKey != "" {
+		cfg.APIKey = apiKey
+	}
+
+	if cfg.Server == "" {
+		cfg.Server = defaultServer
+	}
+	cfg.Server = strings.TrimRight(cfg.Server, "/")
+
+	return cfg, nil
+}
+
+// parseInterspersed parses fs allowing flags after positional arguments,
+// as in "download <url> --type audio".
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
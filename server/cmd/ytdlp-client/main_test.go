@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+api.VideoInfoPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-KEY") != "k" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"abc","title":"Test Video","duration":61,"formats":[{"format_id":"18","ext":"mp4","resolution":"640x360","vcodec":"avc1","acodec":"mp4a","filesize":2048,"format_note":"360p"}]}`))
+	})
+
+	mux.HandleFunc("POST "+api.VideoDownloadPath, func(w http.ResponseWriter, r *http.Request) {
+		var req api.DownloadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(req.URL, "invalid") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: apierror.Error{
+				Code:      apierror.CodeValidationFailed,
+				Message:   "request validation failed",
+				RequestID: "req-42",
+				Details: api.ValidationDetails{Fields: validate.Errors{
+					{Field: "format_note", Code: validate.CodeTooLong, Message: "must be at most 100 characters"},
+				}},
+			}})
+			return
+		}
+		if strings.Contains(req.URL, "broken") {
+			http.Error(w, "yt-dlp download failed", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="video.mp4"`)
+		w.Write([]byte("DATA:" + req.Type))
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func runClient(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"--config", ""}, args...), &stdout, &stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestInfoPrintsFormats(t *testing.T) {
+	ts := newTestServer(t)
+
+	code, out, errOut := runClient(t, "--server", ts.URL, "--key", "k", "info", "https://youtu.be/abc")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d: %s", code, errOut)
+	}
+
+	for _, want := range []string{"Test Video", "1m1s", "640x360", "2.0 KiB", "360p"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestInfoUnauthorizedExitCode(t *testing.T) {
+	ts := newTestServer(t)
+
+	code, _, _ := runClient(t, "--server", ts.URL, "info", "https://youtu.be/abc")
+	if code != exitRejected {
+		t.Fatalf("expected exit %d, got %d", exitRejected, code)
+	}
+}
+
+func TestDownloadWritesFile(t *testing.T) {
+	ts := newTestServer(t)
+	out := filepath.Join(t.TempDir(), "out.m4a")
+
+	code, _, errOut := runClient(t, "--server", ts.URL, "download", "https://youtu.be/abc", "--type", "audio", "-o", out)
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d: %s", code, errOut)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("expected output file: %v", err)
+	}
+	if string(data) != "DATA:audio" {
+		t.Fatalf("unexpected file content: %q", data)
+	}
+}
+
+func TestDownloadSendsOptions(t *testing.T) {
+	var got api.DownloadRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte("clip"))
+	}))
+	t.Cleanup(ts.Close)
+
+	out := filepath.Join(t.TempDir(), "clip.mkv")
+	code, _, errOut := runClient(t, "--server", ts.URL, "download", "https://youtu.be/abc",
+		"--section", "1:30-2:00", "--chapter", "^Q&A$", "--force-keyframes",
+		"--embed", "thumbnail,metadata", "--meta-artist", "The Band", "-o", out)
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d: %s", code, errOut)
+	}
+
+	want := []api.DownloadSection{{Start: "1:30", End: "2:00"}, {Chapter: "^Q&A$"}}
+	if len(got.Sections) != 2 || got.Sections[0] != want[0] || got.Sections[1] != want[1] || !got.ForceKeyframes {
+		t.Fatalf("unexpected request %+v", got)
+	}
+	if !got.EmbedThumbnail || !got.EmbedMetadata || got.EmbedChapters || got.MetaArtist != "The Band" {
+		t.Fatalf("unexpected embed options %+v", got)
+	}
+
+	if code, _, errOut := runClient(t, "--server", ts.URL, "download", "https://youtu.be/abc", "--section", "90"); code == exitOK || !strings.Contains(errOut, "START-END") {
+		t.Fatalf("expected a section without END to be rejected, got exit %d: %s", code, errOut)
+	}
+}
+
+func TestDownloadServerErrorExitCode(t *testing.T) {
+	ts := newTestServer(t)
+	out := filepath.Join(t.TempDir(), "out.mp4")
+
+	code, _, _ := runClient(t, "--server", ts.URL, "download", "https://example.com/broken", "-o", out)
+	if code != exitServer {
+		t.Fatalf("expected exit %d, got %d", exitServer, code)
+	}
+
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("expected no output file on failure")
+	}
+}
+
+func TestDownloadValidationErrorListsFields(t *testing.T) {
+	ts := newTestServer(t)
+
+	code, _, errOut := runClient(t, "--server", ts.URL, "download", "https://example.com/invalid", "-o", filepath.Join(t.TempDir(), "out.mp4"))
+	if code != exitRejected {
+		t.Fatalf("expected exit %d, got %d", exitRejected, code)
+	}
+
+	for _, want := range []string{"format_note: must be at most 100 characters", "req-42"} {
+		if !strings.Contains(errOut, want) {
+			t.Fatalf("expected error output to contain %q, got %q", want, errOut)
+		}
+	}
+}
+
+func TestDownloadBatchPartialFailure(t *testing.T) {
+	ts := newTestServer(t)
+	dir := t.TempDir()
+
+	batch := filepath.Join(dir, "urls.txt")
+	os.WriteFile(batch, []byte("# comment\nhttps://youtu.be/abc\n\nhttps://example.com/broken\n"), 0o644)
+
+	outDir := filepath.Join(dir, "out")
+	code, _, _ := runClient(t, "--server", ts.URL, "download", "--batch", batch, "-o", outDir)
+	if code != exitPartial {
+		t.Fatalf("expected exit %d, got %d", exitPartial, code)
+	}
+
+	if _, err := os.Stat(filepath.Join(outDir, "video.mp4")); err != nil {
+		t.Fatalf("expected successful item to be saved with the server file name: %v", err)
+	}
+}
+
+func TestDownloadBatchKeepsFilesWithTheSameName(t *testing.T) {
+	ts := newTestServer(t)
+	dir := t.TempDir()
+
+	batch := filepath.Join(dir, "urls.txt")
+	os.WriteFile(batch, []byte("https://youtu.be/abc\nhttps://youtu.be/def\n"), 0o644)
+
+	outDir := filepath.Join(dir, "out")
+	os.MkdirAll(outDir, 0o755)
+	os.WriteFile(filepath.Join(outDir, "video.mp4"), []byte("existing"), 0o644)
+
+	code, _, errOut := runClient(t, "--server", ts.URL, "download", "--batch", batch, "-o", outDir)
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d: %s", code, errOut)
+	}
+
+	for name, want := range map[string]string{"video.mp4": "existing", "video (2).mp4": "DATA:video", "video (3).mp4": "DATA:video"} {
+		data, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil || string(data) != want {
+			t.Fatalf("expected %s to contain %q, got %q (%v)", name, want, data, err)
+		}
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"info"},
+		{"download", "https://youtu.be/abc", "--type", "gif"},
+		{"download", "https://youtu.be/abc", "--embed", "lyrics"},
+	} {
+		if code, _, _ := runClient(t, args...); code != exitUsage {
+			t.Fatalf("args %v: expected exit %d, got %d", args, exitUsage, code)
+		}
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"server":"http://file:1","api_key":"file-key"}`), 0o644)
+
+	t.Setenv("YTDLP_SERVER", "")
+	t.Setenv("YTDLP_API_KEY", "env-key")
+
+	cfg, err := loadConfig(path, "http://flag:2/", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Server != "http://flag:2" || cfg.APIKey != "env-key" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
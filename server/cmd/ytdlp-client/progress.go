@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const progressBarWidth = 30
+
+// progressWriter renders a single-line progress bar while bytes pass through it.
+type progressWriter struct {
+	out      io.Writer
+	label    string
+	total    int64 // -1 when unknown
+	written  int64
+	start    time.Time
+	lastDraw time.Time
+}
+
+func newProgressWriter(out io.Writer, label string, total int64) *progressWriter {
+	return &progressWriter{out: out, label: label, total: total, start: time.Now()}
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	p.written += int64(len(b))
+
+	if time.Since(p.lastDraw) >= 100*time.Millisecond {
+		p.draw()
+	}
+
+	return len(b), nil
+}
+
+// Draws the final state and ends the line.
+func (p *progressWriter) Finish() {
+	p.draw()
+	fmt.Fprintln(p.out)
+}
+
+func (p *progressWriter) draw() {
+	p.lastDraw = time.Now()
+
+	elapsed := time.Since(p.start).Seconds()
+	rate := ""
+	if elapsed > 0 {
+		rate = formatBytes(int64(float64(p.written)/elapsed)) + "/s"
+	}
+
+	if p.total > 0 {
+		ratio := float64(p.written) / float64(p.total)
+		if ratio > 1 {
+			ratio = 1
+		}
+		filled := int(ratio * progressBarWidth)
+		bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+		fmt.Fprintf(p.out, "\r%s [%s] %5.1f%% %s / %s %s   ", p.label, bar, ratio*100, formatBytes(p.written), formatBytes(p.total), rate)
+		return
+	}
+
+	fmt.Fprintf(p.out, "\r%s %s %s   ", p.label, formatBytes(p.written), rate)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
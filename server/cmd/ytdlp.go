@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/config"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+const ytDlpUsage = "Usage: server yt-dlp status | install [-sha256 HEX] FILE | rollback"
+
+// runYTDlp manages the yt-dlp versions directory directly, for servers that
+// receive updates as files. A running server picks up the change on restart;
+// use the /api/v1/admin/yt-dlp endpoints to switch it live.
+func runYTDlp(args []string, stdout, stderr io.Writer) int {
+	_ = loadEnv()
+
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, ytDlpUsage)
+		return 2
+	}
+
+	versions := core.NewBinaryVersions(config.YTDlpVersionsDir())
+
+	switch args[0] {
+	case "status":
+		if yt, err := core.InitYTCore(); err != nil {
+			fmt.Fprintf(stdout, "in use:   none (%v)\n", err)
+		} else {
+			fmt.Fprintf(stdout, "in use:   %s (%s, from %s)\n", yt.BinaryVersion, yt.BinaryPath, yt.Source)
+		}
+
+		current, previous, err := versions.State()
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+
+		fmt.Fprintf(stdout, "current:  %s\n", describeInstalled(current))
+		fmt.Fprintf(stdout, "previous: %s\n", describeInstalled(previous))
+
+	case "install":
+		fs := flag.NewFlagSet("yt-dlp install", flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		sum := fs.String("sha256", "", "expected hex SHA-256 of FILE")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if fs.NArg() != 1 {
+			fmt.Fprintln(stderr, ytDlpUsage)
+			return 2
+		}
+
+		// the first install keeps the binary in use, so it can be rolled back to
+		if yt, err := core.InitYTCore(); err == nil {
+			versions.InUse = func() string { return yt.BinaryPath }
+		}
+
+		installed, err := versions.InstallFile(context.Background(), fs.Arg(0), *sum)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+
+		fmt.Fprintf(stdout, "Installed yt-dlp %s at %s\n", installed.Version, installed.Path)
+		fmt.Fprintln(stdout, "Restart the server to use it.")
+
+	case "rollback":
+		previous, err := versions.Rollback()
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+
+		fmt.Fprintf(stdout, "Rolled back to yt-dlp %s at %s\n", previous.Version, previous.Path)
+		fmt.Fprintln(stdout, "Restart the server to use it.")
+
+	default:
+		fmt.Fprintf(stderr, "unknown yt-dlp command %q\n", args[0])
+		return 2
+	}
+
+	return 0
+}
+
+func describeInstalled(b *core.InstalledBinary) string {
+	if b == nil {
+		return "none"
+	}
+
+	return fmt.Sprintf("%s (sha256 %s, installed %s)", b.Version, b.SHA256[:12], b.InstalledAt.Format("2006-01-02 15:04"))
+}
@@ -5,3 +5,7 @@ go 1.25.0
 require github.com/joho/godotenv v1.5.1
 
 require golang.org/x/time v0.14.0
+
+require golang.org/x/sync v0.19.0
+
+require golang.org/x/image v0.25.0
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/config"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/urls"
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+)
+
+// Purges cached video info: a single entry when the url query parameter is set, otherwise all of them.
+func (h *Handlers) PurgeInfoCacheHandler(w http.ResponseWriter, r *http.Request) {
+	var query PurgeInfoQuery
+	if err := validate.Query(r.URL.Query(), &query); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	purged := 0
+
+	if url := strings.TrimSpace(query.URL); url != "" {
+		if h.infoCache.Purge(urls.Resolve(url).Key()) {
+			purged = 1
+		}
+	} else {
+		purged = h.infoCache.PurgeAll()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PurgeResponse{Purged: purged})
+}
+
+// Purges every completed download from the download cache.
+func (h *Handlers) PurgeDownloadCacheHandler(w http.ResponseWriter, r *http.Request) {
+	purged := 0
+
+	if cache := h.downloadCache; cache != nil {
+		purged = cache.Purge()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PurgeResponse{Purged: purged})
+}
+
+// binarySwitcher is implemented by extractors whose yt-dlp binary can be
+// replaced while the server runs, such as core.YTCore.
+type binarySwitcher interface {
+	Binary() (path, version, source string)
+	SetBinary(path, version, source string)
+}
+
+// Reports the yt-dlp binary in use and the installed versions.
+func (h *Handlers) YTDlpStatusHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeYTDlpStatus(w, r)
+}
+
+// Installs a new yt-dlp binary and switches to it without a restart. The body is
+// either the binary itself (sha256 query parameter optional) or, with a JSON
+// content type, a YTDlpInstallRequest naming a file on the server.
+func (h *Handlers) YTDlpInstallHandler(w http.ResponseWriter, r *http.Request) {
+	if h.versions == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "yt-dlp updates are not enabled")
+		return
+	}
+
+	var (
+		installed *core.InstalledBinary
+		err       error
+	)
+
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "application/json" {
+		var req YTDlpInstallRequest
+		if err := validate.JSON(w, r, &req, maxJSONBodyBytes); err != nil {
+			writeValidationError(w, r, err)
+			return
+		}
+
+		installed, err = h.versions.InstallFile(r.Context(), req.Path, req.SHA256)
+	} else {
+		var query YTDlpInstallQuery
+		if err := validate.Query(r.URL.Query(), &query); err != nil {
+			writeValidationError(w, r, err)
+			return
+		}
+
+		maxBytes := config.EnvInt64("YT_DLP_MAX_UPLOAD_MB", 200) << 20
+		body := http.MaxBytesReader(w, r.Body, maxBytes)
+
+		installed, err = h.versions.Install(r.Context(), body, query.SHA256)
+	}
+
+	var maxErr *http.MaxBytesError
+
+	switch {
+	case err == nil:
+	case errors.As(err, &maxErr):
+		apierror.Write(w, r, http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge, "uploaded file is too large")
+		return
+	case errors.Is(err, core.ErrInvalidBinary):
+		apierror.Write(w, r, http.StatusUnprocessableEntity, "invalid_binary", err.Error())
+		return
+	default:
+		log.Println("yt-dlp install error: ", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to install yt-dlp")
+		return
+	}
+
+	h.useBinary(installed)
+	h.writeYTDlpStatus(w, r)
+}
+
+// Switches back to the yt-dlp binary that was current before the last install.
+func (h *Handlers) YTDlpRollbackHandler(w http.ResponseWriter, r *http.Request) {
+	if h.versions == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "yt-dlp updates are not enabled")
+		return
+	}
+
+	previous, err := h.versions.Rollback()
+	if errors.Is(err, core.ErrNoPreviousVersion) {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Println("yt-dlp rollback error: ", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to roll back yt-dlp")
+		return
+	}
+
+	h.useBinary(previous)
+	h.writeYTDlpStatus(w, r)
+}
+
+// Makes b the binary used by every handler from now on.
+func (h *Handlers) useBinary(b *core.InstalledBinary) {
+	if sw, ok := h.extractor.(binarySwitcher); ok {
+		sw.SetBinary(b.Path, b.Version, "versions dir")
+		log.Printf("Switched to yt-dlp %s (%s)", b.Version, b.Path)
+	} else {
+		log.Printf("Installed yt-dlp %s (%s); restart the server to use it", b.Version, b.Path)
+	}
+
+	// Cached info may have been produced by a broken extractor.
+	h.infoCache.PurgeAll()
+}
+
+func (h *Handlers) writeYTDlpStatus(w http.ResponseWriter, r *http.Request) {
+	var resp YTDlpStatusResponse
+
+	if sw, ok := h.extractor.(binarySwitcher); ok {
+		resp.Path, resp.Version, resp.Source = sw.Binary()
+	} else if v, err := h.extractor.Version(r.Context()); err == nil {
+		resp.Version = v
+	}
+
+	if h.versions != nil {
+		current, previous, err := h.versions.State()
+		if err != nil {
+			log.Println("yt-dlp versions error: ", err)
+		}
+		resp.Current, resp.Previous = current, previous
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
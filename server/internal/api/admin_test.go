@@ -0,0 +1,96 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/coretest"
+)
+
+func TestPurgeInfoCacheHandler(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo("", `{"id":"dQw4w9WgXcQ","title":"cached"}`)
+	h := newHandlers(t, fake, api.Options{})
+
+	h.VideoInfoHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/video/info?url=https://youtu.be/dQw4w9WgXcQ", nil))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/admin/cache/info?url=https://www.youtube.com/watch?v%3DdQw4w9WgXcQ", nil)
+	rr := httptest.NewRecorder()
+
+	h.PurgeInfoCacheHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+
+	var data map[string]int
+	if err := json.Unmarshal(rr.Body.Bytes(), &data); err != nil {
+		t.Fatalf("invalid json response: %v", err)
+	}
+
+	if data["purged"] != 1 {
+		t.Fatalf("expected the cached entry to be purged, got %d", data["purged"])
+	}
+}
+
+func TestYTDlpInstallAndRollbackHandlers(t *testing.T) {
+	t.Setenv("YT_DLP_SCRIPT_NAME", "yt-dlp")
+
+	inUse := filepath.Join(t.TempDir(), "yt-dlp")
+	if err := os.WriteFile(inUse, []byte("#!/bin/sh\necho old\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	yt := &core.YTCore{BinaryPath: inUse, BinaryVersion: "old", Source: "PATH"}
+	versions := core.NewBinaryVersions(t.TempDir())
+	h := newHandlers(t, yt, api.Options{Versions: versions})
+
+	install := func(version string) *httptest.ResponseRecorder {
+		body := strings.NewReader("#!/bin/sh\necho " + version + "\n")
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/yt-dlp", body)
+		req.Header.Set("Content-Type", "application/octet-stream")
+		rr := httptest.NewRecorder()
+		h.YTDlpInstallHandler(rr, req)
+		return rr
+	}
+
+	// a rejected install does not touch the versions directory
+	if rr := install(""); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422 for a binary with no version output, got %d", rr.Code)
+	}
+	if current, previous, _ := versions.State(); current != nil || previous != nil {
+		t.Fatalf("expected nothing installed, got current %+v, previous %+v", current, previous)
+	}
+
+	if rr := install("2025.01.01"); rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, version, _ := yt.Binary(); version != "2025.01.01" {
+		t.Fatalf("expected the core to switch to the new binary, got %q", version)
+	}
+
+	// the first install can be rolled back to the binary that was in use
+	rr := httptest.NewRecorder()
+	h.YTDlpRollbackHandler(rr, httptest.NewRequest(http.MethodPost, "/api/admin/yt-dlp/rollback", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var status api.YTDlpStatusResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("invalid json response: %v", err)
+	}
+	if status.Version != "old" || status.Path == inUse || status.Previous == nil || status.Previous.Version != "2025.01.01" {
+		t.Fatalf("unexpected status after rollback: %+v", status)
+	}
+
+	if rr := install(""); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422 for a binary with no version output, got %d", rr.Code)
+	}
+}
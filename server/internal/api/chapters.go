@@ -0,0 +1,125 @@
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/urls"
+	"github.com/gabriel-logan/yt-dlp/server/internal/filename"
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+)
+
+// Serves the chapters listed in the video info.
+func (h *Handlers) VideoChaptersHandler(w http.ResponseWriter, r *http.Request) {
+	var query VideoInfoQuery
+	if err := validate.Query(r.URL.Query(), &query); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	info, _, _, err := h.lookupVideoInfo(r.Context(), urls.Resolve(query.URL))
+	if err != nil {
+		writeError(w, r, "VideoInfo", err)
+		return
+	}
+
+	resp := ChaptersResponse{
+		ID:       info.ID,
+		Title:    info.Title,
+		Duration: info.Duration,
+		Chapters: make([]VideoChapter, 0, len(info.Chapters)),
+	}
+	for i, c := range info.Chapters {
+		resp.Chapters = append(resp.Chapters, VideoChapter{Index: i + 1, Title: c.Title, StartTime: c.StartTime, EndTime: c.EndTime})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Downloads cfg split by chapter into a temporary directory, then streams the
+// chapter files as a ZIP archive. Nothing is written until yt-dlp finished, so
+// failures still get an error status. The archive is sent as zipName.
+func (h *Handlers) sendChapters(ctx context.Context, w http.ResponseWriter, r *http.Request, cfg core.DownloadConfig, zipName string) {
+	dir, err := os.MkdirTemp("", "yt-dlp-chapters-*")
+	if err != nil {
+		writeError(w, r, "VideoDownload", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	select {
+	case h.downloadSem <- struct{}{}:
+	case <-ctx.Done():
+		writeError(w, r, "VideoDownload", fmt.Errorf("request was cancelled before acquiring semaphore: %v", ctx.Err()))
+		return
+	}
+
+	files, err := h.extractor.DownloadChapters(ctx, cfg, dir)
+	<-h.downloadSem
+
+	if err != nil {
+		writeError(w, r, "VideoDownload", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", filename.ContentDisposition(zipName))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeZip(w, files); err != nil {
+		log.Println("writeZip error: ", err)
+	}
+}
+
+// Writes files to w as a ZIP archive, named by their base names. Media is
+// already compressed, so the entries are stored as they are.
+func writeZip(w io.Writer, files []string) error {
+	zw := zip.NewWriter(w)
+
+	for _, path := range files {
+		if err := addZipFile(zw, path); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func addZipFile(zw *zip.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     filepath.Base(path),
+		Method:   zip.Store,
+		Modified: stat.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+
+	bp := copyBufPool.Get().(*[]byte)
+	defer copyBufPool.Put(bp)
+
+	_, err = io.CopyBuffer(dst, f, *bp)
+	return err
+}
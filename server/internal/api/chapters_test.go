@@ -0,0 +1,117 @@
+package api_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/coretest"
+)
+
+func TestVideoChaptersHandler(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testChaptersInfo)
+
+	w := httptest.NewRecorder()
+	newTestHandlers(t, fake).VideoChaptersHandler(w, httptest.NewRequest("GET", "/api/v1/video/chapters?url="+testVideoURL, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.ChaptersResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid json response: %v", err)
+	}
+	if resp.Title != "Webinar" || resp.Duration != 3600 || len(resp.Chapters) != 3 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if c := resp.Chapters[1]; c.Index != 2 || c.Title != "Demo part 1" || c.StartTime != 60 || c.EndTime != 1800 {
+		t.Fatalf("unexpected chapter %+v", c)
+	}
+}
+
+func TestVideoChaptersHandlerWithoutChapters(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testVideoInfo)
+
+	w := httptest.NewRecorder()
+	newTestHandlers(t, fake).VideoChaptersHandler(w, httptest.NewRequest("GET", "/api/v1/video/chapters?url="+testVideoURL, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"chapters":[]`) {
+		t.Fatalf("expected an empty chapter list, got %s", w.Body.String())
+	}
+}
+
+func TestVideoDownloadHandlerSplitChapters(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testChaptersInfo)
+	fake.SetDownload(testVideoURL, coretest.Download{Files: []coretest.File{
+		{Name: "001 - Intro.m4a", Data: []byte("one")},
+		{Name: "002 - Demo part 1.m4a", Data: []byte("two")},
+	}})
+
+	body := `{"url":"` + testVideoURL + `","type":"audio","split_chapters":true}`
+	w := httptest.NewRecorder()
+	newTestHandlers(t, fake).VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+
+	got := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		got[f.Name] = string(data)
+	}
+	if len(got) != 2 || got["001 - Intro.m4a"] != "one" || got["002 - Demo part 1.m4a"] != "two" {
+		t.Fatalf("unexpected archive %v", got)
+	}
+
+	if calls := fake.Downloads(); len(calls) != 1 || !calls[0].SplitChapters {
+		t.Fatalf("unexpected download configs %+v", calls)
+	}
+}
+
+func TestVideoDownloadHandlerSplitChaptersRejected(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testVideoInfo)
+	h := newTestHandlers(t, fake)
+
+	for body, code := range map[string]string{
+		`{"url":"` + testVideoURL + `","type":"video","split_chapters":true}`:                                  "no_chapters",
+		`{"url":"` + testVideoURL + `","type":"video","split_chapters":true,"sections":[{"chapter":"Intro"}]}`: "invalid_choice",
+	} {
+		w := httptest.NewRecorder()
+		h.VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), `"field":"split_chapters","code":"`+code+`"`) {
+			t.Fatalf("%s: unexpected body %s", body, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/openapi"
+)
+
+// docsTemplate renders the OpenAPI document without scripts or external
+// assets, so it works offline and under a strict CSP.
+var docsTemplate = template.Must(template.New("docs").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body{font:15px/1.5 system-ui,sans-serif;max-width:960px;margin:2rem auto;padding:0 1rem;color:#222}
+code,.path{font-family:ui-monospace,monospace}
+section{border:1px solid #ddd;border-radius:6px;padding:.5rem 1rem;margin:1rem 0}
+.method{display:inline-block;min-width:4.5rem;font-weight:bold}
+.deprecated{opacity:.6}
+table{border-collapse:collapse;width:100%}
+td,th{text-align:left;padding:.2rem .5rem;border-bottom:1px solid #eee;vertical-align:top}
+</style>
+</head>
+<body>
+<h1>{{.Title}} <small>{{.Version}}</small></h1>
+<p>{{.Description}} The machine-readable document is at <a href="{{.SpecPath}}"><code>{{.SpecPath}}</code></a>.</p>
+
+<h2>Endpoints</h2>
+{{range .Operations}}
+<section{{if .Deprecated}} class="deprecated"{{end}} id="{{.ID}}">
+<h3><span class="method">{{.Method}}</span> <span class="path">{{.Path}}</span></h3>
+<p>{{.Summary}}{{if .Deprecated}} <strong>Deprecated.</strong> {{.Description}}{{end}}</p>
+<p>Authentication: {{.Auth}}</p>
+{{if .Params}}<table><tr><th>Query parameter</th><th>Type</th><th></th></tr>
+{{range .Params}}<tr><td><code>{{.Name}}</code>{{if .Required}} (required){{end}}</td><td>{{.Type}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Request}}<p>Request body: {{.Request}}</p>{{end}}
+<table><tr><th>Status</th><th>Body</th></tr>
+{{range .Responses}}<tr><td>{{.Status}}</td><td>{{.Body}}</td></tr>
+{{end}}</table>
+</section>
+{{end}}
+
+<h2>Schemas</h2>
+{{range .Schemas}}
+<section id="schema-{{.Name}}">
+<h3>{{.Name}}</h3>
+<table><tr><th>Property</th><th>Type</th><th></th></tr>
+{{range .Properties}}<tr><td><code>{{.Name}}</code>{{if .Required}} (required){{end}}</td><td>{{.Type}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>
+</section>
+{{end}}
+</body>
+</html>
+`))
+
+type docsPage struct {
+	Title, Version, Description, SpecPath string
+
+	Operations []docsOperation
+	Schemas    []docsSchema
+}
+
+type docsOperation struct {
+	ID, Method, Path, Summary, Description, Auth string
+	Deprecated                                   bool
+
+	Params    []docsField
+	Request   string
+	Responses []docsResponse
+}
+
+type docsField struct {
+	Name, Type, Description string
+	Required                bool
+}
+
+type docsResponse struct {
+	Status, Body string
+}
+
+type docsSchema struct {
+	Name       string
+	Properties []docsField
+}
+
+// Serves a human-readable rendering of the OpenAPI document.
+func (h *Handlers) DocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+
+	if err := docsTemplate.Execute(w, newDocsPage(h.OpenAPI())); err != nil {
+		writeError(w, r, "Docs", err)
+	}
+}
+
+func newDocsPage(doc *openapi.Document) docsPage {
+	page := docsPage{
+		Title:       doc.Info.Title,
+		Version:     doc.Info.Version,
+		Description: doc.Info.Description,
+		SpecPath:    OpenAPIPath,
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		for _, method := range []string{"get", "post", "put", "patch", "delete"} {
+			op := doc.Paths[p][method]
+			if op == nil {
+				continue
+			}
+
+			page.Operations = append(page.Operations, newDocsOperation(doc, strings.ToUpper(method), p, op))
+		}
+	}
+
+	// current routes first, deprecated aliases after
+	slices.SortStableFunc(page.Operations, func(a, b docsOperation) int {
+		switch {
+		case a.Deprecated == b.Deprecated:
+			return 0
+		case b.Deprecated:
+			return -1
+		default:
+			return 1
+		}
+	})
+
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		page.Schemas = append(page.Schemas, docsSchema{Name: name, Properties: docsProperties(doc.Components.Schemas[name])})
+	}
+
+	return page
+}
+
+func newDocsOperation(doc *openapi.Document, method, path string, op *openapi.Operation) docsOperation {
+	d := docsOperation{
+		ID:          op.OperationID,
+		Method:      method,
+		Path:        path,
+		Summary:     op.Summary,
+		Description: op.Description,
+		Deprecated:  op.Deprecated,
+		Auth:        "API key",
+	}
+
+	switch {
+	case op.Security != nil && len(op.Security) == 0:
+		d.Auth = "none"
+	case len(op.Security) > 0:
+		if _, ok := op.Security[0]["adminKey"]; ok {
+			d.Auth = "admin key"
+		}
+	}
+
+	for _, p := range op.Parameters {
+		d.Params = append(d.Params, docsField{Name: p.Name, Type: schemaLabel(p.Schema), Description: p.Description, Required: p.Required})
+	}
+
+	if op.RequestBody != nil {
+		d.Request = contentLabel(op.RequestBody.Content)
+	}
+
+	statuses := make([]string, 0, len(op.Responses))
+	for status := range op.Responses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses) // "default" sorts after the numeric codes
+
+	for _, status := range statuses {
+		resp := op.Responses[status]
+		body := contentLabel(resp.Content)
+		if body == "" {
+			body = resp.Description
+		}
+		d.Responses = append(d.Responses, docsResponse{Status: status, Body: body})
+	}
+
+	return d
+}
+
+func docsProperties(s *openapi.Schema) []docsField {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]docsField, 0, len(names))
+	for _, name := range names {
+		p := s.Properties[name]
+		fields = append(fields, docsField{
+			Name:        name,
+			Type:        schemaLabel(p),
+			Description: p.Description,
+			Required:    slices.Contains(s.Required, name),
+		})
+	}
+
+	return fields
+}
+
+// Returns "content-type: schema" pairs, Ex: "application/json: DownloadRequest".
+func contentLabel(content map[string]*openapi.MediaType) string {
+	types := make([]string, 0, len(content))
+	for ct := range content {
+		types = append(types, ct)
+	}
+	sort.Strings(types)
+
+	labels := make([]string, 0, len(types))
+	for _, ct := range types {
+		labels = append(labels, ct+": "+schemaLabel(content[ct].Schema))
+	}
+
+	return strings.Join(labels, ", ")
+}
+
+// Returns a short type description, Ex: "string", "Format[]", "VideoInfo or null".
+func schemaLabel(s *openapi.Schema) string {
+	if s == nil {
+		return "any"
+	}
+
+	label := ""
+	switch {
+	case s.Ref != "":
+		label = strings.TrimPrefix(s.Ref, "#/components/schemas/")
+	case len(s.AllOf) == 1:
+		label = schemaLabel(s.AllOf[0])
+	case s.Type == "array":
+		label = schemaLabel(s.Items) + "[]"
+	case len(s.Enum) > 0:
+		label = `"` + strings.Join(s.Enum, `" | "`) + `"`
+	case s.Format != "":
+		label = s.Type + " (" + s.Format + ")"
+	case s.Type != "":
+		label = s.Type
+	default:
+		label = "any"
+	}
+
+	if s.Nullable {
+		label += " or null"
+	}
+
+	return label
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/filename"
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+)
+
+// Serves a completed download from the download cache, by the key found in
+// the Content-Location of the download response. Unlike the live stream, the
+// file is on disk, so Range, If-Range and HEAD requests let clients resume.
+func (h *Handlers) CachedDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	params := CachedDownloadParams{Key: r.PathValue("key")}
+	if err := validate.Struct(&params); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	if h.downloadCache == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "download caching is disabled")
+		return
+	}
+
+	f, meta, err := h.downloadCache.OpenFile(params.Key)
+	if errors.Is(err, core.ErrNotCached) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "download is not cached, or not complete yet")
+		return
+	} else if err != nil {
+		writeError(w, r, "CachedDownload", err)
+		return
+	}
+	defer f.Close()
+
+	if meta.ContentType == "" || meta.FileName == "" {
+		// saved before the download response was sent, or lost
+		head := make([]byte, core.SniffLen)
+		n, _ := io.ReadFull(f, head)
+		ext := core.SniffContainer(head[:n], false)
+
+		meta.ContentType = core.ContainerMIMEType(ext, false)
+		meta.FileName = filename.Sanitize(params.Key[:12], ext)
+	}
+
+	// Cached files are only replaced by a new download, so the key and the
+	// time it completed identify the content.
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-%x"`, params.Key[:16], meta.ModTime.UnixNano()))
+	w.Header().Set("Content-Type", meta.ContentType)
+	w.Header().Set("Content-Disposition", filename.ContentDisposition(meta.FileName))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	http.ServeContent(w, r, "", meta.ModTime, f)
+}
@@ -0,0 +1,95 @@
+package api_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/coretest"
+)
+
+func TestCachedDownloadHandler(t *testing.T) {
+	data := append([]byte("\x00\x00\x00\x18ftypdash\x00\x00\x00\x00"), bytes.Repeat([]byte("audio"), 1000)...)
+
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testVideoInfo)
+	fake.SetDownload(testVideoURL, coretest.Download{Data: data})
+
+	mux := http.NewServeMux()
+	api.RegisterAPIRoutes(mux, newTestHandlers(t, fake))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", api.VideoDownloadPath, strings.NewReader(`{"url":"`+testVideoURL+`","type":"audio"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	location := w.Header().Get("Content-Location")
+	if !strings.HasPrefix(location, api.DownloadsPath+"/") {
+		t.Fatalf("unexpected Content-Location %q", location)
+	}
+
+	// the whole file, with the name and type of the original response
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", location, nil))
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), data) {
+		t.Fatalf("expected the full file, got %d (%d bytes)", w.Code, w.Body.Len())
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="Test video [dQw4w9WgXcQ].m4a"` {
+		t.Fatalf("unexpected Content-Disposition %s", got)
+	}
+	if w.Header().Get("Content-Type") != "audio/mp4" || w.Header().Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Last-Modified") == "" {
+		t.Fatalf("expected validators, got %v", w.Header())
+	}
+
+	// resuming from an offset
+	req := httptest.NewRequest("GET", location, nil)
+	req.Header.Set("Range", "bytes=100-")
+	req.Header.Set("If-Range", etag)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), data[100:]) {
+		t.Fatalf("expected 206 with the rest of the file, got %d (%d bytes)", w.Code, w.Body.Len())
+	}
+
+	// a stale validator gets the whole file again
+	req = httptest.NewRequest("GET", location, nil)
+	req.Header.Set("Range", "bytes=100-")
+	req.Header.Set("If-Range", `"stale"`)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.Len() != len(data) {
+		t.Fatalf("expected 200 with the full file, got %d (%d bytes)", w.Code, w.Body.Len())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("HEAD", location, nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("Content-Length") != "5016" {
+		t.Fatalf("unexpected HEAD response %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCachedDownloadHandlerErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	api.RegisterAPIRoutes(mux, newTestHandlers(t, coretest.NewFake()))
+
+	for path, status := range map[string]int{
+		api.DownloadsPath + "/" + strings.Repeat("ab", 32): http.StatusNotFound,
+		api.DownloadsPath + "/not-a-key":                   http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		if w.Code != status {
+			t.Errorf("%s: expected %d, got %d: %s", path, status, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+)
+
+const (
+	// retryAfterSeconds is suggested to clients when the site rate limits the server.
+	retryAfterSeconds = "60"
+
+	// maxJSONBodyBytes bounds the JSON bodies of API requests.
+	maxJSONBodyBytes = 64 << 10
+
+	// Field codes for format_ids that do not fit the video.
+	codeUnknownFormat   = "unknown_format"
+	codeWrongFormatKind = "wrong_format_kind"
+
+	// Field codes for chapter options the video cannot satisfy.
+	codeUnknownChapter = "unknown_chapter"
+	codeNoChapters     = "no_chapters"
+)
+
+// extractorErrorStatus maps extractor failures to HTTP status codes.
+var extractorErrorStatus = map[core.ErrorCode]int{
+	core.CodeUnsupportedURL:    http.StatusBadRequest,
+	core.CodeVideoUnavailable:  http.StatusNotFound,
+	core.CodeVideoPrivate:      http.StatusForbidden,
+	core.CodeGeoBlocked:        http.StatusForbidden,
+	core.CodeLoginRequired:     http.StatusForbidden,
+	core.CodeDRMProtected:      http.StatusUnprocessableEntity,
+	core.CodeFormatUnavailable: http.StatusUnprocessableEntity,
+	core.CodeRateLimited:       http.StatusTooManyRequests,
+	core.CodeNetwork:           http.StatusBadGateway,
+	core.CodeTimeout:           http.StatusGatewayTimeout,
+	core.CodeExtractorFailed:   http.StatusBadGateway,
+}
+
+// Writes err as an error envelope. Extractor failures get their own status and
+// code; anything else is logged and reported as a generic internal error, so
+// no paths or tool output reach the client.
+func writeError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	log.Println(op+" error: ", err)
+
+	e := apierror.Error{Code: apierror.CodeInternal, Message: "internal server error"}
+	status := http.StatusInternalServerError
+
+	var exErr *core.ExtractorError
+	if errors.As(err, &exErr) {
+		e = apierror.Error{Code: string(exErr.Code), Message: exErr.Message, Retryable: exErr.Retryable()}
+
+		if s, ok := extractorErrorStatus[exErr.Code]; ok {
+			status = s
+		} else {
+			status = http.StatusBadGateway
+		}
+	}
+
+	if status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", retryAfterSeconds)
+	}
+
+	apierror.WriteError(w, r, status, e)
+}
+
+// Writes the error returned by validate.JSON or validate.Query: every rejected
+// field at once, or why the body could not be read.
+func writeValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		fields validate.Errors
+		maxErr *http.MaxBytesError
+	)
+
+	switch {
+	case errors.As(err, &fields):
+		apierror.WriteError(w, r, http.StatusBadRequest, apierror.Error{
+			Code:    apierror.CodeValidationFailed,
+			Message: "request validation failed",
+			Details: ValidationDetails{Fields: fields},
+		})
+	case errors.As(err, &maxErr):
+		apierror.Write(w, r, http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge, "request body is too large")
+	case errors.Is(err, validate.ErrMalformed):
+		writeBadRequest(w, r, err.Error())
+	default:
+		writeError(w, r, "ReadRequest", err)
+	}
+}
+
+// Writes a request validation error.
+func writeBadRequest(w http.ResponseWriter, r *http.Request, message string) {
+	apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, message)
+}
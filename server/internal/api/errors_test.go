@@ -0,0 +1,84 @@
+package api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/coretest"
+)
+
+func TestExtractorErrorsMapToStatusCodes(t *testing.T) {
+	tests := []struct {
+		stderr    string
+		status    int
+		code      string
+		retryable bool
+	}{
+		{"ERROR: Unsupported URL: https://example.com", http.StatusBadRequest, "unsupported_url", false},
+		{"ERROR: Video unavailable", http.StatusNotFound, "video_unavailable", false},
+		{"ERROR: Private video", http.StatusForbidden, "video_private", false},
+		{"ERROR: Sign in to confirm your age", http.StatusForbidden, "login_required", false},
+		{"ERROR: This video is DRM protected", http.StatusUnprocessableEntity, "drm_protected", false},
+		{"ERROR: HTTP Error 429: Too Many Requests", http.StatusTooManyRequests, "rate_limited", true},
+		{"ERROR: Unable to download webpage: connection reset", http.StatusBadGateway, "network_error", true},
+	}
+
+	for _, tt := range tests {
+		fake := coretest.NewFake()
+		fake.SetInfoError("", core.ClassifyError(errors.New("exit status 1"), tt.stderr+" in /opt/secret/yt-dlp"))
+
+		w := httptest.NewRecorder()
+		newTestHandlers(t, fake).VideoInfoHandler(w, httptest.NewRequest("GET", "/api/video/info?url="+testVideoURL, nil))
+
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.stderr, tt.status, w.Code)
+			continue
+		}
+
+		if strings.Contains(w.Body.String(), "/opt/secret") || strings.Contains(w.Body.String(), "exit status") {
+			t.Errorf("%s: response leaks details: %s", tt.stderr, w.Body.String())
+		}
+
+		var body api.ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid json response: %v", err)
+		}
+		if body.Error.Code != tt.code || body.Error.Retryable != tt.retryable || body.Error.Message == "" {
+			t.Errorf("%s: unexpected body %+v", tt.stderr, body)
+		}
+	}
+}
+
+func TestDownloadExtractorErrorBeforeOutput(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetDownload("", coretest.Download{Err: core.ClassifyError(errors.New("exit status 1"), "ERROR: HTTP Error 429")})
+
+	w := httptest.NewRecorder()
+	body := `{"url":"` + testVideoURL + `","type":"video"}`
+	newTestHandlers(t, fake).VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("expected a Retry-After header")
+	}
+}
+
+func TestInternalErrorsAreNotLeaked(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfoError("", errors.New("open /var/lib/secret: permission denied"))
+
+	w := httptest.NewRecorder()
+	newTestHandlers(t, fake).VideoInfoHandler(w, httptest.NewRequest("GET", "/api/video/info?url="+testVideoURL, nil))
+
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "/var/lib") {
+		t.Fatalf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/filename"
+)
+
+// Returns the file name of the download of cfg with extension ext, rendered
+// from the filename template. info is nil when it could not be fetched, and
+// the name is then "video" or "audio".
+func (h *Handlers) downloadFilename(info *core.VideoInfo, cfg core.DownloadConfig, ext string) string {
+	kind := "video"
+	if cfg.Type == core.Audio {
+		kind = "audio"
+	}
+
+	if info == nil {
+		return filename.Sanitize(kind, ext)
+	}
+
+	return h.filenameTemplate.Execute(map[string]string{
+		"id":          info.ID,
+		"title":       info.Title,
+		"uploader":    info.Uploader,
+		"upload_date": info.UploadDate,
+		"extractor":   info.Extractor,
+		"resolution":  info.ResolutionFor(cfg),
+		"type":        kind,
+		"ext":         ext,
+	})
+}
+
+// Returns the extension used for downloads whose container was not recognized.
+func fallbackExt(dType core.DownloadType) string {
+	if dType == core.Audio {
+		return "m4a"
+	}
+
+	return "mp4"
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/config"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/filename"
+	"github.com/gabriel-logan/yt-dlp/server/internal/jobs"
+	"github.com/gabriel-logan/yt-dlp/server/internal/share"
+	"github.com/gabriel-logan/yt-dlp/server/internal/subscriptions"
+	"github.com/gabriel-logan/yt-dlp/server/internal/thumbnail"
+	"github.com/gabriel-logan/yt-dlp/server/internal/webhooks"
+)
+
+const (
+	// maxPendingJobs bounds the jobs waiting for a worker.
+	maxPendingJobs = 1000
+
+	// keepFinishedJobs is how many finished jobs are remembered.
+	keepFinishedJobs = 1000
+)
+
+// Handlers serves the API routes using the dependencies it was built with.
+type Handlers struct {
+	extractor     core.Extractor
+	infoCache     *core.InfoCache
+	downloadCache *core.DownloadCache
+	versions      *core.BinaryVersions
+	downloadSem   chan struct{}
+	docs          bool
+	hasFFmpeg     func() bool
+	thumbnails    *thumbnail.Proxy
+	share         *share.Signer
+	jobs          *jobs.Queue
+	jobsDir       string
+	webhooks      *webhooks.Dispatcher
+	subscriptions *subscriptions.Scheduler
+
+	subscriptionItems subscriptionItems
+	archiveWrites     sync.WaitGroup
+	streamRoutes      *http.ServeMux
+
+	filenameTemplate *filename.Template
+
+	openAPIJSON func() ([]byte, error)
+}
+
+// Options configures NewHandlers. Zero values select the defaults.
+type Options struct {
+	InfoCache              *core.InfoCache      // default: 10 minutes, 500 entries
+	DownloadCache          *core.DownloadCache  // nil disables download caching
+	Versions               *core.BinaryVersions // nil disables the yt-dlp update endpoints
+	MaxConcurrentDownloads int                  // default: number of CPUs
+	Docs                   bool                 // serve the HTML API reference at DocsPath
+	HasFFmpeg              func() bool          // default: core.HasFFmpeg
+	Thumbnails             *thumbnail.Proxy     // default: 1 hour, 32 MiB
+	FilenameTemplate       *filename.Template   // default: filename.DefaultTemplate
+	Share                  *share.Signer        // nil disables share links
+	JobsDir                string               // where jobs save their files; default: a directory under os.TempDir
+	JobWorkers             int                  // jobs run at once; default: 2
+	Webhooks               *webhooks.Dispatcher // default: subscriptions kept in memory
+	Subscriptions          *subscriptions.Store // default: kept in memory
+	SubscriptionTick       time.Duration        // how often due subscriptions are looked for; default: 1 minute
+}
+
+func NewHandlers(extractor core.Extractor, opts Options) *Handlers {
+	if opts.InfoCache == nil {
+		opts.InfoCache = core.NewInfoCache(10*time.Minute, 500)
+	}
+
+	if opts.HasFFmpeg == nil {
+		opts.HasFFmpeg = core.HasFFmpeg
+	}
+
+	if opts.Thumbnails == nil {
+		opts.Thumbnails = thumbnail.NewProxy(nil, time.Hour, 32<<20)
+	}
+
+	if opts.FilenameTemplate == nil {
+		opts.FilenameTemplate, _ = filename.Parse(filename.DefaultTemplate)
+	}
+
+	if opts.JobsDir == "" {
+		opts.JobsDir = filepath.Join(os.TempDir(), "yt-dlp-server-jobs")
+	}
+
+	if opts.JobWorkers <= 0 {
+		opts.JobWorkers = 2
+	}
+
+	if opts.Webhooks == nil {
+		store, _ := webhooks.Open("")
+		opts.Webhooks = webhooks.NewDispatcher(store, webhooks.Options{})
+	}
+
+	if opts.Subscriptions == nil {
+		opts.Subscriptions, _ = subscriptions.Open("")
+	}
+
+	if opts.SubscriptionTick <= 0 {
+		opts.SubscriptionTick = time.Minute
+	}
+
+	if opts.MaxConcurrentDownloads <= 0 {
+		opts.MaxConcurrentDownloads = core.GetNumCPU()
+	}
+
+	// the first install keeps the binary in use, so it can be rolled back to
+	if sw, ok := extractor.(binarySwitcher); ok && opts.Versions != nil && opts.Versions.InUse == nil {
+		opts.Versions.InUse = func() string {
+			path, _, _ := sw.Binary()
+			return path
+		}
+	}
+
+	h := &Handlers{
+		extractor:     extractor,
+		infoCache:     opts.InfoCache,
+		downloadCache: opts.DownloadCache,
+		versions:      opts.Versions,
+		downloadSem:   make(chan struct{}, opts.MaxConcurrentDownloads),
+		docs:          opts.Docs,
+		hasFFmpeg:     opts.HasFFmpeg,
+		thumbnails:    opts.Thumbnails,
+		share:         opts.Share,
+		jobsDir:       opts.JobsDir,
+		webhooks:      opts.Webhooks,
+
+		filenameTemplate: opts.FilenameTemplate,
+	}
+
+	removeStaleJobFiles(h.jobsDir)
+	h.jobs = jobs.NewQueue(opts.JobWorkers, maxPendingJobs, keepFinishedJobs, h.runJob)
+	h.jobs.Subscribe(h.webhooks.Notify)
+	h.jobs.OnForget(h.removeJobFiles)
+	h.jobs.Subscribe(h.onSubscriptionJob)
+	h.subscriptions = subscriptions.NewScheduler(opts.Subscriptions, h.checkSubscription, opts.SubscriptionTick, subscriptionCheckTimeout)
+
+	h.streamRoutes = streamRoutes(h.Routes())
+
+	h.openAPIJSON = sync.OnceValues(func() ([]byte, error) {
+		return json.MarshalIndent(h.OpenAPI(), "", "  ")
+	})
+
+	return h
+}
+
+// Stops checking subscriptions and running jobs, and waits for both to stop.
+func (h *Handlers) Close() {
+	h.subscriptions.Close()
+	h.jobs.Close()
+	h.archiveWrites.Wait()
+}
+
+// Returns the Options configured by the environment: INFO_CACHE_*,
+// DOWNLOAD_CACHE_*, THUMBNAIL_CACHE_*, DOWNLOAD_FILENAME_TEMPLATE,
+// SHARE_LINK_SECRET, JOBS_DIR, JOB_WORKERS, WEBHOOKS_FILE,
+// WEBHOOK_ALLOWED_NETWORKS, SUBSCRIPTIONS_FILE,
+// YT_DLP_VERSIONS_DIR and API_DOCS.
+func OptionsFromEnv() Options {
+	ttl := config.EnvDuration("INFO_CACHE_TTL", 10*time.Minute)
+	maxEntries := config.EnvInt64("INFO_CACHE_MAX_ENTRIES", 500)
+
+	return Options{
+		InfoCache:     core.NewInfoCache(ttl, int(maxEntries)),
+		DownloadCache: downloadCacheFromEnv(),
+		Thumbnails: thumbnail.NewProxy(nil,
+			config.EnvDuration("THUMBNAIL_CACHE_TTL", time.Hour),
+			config.EnvInt64("THUMBNAIL_CACHE_MAX_MB", 32)*1024*1024),
+		FilenameTemplate: filenameTemplateFromEnv(),
+		Share:            share.NewSigner(config.ShareLinkSecret()),
+		JobsDir:          config.EnvString("JOBS_DIR", filepath.Join(config.DataDir(), "downloads")),
+		JobWorkers:       int(config.EnvInt64("JOB_WORKERS", 2)),
+		Webhooks:         webhooksFromEnv(),
+		Subscriptions:    subscriptionsFromEnv(),
+		Versions:         core.NewBinaryVersions(config.YTDlpVersionsDir()),
+		Docs:             config.EnvString("API_DOCS", "off") == "on",
+	}
+}
+
+// Returns the filename template configured by the environment, or nil for the default.
+func filenameTemplateFromEnv() *filename.Template {
+	t, err := filename.Parse(config.EnvString("DOWNLOAD_FILENAME_TEMPLATE", filename.DefaultTemplate))
+	if err != nil {
+		log.Println("WARNING: using the default filename template: ", err)
+		return nil
+	}
+
+	return t
+}
+
+// Returns a webhook dispatcher using the subscriptions file, or keeping
+// subscriptions in memory when it cannot be read.
+func webhooksFromEnv() *webhooks.Dispatcher {
+	allow, err := webhooks.ParseNetworks(config.WebhookAllowedNetworks())
+	if err != nil {
+		log.Println("WARNING: ignoring WEBHOOK_ALLOWED_NETWORKS: ", err)
+	}
+
+	store, err := webhooks.Open(config.WebhooksFile())
+	if err != nil {
+		log.Println("WARNING: webhook subscriptions are kept in memory only: ", err)
+		store, _ = webhooks.Open("")
+	}
+
+	return webhooks.NewDispatcher(store, webhooks.Options{Allow: allow})
+}
+
+// Returns the subscription store using the subscriptions file, or nil when it
+// cannot be read.
+func subscriptionsFromEnv() *subscriptions.Store {
+	store, err := subscriptions.Open(config.SubscriptionsFile())
+	if err != nil {
+		log.Println("WARNING: subscriptions are kept in memory only: ", err)
+		return nil
+	}
+
+	return store
+}
+
+// Returns the download cache configured by the environment, or nil when caching is disabled.
+func downloadCacheFromEnv() *core.DownloadCache {
+	maxMB := config.EnvInt64("DOWNLOAD_CACHE_MAX_MB", 2048)
+	if maxMB <= 0 {
+		log.Println("Download cache disabled")
+		return nil
+	}
+
+	dir := config.EnvString("DOWNLOAD_CACHE_DIR", filepath.Join(os.TempDir(), "yt-dlp-server-cache"))
+	maxAge := config.EnvDuration("DOWNLOAD_CACHE_MAX_AGE", 6*time.Hour)
+
+	c, err := core.NewDownloadCache(dir, maxMB*1024*1024, maxAge)
+	if err != nil {
+		log.Println("WARNING: download cache disabled: ", err)
+		return nil
+	}
+
+	return c
+}
@@ -5,9 +5,7 @@ import (
 	"net/http"
 )
 
-var helloHandlerResp = map[string]string{
-	"message": "Hello World!",
-}
+var helloHandlerResp = HelloResponse{Message: "Hello World!"}
 
 func HelloHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
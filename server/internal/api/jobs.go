@@ -0,0 +1,317 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/urls"
+	"github.com/gabriel-logan/yt-dlp/server/internal/filename"
+	"github.com/gabriel-logan/yt-dlp/server/internal/jobs"
+	"github.com/gabriel-logan/yt-dlp/server/internal/keys"
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+)
+
+// jobTimeout bounds a single background download.
+const jobTimeout = 2 * time.Hour
+
+// Queues a download to run in the background. Its progress is reported by
+// the job and, when set, posted to its webhook and those of the API key.
+func (h *Handlers) CreateJobHandler(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+	if err := validate.JSON(w, r, &req, maxJSONBodyBytes); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	_, errs := checkDownloadRequest(req.Download)
+	for i := range errs {
+		errs[i].Field = "download." + errs[i].Field
+	}
+	if req.Download.SplitChapters {
+		errs = append(errs, validate.FieldError{Field: "download.split_chapters", Code: validate.CodeInvalidChoice, Message: "is not supported for jobs"})
+	}
+	errs = append(errs, h.checkWebhook(r.Context(), "webhook_url", req.WebhookURL, "webhook_secret", req.WebhookSecret)...)
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+
+	data, err := json.Marshal(req.Download)
+	if err != nil {
+		writeError(w, r, "CreateJob", err)
+		return
+	}
+
+	job, err := h.jobs.Submit(jobs.Job{
+		KeyID:         keys.KeyID(r.Context()),
+		Request:       data,
+		WebhookURL:    req.WebhookURL,
+		WebhookSecret: req.WebhookSecret,
+	})
+	if errors.Is(err, jobs.ErrQueueFull) {
+		apierror.WriteError(w, r, http.StatusServiceUnavailable, apierror.Error{Code: apierror.CodeUnavailable, Message: err.Error(), Retryable: true})
+		return
+	} else if err != nil {
+		writeError(w, r, "CreateJob", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", JobsPath+"/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newJobResponse(job))
+}
+
+// Lists the jobs of the API key, newest first.
+func (h *Handlers) ListJobsHandler(w http.ResponseWriter, r *http.Request) {
+	resp := JobListResponse{Jobs: []JobResponse{}}
+	for _, job := range h.jobs.List(keys.KeyID(r.Context())) {
+		resp.Jobs = append(resp.Jobs, newJobResponse(job))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Reports the state of a job.
+func (h *Handlers) GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.lookupJob(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(newJobResponse(job))
+}
+
+// Serves the file downloaded by a job that succeeded, with Range support.
+func (h *Handlers) JobFileHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.lookupJob(w, r)
+	if !ok {
+		return
+	}
+
+	if job.Status != jobs.Succeeded {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, fmt.Sprintf("job is %s", job.Status))
+		return
+	}
+
+	f, err := os.Open(filepath.Join(h.jobsDir, job.ID, job.File))
+	if os.IsNotExist(err) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "the file of the job was removed")
+		return
+	} else if err != nil {
+		writeError(w, r, "JobFile", err)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		writeError(w, r, "JobFile", err)
+		return
+	}
+
+	var req DownloadRequest
+	json.Unmarshal(job.Request, &req)
+	ext := strings.TrimPrefix(filepath.Ext(job.File), ".")
+
+	w.Header().Set("Content-Type", core.ContainerMIMEType(ext, req.Type == "audio"))
+	w.Header().Set("Content-Disposition", filename.ContentDisposition(job.File))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	http.ServeContent(w, r, "", fi.ModTime(), f)
+}
+
+// Returns the job named by the id path parameter, answering 404 when the key
+// of the request does not own it.
+func (h *Handlers) lookupJob(w http.ResponseWriter, r *http.Request) (jobs.Job, bool) {
+	params := JobParams{ID: r.PathValue("id")}
+	if err := validate.Struct(&params); err != nil {
+		writeValidationError(w, r, err)
+		return jobs.Job{}, false
+	}
+
+	job, ok := h.jobs.Get(params.ID)
+	if !ok || job.KeyID != keys.KeyID(r.Context()) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "job not found")
+		return jobs.Job{}, false
+	}
+
+	return job, true
+}
+
+func newJobResponse(job jobs.Job) JobResponse {
+	resp := JobResponse{
+		ID:         job.ID,
+		Source:     job.Source,
+		Status:     job.Status,
+		Error:      job.Error,
+		File:       job.File,
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+		WebhookURL: job.WebhookURL,
+	}
+	// the request was encoded from a DownloadRequest when the job was submitted
+	json.Unmarshal(job.Request, &resp.Request)
+
+	return resp
+}
+
+// Checks a webhook URL, which must not point inside the server's network,
+// and the secret its requests are signed with.
+func (h *Handlers) checkWebhook(ctx context.Context, urlField, rawURL, secretField, secret string) validate.Errors {
+	var errs validate.Errors
+	if rawURL != "" {
+		if err := h.webhooks.CheckURL(ctx, rawURL); err != nil {
+			errs = append(errs, validate.FieldError{Field: urlField, Code: validate.CodeInvalidFormat, Message: err.Error()})
+		}
+	}
+	if rawURL != "" && secret == "" {
+		errs = append(errs, validate.FieldError{Field: secretField, Code: validate.CodeRequired, Message: "is required with " + urlField})
+	}
+
+	return errs
+}
+
+// Downloads the request of job into its own directory under the jobs
+// directory and returns the name of the file.
+func (h *Handlers) runJob(ctx context.Context, job jobs.Job) (string, error) {
+	var req DownloadRequest
+	if err := json.Unmarshal(job.Request, &req); err != nil {
+		return "", fmt.Errorf("invalid job request: %v", err)
+	}
+
+	sections, errs := checkDownloadRequest(req)
+	if len(errs) > 0 {
+		return "", errs
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	target := urls.Resolve(req.URL)
+	cfg := downloadConfig(req, target, sections)
+
+	info, _, _, err := h.lookupVideoInfo(ctx, target)
+	if err != nil {
+		if needsInfo(req) {
+			return "", err
+		}
+		log.Println("VideoInfo error: ", err)
+	} else {
+		errs = append(checkSections(sections, info), checkFormats(req, info)...)
+		if len(errs) > 0 {
+			return "", errs
+		}
+	}
+
+	if cfg.NeedsFFmpeg() && !h.hasFFmpeg() {
+		return "", errNoFFmpeg
+	}
+
+	select {
+	case h.downloadSem <- struct{}{}:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	defer func() { <-h.downloadSem }()
+
+	reader, err := h.extractor.Download(ctx, cfg)
+	if err != nil {
+		return "", h.formatGone(err, target, cfg)
+	}
+	defer reader.Close()
+
+	dir := filepath.Join(h.jobsDir, job.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create job directory: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".job-*.part")
+	if err != nil {
+		return "", fmt.Errorf("failed to create job file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	bp := copyBufPool.Get().(*[]byte)
+	defer copyBufPool.Put(bp)
+
+	_, err = io.CopyBuffer(tmp, noWriterTo{reader}, *bp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", h.formatGone(err, target, cfg)
+	}
+
+	head, err := readHead(tmp.Name())
+	if err != nil {
+		return "", err
+	}
+
+	name := h.downloadFilename(info, cfg, cmp.Or(core.SniffContainer(head, cfg.Type == core.Audio), fallbackExt(cfg.Type)))
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return "", fmt.Errorf("failed to save job file: %v", err)
+	}
+
+	return name, nil
+}
+
+// Removes the directory of a job the queue forgot, with its file.
+func (h *Handlers) removeJobFiles(job jobs.Job) {
+	if err := os.RemoveAll(filepath.Join(h.jobsDir, job.ID)); err != nil {
+		log.Println("Job error: ", err)
+	}
+}
+
+// Removes the job directories left in dir by a previous run, whose jobs
+// were forgotten when it stopped.
+func removeStaleJobFiles(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		if _, err := hex.DecodeString(e.Name()); err != nil || !e.IsDir() || len(e.Name()) != 16 {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			log.Println("Job error: ", err)
+		}
+	}
+}
+
+// Returns the first core.SniffLen bytes of the file at path.
+func readHead(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, core.SniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	return head[:n], nil
+}
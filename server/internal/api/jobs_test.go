@@ -0,0 +1,322 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/coretest"
+	"github.com/gabriel-logan/yt-dlp/server/internal/jobs"
+	"github.com/gabriel-logan/yt-dlp/server/internal/keys"
+	"github.com/gabriel-logan/yt-dlp/server/internal/webhooks"
+)
+
+func newJobsMux(t *testing.T, fake *coretest.Fake) (*http.ServeMux, string) {
+	t.Helper()
+
+	store, _ := webhooks.Open("")
+	dir := t.TempDir()
+
+	mux := http.NewServeMux()
+	api.RegisterAPIRoutes(mux, newHandlers(t, fake, api.Options{
+		JobsDir:   dir,
+		Webhooks:  webhooks.NewDispatcher(store, webhooks.Options{Backoff: time.Millisecond, Allow: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}}),
+		HasFFmpeg: func() bool { return true },
+	}))
+
+	return mux, dir
+}
+
+// Sends a request as the API key keyID.
+func serveAs(mux *http.ServeMux, keyID, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req = req.WithContext(keys.WithKeyID(req.Context(), keyID))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	return w
+}
+
+// Polls the job until it is done.
+func waitJob(t *testing.T, mux *http.ServeMux, keyID, id string) jobs.Job {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w := serveAs(mux, keyID, "GET", api.JobsPath+"/"+id, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var job jobs.Job
+		json.Unmarshal(w.Body.Bytes(), &job)
+		if job.Status.Done() {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s did not finish: %+v", id, job)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// hookReceiver collects the verified events posted to it.
+type hookReceiver struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	events []webhooks.Payload
+}
+
+func newHookReceiver(t *testing.T, secret string) *hookReceiver {
+	rc := &hookReceiver{}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !webhooks.Verify(secret, r.Header.Get(webhooks.SignatureHeader), r.Header.Get(webhooks.TimestampHeader), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var p webhooks.Payload
+		json.Unmarshal(body, &p)
+
+		rc.mu.Lock()
+		rc.events = append(rc.events, p)
+		rc.mu.Unlock()
+	}))
+	t.Cleanup(rc.Close)
+
+	return rc
+}
+
+// Waits for n events and returns their types.
+func (rc *hookReceiver) wait(t *testing.T, n int) []jobs.EventType {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rc.mu.Lock()
+		var types []jobs.EventType
+		for _, p := range rc.events {
+			types = append(types, p.Event)
+		}
+		rc.mu.Unlock()
+
+		if len(types) >= n {
+			return types
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d events, got %v", n, types)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJobDownloadsInBackgroundAndNotifies(t *testing.T) {
+	data := append([]byte("\x00\x00\x00\x18ftypdash\x00\x00\x00\x00"), bytes.Repeat([]byte("audio"), 100)...)
+
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testVideoInfo)
+	fake.SetDownload(testVideoURL, coretest.Download{Data: data})
+	mux, dir := newJobsMux(t, fake)
+
+	keyHook := newHookReceiver(t, "key-secret-0123456789")
+	jobHook := newHookReceiver(t, "job-secret-0123456789")
+
+	w := serveAs(mux, "k1", "POST", api.WebhooksPath, `{"url":"`+keyHook.URL+`","secret":"key-secret-0123456789","events":["job.succeeded","job.failed"]}`)
+	if w.Code != http.StatusCreated || strings.Contains(w.Body.String(), "key-secret") {
+		t.Fatalf("expected 201 without the secret, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serveAs(mux, "k1", "POST", api.JobsPath, `{"download":{"url":"`+testVideoURL+`","type":"audio"},"webhook_url":"`+jobHook.URL+`","webhook_secret":"job-secret-0123456789"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var job jobs.Job
+	json.Unmarshal(w.Body.Bytes(), &job)
+	if w.Header().Get("Location") != api.JobsPath+"/"+job.ID || job.Status != jobs.Queued {
+		t.Fatalf("unexpected job %+v, Location %q", job, w.Header().Get("Location"))
+	}
+
+	job = waitJob(t, mux, "k1", job.ID)
+	if job.Status != jobs.Succeeded || job.File != "Test video [dQw4w9WgXcQ].m4a" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	if saved, _ := os.ReadFile(filepath.Join(dir, job.ID, job.File)); !bytes.Equal(saved, data) {
+		t.Fatalf("expected the download to be saved in the directory of the job")
+	}
+
+	w = serveAs(mux, "k1", "GET", api.JobsPath+"/"+job.ID+"/file", "")
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), data) || w.Header().Get("Content-Type") != "audio/mp4" {
+		t.Fatalf("expected the file, got %d %v", w.Code, w.Header())
+	}
+
+	if got := jobHook.wait(t, 3); len(got) != 3 {
+		t.Fatalf("expected every event on the job webhook, got %v", got)
+	}
+	if got := keyHook.wait(t, 1); got[0] != jobs.EventSucceeded {
+		t.Fatalf("expected only job.succeeded on the key webhook, got %v", got)
+	}
+
+	// the deliveries are logged for the key
+	var deliveries api.DeliveriesResponse
+	deadline := time.Now().Add(5 * time.Second)
+	for delivered := 0; delivered < 4; {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 4 delivered events, got %+v", deliveries)
+		}
+		w = serveAs(mux, "k1", "GET", api.WebhooksPath+"/deliveries", "")
+		json.Unmarshal(w.Body.Bytes(), &deliveries)
+
+		delivered = 0
+		for _, d := range deliveries.Deliveries {
+			if d.Status == webhooks.Delivered {
+				delivered++
+			}
+		}
+	}
+
+	// other keys see none of it
+	if w := serveAs(mux, "k2", "GET", api.JobsPath+"/"+job.ID, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another key, got %d", w.Code)
+	}
+	w = serveAs(mux, "k2", "GET", api.JobsPath, "")
+	if !strings.Contains(w.Body.String(), `"jobs":[]`) {
+		t.Fatalf("expected no jobs for another key, got %s", w.Body.String())
+	}
+}
+
+func TestJobsOfTheSameVideoKeepTheirFiles(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testVideoInfo)
+	fake.SetDownload(testVideoURL, coretest.Download{Data: []byte("first")})
+	mux, dir := newJobsMux(t, fake)
+
+	w := serveAs(mux, "k1", "POST", api.JobsPath, `{"download":{"url":"`+testVideoURL+`","type":"audio"}}`)
+	var first jobs.Job
+	json.Unmarshal(w.Body.Bytes(), &first)
+	first = waitJob(t, mux, "k1", first.ID)
+
+	fake.SetDownload(testVideoURL, coretest.Download{Data: []byte("second")})
+	w = serveAs(mux, "k2", "POST", api.JobsPath, `{"download":{"url":"`+testVideoURL+`","type":"audio"}}`)
+	var second jobs.Job
+	json.Unmarshal(w.Body.Bytes(), &second)
+	second = waitJob(t, mux, "k2", second.ID)
+
+	if first.File != second.File {
+		t.Fatalf("expected both jobs to name the file alike, got %q and %q", first.File, second.File)
+	}
+	if w := serveAs(mux, "k1", "GET", api.JobsPath+"/"+first.ID+"/file", ""); w.Body.String() != "first" {
+		t.Fatalf("expected the first job to keep its file, got %q", w.Body.String())
+	}
+	if w := serveAs(mux, "k2", "GET", api.JobsPath+"/"+second.ID+"/file", ""); w.Body.String() != "second" {
+		t.Fatalf("expected the second job to keep its file, got %q", w.Body.String())
+	}
+
+	// a restart forgets the jobs, and deletes their files
+	newHandlers(t, fake, api.Options{JobsDir: dir})
+	if _, err := os.Stat(filepath.Join(dir, first.ID)); !os.IsNotExist(err) {
+		t.Fatalf("expected the files of forgotten jobs to be deleted, got %v", err)
+	}
+}
+
+func TestJobFailure(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testVideoInfo)
+	mux, _ := newJobsMux(t, fake)
+
+	w := serveAs(mux, "k1", "POST", api.JobsPath, `{"download":{"url":"`+testVideoURL+`","type":"video","video_format_id":"999"}}`)
+	var job jobs.Job
+	json.Unmarshal(w.Body.Bytes(), &job)
+
+	job = waitJob(t, mux, "k1", job.ID)
+	if job.Status != jobs.Failed || !strings.Contains(job.Error, "999") {
+		t.Fatalf("expected the job to fail on the unknown format, got %+v", job)
+	}
+
+	if w := serveAs(mux, "k1", "GET", api.JobsPath+"/"+job.ID+"/file", ""); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for the file of a failed job, got %d", w.Code)
+	}
+}
+
+func TestJobAndWebhookValidation(t *testing.T) {
+	mux, _ := newJobsMux(t, coretest.NewFake())
+
+	tests := []struct {
+		target string
+		body   string
+		want   map[string]string
+	}{
+		{api.JobsPath, `{"download":{"url":"` + testVideoURL + `","type":"video","split_chapters":true}}`, map[string]string{"download.split_chapters": "invalid_choice"}},
+		{api.JobsPath, `{"download":{"url":"` + testVideoURL + `","type":"video"},"webhook_url":"ftp://x"}`, map[string]string{"webhook_url": "invalid_format", "webhook_secret": "required"}},
+		{api.JobsPath, `{"download":{"url":"` + testVideoURL + `","type":"video"},"webhook_url":"https://x","webhook_secret":"short"}`, map[string]string{"webhook_secret": "too_short"}},
+		{api.WebhooksPath, `{"url":"https://203.0.113.10/hook","secret":"0123456789abcdef","events":["job.done"]}`, map[string]string{"events[0]": "invalid_choice"}},
+		{api.WebhooksPath, `{"url":"example.com","secret":"0123456789abcdef"}`, map[string]string{"url": "invalid_format"}},
+		{api.WebhooksPath, `{"url":"https://203.0.113.10/hook"}`, map[string]string{"secret": "required"}},
+		{api.WebhooksPath, `{"url":"http://169.254.169.254/latest/meta-data/","secret":"0123456789abcdef"}`, map[string]string{"url": "invalid_format"}},
+		{api.JobsPath, `{"download":{"url":"` + testVideoURL + `","type":"video"},"webhook_url":"http://10.0.0.1:8080/","webhook_secret":"0123456789abcdef"}`, map[string]string{"webhook_url": "invalid_format"}},
+	}
+
+	for _, tt := range tests {
+		w := serveAs(mux, "k1", "POST", tt.target, tt.body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", tt.body, w.Code)
+			continue
+		}
+
+		var resp struct {
+			Error struct {
+				Details api.ValidationDetails `json:"details"`
+			} `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+
+		got := map[string]string{}
+		for _, fe := range resp.Error.Details.Fields {
+			got[fe.Field] = fe.Code
+		}
+		for field, code := range tt.want {
+			if got[field] != code {
+				t.Errorf("%s: %s: expected code %q, got %q (all: %v)", tt.body, field, code, got[field], got)
+			}
+		}
+	}
+}
+
+func TestWebhookListAndDelete(t *testing.T) {
+	mux, _ := newJobsMux(t, coretest.NewFake())
+
+	w := serveAs(mux, "k1", "POST", api.WebhooksPath, `{"url":"https://203.0.113.10/hook","secret":"0123456789abcdef"}`)
+	var hook api.WebhookResponse
+	json.Unmarshal(w.Body.Bytes(), &hook)
+	if len(hook.Events) != len(jobs.EventTypes) {
+		t.Fatalf("expected every event by default, got %+v", hook)
+	}
+
+	var list api.WebhookListResponse
+	json.Unmarshal(serveAs(mux, "k1", "GET", api.WebhooksPath, "").Body.Bytes(), &list)
+	if len(list.Webhooks) != 1 || list.Webhooks[0].ID != hook.ID {
+		t.Fatalf("unexpected webhooks %+v", list)
+	}
+
+	if w := serveAs(mux, "k2", "DELETE", api.WebhooksPath+"/"+hook.ID, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another key, got %d", w.Code)
+	}
+	if w := serveAs(mux, "k1", "DELETE", api.WebhooksPath+"/"+hook.ID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if w := serveAs(mux, "k1", "DELETE", api.WebhooksPath+"/"+hook.ID, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 once deleted, got %d", w.Code)
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"cmp"
+	"net/http"
+	"strconv"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/openapi"
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+)
+
+// APIVersion is reported in the OpenAPI document; bump it with the routes.
+const APIVersion = "1.0.0"
+
+// Returns the OpenAPI document describing every route in h.Routes(). Schemas
+// come from the same Go types the handlers encode and decode.
+func (h *Handlers) OpenAPI() *openapi.Document {
+	doc := openapi.New("yt-dlp server API", APIVersion)
+	doc.Info.Description = "Errors use the ErrorResponse envelope. Unversioned /api paths are deprecated aliases of /api/v1."
+
+	doc.Components.SecuritySchemes["apiKey"] = &openapi.SecurityScheme{Type: "apiKey", Name: "X-API-KEY", In: "header", Description: "VITE_X_API_KEY or a key created with \"server keys create\""}
+	doc.Components.SecuritySchemes["adminKey"] = &openapi.SecurityScheme{Type: "apiKey", Name: "X-API-KEY", In: "header", Description: "ADMIN_API_KEY"}
+	doc.Security = []map[string][]string{{"apiKey": {}}}
+
+	gen := openapi.NewGenerator(doc)
+	gen.Name(ErrorResponse{}, "ErrorResponse")
+	gen.Name(apierror.Error{}, "Error")
+	gen.Name(validate.FieldError{}, "FieldError")
+	// yt-dlp's output is forwarded unchanged, so it may hold more than VideoInfo declares.
+	gen.Open(core.VideoInfo{})
+	gen.Open(core.Format{})
+	gen.Open(core.Chapter{})
+
+	errorSchema := gen.Schema(ErrorResponse{})
+
+	for _, route := range h.Routes() {
+		doc.Add(route.Method, route.Path, operation(gen, route.Doc, errorSchema))
+
+		if route.LegacyPath != "" {
+			op := operation(gen, route.Doc, errorSchema)
+			op.OperationID += "Legacy"
+			op.Deprecated = true
+			op.Description = "Deprecated alias of " + route.Path + "."
+			doc.Add(route.Method, route.LegacyPath, op)
+		}
+	}
+
+	return doc
+}
+
+func operation(gen *openapi.Generator, rd RouteDoc, errorSchema *openapi.Schema) *openapi.Operation {
+	op := &openapi.Operation{
+		OperationID: rd.ID,
+		Summary:     rd.Summary,
+		Responses:   map[string]*openapi.Response{},
+	}
+	if rd.Tag != "" {
+		op.Tags = []string{rd.Tag}
+	}
+	if rd.Query != nil {
+		op.Parameters = gen.Parameters(rd.Query)
+	}
+
+	switch rd.Access {
+	case AccessPublic, AccessShare:
+		op.Security = []map[string][]string{}
+	case AccessAdmin:
+		op.Security = []map[string][]string{{"adminKey": {}}}
+	}
+
+	if rd.Request != nil || rd.RawBody != "" {
+		op.RequestBody = &openapi.RequestBody{Required: true, Description: rd.RawBodyNote, Content: map[string]*openapi.MediaType{}}
+
+		if rd.Request != nil {
+			op.RequestBody.Content["application/json"] = &openapi.MediaType{Schema: gen.Schema(rd.Request)}
+		}
+		if rd.RawBody != "" {
+			op.RequestBody.Content[rd.RawBody] = &openapi.MediaType{Schema: &openapi.Schema{Type: "string", Format: "binary"}}
+		}
+	}
+
+	status := cmp.Or(rd.Status, http.StatusOK)
+	ok := &openapi.Response{Description: http.StatusText(status)}
+	switch {
+	case rd.Response != nil:
+		ok.Content = map[string]*openapi.MediaType{"application/json": {Schema: gen.Schema(rd.Response)}}
+	case rd.ContentType == "application/json":
+		ok.Content = map[string]*openapi.MediaType{rd.ContentType: {Schema: &openapi.Schema{Type: "object"}}}
+	case rd.ContentType != "":
+		ok.Content = map[string]*openapi.MediaType{rd.ContentType: {Schema: &openapi.Schema{Type: "string", Format: "binary"}}}
+	}
+	op.Responses[strconv.Itoa(status)] = ok
+
+	errorContent := map[string]*openapi.MediaType{"application/json": {Schema: errorSchema}}
+
+	statuses := rd.Errors
+	if rd.Access != AccessPublic {
+		statuses = append([]int{http.StatusUnauthorized}, statuses...)
+	}
+	for _, status := range statuses {
+		op.Responses[strconv.Itoa(status)] = &openapi.Response{Description: http.StatusText(status), Content: errorContent}
+	}
+	op.Responses["default"] = &openapi.Response{Description: "Error", Content: errorContent}
+
+	return op
+}
+
+// Serves the OpenAPI document.
+func (h *Handlers) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	spec, err := h.openAPIJSON()
+	if err != nil {
+		writeError(w, r, "OpenAPI", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(spec)
+}
@@ -0,0 +1,207 @@
+package api_test
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/coretest"
+	"github.com/gabriel-logan/yt-dlp/server/internal/share"
+)
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	h := newHandlers(t, coretest.NewFake(), api.Options{Docs: true})
+	doc := h.OpenAPI()
+
+	mux := http.NewServeMux()
+	api.RegisterAPIRoutes(mux, h)
+
+	described := 0
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			described++
+
+			req := httptest.NewRequest(strings.ToUpper(method), path, nil)
+			if _, pattern := mux.Handler(req); pattern != strings.ToUpper(method)+" "+path {
+				t.Errorf("%s %s is in the spec but served by %q", method, path, pattern)
+			}
+		}
+	}
+
+	served := 0
+	for _, route := range h.Routes() {
+		served++
+		if doc.Operation(route.Method, route.Path) == nil {
+			t.Errorf("%s %s is not in the spec", route.Method, route.Path)
+		}
+
+		if route.LegacyPath != "" {
+			served++
+			if op := doc.Operation(route.Method, route.LegacyPath); op == nil || !op.Deprecated {
+				t.Errorf("%s %s should be in the spec as deprecated", route.Method, route.LegacyPath)
+			}
+		}
+	}
+
+	if described != served {
+		t.Errorf("spec has %d operations, the mux serves %d", described, served)
+	}
+}
+
+// Sends requests through the registered routes and checks every status and
+// JSON body against the operation the spec declares for it.
+func TestOpenAPIMatchesHandlerResponses(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testVideoInfo)
+	fake.SetDownload(testVideoURL, coretest.Download{Data: []byte("media")})
+
+	cache, err := core.NewDownloadCache(t.TempDir(), 64<<20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newHandlers(t, fake, api.Options{
+		DownloadCache: cache,
+		Versions:      core.NewBinaryVersions(t.TempDir()),
+		Share:         share.NewSigner("secret"),
+		JobsDir:       t.TempDir(),
+		HasFFmpeg:     func() bool { return true },
+		Docs:          true,
+	})
+	doc := h.OpenAPI()
+
+	download := `{"url":"` + testVideoURL + `","type":"audio","quality":0,"format_note":""}`
+	missing := strings.Repeat("0", 12)
+
+	mux := http.NewServeMux()
+	api.RegisterAPIRoutes(mux, h)
+
+	tests := []struct {
+		method, target, body string
+	}{
+		{"GET", api.HelloPath, ""},
+		{"GET", "/api/hello", ""},
+		{"GET", api.OpenAPIPath, ""},
+		{"GET", api.DocsPath, ""},
+		{"GET", api.VideoInfoPath + "?url=" + testVideoURL, ""},
+		{"GET", api.VideoInfoPath + "?url=", ""},
+		{"GET", api.VideoInfoPath + "?url=https://example.com/unscripted", ""},
+		{"POST", api.VideoDownloadPath, `{"url":"` + testVideoURL + `","type":"video","quality":0,"format_note":""}`},
+		{"POST", api.VideoDownloadPath, `{"url":"` + testVideoURL + `","type":"gif"}`},
+		{"GET", api.VideoChaptersPath + "?url=" + testVideoURL, ""},
+		{"GET", api.VideoThumbnailPath + "?url=" + testVideoURL, ""},
+		{"GET", api.VideoThumbnailPath + "?url=", ""},
+		{"GET", api.DownloadsPath + "/" + strings.Repeat("0", 64), ""},
+		{"GET", api.DownloadsPath + "/not-a-key", ""},
+		{"POST", api.SharePath, `{"download":` + download + `,"max_uses":2}`},
+		{"GET", api.SharePath + "/forged", ""},
+		{"POST", api.JobsPath, `{"download":` + download + `}`},
+		{"POST", api.JobsPath, `{"download":{"url":"` + testVideoURL + `","type":"gif"}}`},
+		{"GET", api.JobsPath, ""},
+		{"GET", api.JobsPath + "/" + strings.Repeat("0", 16), ""},
+		{"GET", api.JobsPath + "/" + strings.Repeat("0", 16) + "/file", ""},
+		{"GET", api.JobsPath + "/not-an-id", ""},
+		{"POST", api.WebhooksPath, `{"url":"https://93.184.216.34/hook","secret":"0123456789abcdef","events":["job.succeeded"]}`},
+		{"POST", api.WebhooksPath, `{"url":"http://127.0.0.1/hook","secret":"0123456789abcdef"}`},
+		{"GET", api.WebhooksPath, ""},
+		{"GET", api.WebhooksPath + "/deliveries", ""},
+		{"DELETE", api.WebhooksPath + "/" + missing, ""},
+		{"POST", api.SubscriptionsPath, `{"download":{"url":"https://www.youtube.com/playlist?list=PL1","type":"audio","quality":0,"format_note":""},"interval":3600}`},
+		{"POST", api.SubscriptionsPath, `{"download":{"url":"` + testVideoURL + `","type":"gif"},"interval":3600}`},
+		{"GET", api.SubscriptionsPath, ""},
+		{"GET", api.SubscriptionsPath + "/" + missing, ""},
+		{"PUT", api.SubscriptionsPath + "/" + missing, `{"download":` + download + `,"interval":3600}`},
+		{"POST", api.SubscriptionsPath + "/" + missing + "/run", ""},
+		{"DELETE", api.SubscriptionsPath + "/" + missing, ""},
+		{"DELETE", api.APIPrefix + "/admin/cache/info", ""},
+		{"DELETE", api.APIPrefix + "/admin/cache/downloads", ""},
+		{"GET", api.APIPrefix + "/admin/yt-dlp", ""},
+		{"POST", api.APIPrefix + "/admin/yt-dlp/rollback", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		_, pattern := mux.Handler(req)
+		method, path, _ := strings.Cut(pattern, " ")
+
+		op := doc.Operation(method, path)
+		if op == nil {
+			t.Errorf("%s %s: no operation for pattern %q", tt.method, tt.target, pattern)
+			continue
+		}
+
+		if tt.body != "" && op.RequestBody != nil {
+			if err := doc.Validate(op.RequestBody.Content["application/json"].Schema, []byte(tt.body)); err != nil && !strings.Contains(tt.body, "gif") {
+				t.Errorf("%s %s: request does not match the spec: %v", tt.method, tt.target, err)
+			}
+		}
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		resp := op.Responses[strconv.Itoa(rr.Code)]
+		if resp == nil && rr.Code >= 400 {
+			resp = op.Responses["default"]
+		}
+		if resp == nil {
+			t.Errorf("%s %s: status %d is not in the spec", tt.method, tt.target, rr.Code)
+			continue
+		}
+
+		ct, _, _ := mime.ParseMediaType(rr.Header().Get("Content-Type"))
+		content, ok := resp.Content[ct]
+		if !ok {
+			t.Errorf("%s %s: content type %q of status %d is not in the spec", tt.method, tt.target, ct, rr.Code)
+			continue
+		}
+
+		if ct == "application/json" {
+			if err := doc.Validate(content.Schema, rr.Body.Bytes()); err != nil {
+				t.Errorf("%s %s: status %d body does not match the spec: %v\n%s", tt.method, tt.target, rr.Code, err, rr.Body.String())
+			}
+		}
+	}
+}
+
+func TestOpenAPIRejectsUndeclaredRequestFields(t *testing.T) {
+	doc := newHandlers(t, coretest.NewFake(), api.Options{}).OpenAPI()
+	schema := doc.Operation("POST", api.VideoDownloadPath).RequestBody.Content["application/json"].Schema
+
+	if err := doc.Validate(schema, []byte(`{"url":"u","type":"audio","quality":1,"format_note":"","bitrate":320}`)); err == nil {
+		t.Fatal("expected an undeclared field to fail validation")
+	}
+	if err := doc.Validate(schema, []byte(`{"url":"u","type":"gif","quality":1,"format_note":""}`)); err == nil {
+		t.Fatal("expected an unknown type to fail validation")
+	}
+}
+
+func TestOpenAPIHandlerServesDocument(t *testing.T) {
+	h := newHandlers(t, coretest.NewFake(), api.Options{})
+
+	rr := httptest.NewRecorder()
+	h.OpenAPIHandler(rr, httptest.NewRequest("GET", api.OpenAPIPath, nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" || doc.Paths[api.VideoDownloadPath] == nil {
+		t.Fatalf("unexpected document: %.200s", rr.Body.String())
+	}
+	if _, ok := doc.Paths[api.DocsPath]; ok {
+		t.Fatal("docs route should only be described when enabled")
+	}
+}
@@ -1,10 +1,320 @@
 package api
 
-import "net/http"
+import (
+	"net/http"
+)
 
-func RegisterAPIRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("GET /api/hello", HelloHandler)
+// APIPrefix is the base path of the current API version.
+const APIPrefix = "/api/v1"
 
-	mux.HandleFunc("GET /api/video/info", VideoInfoHandler)
-	mux.HandleFunc("POST /api/video/download", VideoDownloadHandler)
+const (
+	HelloPath          = APIPrefix + "/hello"
+	VideoInfoPath      = APIPrefix + "/video/info"
+	VideoDownloadPath  = APIPrefix + "/video/download"
+	VideoChaptersPath  = APIPrefix + "/video/chapters"
+	VideoThumbnailPath = APIPrefix + "/video/thumbnail"
+	DownloadsPath      = APIPrefix + "/downloads"
+	SharePath          = APIPrefix + "/share"
+	JobsPath           = APIPrefix + "/jobs"
+	WebhooksPath       = APIPrefix + "/webhooks"
+	SubscriptionsPath  = APIPrefix + "/subscriptions"
+
+	OpenAPIPath = "/api/openapi.json"
+	DocsPath    = "/api/docs"
+)
+
+// Route is an API endpoint. LegacyPath, when set, is the pre-v1 path still
+// served as a deprecated alias.
+type Route struct {
+	Method     string
+	Path       string
+	LegacyPath string
+	Handler    http.HandlerFunc
+	Stream     bool // sends a file or a download, which the request timeout must not buffer
+	Doc        RouteDoc
+}
+
+// Access levels of a route, matching the Auth middleware.
+const (
+	AccessKey    = ""       // X-API-KEY with the client key or a stored key
+	AccessPublic = "public" // no key
+	AccessAdmin  = "admin"  // X-API-KEY with ADMIN_API_KEY
+	AccessShare  = "share"  // a share link in the path, or any key
+)
+
+// RouteDoc describes a route in the OpenAPI document.
+type RouteDoc struct {
+	ID      string // operationId
+	Summary string
+	Tag     string
+	Access  string
+
+	Query any // struct with `query` or `path` tags, Ex: VideoInfoQuery{}
+
+	Request     any    // JSON request body, Ex: DownloadRequest{}
+	RawBody     string // content type of a raw request body, accepted besides Request
+	RawBodyNote string
+
+	Status      int    // status of a successful response; 200 when 0
+	Response    any    // JSON body of a successful response
+	ContentType string // content type of a non-JSON successful response, Ex: "text/html"
+
+	Errors []int // statuses answered with an ErrorResponse
+}
+
+// Returns every API route served by h.
+func (h *Handlers) Routes() []Route {
+	routes := []Route{
+		{
+			Method: http.MethodGet, Path: HelloPath, LegacyPath: "/api/hello", Handler: HelloHandler,
+			Doc: RouteDoc{ID: "hello", Summary: "Health check", Tag: "meta", Access: AccessPublic, Response: HelloResponse{}},
+		},
+		{
+			Method: http.MethodGet, Path: OpenAPIPath, Handler: h.OpenAPIHandler,
+			Doc: RouteDoc{ID: "getOpenAPI", Summary: "This OpenAPI document", Tag: "meta", Access: AccessPublic, ContentType: "application/json"},
+		},
+
+		{
+			Method: http.MethodGet, Path: VideoInfoPath, LegacyPath: "/api/video/info", Handler: h.VideoInfoHandler,
+			Doc: RouteDoc{
+				ID: "getVideoInfo", Summary: "Video metadata as reported by yt-dlp", Tag: "video",
+				Query:    VideoInfoQuery{},
+				Response: VideoInfoResponse{},
+				Errors:   []int{400, 403, 404, 422, 429, 502, 504},
+			},
+		},
+		{
+			Method: http.MethodGet, Path: VideoChaptersPath, Handler: h.VideoChaptersHandler,
+			Doc: RouteDoc{
+				ID: "getVideoChapters", Summary: "Chapters of the video", Tag: "video",
+				Query:    VideoInfoQuery{},
+				Response: ChaptersResponse{},
+				Errors:   []int{400, 403, 404, 422, 429, 502, 504},
+			},
+		},
+		{
+			Method: http.MethodGet, Path: VideoThumbnailPath, Handler: h.VideoThumbnailHandler,
+			Doc: RouteDoc{
+				ID: "getVideoThumbnail", Summary: "Thumbnail of the video, proxied and cached by the server", Tag: "video",
+				Query:       ThumbnailQuery{},
+				ContentType: "image/*",
+				Errors:      []int{400, 403, 404, 422, 429, 502, 504},
+			},
+		},
+		{
+			Method: http.MethodPost, Path: VideoDownloadPath, LegacyPath: "/api/video/download", Handler: h.VideoDownloadHandler, Stream: true,
+			Doc: RouteDoc{
+				ID: "downloadVideo", Summary: "Stream the video or audio file, or a ZIP of its chapters with split_chapters", Tag: "video",
+				Request:     DownloadRequest{},
+				ContentType: "application/octet-stream",
+				Errors:      []int{400, 403, 404, 413, 422, 429, 502, 504},
+			},
+		},
+		{
+			Method: http.MethodGet, Path: DownloadsPath + "/{key}", Handler: h.CachedDownloadHandler, Stream: true,
+			Doc: RouteDoc{
+				ID: "getCachedDownload", Summary: "A completed download from the cache; supports Range, If-Range and HEAD", Tag: "video",
+				Query:       CachedDownloadParams{},
+				ContentType: "application/octet-stream",
+				Errors:      []int{400, 404, 416, 429},
+			},
+		},
+		{
+			Method: http.MethodPost, Path: SharePath, Handler: h.ShareHandler,
+			Doc: RouteDoc{
+				ID: "createShareLink", Summary: "Mint a signed link that starts a download without an API key", Tag: "video",
+				Request:  ShareRequest{},
+				Response: ShareResponse{},
+				Errors:   []int{400, 404, 413, 429},
+			},
+		},
+		{
+			Method: http.MethodGet, Path: SharePath + "/{token}", Handler: h.SharedDownloadHandler, Stream: true,
+			Doc: RouteDoc{
+				ID: "getSharedDownload", Summary: "Stream the download a share link was minted for; each GET uses the link once", Tag: "video", Access: AccessShare,
+				Query:       SharedDownloadParams{},
+				ContentType: "application/octet-stream",
+				Errors:      []int{400, 403, 404, 410, 422, 429, 502, 504},
+			},
+		},
+
+		{
+			Method: http.MethodPost, Path: JobsPath, Handler: h.CreateJobHandler,
+			Doc: RouteDoc{
+				ID: "createJob", Summary: "Queue a download to run in the background", Tag: "jobs",
+				Request:  JobRequest{},
+				Status:   http.StatusAccepted,
+				Response: JobResponse{},
+				Errors:   []int{400, 413, 429, 503},
+			},
+		},
+		{
+			Method: http.MethodGet, Path: JobsPath, Handler: h.ListJobsHandler,
+			Doc: RouteDoc{ID: "listJobs", Summary: "Recent jobs of the API key, newest first", Tag: "jobs", Response: JobListResponse{}, Errors: []int{429}},
+		},
+		{
+			Method: http.MethodGet, Path: JobsPath + "/{id}", Handler: h.GetJobHandler,
+			Doc: RouteDoc{ID: "getJob", Summary: "State of a job", Tag: "jobs", Query: JobParams{}, Response: JobResponse{}, Errors: []int{400, 404, 429}},
+		},
+		{
+			Method: http.MethodGet, Path: JobsPath + "/{id}/file", Handler: h.JobFileHandler, Stream: true,
+			Doc: RouteDoc{
+				ID: "getJobFile", Summary: "The file downloaded by a succeeded job; supports Range, If-Range and HEAD", Tag: "jobs",
+				Query:       JobParams{},
+				ContentType: "application/octet-stream",
+				Errors:      []int{400, 404, 409, 416, 429},
+			},
+		},
+		{
+			Method: http.MethodPost, Path: WebhooksPath, Handler: h.CreateWebhookHandler,
+			Doc: RouteDoc{
+				ID: "createWebhook", Summary: "Post the job events of the API key to a URL", Tag: "jobs",
+				Request:  WebhookRequest{},
+				Status:   http.StatusCreated,
+				Response: WebhookResponse{},
+				Errors:   []int{400, 413, 429},
+			},
+		},
+		{
+			Method: http.MethodGet, Path: WebhooksPath, Handler: h.ListWebhooksHandler,
+			Doc: RouteDoc{ID: "listWebhooks", Summary: "Webhooks of the API key", Tag: "jobs", Response: WebhookListResponse{}, Errors: []int{429}},
+		},
+		{
+			Method: http.MethodDelete, Path: WebhooksPath + "/{id}", Handler: h.DeleteWebhookHandler,
+			Doc: RouteDoc{ID: "deleteWebhook", Summary: "Remove a webhook", Tag: "jobs", Query: WebhookParams{}, Status: http.StatusNoContent, Errors: []int{400, 404, 429}},
+		},
+		{
+			Method: http.MethodGet, Path: WebhooksPath + "/deliveries", Handler: h.WebhookDeliveriesHandler,
+			Doc: RouteDoc{ID: "listWebhookDeliveries", Summary: "Recent webhook deliveries of the API key and their attempts", Tag: "jobs", Response: DeliveriesResponse{}, Errors: []int{429}},
+		},
+
+		{
+			Method: http.MethodPost, Path: SubscriptionsPath, Handler: h.CreateSubscriptionHandler,
+			Doc: RouteDoc{
+				ID: "createSubscription", Summary: "Watch a playlist or channel, queueing a job for every new item", Tag: "subscriptions",
+				Request:  SubscriptionRequest{},
+				Status:   http.StatusCreated,
+				Response: SubscriptionResponse{},
+				Errors:   []int{400, 413, 429},
+			},
+		},
+		{
+			Method: http.MethodGet, Path: SubscriptionsPath, Handler: h.ListSubscriptionsHandler,
+			Doc: RouteDoc{ID: "listSubscriptions", Summary: "Subscriptions of the API key", Tag: "subscriptions", Response: SubscriptionListResponse{}, Errors: []int{429}},
+		},
+		{
+			Method: http.MethodGet, Path: SubscriptionsPath + "/{id}", Handler: h.GetSubscriptionHandler,
+			Doc: RouteDoc{ID: "getSubscription", Summary: "A subscription and its last check", Tag: "subscriptions", Query: SubscriptionParams{}, Response: SubscriptionResponse{}, Errors: []int{400, 404, 429}},
+		},
+		{
+			Method: http.MethodPut, Path: SubscriptionsPath + "/{id}", Handler: h.UpdateSubscriptionHandler,
+			Doc: RouteDoc{
+				ID: "updateSubscription", Summary: "Change the download, schedule or limit of a subscription", Tag: "subscriptions",
+				Query:    SubscriptionParams{},
+				Request:  SubscriptionRequest{},
+				Response: SubscriptionResponse{},
+				Errors:   []int{400, 404, 413, 429},
+			},
+		},
+		{
+			Method: http.MethodDelete, Path: SubscriptionsPath + "/{id}", Handler: h.DeleteSubscriptionHandler,
+			Doc: RouteDoc{ID: "deleteSubscription", Summary: "Remove a subscription and its download archive", Tag: "subscriptions", Query: SubscriptionParams{}, Status: http.StatusNoContent, Errors: []int{400, 404, 429}},
+		},
+		{
+			Method: http.MethodPost, Path: SubscriptionsPath + "/{id}/run", Handler: h.RunSubscriptionHandler,
+			Doc: RouteDoc{
+				ID: "runSubscription", Summary: "Check a subscription now, in the background", Tag: "subscriptions",
+				Query:    SubscriptionParams{},
+				Status:   http.StatusAccepted,
+				Response: SubscriptionResponse{},
+				Errors:   []int{400, 404, 409, 429},
+			},
+		},
+
+		{
+			Method: http.MethodDelete, Path: APIPrefix + "/admin/cache/info", LegacyPath: "/api/admin/cache/info", Handler: h.PurgeInfoCacheHandler,
+			Doc: RouteDoc{
+				ID: "purgeInfoCache", Summary: "Purge cached video info", Tag: "admin", Access: AccessAdmin,
+				Query:    PurgeInfoQuery{},
+				Response: PurgeResponse{},
+			},
+		},
+		{
+			Method: http.MethodDelete, Path: APIPrefix + "/admin/cache/downloads", LegacyPath: "/api/admin/cache/downloads", Handler: h.PurgeDownloadCacheHandler,
+			Doc: RouteDoc{ID: "purgeDownloadCache", Summary: "Purge completed downloads from the cache", Tag: "admin", Access: AccessAdmin, Response: PurgeResponse{}},
+		},
+
+		{
+			Method: http.MethodGet, Path: APIPrefix + "/admin/yt-dlp", LegacyPath: "/api/admin/yt-dlp", Handler: h.YTDlpStatusHandler,
+			Doc: RouteDoc{ID: "getYTDlpStatus", Summary: "The yt-dlp binary in use and the installed versions", Tag: "admin", Access: AccessAdmin, Response: YTDlpStatusResponse{}},
+		},
+		{
+			Method: http.MethodPost, Path: APIPrefix + "/admin/yt-dlp", LegacyPath: "/api/admin/yt-dlp", Handler: h.YTDlpInstallHandler,
+			Doc: RouteDoc{
+				ID: "installYTDlp", Summary: "Install a yt-dlp binary and switch to it", Tag: "admin", Access: AccessAdmin,
+				Query:       YTDlpInstallQuery{},
+				Request:     YTDlpInstallRequest{},
+				RawBody:     "application/octet-stream",
+				RawBodyNote: "the binary itself, or a JSON body naming a file on the server",
+				Response:    YTDlpStatusResponse{},
+				Errors:      []int{400, 404, 413, 422},
+			},
+		},
+		{
+			Method: http.MethodPost, Path: APIPrefix + "/admin/yt-dlp/rollback", LegacyPath: "/api/admin/yt-dlp/rollback", Handler: h.YTDlpRollbackHandler,
+			Doc: RouteDoc{ID: "rollbackYTDlp", Summary: "Switch back to the previous yt-dlp binary", Tag: "admin", Access: AccessAdmin, Response: YTDlpStatusResponse{}, Errors: []int{404, 409}},
+		},
+	}
+
+	if h.docs {
+		routes = append(routes, Route{
+			Method: http.MethodGet, Path: DocsPath, Handler: h.DocsHandler,
+			Doc: RouteDoc{ID: "getDocs", Summary: "Human-readable API reference", Tag: "meta", Access: AccessPublic, ContentType: "text/html"},
+		})
+	}
+
+	return routes
+}
+
+// Reports whether r is for a route of h that streams; see Route.Stream.
+func (h *Handlers) Streams(r *http.Request) bool {
+	_, pattern := h.streamRoutes.Handler(r)
+	return pattern != ""
+}
+
+// Returns a mux matching the routes that stream, for Handlers.Streams.
+func streamRoutes(routes []Route) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, route := range routes {
+		if !route.Stream {
+			continue
+		}
+
+		mux.HandleFunc(route.Method+" "+route.Path, route.Handler)
+		if route.LegacyPath != "" {
+			mux.HandleFunc(route.Method+" "+route.LegacyPath, route.Handler)
+		}
+	}
+
+	return mux
+}
+
+func RegisterAPIRoutes(mux *http.ServeMux, h *Handlers) {
+	for _, route := range h.Routes() {
+		mux.HandleFunc(route.Method+" "+route.Path, route.Handler)
+
+		if route.LegacyPath != "" {
+			mux.HandleFunc(route.Method+" "+route.LegacyPath, deprecated(route.Path, route.Handler))
+		}
+	}
+}
+
+// Wraps a legacy alias so responses point clients to the v1 path.
+func deprecated(successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+">; rel=\"successor-version\"")
+
+		next(w, r)
+	}
 }
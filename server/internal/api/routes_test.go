@@ -2,28 +2,41 @@ package api_test
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/coretest"
 )
 
 func TestRegisterAPIRoutesNoError(t *testing.T) {
 	mux := http.NewServeMux()
-	api.RegisterAPIRoutes(mux)
+	api.RegisterAPIRoutes(mux, newHandlers(t, coretest.NewFake(), api.Options{}))
 }
 
 func TestRegisterAPIRoutesRoutesRegistered(t *testing.T) {
 	mux := http.NewServeMux()
-	api.RegisterAPIRoutes(mux)
+	api.RegisterAPIRoutes(mux, newHandlers(t, coretest.NewFake(), api.Options{}))
 
 	tests := []struct {
 		method  string
 		path    string
 		pattern string
 	}{
+		{"GET", "/api/v1/hello", "GET /api/v1/hello"},
+		{"GET", "/api/v1/video/info", "GET /api/v1/video/info"},
+		{"POST", "/api/v1/video/download", "POST /api/v1/video/download"},
+		{"DELETE", "/api/v1/admin/cache/info", "DELETE /api/v1/admin/cache/info"},
+		{"GET", "/api/v1/admin/yt-dlp", "GET /api/v1/admin/yt-dlp"},
+		{"POST", "/api/v1/admin/yt-dlp/rollback", "POST /api/v1/admin/yt-dlp/rollback"},
 		{"GET", "/api/hello", "GET /api/hello"},
 		{"GET", "/api/video/info", "GET /api/video/info"},
 		{"POST", "/api/video/download", "POST /api/video/download"},
+		{"DELETE", "/api/admin/cache/info", "DELETE /api/admin/cache/info"},
+		{"DELETE", "/api/admin/cache/downloads", "DELETE /api/admin/cache/downloads"},
+		{"GET", "/api/admin/yt-dlp", "GET /api/admin/yt-dlp"},
+		{"POST", "/api/admin/yt-dlp", "POST /api/admin/yt-dlp"},
+		{"POST", "/api/admin/yt-dlp/rollback", "POST /api/admin/yt-dlp/rollback"},
 	}
 
 	for _, tt := range tests {
@@ -41,3 +54,53 @@ func TestRegisterAPIRoutesRoutesRegistered(t *testing.T) {
 		}
 	}
 }
+
+func TestLegacyRoutesAreDeprecated(t *testing.T) {
+	mux := http.NewServeMux()
+	api.RegisterAPIRoutes(mux, newHandlers(t, coretest.NewFake(), api.Options{}))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/hello", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if w.Header().Get("Deprecation") != "true" {
+		t.Errorf("expected a Deprecation header, got %q", w.Header().Get("Deprecation"))
+	}
+	if link := w.Header().Get("Link"); link != `</api/v1/hello>; rel="successor-version"` {
+		t.Errorf("unexpected Link header %q", link)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", api.HelloPath, nil))
+
+	if w.Header().Get("Deprecation") != "" {
+		t.Error("v1 routes must not be marked deprecated")
+	}
+}
+
+func TestStreams(t *testing.T) {
+	h := newHandlers(t, coretest.NewFake(), api.Options{})
+
+	tests := []struct {
+		method string
+		path   string
+		want   bool
+	}{
+		{"POST", "/api/v1/video/download", true},
+		{"POST", "/api/video/download", true},
+		{"HEAD", "/api/v1/downloads/0123abcd", true},
+		{"GET", "/api/v1/share/token", true},
+		{"GET", "/api/v1/jobs/0123456789abcdef/file", true},
+		{"GET", "/api/v1/jobs/0123456789abcdef", false},
+		{"GET", "/api/v1/video/info", false},
+		{"POST", "/api/v1/share", false},
+	}
+
+	for _, tt := range tests {
+		if got := h.Streams(httptest.NewRequest(tt.method, tt.path, nil)); got != tt.want {
+			t.Errorf("%s %s: expected %v, got %v", tt.method, tt.path, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+)
+
+// Converts the requested sections, reporting timestamps and chapter patterns
+// that cannot be parsed.
+func parseSections(req []DownloadSection) ([]core.Section, validate.Errors) {
+	var (
+		sections []core.Section
+		errs     validate.Errors
+	)
+
+	for i, rs := range req {
+		field := fmt.Sprintf("sections[%d].", i)
+
+		if rs.Chapter != "" {
+			if rs.Start != "" || rs.End != "" {
+				errs = append(errs, validate.FieldError{Field: field + "chapter", Code: validate.CodeInvalidChoice, Message: "cannot be combined with start and end"})
+				continue
+			}
+			if _, err := regexp.Compile(rs.Chapter); err != nil {
+				errs = append(errs, validate.FieldError{Field: field + "chapter", Code: validate.CodeInvalidFormat, Message: "must be a valid regular expression"})
+				continue
+			}
+
+			sections = append(sections, core.Section{Chapter: rs.Chapter})
+			continue
+		}
+
+		var s core.Section
+		ok := true
+
+		for _, ts := range []struct {
+			name  string
+			value string
+			dst   *float64
+		}{{"start", rs.Start, &s.Start}, {"end", rs.End, &s.End}} {
+			if ts.value == "" {
+				continue
+			}
+
+			secs, err := core.ParseTimestamp(ts.value)
+			if err != nil {
+				errs = append(errs, validate.FieldError{Field: field + ts.name, Code: validate.CodeInvalidFormat, Message: "must be seconds, MM:SS or HH:MM:SS"})
+				ok = false
+				continue
+			}
+			*ts.dst = secs
+		}
+
+		switch {
+		case !ok:
+			continue
+		case rs.Start == "" && rs.End == "":
+			errs = append(errs, validate.FieldError{Field: field + "start", Code: validate.CodeRequired, Message: "start, end or chapter is required"})
+			continue
+		case rs.End != "" && s.End <= s.Start:
+			errs = append(errs, validate.FieldError{Field: field + "end", Code: validate.CodeTooSmall, Message: "must be after start"})
+			continue
+		}
+
+		sections = append(sections, s)
+	}
+
+	return sections, errs
+}
+
+// Checks sections against the video: times must fall within its duration,
+// chapter patterns must match a chapter, and the sections must select a single
+// part, since a download returns one file.
+func checkSections(sections []core.Section, info *core.VideoInfo) validate.Errors {
+	if len(sections) == 0 {
+		return nil
+	}
+
+	var errs validate.Errors
+	parts := 0
+
+	for i, s := range sections {
+		field := fmt.Sprintf("sections[%d].", i)
+
+		if s.Chapter != "" {
+			n := len(info.MatchChapters(regexp.MustCompile(s.Chapter)))
+			if n == 0 {
+				errs = append(errs, validate.FieldError{Field: field + "chapter", Code: codeUnknownChapter, Message: "matches no chapter of the video"})
+			}
+			parts += n
+			continue
+		}
+
+		parts++
+
+		// live streams and some sites report no duration
+		if info.Duration <= 0 {
+			continue
+		}
+
+		limit := "must be within the video's " + core.FormatTimestamp(info.Duration)
+		if s.Start >= info.Duration {
+			errs = append(errs, validate.FieldError{Field: field + "start", Code: validate.CodeTooLarge, Message: limit})
+		} else if s.End > info.Duration {
+			errs = append(errs, validate.FieldError{Field: field + "end", Code: validate.CodeTooLarge, Message: limit})
+		}
+	}
+
+	if len(errs) == 0 && parts > 1 {
+		errs = append(errs, validate.FieldError{Field: "sections", Code: validate.CodeTooMany, Message: fmt.Sprintf("selects %d parts of the video; a download returns a single file", parts)})
+	}
+
+	return errs
+}
+
+// Checks the embedding options that the validate tags cannot express.
+func checkEmbed(req DownloadRequest) validate.Errors {
+	var errs validate.Errors
+
+	if req.EmbedInfoJSON && req.Type == "audio" {
+		errs = append(errs, validate.FieldError{Field: "embed_info_json", Code: validate.CodeInvalidChoice, Message: "is only supported for video downloads"})
+	}
+
+	for _, m := range []struct{ field, value string }{
+		{"meta_title", req.MetaTitle},
+		{"meta_artist", req.MetaArtist},
+		{"meta_album", req.MetaAlbum},
+	} {
+		// yt-dlp cannot escape a backslash before the ':' ending the value
+		if strings.ContainsAny(m.value, "\\\r\n") {
+			errs = append(errs, validate.FieldError{Field: m.field, Code: validate.CodeInvalidFormat, Message: "must not contain backslashes or line breaks"})
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/share"
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+)
+
+const (
+	// defaultShareTTL is how long a share link lasts when expires_in is not set.
+	defaultShareTTL = 24 * time.Hour
+
+	// Codes answered for share links that can no longer be used.
+	CodeShareLinkExpired = "share_link_expired"
+	CodeShareLinkUsedUp  = "share_link_used_up"
+)
+
+// Mints a signed link that starts the requested download without an API key,
+// until it expires or runs out of uses.
+func (h *Handlers) ShareHandler(w http.ResponseWriter, r *http.Request) {
+	if h.share == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "share links are disabled; set SHARE_LINK_SECRET to enable them")
+		return
+	}
+
+	var req ShareRequest
+	if err := validate.JSON(w, r, &req, maxJSONBodyBytes); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	_, errs := checkDownloadRequest(req.Download)
+	for i := range errs {
+		errs[i].Field = "download." + errs[i].Field
+	}
+	if req.ClientIP != "" && net.ParseIP(req.ClientIP) == nil {
+		errs = append(errs, validate.FieldError{Field: "client_ip", Code: validate.CodeInvalidFormat, Message: "must be an IPv4 or IPv6 address"})
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+
+	ttl := defaultShareTTL
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+
+	data, err := json.Marshal(req.Download)
+	if err != nil {
+		writeError(w, r, "Share", err)
+		return
+	}
+
+	token, claims, err := h.share.Sign(share.Claims{
+		Data:     data,
+		Expires:  time.Now().Add(ttl).Unix(),
+		MaxUses:  req.MaxUses,
+		ClientIP: req.ClientIP,
+	})
+	if err != nil {
+		writeError(w, r, "Share", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(ShareResponse{
+		URL:       SharePath + "/" + token,
+		ExpiresAt: claims.ExpiresAt().UTC(),
+		MaxUses:   claims.MaxUses,
+	})
+}
+
+// Sends the download a share link was minted for. Every GET counts as a use;
+// HEAD only checks that the link is still valid.
+func (h *Handlers) SharedDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	params := SharedDownloadParams{Token: r.PathValue("token")}
+	if err := validate.Struct(&params); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	if h.share == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "share links are disabled")
+		return
+	}
+
+	claims, err := h.share.Verify(params.Token, share.ClientIP(r), time.Now())
+	if err != nil {
+		writeShareError(w, r, err)
+		return
+	}
+
+	// the rules may have tightened since the link was minted
+	var req DownloadRequest
+	if err := json.Unmarshal(claims.Data, &req); err != nil {
+		writeShareError(w, r, share.ErrInvalid)
+		return
+	}
+	if err := validate.Struct(&req); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := h.share.Redeem(claims, time.Now()); err != nil {
+		writeShareError(w, r, err)
+		return
+	}
+
+	h.download(w, r, req)
+}
+
+// Answers a share link that cannot be used.
+func writeShareError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, share.ErrExpired):
+		apierror.Write(w, r, http.StatusGone, CodeShareLinkExpired, err.Error())
+	case errors.Is(err, share.ErrUsedUp):
+		apierror.Write(w, r, http.StatusGone, CodeShareLinkUsedUp, err.Error())
+	case errors.Is(err, share.ErrWrongClient):
+		apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, err.Error())
+	default:
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, share.ErrInvalid.Error())
+	}
+}
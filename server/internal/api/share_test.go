@@ -0,0 +1,174 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/coretest"
+	"github.com/gabriel-logan/yt-dlp/server/internal/share"
+)
+
+func newShareMux(t *testing.T, fake *coretest.Fake) *http.ServeMux {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	api.RegisterAPIRoutes(mux, newHandlers(t, fake, api.Options{Share: share.NewSigner("secret"), HasFFmpeg: func() bool { return true }}))
+
+	return mux
+}
+
+func mintShareLink(t *testing.T, mux *http.ServeMux, body string) api.ShareResponse {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", api.SharePath, strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp api.ShareResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid json response: %v", err)
+	}
+
+	return resp
+}
+
+func TestShareLinkDownloads(t *testing.T) {
+	data := append([]byte("\x00\x00\x00\x18ftypdash\x00\x00\x00\x00"), bytes.Repeat([]byte("audio"), 100)...)
+
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testVideoInfo)
+	fake.SetDownload(testVideoURL, coretest.Download{Data: data})
+	mux := newShareMux(t, fake)
+
+	link := mintShareLink(t, mux, `{"download":{"url":"`+testVideoURL+`","type":"audio"},"expires_in":600,"max_uses":2}`)
+	if !strings.HasPrefix(link.URL, api.SharePath+"/") || link.MaxUses != 2 {
+		t.Fatalf("unexpected link %+v", link)
+	}
+	if d := time.Until(link.ExpiresAt); d < 9*time.Minute || d > 10*time.Minute {
+		t.Fatalf("unexpected expiry %s", link.ExpiresAt)
+	}
+
+	// HEAD checks the link without using it
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("HEAD", link.URL, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for HEAD, got %d", w.Code)
+	}
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", link.URL, nil))
+		if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), data) {
+			t.Fatalf("use %d: expected the download, got %d: %s", i+1, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="Test video [dQw4w9WgXcQ].m4a"` {
+			t.Fatalf("unexpected Content-Disposition %s", got)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", link.URL, nil))
+	if w.Code != http.StatusGone || !strings.Contains(w.Body.String(), api.CodeShareLinkUsedUp) {
+		t.Fatalf("expected 410 once used up, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestShareLinkClientIP(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testVideoInfo)
+	fake.SetDownload(testVideoURL, coretest.Download{Data: []byte("data")})
+	mux := newShareMux(t, fake)
+
+	link := mintShareLink(t, mux, `{"download":{"url":"`+testVideoURL+`","type":"video"},"client_ip":"203.0.113.7"}`)
+
+	req := httptest.NewRequest("GET", link.URL, nil)
+	req.RemoteAddr = "198.51.100.1:1234"
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another client, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", link.URL, nil)
+	req.RemoteAddr = "203.0.113.7:1234"
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for the bound client, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestShareLinkErrors(t *testing.T) {
+	mux := newShareMux(t, coretest.NewFake())
+
+	tests := []struct {
+		name string
+		body string
+		want map[string]string
+	}{
+		{"download rules", `{"download":{"url":"","type":"gif"}}`, map[string]string{"download.url": "required", "download.type": "invalid_choice"}},
+		{"download checks", `{"download":{"url":"` + testVideoURL + `","type":"audio","video_format_id":"18"}}`, map[string]string{"download.video_format_id": "invalid_choice"}},
+		{"link options", `{"download":{"url":"` + testVideoURL + `","type":"video"},"expires_in":-1,"max_uses":20000}`,
+			map[string]string{"expires_in": "too_small", "max_uses": "too_large"}},
+		{"client ip", `{"download":{"url":"` + testVideoURL + `","type":"video"},"client_ip":"nope"}`, map[string]string{"client_ip": "invalid_format"}},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", api.SharePath, strings.NewReader(tt.body)))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", tt.name, w.Code)
+		}
+
+		var resp struct {
+			Error struct {
+				Details api.ValidationDetails `json:"details"`
+			} `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+
+		got := map[string]string{}
+		for _, fe := range resp.Error.Details.Fields {
+			got[fe.Field] = fe.Code
+		}
+		for field, code := range tt.want {
+			if got[field] != code {
+				t.Errorf("%s: %s: expected code %q, got %q (all: %v)", tt.name, field, code, got[field], got)
+			}
+		}
+	}
+
+	token, _, _ := share.NewSigner("other").Sign(share.Claims{Data: json.RawMessage(`{}`), Expires: time.Now().Add(time.Hour).Unix()})
+	expired, _, _ := share.NewSigner("secret").Sign(share.Claims{Data: json.RawMessage(`{}`), Expires: time.Now().Add(-time.Second).Unix()})
+
+	for token, want := range map[string]int{
+		token:   http.StatusNotFound,
+		"bogus": http.StatusNotFound,
+		expired: http.StatusGone,
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", api.SharePath+"/"+token, nil))
+		if w.Code != want {
+			t.Errorf("%.20s: expected %d, got %d", token, want, w.Code)
+		}
+	}
+}
+
+func TestShareLinksDisabled(t *testing.T) {
+	mux := http.NewServeMux()
+	api.RegisterAPIRoutes(mux, newHandlers(t, coretest.NewFake(), api.Options{}))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", api.SharePath, strings.NewReader(`{"download":{"url":"`+testVideoURL+`","type":"video"}}`)))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a secret, got %d", w.Code)
+	}
+}
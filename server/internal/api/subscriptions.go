@@ -0,0 +1,400 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/jobs"
+	"github.com/gabriel-logan/yt-dlp/server/internal/keys"
+	"github.com/gabriel-logan/yt-dlp/server/internal/subscriptions"
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+)
+
+const (
+	// subscriptionCheckTimeout bounds a single check of a subscription.
+	subscriptionCheckTimeout = 10 * time.Minute
+
+	// maxSubscriptionJobs bounds the unfinished jobs of a subscription, so a
+	// long playlist does not fill the job queue at once.
+	maxSubscriptionJobs = 50
+)
+
+// Subscribes the API key to a playlist or channel. It is checked at once,
+// then every interval, and a job is queued for every item not seen before.
+func (h *Handlers) CreateSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	sub, ok := decodeSubscription(w, r)
+	if !ok {
+		return
+	}
+	sub.KeyID = keys.KeyID(r.Context())
+
+	sub, err := h.subscriptions.Store().Create(sub)
+	if err != nil {
+		writeError(w, r, "CreateSubscription", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", SubscriptionsPath+"/"+sub.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newSubscriptionResponse(sub))
+}
+
+// Lists the subscriptions of the API key, oldest first.
+func (h *Handlers) ListSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	resp := SubscriptionListResponse{Subscriptions: []SubscriptionResponse{}}
+	for _, sub := range h.subscriptions.Store().List(keys.KeyID(r.Context())) {
+		resp.Subscriptions = append(resp.Subscriptions, newSubscriptionResponse(sub))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Reports a subscription, its schedule and the outcome of its last check.
+func (h *Handlers) GetSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	params, ok := subscriptionParams(w, r)
+	if !ok {
+		return
+	}
+
+	sub, ok := h.subscriptions.Store().Get(keys.KeyID(r.Context()), params.ID)
+	if !ok {
+		writeSubscriptionNotFound(w, r)
+		return
+	}
+
+	writeSubscription(w, sub)
+}
+
+// Replaces the download, schedule, limit and paused state of a subscription.
+// Items it already queued are not queued again.
+func (h *Handlers) UpdateSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	params, ok := subscriptionParams(w, r)
+	if !ok {
+		return
+	}
+
+	update, ok := decodeSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	sub, ok, err := h.subscriptions.Store().Update(keys.KeyID(r.Context()), params.ID, update)
+	if err != nil {
+		writeError(w, r, "UpdateSubscription", err)
+		return
+	}
+	if !ok {
+		writeSubscriptionNotFound(w, r)
+		return
+	}
+
+	writeSubscription(w, sub)
+}
+
+// Removes a subscription and its download archive. Jobs it queued are kept.
+func (h *Handlers) DeleteSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	params, ok := subscriptionParams(w, r)
+	if !ok {
+		return
+	}
+
+	ok, err := h.subscriptions.Store().Delete(keys.KeyID(r.Context()), params.ID)
+	if err != nil {
+		writeError(w, r, "DeleteSubscription", err)
+		return
+	}
+	if !ok {
+		writeSubscriptionNotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Checks a subscription in the background without waiting for its schedule,
+// even when it is paused. Its last_run reports the outcome.
+func (h *Handlers) RunSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	params, ok := subscriptionParams(w, r)
+	if !ok {
+		return
+	}
+
+	sub, ok, err := h.subscriptions.RunNow(keys.KeyID(r.Context()), params.ID)
+	if errors.Is(err, subscriptions.ErrRunning) {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, err.Error())
+		return
+	}
+	if !ok {
+		writeSubscriptionNotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", SubscriptionsPath+"/"+sub.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newSubscriptionResponse(sub))
+}
+
+// Decodes and checks a SubscriptionRequest, returning the subscription it
+// describes.
+func decodeSubscription(w http.ResponseWriter, r *http.Request) (subscriptions.Subscription, bool) {
+	var req SubscriptionRequest
+	if err := validate.JSON(w, r, &req, maxJSONBodyBytes); err != nil {
+		writeValidationError(w, r, err)
+		return subscriptions.Subscription{}, false
+	}
+
+	_, errs := checkDownloadRequest(req.Download)
+	for _, name := range unsupportedForSubscriptions(req.Download) {
+		errs = append(errs, validate.FieldError{Field: name, Code: validate.CodeInvalidChoice, Message: "is not supported for subscriptions"})
+	}
+	for i := range errs {
+		errs[i].Field = "download." + errs[i].Field
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return subscriptions.Subscription{}, false
+	}
+
+	data, err := json.Marshal(req.Download)
+	if err != nil {
+		writeError(w, r, "Subscription", err)
+		return subscriptions.Subscription{}, false
+	}
+
+	return subscriptions.Subscription{
+		URL:      req.Download.URL,
+		Download: data,
+		Interval: req.Interval,
+		Limit:    req.Limit,
+		Paused:   req.Paused,
+	}, true
+}
+
+// Returns the fields of req that only make sense for a single video.
+func unsupportedForSubscriptions(req DownloadRequest) []string {
+	var names []string
+	if req.VideoFormatID != "" {
+		names = append(names, "video_format_id")
+	}
+	if req.AudioFormatID != "" {
+		names = append(names, "audio_format_id")
+	}
+	if len(req.Sections) > 0 {
+		names = append(names, "sections")
+	}
+	if req.SplitChapters {
+		names = append(names, "split_chapters")
+	}
+
+	return names
+}
+
+func subscriptionParams(w http.ResponseWriter, r *http.Request) (SubscriptionParams, bool) {
+	params := SubscriptionParams{ID: r.PathValue("id")}
+	if err := validate.Struct(&params); err != nil {
+		writeValidationError(w, r, err)
+		return SubscriptionParams{}, false
+	}
+
+	return params, true
+}
+
+func writeSubscription(w http.ResponseWriter, sub subscriptions.Subscription) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(newSubscriptionResponse(sub))
+}
+
+func newSubscriptionResponse(sub subscriptions.Subscription) SubscriptionResponse {
+	resp := SubscriptionResponse{
+		ID:        sub.ID,
+		URL:       sub.URL,
+		Interval:  sub.Interval,
+		Limit:     sub.Limit,
+		Paused:    sub.Paused,
+		CreatedAt: sub.CreatedAt,
+		NextRunAt: sub.NextRunAt,
+		Running:   sub.Running,
+		LastRun:   sub.LastRun,
+	}
+	// the download was encoded from a DownloadRequest when it was saved
+	json.Unmarshal(sub.Download, &resp.Download)
+
+	return resp
+}
+
+func writeSubscriptionNotFound(w http.ResponseWriter, r *http.Request) {
+	apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "subscription not found")
+}
+
+// Lists the items of sub missing from its download archive and queues a job
+// for each, with the download of the subscription. Items are recorded in the
+// archive once their job succeeded, so a failed item is queued again by the
+// next check; items whose job has not finished yet are skipped. Items beyond
+// maxSubscriptionJobs unfinished jobs, or found while the queue is full, are
+// left for the next checks.
+func (h *Handlers) checkSubscription(ctx context.Context, sub subscriptions.Subscription, archive string) (int, []string, error) {
+	var req DownloadRequest
+	if err := json.Unmarshal(sub.Download, &req); err != nil {
+		return 0, nil, err
+	}
+
+	entries, err := h.extractor.ListEntries(ctx, sub.URL, core.ListOptions{Archive: archive, Limit: sub.Limit})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	entries = slices.DeleteFunc(entries, func(e core.PlaylistEntry) bool {
+		return e.URL == "" || h.subscriptionItems.queued(sub.ID, e)
+	})
+	queue := entries[:min(len(entries), max(0, maxSubscriptionJobs-h.subscriptionItems.count(sub.ID)))]
+
+	var jobIDs []string
+	for _, e := range queue {
+		req.URL = e.URL
+		data, err := json.Marshal(req)
+		if err != nil {
+			return len(entries), jobIDs, err
+		}
+
+		job, err := h.jobs.Submit(jobs.Job{
+			KeyID:   sub.KeyID,
+			Source:  "subscription:" + sub.ID,
+			Request: data,
+		})
+		if err != nil {
+			return len(entries), jobIDs, err
+		}
+		jobIDs = append(jobIDs, job.ID)
+
+		h.subscriptionItems.add(job.ID, sub.ID, e)
+		if job, ok := h.jobs.Get(job.ID); ok && job.Status.Done() {
+			// finished before it was remembered
+			h.finishSubscriptionItem(job)
+		}
+	}
+
+	return len(entries), jobIDs, nil
+}
+
+// Records the item of a subscription job that succeeded in the download
+// archive of the subscription, in the background. It is a jobs.Queue listener.
+func (h *Handlers) onSubscriptionJob(ev jobs.Event) {
+	if ev.Type == jobs.EventSucceeded || ev.Type == jobs.EventFailed {
+		h.archiveWrites.Add(1)
+		go func() {
+			defer h.archiveWrites.Done()
+			h.finishSubscriptionItem(ev.Job)
+		}()
+	}
+}
+
+func (h *Handlers) finishSubscriptionItem(job jobs.Job) {
+	item, ok := h.subscriptionItems.claim(job.ID)
+	if !ok {
+		return
+	}
+	// forgotten once recorded, so the next check does not queue it again meanwhile
+	defer h.subscriptionItems.remove(job.ID)
+
+	if job.Status != jobs.Succeeded {
+		return
+	}
+
+	store := h.subscriptions.Store()
+	if _, ok := store.Get(job.KeyID, item.subID); !ok {
+		return // deleted with its archive
+	}
+
+	if err := core.RecordArchive(store.ArchivePath(item.subID), item.entry); err != nil {
+		log.Println("Subscription error: ", err)
+	}
+}
+
+// subscriptionItems remembers the playlist item downloaded by each unfinished
+// job of a subscription.
+type subscriptionItems struct {
+	mu    sync.Mutex
+	byJob map[string]subscriptionItem
+}
+
+type subscriptionItem struct {
+	subID   string
+	entry   core.PlaylistEntry
+	claimed bool // its job finished and the archive is being written
+}
+
+func (s *subscriptionItems) add(jobID, subID string, entry core.PlaylistEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.byJob == nil {
+		s.byJob = map[string]subscriptionItem{}
+	}
+	s.byJob[jobID] = subscriptionItem{subID: subID, entry: entry}
+}
+
+// Reports whether a job for entry of the subscription subID is unfinished.
+func (s *subscriptionItems) queued(subID string, entry core.PlaylistEntry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, item := range s.byJob {
+		if item.subID == subID && item.entry.ArchiveID() == entry.ArchiveID() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the number of unfinished jobs of the subscription subID.
+func (s *subscriptionItems) count(subID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, item := range s.byJob {
+		if item.subID == subID {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Returns the item of the job jobID, once: it is still remembered until
+// remove, but later calls report false.
+func (s *subscriptionItems) claim(jobID string) (subscriptionItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.byJob[jobID]
+	if !ok || item.claimed {
+		return subscriptionItem{}, false
+	}
+	item.claimed = true
+	s.byJob[jobID] = item
+
+	return item, true
+}
+
+// Forgets the item of the job jobID.
+func (s *subscriptionItems) remove(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.byJob, jobID)
+}
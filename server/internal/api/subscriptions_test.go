@@ -0,0 +1,299 @@
+package api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/coretest"
+	"github.com/gabriel-logan/yt-dlp/server/internal/jobs"
+	"github.com/gabriel-logan/yt-dlp/server/internal/subscriptions"
+)
+
+const testChannelURL = "https://www.youtube.com/@test/videos"
+
+func newSubscriptionsMux(t *testing.T, fake *coretest.Fake) *http.ServeMux {
+	t.Helper()
+
+	store, err := subscriptions.Open(filepath.Join(t.TempDir(), "subscriptions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	api.RegisterAPIRoutes(mux, newHandlers(t, fake, api.Options{
+		JobsDir:          t.TempDir(),
+		JobWorkers:       2,
+		Subscriptions:    store,
+		SubscriptionTick: 5 * time.Millisecond,
+		HasFFmpeg:        func() bool { return true },
+
+		MaxConcurrentDownloads: 2,
+	}))
+
+	return mux
+}
+
+// Polls the subscription until a finished check satisfies done.
+func waitSubscription(t *testing.T, mux *http.ServeMux, id string, done func(subscriptions.Subscription) bool) subscriptions.Subscription {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w := serveAs(mux, "k1", "GET", api.SubscriptionsPath+"/"+id, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var sub subscriptions.Subscription
+		json.Unmarshal(w.Body.Bytes(), &sub)
+		if !sub.Running && sub.LastRun != nil && done(sub) {
+			return sub
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("subscription %s was not checked: %+v", id, sub)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func entry(id string) core.PlaylistEntry {
+	return core.PlaylistEntry{ID: id, URL: "https://www.youtube.com/watch?v=" + id, IEKey: "Youtube"}
+}
+
+func TestSubscriptionQueuesNewItems(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetEntries(testChannelURL, entry("aaaaaaaaaaa"), entry("bbbbbbbbbbb"), entry("ccccccccccc"))
+	fake.SetDownload("", coretest.Download{Data: []byte("media")})
+	mux := newSubscriptionsMux(t, fake)
+
+	w := serveAs(mux, "k1", "POST", api.SubscriptionsPath, `{"download":{"url":"`+testChannelURL+`","type":"audio","embed_metadata":true},"interval":3600,"limit":2}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var sub subscriptions.Subscription
+	json.Unmarshal(w.Body.Bytes(), &sub)
+	if w.Header().Get("Location") != api.SubscriptionsPath+"/"+sub.ID || sub.URL != testChannelURL || sub.Interval != 3600 {
+		t.Fatalf("unexpected subscription %+v", sub)
+	}
+
+	// the first check runs at once, and only looks at the first 2 items
+	sub = waitSubscription(t, mux, sub.ID, func(s subscriptions.Subscription) bool { return true })
+	if run := sub.LastRun; run.Status != subscriptions.RunSucceeded || run.Found != 2 || len(run.Jobs) != 2 {
+		t.Fatalf("unexpected run %+v", run)
+	}
+	if !sub.NextRunAt.Equal(sub.LastRun.StartedAt.Add(time.Hour)) {
+		t.Fatalf("expected the next check an hour later, got %s", sub.NextRunAt)
+	}
+
+	job := waitJob(t, mux, "k1", sub.LastRun.Jobs[0])
+	var req api.DownloadRequest
+	json.Unmarshal(job.Request, &req)
+	if job.Source != "subscription:"+sub.ID || req.URL != entry("aaaaaaaaaaa").URL || req.Type != "audio" || !req.EmbedMetadata {
+		t.Fatalf("expected the job to download the item with the saved download, got %+v", job)
+	}
+	if job.Status != jobs.Succeeded {
+		t.Fatalf("expected the job to succeed, got %+v", job)
+	}
+
+	// a new upload is found by the next check; the archive skips the others
+	fake.SetEntries(testChannelURL, entry("ddddddddddd"), entry("aaaaaaaaaaa"), entry("bbbbbbbbbbb"))
+	first := sub.LastRun.StartedAt
+	if w := serveAs(mux, "k1", "POST", api.SubscriptionsPath+"/"+sub.ID+"/run", ""); w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	sub = waitSubscription(t, mux, sub.ID, func(s subscriptions.Subscription) bool { return s.LastRun.StartedAt.After(first) })
+	if run := sub.LastRun; run.Found != 1 || len(run.Jobs) != 1 {
+		t.Fatalf("expected only the new item, got %+v", run)
+	}
+	if job := waitJob(t, mux, "k1", sub.LastRun.Jobs[0]); !strings.Contains(string(job.Request), "ddddddddddd") {
+		t.Fatalf("unexpected job %+v", job)
+	}
+}
+
+func TestSubscriptionRecordsDownloadedItems(t *testing.T) {
+	release := make(chan struct{})
+	fake := coretest.NewFake()
+	fake.SetEntries(testChannelURL, entry("aaaaaaaaaaa"), entry("bbbbbbbbbbb"), entry("ccccccccccc"))
+	fake.SetDownload(entry("aaaaaaaaaaa").URL, coretest.Download{Data: []byte("media")})
+	fake.SetDownload(entry("bbbbbbbbbbb").URL, coretest.Download{StartErr: errors.New("network down")})
+	fake.SetDownload(entry("ccccccccccc").URL, coretest.Download{Data: []byte("media"), Block: release})
+	mux := newSubscriptionsMux(t, fake)
+
+	w := serveAs(mux, "k1", "POST", api.SubscriptionsPath, `{"download":{"url":"`+testChannelURL+`","type":"audio"},"interval":3600}`)
+	var sub subscriptions.Subscription
+	json.Unmarshal(w.Body.Bytes(), &sub)
+
+	sub = waitSubscription(t, mux, sub.ID, func(s subscriptions.Subscription) bool { return true })
+	first := sub.LastRun.Jobs
+	if len(first) != 3 {
+		t.Fatalf("expected a job per item, got %+v", sub.LastRun)
+	}
+	for i, want := range []jobs.Status{jobs.Succeeded, jobs.Failed} {
+		if job := waitJob(t, mux, "k1", first[i]); job.Status != want {
+			t.Fatalf("expected job %d to be %s, got %+v", i, want, job)
+		}
+	}
+
+	// the downloaded item is archived and the unfinished one skipped, so
+	// only the failed item is queued again
+	check := func() subscriptions.Run {
+		t.Helper()
+		last := sub.LastRun.StartedAt
+		if w := serveAs(mux, "k1", "POST", api.SubscriptionsPath+"/"+sub.ID+"/run", ""); w.Code != http.StatusAccepted {
+			t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+		}
+		sub = waitSubscription(t, mux, sub.ID, func(s subscriptions.Subscription) bool { return s.LastRun.StartedAt.After(last) })
+		return *sub.LastRun
+	}
+
+	for _, blocked := range []bool{true, false} {
+		run := check()
+		if run.Found != 1 || len(run.Jobs) != 1 {
+			t.Fatalf("expected only the failed item to be queued again, got %+v", run)
+		}
+		if job := waitJob(t, mux, "k1", run.Jobs[0]); !strings.Contains(string(job.Request), "bbbbbbbbbbb") {
+			t.Fatalf("unexpected job %+v", job)
+		}
+
+		// the last item is archived once downloaded
+		if blocked {
+			close(release)
+			waitJob(t, mux, "k1", first[2])
+		}
+	}
+}
+
+func TestSubscriptionLimitsUnfinishedJobs(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	var items []core.PlaylistEntry
+	for i := 0; i < 60; i++ {
+		items = append(items, entry(fmt.Sprintf("item%07d", i)))
+	}
+	fake := coretest.NewFake()
+	fake.SetEntries(testChannelURL, items...)
+	fake.SetDownload("", coretest.Download{Data: []byte("media"), Block: release})
+	mux := newSubscriptionsMux(t, fake)
+
+	w := serveAs(mux, "k1", "POST", api.SubscriptionsPath, `{"download":{"url":"`+testChannelURL+`","type":"audio"},"interval":3600}`)
+	var sub subscriptions.Subscription
+	json.Unmarshal(w.Body.Bytes(), &sub)
+
+	sub = waitSubscription(t, mux, sub.ID, func(s subscriptions.Subscription) bool { return true })
+	if run := sub.LastRun; run.Status != subscriptions.RunSucceeded || run.Found != 60 || len(run.Jobs) != 50 {
+		t.Fatalf("expected 50 of the 60 items to be queued, got %+v", run)
+	}
+
+	// the rest waits until jobs finish
+	first := sub.LastRun.StartedAt
+	serveAs(mux, "k1", "POST", api.SubscriptionsPath+"/"+sub.ID+"/run", "")
+	sub = waitSubscription(t, mux, sub.ID, func(s subscriptions.Subscription) bool { return s.LastRun.StartedAt.After(first) })
+	if run := sub.LastRun; run.Found != 10 || len(run.Jobs) != 0 {
+		t.Fatalf("expected no more jobs, got %+v", run)
+	}
+}
+
+func TestSubscriptionFailedCheck(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetEntriesError(testChannelURL, &core.ExtractorError{Code: core.CodeVideoUnavailable, Message: "This channel does not exist"})
+	mux := newSubscriptionsMux(t, fake)
+
+	w := serveAs(mux, "k1", "POST", api.SubscriptionsPath, `{"download":{"url":"`+testChannelURL+`","type":"video"},"interval":300}`)
+	var sub subscriptions.Subscription
+	json.Unmarshal(w.Body.Bytes(), &sub)
+
+	sub = waitSubscription(t, mux, sub.ID, func(s subscriptions.Subscription) bool { return true })
+	if run := sub.LastRun; run.Status != subscriptions.RunFailed || !strings.Contains(run.Error, "does not exist") || len(run.Jobs) != 0 {
+		t.Fatalf("expected a failed run, got %+v", run)
+	}
+}
+
+func TestSubscriptionUpdateAndDelete(t *testing.T) {
+	mux := newSubscriptionsMux(t, coretest.NewFake())
+
+	w := serveAs(mux, "k1", "POST", api.SubscriptionsPath, `{"download":{"url":"`+testChannelURL+`","type":"video"},"interval":3600,"paused":true}`)
+	var sub subscriptions.Subscription
+	json.Unmarshal(w.Body.Bytes(), &sub)
+
+	w = serveAs(mux, "k1", "PUT", api.SubscriptionsPath+"/"+sub.ID, `{"download":{"url":"`+testChannelURL+`","type":"audio"},"interval":86400,"paused":true}`)
+	var updated subscriptions.Subscription
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	if w.Code != http.StatusOK || updated.Interval != 86400 || !strings.Contains(string(updated.Download), `"audio"`) || updated.LastRun != nil {
+		t.Fatalf("unexpected update %d %s", w.Code, w.Body.String())
+	}
+
+	var list api.SubscriptionListResponse
+	json.Unmarshal(serveAs(mux, "k1", "GET", api.SubscriptionsPath, "").Body.Bytes(), &list)
+	if len(list.Subscriptions) != 1 || list.Subscriptions[0].ID != sub.ID {
+		t.Fatalf("unexpected subscriptions %+v", list)
+	}
+
+	// other keys see none of it
+	for _, method := range []string{"GET", "PUT", "DELETE"} {
+		if w := serveAs(mux, "k2", method, api.SubscriptionsPath+"/"+sub.ID, `{"download":{"url":"`+testChannelURL+`","type":"audio"},"interval":300}`); w.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404 for another key, got %d", method, w.Code)
+		}
+	}
+	if w := serveAs(mux, "k2", "POST", api.SubscriptionsPath+"/"+sub.ID+"/run", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another key, got %d", w.Code)
+	}
+
+	if w := serveAs(mux, "k1", "DELETE", api.SubscriptionsPath+"/"+sub.ID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if w := serveAs(mux, "k1", "GET", api.SubscriptionsPath+"/"+sub.ID, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 once deleted, got %d", w.Code)
+	}
+}
+
+func TestSubscriptionValidation(t *testing.T) {
+	mux := newSubscriptionsMux(t, coretest.NewFake())
+
+	tests := []struct {
+		body string
+		want map[string]string
+	}{
+		{`{"download":{"url":"` + testChannelURL + `","type":"video"}}`, map[string]string{"interval": "too_small"}},
+		{`{"download":{"url":"` + testChannelURL + `","type":"video"},"interval":60,"limit":5000}`, map[string]string{"interval": "too_small", "limit": "too_large"}},
+		{`{"download":{"url":"` + testChannelURL + `","type":"video","video_format_id":"18","sections":[{"start":"10"}]},"interval":300}`, map[string]string{"download.video_format_id": "invalid_choice", "download.sections": "invalid_choice"}},
+		{`{"download":{"url":"` + testChannelURL + `","type":"audio","split_chapters":true},"interval":300}`, map[string]string{"download.split_chapters": "invalid_choice"}},
+	}
+
+	for _, tt := range tests {
+		w := serveAs(mux, "k1", "POST", api.SubscriptionsPath, tt.body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", tt.body, w.Code)
+			continue
+		}
+
+		var resp struct {
+			Error struct {
+				Details api.ValidationDetails `json:"details"`
+			} `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+
+		got := map[string]string{}
+		for _, fe := range resp.Error.Details.Fields {
+			got[fe.Field] = fe.Code
+		}
+		for field, code := range tt.want {
+			if got[field] != code {
+				t.Errorf("%s: %s: expected code %q, got %q (all: %v)", tt.body, field, code, got[field], got)
+			}
+		}
+	}
+
+	if w := serveAs(mux, "k1", "GET", api.SubscriptionsPath+"/nothex", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid id, got %d", w.Code)
+	}
+}
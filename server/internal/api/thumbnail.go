@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/urls"
+	"github.com/gabriel-logan/yt-dlp/server/internal/thumbnail"
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+)
+
+const (
+	// maxThumbnailWidth bounds the width of converted thumbnails.
+	maxThumbnailWidth = 1280
+
+	// thumbnailMaxAge is how long browsers may keep a served thumbnail.
+	thumbnailMaxAge = time.Hour
+
+	// CodeThumbnailUnavailable is answered when the thumbnail could not be fetched upstream.
+	CodeThumbnailUnavailable = "thumbnail_unavailable"
+)
+
+// Serves a thumbnail of the video through the server, so clients never load
+// it from the upstream CDN. Format jpeg re-encodes it, scaled down to width;
+// webp only picks among the WebP thumbnails the site offers.
+func (h *Handlers) VideoThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	var query ThumbnailQuery
+	if err := validate.Query(r.URL.Query(), &query); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	info, _, _, err := h.lookupVideoInfo(r.Context(), urls.Resolve(query.URL))
+	if err != nil {
+		writeError(w, r, "VideoThumbnail", err)
+		return
+	}
+
+	ext := ""
+	if query.Format == "webp" {
+		ext = "webp"
+	}
+
+	thumb, ok := info.PickThumbnail(query.Width, ext)
+	if !ok {
+		status, msg := http.StatusNotFound, "video has no thumbnail"
+		if ext != "" {
+			status, msg = http.StatusUnprocessableEntity, "video has no "+ext+" thumbnail"
+		}
+		apierror.Write(w, r, status, apierror.CodeForStatus(status), msg)
+		return
+	}
+
+	req := thumbnail.Request{URL: thumb.URL}
+	if query.Format == "jpeg" {
+		req.JPEG = true
+		req.Width = maxThumbnailWidth
+		if query.Width > 0 {
+			req.Width = query.Width
+		}
+	}
+
+	img, status, err := h.thumbnails.Get(r.Context(), req)
+	if err != nil {
+		if r.Context().Err() != nil {
+			writeError(w, r, "VideoThumbnail", err)
+		} else {
+			writeThumbnailUnavailable(w, r, err)
+		}
+		return
+	}
+
+	etag := img.ETag()
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(thumbnailMaxAge.Seconds())))
+	w.Header().Set("X-Cache", string(status))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Write(img.Data)
+}
+
+// Reports a thumbnail that could not be fetched, or was not a valid image.
+func writeThumbnailUnavailable(w http.ResponseWriter, r *http.Request, err error) {
+	log.Println("VideoThumbnail error: ", err)
+
+	apierror.WriteError(w, r, http.StatusBadGateway, apierror.Error{
+		Code:      CodeThumbnailUnavailable,
+		Message:   "thumbnail could not be fetched",
+		Retryable: true,
+	})
+}
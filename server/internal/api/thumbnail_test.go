@@ -0,0 +1,100 @@
+package api_test
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/coretest"
+)
+
+// Returns a fake serving the video with two PNG thumbnails, one of them
+// served with a .webp path, from an httptest server.
+func newThumbnailFake(t *testing.T) *coretest.Fake {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 64, 36))); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken.jpg" {
+			w.Write([]byte("not an image"))
+			return
+		}
+		w.Write(buf.Bytes())
+	}))
+	t.Cleanup(srv.Close)
+
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, `{"id":"dQw4w9WgXcQ","title":"Test video","extractor_key":"Youtube","thumbnails":[`+
+		`{"id":"0","url":"`+srv.URL+`/default.png","width":120,"height":90},`+
+		`{"id":"1","url":"`+srv.URL+`/hq.webp","width":480,"height":360}]}`)
+	fake.SetInfo("https://example.com/broken", `{"id":"x","extractor_key":"Youtube","thumbnail":"`+srv.URL+`/broken.jpg"}`)
+
+	return fake
+}
+
+func TestVideoThumbnailHandler(t *testing.T) {
+	h := newTestHandlers(t, newThumbnailFake(t))
+
+	for query, want := range map[string]string{
+		"":                       "image/png",
+		"&format=original":       "image/png",
+		"&format=webp&width=100": "image/png", // sniffed: the fake serves PNG bytes under .webp
+		"&format=jpeg&width=32":  "image/jpeg",
+	} {
+		w := httptest.NewRecorder()
+		h.VideoThumbnailHandler(w, httptest.NewRequest("GET", "/api/v1/video/thumbnail?url="+testVideoURL+query, nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		if ct := w.Header().Get("Content-Type"); ct != want {
+			t.Fatalf("%q: expected %s, got %s", query, want, ct)
+		}
+		if w.Header().Get("ETag") == "" || w.Header().Get("X-Cache") == "" {
+			t.Fatalf("%q: missing cache headers %v", query, w.Header())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.VideoThumbnailHandler(w, httptest.NewRequest("GET", "/api/v1/video/thumbnail?url="+testVideoURL+"&format=jpeg&width=32", nil))
+
+	cfg, _, err := image.DecodeConfig(w.Body)
+	if err != nil || cfg.Width != 32 || cfg.Height != 18 {
+		t.Fatalf("unexpected converted image %dx%d: %v", cfg.Width, cfg.Height, err)
+	}
+	if w.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("expected a cache hit, got %q", w.Header().Get("X-Cache"))
+	}
+}
+
+func TestVideoThumbnailHandlerErrors(t *testing.T) {
+	fake := newThumbnailFake(t)
+	fake.SetInfo("https://example.com/none", testVideoInfo)
+	h := newTestHandlers(t, fake)
+
+	for query, want := range map[string]struct {
+		status int
+		code   string
+	}{
+		testVideoURL + "&format=gif":             {http.StatusBadRequest, "validation_failed"},
+		testVideoURL + "&width=5000":             {http.StatusBadRequest, "validation_failed"},
+		"https://example.com/none":               {http.StatusNotFound, "not_found"},
+		"https://example.com/none&format=webp":   {http.StatusUnprocessableEntity, "unprocessable_entity"},
+		"https://example.com/broken&format=jpeg": {http.StatusBadGateway, "thumbnail_unavailable"},
+	} {
+		w := httptest.NewRecorder()
+		h.VideoThumbnailHandler(w, httptest.NewRequest("GET", "/api/v1/video/thumbnail?url="+query, nil))
+
+		if w.Code != want.status || !strings.Contains(w.Body.String(), `"code":"`+want.code+`"`) {
+			t.Errorf("%q: expected %d %s, got %d: %s", query, want.status, want.code, w.Code, w.Body.String())
+		}
+	}
+}
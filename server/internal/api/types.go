@@ -0,0 +1,239 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/jobs"
+	"github.com/gabriel-logan/yt-dlp/server/internal/subscriptions"
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+	"github.com/gabriel-logan/yt-dlp/server/internal/webhooks"
+)
+
+// HelloResponse is returned by HelloHandler.
+type HelloResponse struct {
+	Message string `json:"message"`
+}
+
+// DownloadRequest is the JSON body accepted by VideoDownloadHandler.
+type DownloadRequest struct {
+	URL        string `json:"url" validate:"required,maxlen=2000"`
+	Type       string `json:"type" enum:"video,audio" validate:"required"`
+	Quality    int    `json:"quality,omitempty" validate:"min=0,max=1000" doc:"index into the server's quality ladder"`
+	FormatNote string `json:"format_note,omitempty" validate:"maxlen=100" doc:"Ex: 720p60; takes precedence over quality"`
+
+	VideoFormatID string `json:"video_format_id,omitempty" validate:"maxlen=100,token" doc:"exact format_id from the video info; overrides quality and format_note. Video-only formats are merged with audio_format_id, or the best audio"`
+	AudioFormatID string `json:"audio_format_id,omitempty" validate:"maxlen=100,token" doc:"exact format_id of an audio format from the video info"`
+
+	Sections       []DownloadSection `json:"sections,omitempty" validate:"maxitems=10" doc:"download only these parts of the video; together they must select a single part"`
+	ForceKeyframes bool              `json:"force_keyframes,omitempty" doc:"re-encode around the cuts so sections start exactly at the requested times; slower"`
+
+	SplitChapters bool `json:"split_chapters,omitempty" doc:"respond with a ZIP archive holding one file per chapter"`
+
+	EmbedMetadata  bool `json:"embed_metadata,omitempty" doc:"write the title, artist, date and other tags into the file"`
+	EmbedThumbnail bool `json:"embed_thumbnail,omitempty" doc:"write the thumbnail as cover art"`
+	EmbedChapters  bool `json:"embed_chapters,omitempty" doc:"write chapter markers"`
+	EmbedInfoJSON  bool `json:"embed_info_json,omitempty" doc:"attach the info JSON; video downloads only"`
+
+	MetaTitle  string `json:"meta_title,omitempty" validate:"maxlen=200" doc:"replaces the title tag; implies embed_metadata"`
+	MetaArtist string `json:"meta_artist,omitempty" validate:"maxlen=200" doc:"replaces the artist tag; implies embed_metadata"`
+	MetaAlbum  string `json:"meta_album,omitempty" validate:"maxlen=200" doc:"replaces the album tag; implies embed_metadata"`
+}
+
+// DownloadSection is a time range, or a pattern matched against chapter titles.
+type DownloadSection struct {
+	Start   string `json:"start,omitempty" validate:"maxlen=20" doc:"Ex: 90, 1:30 or 1:02:03.5; defaults to the start of the video"`
+	End     string `json:"end,omitempty" validate:"maxlen=20" doc:"defaults to the end of the video"`
+	Chapter string `json:"chapter,omitempty" validate:"maxlen=200" doc:"regular expression matched against chapter titles; replaces start and end"`
+}
+
+// VideoInfoQuery holds the query parameters of VideoInfoHandler.
+type VideoInfoQuery struct {
+	URL string `query:"url" validate:"required,maxlen=2000" doc:"video URL"`
+}
+
+// ThumbnailQuery holds the query parameters of VideoThumbnailHandler.
+type ThumbnailQuery struct {
+	URL    string `query:"url" validate:"required,maxlen=2000" doc:"video URL"`
+	Width  int    `query:"width" validate:"min=0,max=1280" doc:"smallest wanted width in pixels, and the width jpeg is scaled down to; the best thumbnail when 0"`
+	Format string `query:"format" enum:"original,jpeg,webp" doc:"original proxies the image as is, jpeg re-encodes it, webp picks a WebP thumbnail; original when empty"`
+}
+
+// CachedDownloadParams holds the path parameters of CachedDownloadHandler.
+type CachedDownloadParams struct {
+	Key string `path:"key" validate:"required,len=64,hex" doc:"cache key, from the Content-Location of a download response"`
+}
+
+// ShareRequest is the JSON body accepted by ShareHandler.
+type ShareRequest struct {
+	Download  DownloadRequest `json:"download" doc:"the download the link starts, as sent to the download endpoint"`
+	ExpiresIn int             `json:"expires_in,omitempty" validate:"min=0,max=2592000" doc:"seconds until the link expires, at most 30 days; 1 day when 0"`
+	MaxUses   int             `json:"max_uses,omitempty" validate:"min=0,max=10000" doc:"how many downloads the link allows; unlimited when 0"`
+	ClientIP  string          `json:"client_ip,omitempty" validate:"maxlen=45" doc:"only this IP address may use the link"`
+}
+
+// ShareResponse is returned by ShareHandler.
+type ShareResponse struct {
+	URL       string    `json:"url" doc:"path of the link on this server; it needs no API key"`
+	ExpiresAt time.Time `json:"expires_at"`
+	MaxUses   int       `json:"max_uses,omitempty"`
+}
+
+// SharedDownloadParams holds the path parameters of SharedDownloadHandler.
+type SharedDownloadParams struct {
+	Token string `path:"token" validate:"required,maxlen=8192" doc:"signed token, from the url of a share response"`
+}
+
+// JobRequest is the JSON body accepted by CreateJobHandler.
+type JobRequest struct {
+	Download      DownloadRequest `json:"download" doc:"the download to run, as sent to the download endpoint; split_chapters is not supported"`
+	WebhookURL    string          `json:"webhook_url,omitempty" validate:"maxlen=2000" doc:"receives the events of this job, besides the webhooks of the API key"`
+	WebhookSecret string          `json:"webhook_secret,omitempty" validate:"minlen=16,maxlen=200" doc:"signs the requests to webhook_url; required with it"`
+}
+
+// JobParams holds the path parameters of the job endpoints.
+type JobParams struct {
+	ID string `path:"id" validate:"required,len=16,hex" doc:"job id"`
+}
+
+// JobResponse describes a background download.
+type JobResponse struct {
+	ID         string          `json:"id"`
+	Source     string          `json:"source,omitempty" doc:"what submitted the job, Ex: a subscription; empty for API requests"`
+	Request    DownloadRequest `json:"request" doc:"the download request"`
+	Status     jobs.Status     `json:"status" enum:"queued,running,succeeded,failed"`
+	Error      string          `json:"error,omitempty"`
+	File       string          `json:"file,omitempty" doc:"name of the downloaded file, once succeeded"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	WebhookURL string          `json:"webhook_url,omitempty" doc:"receives the events of this job, besides the webhooks of the API key"`
+}
+
+// JobListResponse is returned by ListJobsHandler.
+type JobListResponse struct {
+	Jobs []JobResponse `json:"jobs"`
+}
+
+// WebhookRequest is the JSON body accepted by CreateWebhookHandler.
+type WebhookRequest struct {
+	URL    string           `json:"url" validate:"required,maxlen=2000" doc:"absolute http or https URL receiving the events"`
+	Secret string           `json:"secret" validate:"required,minlen=16,maxlen=200" doc:"signs the requests; it is never returned"`
+	Events []jobs.EventType `json:"events,omitempty" validate:"maxitems=4" doc:"job.queued, job.started, job.succeeded or job.failed; every event when empty"`
+}
+
+// WebhookResponse describes a webhook subscription.
+type WebhookResponse struct {
+	ID        string           `json:"id"`
+	URL       string           `json:"url"`
+	Events    []jobs.EventType `json:"events"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// WebhookListResponse is returned by ListWebhooksHandler.
+type WebhookListResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+// WebhookParams holds the path parameters of DeleteWebhookHandler.
+type WebhookParams struct {
+	ID string `path:"id" validate:"required,len=12,hex" doc:"webhook id"`
+}
+
+// DeliveriesResponse is returned by WebhookDeliveriesHandler.
+type DeliveriesResponse struct {
+	Deliveries []webhooks.Delivery `json:"deliveries"`
+}
+
+// SubscriptionRequest is the JSON body accepted by CreateSubscriptionHandler
+// and UpdateSubscriptionHandler.
+type SubscriptionRequest struct {
+	Download DownloadRequest `json:"download" doc:"the playlist or channel in url, and the download queued for each of its new items; exact formats, sections and split_chapters are not supported"`
+	Interval int             `json:"interval" validate:"required,min=300,max=2592000" doc:"seconds between two checks, from 5 minutes to 30 days"`
+	Limit    int             `json:"limit,omitempty" validate:"min=0,max=1000" doc:"check only the first limit items of the list, Ex: the latest uploads of a channel; all when 0"`
+	Paused   bool            `json:"paused,omitempty" doc:"stop the scheduled checks"`
+}
+
+// SubscriptionParams holds the path parameters of the subscription endpoints.
+type SubscriptionParams struct {
+	ID string `path:"id" validate:"required,len=12,hex" doc:"subscription id"`
+}
+
+// SubscriptionResponse describes a subscription, its schedule and its last
+// check.
+type SubscriptionResponse struct {
+	ID        string             `json:"id"`
+	URL       string             `json:"url" doc:"the playlist or channel"`
+	Download  DownloadRequest    `json:"download" doc:"the download request queued for every new item, with the URL of the item"`
+	Interval  int                `json:"interval" doc:"seconds between two checks"`
+	Limit     int                `json:"limit,omitempty" doc:"only the first limit items of the list are checked; all when 0"`
+	Paused    bool               `json:"paused,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+	NextRunAt time.Time          `json:"next_run_at"`
+	Running   bool               `json:"running"`
+	LastRun   *subscriptions.Run `json:"last_run,omitempty"`
+}
+
+// SubscriptionListResponse is returned by ListSubscriptionsHandler.
+type SubscriptionListResponse struct {
+	Subscriptions []SubscriptionResponse `json:"subscriptions"`
+}
+
+// PurgeInfoQuery holds the query parameters of PurgeInfoCacheHandler.
+type PurgeInfoQuery struct {
+	URL string `query:"url" validate:"maxlen=2000" doc:"purge only this video; all entries when empty"`
+}
+
+// VideoInfoResponse is the JSON document returned by VideoInfoHandler. The server
+// forwards yt-dlp's full output; this type covers the documented fields.
+type VideoInfoResponse = core.VideoInfo
+
+// ChaptersResponse is returned by VideoChaptersHandler. Chapters is empty when
+// the video has none.
+type ChaptersResponse struct {
+	ID       string         `json:"id"`
+	Title    string         `json:"title"`
+	Duration float64        `json:"duration" doc:"seconds"`
+	Chapters []VideoChapter `json:"chapters"`
+}
+
+type VideoChapter struct {
+	Index     int     `json:"index" doc:"1-based position, also the prefix of the file name in split downloads"`
+	Title     string  `json:"title"`
+	StartTime float64 `json:"start_time" doc:"seconds"`
+	EndTime   float64 `json:"end_time" doc:"seconds"`
+}
+
+// PurgeResponse is returned by the admin cache purge endpoints.
+type PurgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+// YTDlpStatusResponse describes the yt-dlp binary in use and the managed versions.
+type YTDlpStatusResponse struct {
+	Version  string                `json:"version"`
+	Path     string                `json:"path"`
+	Source   string                `json:"source"` // Ex: "versions dir", "PATH"
+	Current  *core.InstalledBinary `json:"current"`
+	Previous *core.InstalledBinary `json:"previous"` // what a rollback would switch to
+}
+
+// YTDlpInstallRequest is the JSON body for installing a yt-dlp binary already on the server's disk.
+type YTDlpInstallRequest struct {
+	Path   string `json:"path" validate:"required"`
+	SHA256 string `json:"sha256,omitempty" validate:"len=64,hex" doc:"digest the file must match"`
+}
+
+// YTDlpInstallQuery holds the query parameters of an upload to YTDlpInstallHandler.
+type YTDlpInstallQuery struct {
+	SHA256 string `query:"sha256" validate:"len=64,hex" doc:"digest the uploaded binary must match"`
+}
+
+// ValidationDetails is the details of a validation_failed error.
+type ValidationDetails struct {
+	Fields validate.Errors `json:"fields"`
+}
+
+// ErrorResponse is the JSON body of every failed API request.
+type ErrorResponse = apierror.Response
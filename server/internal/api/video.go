@@ -1,156 +1,405 @@
 package api
 
 import (
+	"bufio"
+	"cmp"
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"net/url"
-	"os/exec"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
 	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/urls"
+	"github.com/gabriel-logan/yt-dlp/server/internal/filename"
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
 )
 
-var (
-	ytCore      *core.YTCore
-	initErr     error
-	downloadSem = make(chan struct{}, core.GetNumCPU())
-	once        sync.Once
-	copyBufPool = sync.Pool{
-		New: func() any {
-			b := make([]byte, 256*1024) // 256KB
-			return &b
-		},
-	}
-)
+var copyBufPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 256*1024) // 256KB
+		return &b
+	},
+}
 
-func getYTCore() (*core.YTCore, error) {
-	once.Do(func() {
-		ytCore, initErr = core.InitYTCore()
-	})
+// Returns the video info for target, fetching it only when it is not cached.
+func (h *Handlers) lookupVideoInfo(ctx context.Context, target urls.Resolved) (*core.VideoInfo, time.Time, core.CacheStatus, error) {
+	return h.infoCache.Get(ctx, target.Key(), func(ctx context.Context) (*core.VideoInfo, error) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+		defer cancel()
 
-	return ytCore, initErr
+		return h.extractor.Info(ctx, target.URL)
+	})
 }
 
-func VideoInfoHandler(w http.ResponseWriter, r *http.Request) {
-	url := r.URL.Query().Get("url")
-
-	if strings.TrimSpace(url) == "" || len(strings.TrimSpace(url)) > 2000 {
-		http.Error(w, "url parameter is required and must be a valid URL with a maximum length of 2000 characters", http.StatusBadRequest)
+func (h *Handlers) VideoInfoHandler(w http.ResponseWriter, r *http.Request) {
+	var query VideoInfoQuery
+	if err := validate.Query(r.URL.Query(), &query); err != nil {
+		writeValidationError(w, r, err)
 		return
 	}
 
-	yt, err := getYTCore()
+	info, expires, status, err := h.lookupVideoInfo(r.Context(), urls.Resolve(query.URL))
 	if err != nil {
-		log.Println("getYTCore error: ", err)
-		http.Error(w, "Some error occurred while initializing yt-dlp core", http.StatusInternalServerError)
+		writeError(w, r, "VideoInfo", err)
 		return
 	}
 
-	url = stripYouTubeListParam(url)
+	sum := sha256.Sum256(info.Raw)
+	etag := fmt.Sprintf("\"%s\"", hex.EncodeToString(sum[:16]))
+	maxAge := int(time.Until(expires).Seconds())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+	w.Header().Set("X-Cache", string(status))
 
-	info, err := yt.GetVideoInfo(url)
-	if err != nil {
-		log.Println("GetVideoInfo error: ", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(info))
+	w.Write(info.Raw)
+}
+
+func (h *Handlers) VideoDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	var req DownloadRequest
+	if err := validate.JSON(w, r, &req, maxJSONBodyBytes); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	h.download(w, r, req)
+}
+
+// Checks the options of req that depend on each other, and parses its sections.
+func checkDownloadRequest(req DownloadRequest) ([]core.Section, validate.Errors) {
+	var errs validate.Errors
+	if req.Type == "audio" && req.VideoFormatID != "" {
+		errs = append(errs, validate.FieldError{Field: "video_format_id", Code: validate.CodeInvalidChoice, Message: "cannot be set for audio downloads"})
+	}
+
+	sections, sectionErrs := parseSections(req.Sections)
+	errs = append(errs, sectionErrs...)
+	errs = append(errs, checkEmbed(req)...)
+	if req.SplitChapters && len(req.Sections) > 0 {
+		errs = append(errs, validate.FieldError{Field: "split_chapters", Code: validate.CodeInvalidChoice, Message: "cannot be combined with sections"})
+	}
+
+	return sections, errs
+}
+
+// errNoFFmpeg is reported for downloads that need ffmpeg when it is missing.
+var errNoFFmpeg = errors.New("ffmpeg is not installed on the server; embedding, metadata, sections and chapter splitting need it")
+
+// Returns the configuration of the download requested by req.
+func downloadConfig(req DownloadRequest, target urls.Resolved, sections []core.Section) core.DownloadConfig {
+	dType := core.Video
+	if req.Type == "audio" {
+		dType = core.Audio
+	}
+
+	return core.DownloadConfig{
+		URL:           target.URL,
+		Type:          dType,
+		Quality:       req.Quality,
+		FormatNote:    req.FormatNote,
+		IsYouTube:     target.Site == urls.YouTubeSite,
+		VideoFormatID: req.VideoFormatID,
+		AudioFormatID: req.AudioFormatID,
+
+		Sections:       sections,
+		ForceKeyframes: req.ForceKeyframes,
+		SplitChapters:  req.SplitChapters,
+
+		Embed: core.EmbedOptions{
+			Metadata:  req.EmbedMetadata,
+			Thumbnail: req.EmbedThumbnail,
+			Chapters:  req.EmbedChapters,
+			InfoJSON:  req.EmbedInfoJSON,
+		},
+		Metadata: core.MetadataOverrides{Title: req.MetaTitle, Artist: req.MetaArtist, Album: req.MetaAlbum},
+	}
+}
+
+// Reports whether req cannot be carried out without the video info.
+func needsInfo(req DownloadRequest) bool {
+	return req.VideoFormatID != "" || req.AudioFormatID != "" || len(req.Sections) > 0 || req.SplitChapters
 }
 
-func VideoDownloadHandler(w http.ResponseWriter, r *http.Request) {
+// Sends the download described by req, which already passed its tag rules.
+func (h *Handlers) download(w http.ResponseWriter, r *http.Request, req DownloadRequest) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Minute)
 	defer cancel()
 
-	select {
-	case downloadSem <- struct{}{}:
-	case <-ctx.Done():
-		log.Println("DownloadHandler: context done before acquiring semaphore")
-		http.Error(w, "request was cancelled before acquiring semaphore", http.StatusRequestTimeout)
+	sections, errs := checkDownloadRequest(req)
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
 		return
 	}
-	defer func() { <-downloadSem }()
 
-	var req struct {
-		URL        string `json:"url"`
-		Type       string `json:"type"`
-		Quality    int    `json:"quality"`
-		FormatNote string `json:"format_note"`
+	target := urls.Resolve(req.URL)
+	cfg := downloadConfig(req, target, sections)
+
+	var key core.DownloadCacheKey
+	cache := h.downloadCache
+	if cfg.SplitChapters {
+		cache = nil
 	}
+	if cache != nil {
+		videoID := target.VideoID
+		if videoID == "" {
+			videoID = target.URL
+		}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
-		return
+		var err error
+		key, err = core.NewDownloadCacheKey(target.Site, videoID, cfg)
+		if err != nil {
+			writeBadRequest(w, r, err.Error())
+			return
+		}
+
+		// A completed download passed every check when it was made, and is
+		// named as it was then, so it is sent without fetching the info.
+		if f, meta, err := cache.OpenFile(key.Hash()); err == nil {
+			d := download{dType: cfg.Type, status: core.CacheHit, cache: cache, key: key.Hash()}
+			d.fileName = func(ext string) string { return cmp.Or(meta.FileName, h.downloadFilename(nil, cfg, ext)) }
+			if fi, err := f.Stat(); err == nil {
+				d.size = fi.Size()
+			}
+			if err := sendDownloadResponse(w, r, f, d); err != nil {
+				log.Println("sendDownloadResponse error: ", err)
+			}
+			return
+		}
 	}
 
-	// Validations
-	if strings.TrimSpace(req.URL) == "" || len(strings.TrimSpace(req.URL)) > 2000 {
-		http.Error(w, "url parameter is required and must be a valid URL with a maximum length of 2000 characters", http.StatusBadRequest)
-		return
+	// The info also names the file, but downloads that do not need it for
+	// anything else go ahead under a generic name when it cannot be fetched.
+	info, _, _, err := h.lookupVideoInfo(r.Context(), target)
+	if err != nil {
+		if needsInfo(req) {
+			writeError(w, r, "VideoInfo", err)
+			return
+		}
+
+		log.Println("VideoInfo error: ", err)
+	} else {
+		if errs := checkSections(sections, info); len(errs) > 0 {
+			writeValidationError(w, r, errs)
+			return
+		}
+
+		if req.SplitChapters && len(info.Chapters) == 0 {
+			writeValidationError(w, r, validate.Errors{{Field: "split_chapters", Code: codeNoChapters, Message: "the video has no chapters"}})
+			return
+		}
+
+		if errs := checkFormats(req, info); len(errs) > 0 {
+			apierror.WriteError(w, r, http.StatusUnprocessableEntity, apierror.Error{
+				Code:    string(core.CodeFormatUnavailable),
+				Message: "the requested format is not available",
+				Details: ValidationDetails{Fields: errs},
+			})
+			return
+		}
 	}
 
-	if req.Type != "video" && req.Type != "audio" {
-		http.Error(w, "type parameter must be either 'video' or 'audio'", http.StatusBadRequest)
+	if cfg.NeedsFFmpeg() && !h.hasFFmpeg() {
+		apierror.WriteError(w, r, http.StatusUnprocessableEntity, apierror.Error{
+			Code:    apierror.CodeUnprocessable,
+			Message: errNoFFmpeg.Error(),
+		})
 		return
 	}
 
-	if req.Quality > 1000 {
-		http.Error(w, "quality parameter must be less than or equal to 1000", http.StatusBadRequest)
+	if cfg.SplitChapters {
+		h.sendChapters(ctx, w, r, cfg, h.downloadFilename(info, cfg, "zip"))
 		return
 	}
 
-	if len(strings.TrimSpace(req.FormatNote)) > 100 {
-		http.Error(w, "format_note parameter must be less than or equal to 100 characters", http.StatusBadRequest)
-		return
+	fill := func(ctx context.Context, dst io.Writer) (err error) {
+		defer func() { err = h.formatGone(err, target, cfg) }()
+
+		select {
+		case h.downloadSem <- struct{}{}:
+		case <-ctx.Done():
+			return fmt.Errorf("request was cancelled before acquiring semaphore: %v", ctx.Err())
+		}
+		defer func() { <-h.downloadSem }()
+
+		reader, err := h.extractor.Download(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		bp := copyBufPool.Get().(*[]byte)
+		defer copyBufPool.Put(bp)
+
+		_, err = io.CopyBuffer(dst, noWriterTo{reader}, *bp)
+		return err
 	}
 
-	dType := core.Video
-	if req.Type == "audio" {
-		dType = core.Audio
+	var (
+		reader io.ReadCloser
+		status core.CacheStatus
+	)
+
+	if cache != nil {
+		var err error
+		reader, status, err = cache.Open(ctx, key, fill)
+		if err != nil {
+			writeError(w, r, "DownloadCache.Open", err)
+			return
+		}
+	} else {
+		reader = streamDirect(ctx, fill)
 	}
 
-	yt, err := getYTCore()
-	if err != nil {
-		log.Println("getYTCore error: ", err)
-		http.Error(w, "init error", http.StatusInternalServerError)
+	d := download{
+		dType:    cfg.Type,
+		status:   status,
+		fileName: func(ext string) string { return h.downloadFilename(info, cfg, ext) },
+	}
+	if cache != nil {
+		d.cache, d.key = cache, key.Hash()
+	}
+	if info != nil {
+		d.size = info.ExactSize(cfg)
+	}
+	if f, ok := reader.(*os.File); ok {
+		// cache hits are read from a complete file
+		if fi, err := f.Stat(); err == nil {
+			d.size = fi.Size()
+		}
+	}
+
+	if err := sendDownloadResponse(w, r, reader, d); err != nil {
+		log.Println("sendDownloadResponse error: ", err)
 		return
 	}
+}
 
-	req.URL = stripYouTubeListParam(req.URL)
-	isYouTube := isYouTubeURL(req.URL)
+// Checks the requested format_ids against the video info, so unknown or
+// mismatched formats fail before yt-dlp runs.
+func checkFormats(req DownloadRequest, info *core.VideoInfo) validate.Errors {
+	var errs validate.Errors
+
+	if id := req.VideoFormatID; id != "" {
+		if f, ok := info.Format(id); !ok {
+			errs = append(errs, validate.FieldError{Field: "video_format_id", Code: codeUnknownFormat, Message: fmt.Sprintf("format %q is not available for this video", id)})
+		} else if !f.HasVideo() {
+			errs = append(errs, validate.FieldError{Field: "video_format_id", Code: codeWrongFormatKind, Message: fmt.Sprintf("format %q has no video stream", id)})
+		} else if f.HasAudio() && req.AudioFormatID != "" {
+			// merging would keep this format's audio and drop the chosen one
+			errs = append(errs, validate.FieldError{Field: "video_format_id", Code: codeWrongFormatKind, Message: fmt.Sprintf("format %q already has audio; leave out audio_format_id or pick a video-only format", id)})
+		}
+	}
 
-	reader, cmd, err := yt.DownloadBinaryCtx(ctx, core.DownloadConfig{
-		URL:        req.URL,
-		Type:       dType,
-		Quality:    req.Quality,
-		FormatNote: req.FormatNote,
-		IsYouTube:  isYouTube,
-	})
-	if err != nil {
-		log.Println("DownloadBinaryCtx error: ", err)
-		http.Error(w, "yt-dlp download failed", http.StatusInternalServerError)
-		return
+	if id := req.AudioFormatID; id != "" {
+		f, ok := info.Format(id)
+		switch {
+		case !ok:
+			errs = append(errs, validate.FieldError{Field: "audio_format_id", Code: codeUnknownFormat, Message: fmt.Sprintf("format %q is not available for this video", id)})
+		case !f.HasAudio():
+			errs = append(errs, validate.FieldError{Field: "audio_format_id", Code: codeWrongFormatKind, Message: fmt.Sprintf("format %q has no audio stream", id)})
+		case req.Type == "video" && f.HasVideo():
+			// merging would keep this format's video and drop the chosen one
+			errs = append(errs, validate.FieldError{Field: "audio_format_id", Code: codeWrongFormatKind, Message: fmt.Sprintf("format %q is not audio-only", id)})
+		}
 	}
 
-	if err := sendDownloadResponse(w, reader, cmd, dType); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	return errs
+}
+
+// A format picked from cached info may have been withdrawn since. Drops the
+// stale info and names the missing format in the error.
+func (h *Handlers) formatGone(err error, target urls.Resolved, cfg core.DownloadConfig) error {
+	if (cfg.VideoFormatID == "" && cfg.AudioFormatID == "") || !errors.Is(err, core.ErrFormatUnavailable) {
+		return err
+	}
+
+	h.infoCache.Purge(target.Key())
+
+	var ids []string
+	for _, id := range []string{cfg.VideoFormatID, cfg.AudioFormatID} {
+		if id != "" {
+			ids = append(ids, strconv.Quote(id))
+		}
+	}
+
+	return &core.ExtractorError{
+		Code:    core.CodeFormatUnavailable,
+		Message: fmt.Sprintf("format %s is no longer available; fetch the video info again", strings.Join(ids, " + ")),
+		Err:     err,
 	}
 }
 
-func sendDownloadResponse(w http.ResponseWriter, reader io.ReadCloser, cmd *exec.Cmd, dType core.DownloadType) error {
+// Runs fill in the background and returns its output as a stream; closing the
+// stream cancels fill.
+func streamDirect(ctx context.Context, fill core.FillFunc) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(fill(ctx, pw))
+	}()
+
+	return &cancelReadCloser{ReadCloser: pr, cancel: cancel}
+}
+
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelReadCloser) Close() error {
+	c.cancel()
+	return c.ReadCloser.Close()
+}
+
+type noWriterTo struct{ io.Reader }
+
+// download describes a media stream sent by sendDownloadResponse.
+type download struct {
+	dType    core.DownloadType
+	status   core.CacheStatus
+	size     int64                   // exact length in bytes, 0 when unknown
+	fileName func(ext string) string // names the file after its container
+
+	cache *core.DownloadCache // when set, the download is stored under key
+	key   string
+}
+
+// Streams the download to the client. The container found in its first bytes
+// gives the Content-Type and the extension of the file name; Content-Length is
+// only set when the exact size is known, otherwise the body is chunked.
+func sendDownloadResponse(w http.ResponseWriter, r *http.Request, reader io.ReadCloser, d download) error {
 	defer reader.Close()
 
-	flusher, canFlush := w.(http.Flusher)
+	// Wait for the first bytes so that failures before any output still get a
+	// proper status, and so the container can be recognized.
+	br := bufio.NewReaderSize(reader, 64*1024)
+	head, err := br.Peek(core.SniffLen)
+	if err != nil && err != io.EOF {
+		writeError(w, r, "VideoDownload", err)
+		return nil
+	}
 
-	type noWriterTo struct{ io.Reader }
+	flusher, canFlush := w.(http.Flusher)
 
 	type flushEveryNWriter struct {
 		w       http.ResponseWriter
@@ -180,19 +429,34 @@ func sendDownloadResponse(w http.ResponseWriter, reader io.ReadCloser, cmd *exec
 		})
 	}
 
-	fileName := "download.bin"
-	if dType == core.Audio {
-		fileName = "audio.m4a"
-	} else {
-		fileName = "video.mp4"
+	audio := d.dType == core.Audio
+	ext := core.SniffContainer(head, audio)
+	meta := core.DownloadMeta{
+		FileName:    d.fileName(cmp.Or(ext, fallbackExt(d.dType))),
+		ContentType: core.ContainerMIMEType(ext, audio),
+	}
+
+	if d.cache != nil {
+		// the stored file can be fetched again, with Range support, once complete
+		w.Header().Set("Content-Location", DownloadsPath+"/"+d.key)
+		if d.status == core.CacheMiss {
+			if err := d.cache.SetMeta(d.key, meta); err != nil {
+				log.Println("DownloadCache.SetMeta error: ", err)
+			}
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	w.Header().Set("Content-Type", meta.ContentType)
+	w.Header().Set("Content-Disposition", filename.ContentDisposition(meta.FileName))
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.Header().Set("Cache-Control", "no-store")
 	w.Header().Set("X-Accel-Buffering", "no")
-	w.Header().Set("Transfer-Encoding", "chunked")
+	if d.size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(d.size, 10))
+	}
+	if d.status != "" {
+		w.Header().Set("X-Cache", string(d.status))
+	}
 
 	w.WriteHeader(http.StatusOK)
 	if canFlush {
@@ -203,18 +467,14 @@ func sendDownloadResponse(w http.ResponseWriter, reader io.ReadCloser, cmd *exec
 	buf := *bp
 	defer copyBufPool.Put(bp)
 
-	_, copyErr := io.CopyBuffer(dst, noWriterTo{reader}, buf)
+	_, copyErr := io.CopyBuffer(dst, noWriterTo{br}, buf)
 	if copyErr != nil {
 		msg := copyErr.Error()
 		if strings.Contains(msg, "broken pipe") ||
 			strings.Contains(msg, "reset by peer") ||
 			strings.Contains(msg, "context canceled") {
-			_ = cmd.Process.Kill()
-			_ = cmd.Wait()
 			return nil
 		}
-		_ = cmd.Process.Kill()
-		_ = cmd.Wait()
 		return fmt.Errorf("stream copy error: %v", copyErr)
 	}
 
@@ -222,55 +482,21 @@ func sendDownloadResponse(w http.ResponseWriter, reader io.ReadCloser, cmd *exec
 		flusher.Flush()
 	}
 
-	if waitErr := cmd.Wait(); waitErr != nil {
-		return fmt.Errorf("yt-dlp error: %v", waitErr)
-	}
-
 	return nil
 }
 
-type writerFunc func([]byte) (int, error)
-
-func (wf writerFunc) Write(p []byte) (int, error) { return wf(p) }
-
-func stripYouTubeListParam(raw string) string {
-	u, err := url.Parse(raw)
-	if err != nil {
-		return raw
-	}
-
-	host := strings.ToLower(u.Hostname())
-	isYouTube := host == "youtube.com" ||
-		host == "www.youtube.com" ||
-		host == "m.youtube.com" ||
-		host == "music.youtube.com" ||
-		host == "youtu.be"
-
-	if !isYouTube {
-		return raw
+// Reports whether an If-None-Match header value matches etag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
 	}
 
-	q := u.Query()
-	if _, ok := q["list"]; !ok {
-		return raw
-	}
-	q.Del("list")
-	u.RawQuery = q.Encode()
-	return u.String()
+	return false
 }
 
-func isYouTubeURL(raw string) bool {
-	u, err := url.Parse(raw)
-
-	if err != nil {
-		return false
-	}
-
-	host := strings.ToLower(u.Hostname())
+type writerFunc func([]byte) (int, error)
 
-	return host == "youtube.com" ||
-		host == "www.youtube.com" ||
-		host == "m.youtube.com" ||
-		host == "music.youtube.com" ||
-		host == "youtu.be"
-}
+func (wf writerFunc) Write(p []byte) (int, error) { return wf(p) }
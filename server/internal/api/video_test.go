@@ -1,18 +1,52 @@
 package api_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/gabriel-logan/yt-dlp/server/internal/api"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/coretest"
+	"github.com/gabriel-logan/yt-dlp/server/internal/filename"
 )
 
+const (
+	testVideoURL  = "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+	testVideoInfo = `{"id":"dQw4w9WgXcQ","title":"Test video","extractor_key":"Youtube","formats":[{"format_id":"18","ext":"mp4"}]}`
+)
+
+// Returns NewHandlers(extractor, opts), closed when the test ends.
+func newHandlers(t *testing.T, extractor core.Extractor, opts api.Options) *api.Handlers {
+	t.Helper()
+
+	h := api.NewHandlers(extractor, opts)
+	t.Cleanup(h.Close)
+
+	return h
+}
+
+func newTestHandlers(t *testing.T, fake *coretest.Fake) *api.Handlers {
+	t.Helper()
+
+	cache, err := core.NewDownloadCache(t.TempDir(), 64<<20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newHandlers(t, fake, api.Options{DownloadCache: cache, HasFFmpeg: func() bool { return true }})
+}
+
 func TestVideoInfoHandlerBadURL(t *testing.T) {
 	req := httptest.NewRequest("GET", "/info?url=", nil)
 	w := httptest.NewRecorder()
 
-	api.VideoInfoHandler(w, req)
+	newTestHandlers(t, coretest.NewFake()).VideoInfoHandler(w, req)
 
 	if w.Code != 400 {
 		t.Fatalf("expected 400, got %d", w.Code)
@@ -24,9 +58,544 @@ func TestVideoInfoHandlerBigBadURL(t *testing.T) {
 	req := httptest.NewRequest("GET", "/info?url="+longURL, nil)
 	w := httptest.NewRecorder()
 
-	api.VideoInfoHandler(w, req)
+	newTestHandlers(t, coretest.NewFake()).VideoInfoHandler(w, req)
 
 	if w.Code != 400 {
 		t.Fatalf("expected 400, got %d", w.Code)
 	}
 }
+
+func TestVideoInfoHandlerCachesAndRevalidates(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testVideoInfo)
+	h := newTestHandlers(t, fake)
+
+	w := httptest.NewRecorder()
+	h.VideoInfoHandler(w, httptest.NewRequest("GET", "/api/video/info?url=https://youtu.be/dQw4w9WgXcQ?si=share", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Body.String() != testVideoInfo {
+		t.Fatalf("expected the raw info document, got %s", w.Body.String())
+	}
+	if got := w.Header().Get("X-Cache"); got != string(core.CacheMiss) {
+		t.Fatalf("expected X-Cache MISS, got %q", got)
+	}
+
+	req := httptest.NewRequest("GET", "/api/video/info?url="+testVideoURL, nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	h.VideoInfoHandler(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-Cache"); got != string(core.CacheHit) {
+		t.Fatalf("expected X-Cache HIT, got %q", got)
+	}
+	if n := fake.InfoCalls(testVideoURL); n != 1 {
+		t.Fatalf("expected a single extractor call, got %d", n)
+	}
+}
+
+func TestVideoInfoHandlerExtractorError(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfoError("", errors.New("extractor exploded"))
+
+	w := httptest.NewRecorder()
+	newTestHandlers(t, fake).VideoInfoHandler(w, httptest.NewRequest("GET", "/api/video/info?url="+testVideoURL, nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", w.Code)
+	}
+}
+
+func TestVideoDownloadHandlerStreamsAndCaches(t *testing.T) {
+	data := bytes.Repeat([]byte("media"), 10000)
+
+	fake := coretest.NewFake()
+	fake.SetDownload(testVideoURL, coretest.Download{Data: data})
+	h := newTestHandlers(t, fake)
+
+	for i, want := range []core.CacheStatus{core.CacheMiss, core.CacheHit} {
+		body := `{"url":"https://youtu.be/dQw4w9WgXcQ","type":"audio","quality":2}`
+		w := httptest.NewRecorder()
+		h.VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d: %s", i, w.Code, w.Body.String())
+		}
+		if !bytes.Equal(w.Body.Bytes(), data) {
+			t.Fatalf("request %d: body mismatch (%d bytes)", i, w.Body.Len())
+		}
+		if got := w.Header().Get("X-Cache"); got != string(want) {
+			t.Fatalf("request %d: expected X-Cache %s, got %q", i, want, got)
+		}
+	}
+
+	calls := fake.Downloads()
+	if len(calls) != 1 {
+		t.Fatalf("expected one extractor download, got %d", len(calls))
+	}
+	if calls[0].URL != testVideoURL || calls[0].Type != core.Audio || calls[0].Quality != 2 || !calls[0].IsYouTube {
+		t.Fatalf("unexpected download config: %+v", calls[0])
+	}
+}
+
+func TestVideoDownloadHandlerCacheHitSkipsInfo(t *testing.T) {
+	m4a := append([]byte("\x00\x00\x00\x18ftypdash\x00\x00\x00\x00"), bytes.Repeat([]byte("audio"), 100)...)
+	cache, err := core.NewDownloadCache(t.TempDir(), 64<<20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testVideoInfo)
+	fake.SetDownload(testVideoURL, coretest.Download{Data: m4a})
+	body := `{"url":"` + testVideoURL + `","type":"audio"}`
+
+	w := httptest.NewRecorder()
+	newHandlers(t, fake, api.Options{DownloadCache: cache}).VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+	name := w.Header().Get("Content-Disposition")
+	if w.Code != http.StatusOK || !strings.Contains(name, "Test video") {
+		t.Fatalf("expected the download named after the video, got %d %q", w.Code, name)
+	}
+
+	// a fresh info cache and a failing extractor do not matter to a hit
+	fake.SetInfoError(testVideoURL, errors.New("extractor exploded"))
+	w = httptest.NewRecorder()
+	newHandlers(t, fake, api.Options{DownloadCache: cache}).VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), m4a) || w.Header().Get("X-Cache") != string(core.CacheHit) {
+		t.Fatalf("expected a cache hit, got %d %v", w.Code, w.Header())
+	}
+	if got := w.Header().Get("Content-Disposition"); got != name {
+		t.Fatalf("expected the hit to keep its name %q, got %q", name, got)
+	}
+	if n := fake.InfoCalls(testVideoURL); n != 1 {
+		t.Fatalf("expected no info lookup for the hit, got %d calls", n)
+	}
+}
+
+func TestVideoDownloadHandlerFilename(t *testing.T) {
+	mp4 := append([]byte("\x00\x00\x00\x20ftypisom\x00\x00\x02\x00"), bytes.Repeat([]byte("media"), 200)...)
+	webm := append([]byte{0x1a, 0x45, 0xdf, 0xa3, 0x9f, 0x42, 0x82, 0x84}, "webm"...)
+
+	tmpl, err := filename.Parse("%(uploader)s - %(title)s (%(resolution)s)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		info, body string
+		data       []byte
+		want       string
+	}{
+		{
+			`{"id":"dQw4w9WgXcQ","title":"Ça va? «Live»","uploader":"Rick","extractor_key":"Youtube","formats":[{"format_id":"22","width":1280,"height":720,"vcodec":"avc1","acodec":"mp4a"}]}`,
+			`"type":"video","quality":4`, mp4,
+			`attachment; filename="Rick - _a va_ _Live_ (1280x720).mp4"; filename*=UTF-8''Rick%20-%20%C3%87a%20va_%20%C2%ABLive%C2%BB%20%281280x720%29.mp4`,
+		},
+		{testVideoInfo, `"type":"audio"`, webm, `attachment; filename="NA - Test video (audio only).webm"`},
+		{"", `"type":"audio"`, []byte("media"), `attachment; filename="audio.m4a"`},
+	} {
+		fake := coretest.NewFake()
+		if tc.info != "" {
+			fake.SetInfo(testVideoURL, tc.info)
+		}
+		fake.SetDownload(testVideoURL, coretest.Download{Data: tc.data})
+
+		h := newHandlers(t, fake, api.Options{FilenameTemplate: tmpl})
+		w := httptest.NewRecorder()
+		h.VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(`{"url":"`+testVideoURL+`",`+tc.body+`}`)))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", tc.body, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("Content-Disposition"); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.body, tc.want, got)
+		}
+	}
+}
+
+func TestVideoDownloadHandlerContentHeaders(t *testing.T) {
+	m4a := append([]byte("\x00\x00\x00\x18ftypdash\x00\x00\x00\x00"), bytes.Repeat([]byte("audio"), 100)...)
+	webm := append([]byte{0x1a, 0x45, 0xdf, 0xa3, 0x9f, 0x42, 0x82, 0x84}, "webm media"...)
+
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, `{"id":"dQw4w9WgXcQ","title":"Test video","extractor_key":"Youtube","formats":[`+
+		`{"format_id":"140","vcodec":"none","acodec":"mp4a","protocol":"https","filesize":`+strconv.Itoa(len(m4a))+`},`+
+		`{"format_id":"251","vcodec":"none","acodec":"opus","protocol":"https","filesize":999}]}`)
+	h := newTestHandlers(t, fake)
+
+	for _, tc := range []struct {
+		body, contentType string
+		data              []byte
+		length            int64 // -1 when unknown
+	}{
+		// a single format sent as is has the size reported by yt-dlp
+		{`"type":"audio","audio_format_id":"140"`, "audio/mp4", m4a, int64(len(m4a))},
+		// embedding rewrites the file, so the size is unknown
+		{`"type":"audio","audio_format_id":"251","embed_metadata":true`, "audio/webm", webm, -1},
+		{`"type":"video","quality":3`, "video/webm", webm, -1},
+	} {
+		fake.SetDownload(testVideoURL, coretest.Download{Data: tc.data})
+
+		for _, want := range []core.CacheStatus{core.CacheMiss, core.CacheHit} {
+			w := httptest.NewRecorder()
+			h.VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(`{"url":"`+testVideoURL+`",`+tc.body+`}`)))
+
+			if w.Code != http.StatusOK || w.Header().Get("X-Cache") != string(want) {
+				t.Fatalf("%s: expected 200 %s, got %d %s: %s", tc.body, want, w.Code, w.Header().Get("X-Cache"), w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tc.contentType {
+				t.Errorf("%s: expected Content-Type %s, got %s", tc.body, tc.contentType, ct)
+			}
+
+			length := tc.length
+			if want == core.CacheHit {
+				// completed cache files always have a known size
+				length = int64(len(tc.data))
+			}
+			if got := w.Header().Get("Content-Length"); (length < 0 && got != "") || (length >= 0 && got != strconv.FormatInt(length, 10)) {
+				t.Errorf("%s (%s): expected Content-Length %d, got %q", tc.body, want, length, got)
+			}
+		}
+	}
+}
+
+func TestVideoDownloadHandlerFailureBeforeOutput(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetDownload("", coretest.Download{Err: errors.New("yt-dlp exited with status 1")})
+
+	w := httptest.NewRecorder()
+	body := `{"url":"` + testVideoURL + `","type":"video"}`
+	newTestHandlers(t, fake).VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", w.Code)
+	}
+}
+
+func TestVideoDownloadHandlerValidation(t *testing.T) {
+	h := newTestHandlers(t, coretest.NewFake())
+
+	for _, body := range []string{
+		`not json`,
+		`{"url":"","type":"video"}`,
+		`{"url":"` + testVideoURL + `","type":"gif"}`,
+		`{"url":"` + testVideoURL + `","type":"video","quality":1001}`,
+		`{"url":"` + testVideoURL + `","type":"video","quality":-1}`,
+		`{"url":"` + testVideoURL + `","type":"video","bitrate":320}`,
+		`{"url":"` + testVideoURL + `","type":"video"} {}`,
+	} {
+		w := httptest.NewRecorder()
+		h.VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestVideoDownloadHandlerReportsEveryFieldError(t *testing.T) {
+	body := `{"url":"","type":"gif","quality":"high","format_note":"` + strings.Repeat("x", 101) + `","extra":true}`
+
+	w := httptest.NewRecorder()
+	newTestHandlers(t, coretest.NewFake()).VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+
+	var resp struct {
+		Error struct {
+			Code    string                `json:"code"`
+			Details api.ValidationDetails `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid json response: %v", err)
+	}
+	if resp.Error.Code != "validation_failed" {
+		t.Fatalf("unexpected code %q", resp.Error.Code)
+	}
+
+	got := map[string]string{}
+	for _, fe := range resp.Error.Details.Fields {
+		got[fe.Field] = fe.Code
+	}
+	want := map[string]string{
+		"url":         "required",
+		"type":        "invalid_choice",
+		"quality":     "invalid_type",
+		"format_note": "too_long",
+		"extra":       "unknown_field",
+	}
+	for field, code := range want {
+		if got[field] != code {
+			t.Errorf("%s: expected code %q, got %q (all: %v)", field, code, got[field], got)
+		}
+	}
+}
+
+func TestVideoDownloadHandlerRejectsLargeBody(t *testing.T) {
+	body := `{"url":"` + testVideoURL + `","type":"video","format_note":"` + strings.Repeat("x", 70<<10) + `"}`
+
+	w := httptest.NewRecorder()
+	newTestHandlers(t, coretest.NewFake()).VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", w.Code)
+	}
+}
+
+const testFormatsInfo = `{"id":"dQw4w9WgXcQ","title":"Test video","extractor_key":"Youtube","formats":[` +
+	`{"format_id":"18","vcodec":"avc1","acodec":"mp4a"},` +
+	`{"format_id":"137","vcodec":"avc1","acodec":"none"},` +
+	`{"format_id":"140","vcodec":"none","acodec":"mp4a"}]}`
+
+func TestVideoDownloadHandlerExactFormats(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testFormatsInfo)
+	fake.SetDownload(testVideoURL, coretest.Download{Data: []byte("media")})
+	h := newTestHandlers(t, fake)
+
+	body := `{"url":"` + testVideoURL + `","type":"video","video_format_id":"137","audio_format_id":"140"}`
+	w := httptest.NewRecorder()
+	h.VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	calls := fake.Downloads()
+	if len(calls) != 1 || calls[0].VideoFormatID != "137" || calls[0].AudioFormatID != "140" {
+		t.Fatalf("unexpected download configs: %+v", calls)
+	}
+}
+
+func TestVideoDownloadHandlerRejectsUnusableFormats(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testFormatsInfo)
+	h := newTestHandlers(t, fake)
+
+	for _, tc := range []struct {
+		body, field, code string
+	}{
+		{`"type":"video","video_format_id":"999"`, "video_format_id", "unknown_format"},
+		{`"type":"video","video_format_id":"140"`, "video_format_id", "wrong_format_kind"},
+		{`"type":"video","audio_format_id":"18"`, "audio_format_id", "wrong_format_kind"},
+		{`"type":"video","video_format_id":"18","audio_format_id":"140"`, "video_format_id", "wrong_format_kind"},
+		{`"type":"audio","audio_format_id":"137"`, "audio_format_id", "wrong_format_kind"},
+	} {
+		w := httptest.NewRecorder()
+		body := `{"url":"` + testVideoURL + `",` + tc.body + `}`
+		h.VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected 422, got %d: %s", tc.body, w.Code, w.Body.String())
+		}
+
+		var resp struct {
+			Error struct {
+				Code    string                `json:"code"`
+				Details api.ValidationDetails `json:"details"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid json response: %v", err)
+		}
+		if resp.Error.Code != string(core.CodeFormatUnavailable) {
+			t.Fatalf("%s: unexpected code %q", tc.body, resp.Error.Code)
+		}
+		if f := resp.Error.Details.Fields; len(f) != 1 || f[0].Field != tc.field || f[0].Code != tc.code {
+			t.Fatalf("%s: unexpected fields %+v", tc.body, f)
+		}
+	}
+
+	if n := len(fake.Downloads()); n != 0 {
+		t.Fatalf("expected no downloads, got %d", n)
+	}
+}
+
+func TestVideoDownloadHandlerFormatGone(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testFormatsInfo)
+	fake.SetDownload(testVideoURL, coretest.Download{Err: core.ErrFormatUnavailable})
+	h := newTestHandlers(t, fake)
+
+	body := `{"url":"` + testVideoURL + `","type":"video","video_format_id":"137"}`
+	w := httptest.NewRecorder()
+	h.VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `format \"137\" is no longer available`) {
+		t.Fatalf("unexpected body %s", w.Body.String())
+	}
+
+	// the stale info was dropped, so the next request asks yt-dlp again
+	h.VideoDownloadHandler(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+	if n := fake.InfoCalls(testVideoURL); n != 2 {
+		t.Fatalf("expected the info to be fetched again, got %d calls", n)
+	}
+}
+
+const testChaptersInfo = `{"id":"dQw4w9WgXcQ","title":"Webinar","extractor_key":"Youtube","duration":3600,"chapters":[` +
+	`{"title":"Intro","start_time":0,"end_time":60},` +
+	`{"title":"Demo part 1","start_time":60,"end_time":1800},` +
+	`{"title":"Demo part 2","start_time":1800,"end_time":3600}]}`
+
+func TestVideoDownloadHandlerSections(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testChaptersInfo)
+	fake.SetDownload(testVideoURL, coretest.Download{Data: []byte("clip")})
+	h := newTestHandlers(t, fake)
+
+	for _, sections := range []string{
+		`[{"start":"10:00","end":"10:30"}]`,
+		`[{"chapter":"^Intro$"}]`,
+	} {
+		body := `{"url":"` + testVideoURL + `","type":"video","sections":` + sections + `,"force_keyframes":true}`
+		w := httptest.NewRecorder()
+		h.VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", sections, w.Code, w.Body.String())
+		}
+	}
+
+	calls := fake.Downloads()
+	if len(calls) != 2 {
+		t.Fatalf("expected two downloads, got %d", len(calls))
+	}
+	if s := calls[0].Sections; len(s) != 1 || s[0].Start != 600 || s[0].End != 630 || !calls[0].ForceKeyframes {
+		t.Fatalf("unexpected config %+v", calls[0])
+	}
+	if s := calls[1].Sections; len(s) != 1 || s[0].Chapter != "^Intro$" {
+		t.Fatalf("unexpected config %+v", calls[1])
+	}
+}
+
+func TestVideoDownloadHandlerRejectsBadSections(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testChaptersInfo)
+	h := newTestHandlers(t, fake)
+
+	for _, tc := range []struct {
+		sections, field, code string
+	}{
+		{`[{"start":"1:2:3:4"}]`, "sections[0].start", "invalid_format"},
+		{`[{"start":"1:00","end":"0:30"}]`, "sections[0].end", "too_small"},
+		{`[{}]`, "sections[0].start", "required"},
+		{`[{"chapter":"(unclosed"}]`, "sections[0].chapter", "invalid_format"},
+		{`[{"chapter":"Intro","start":"5"}]`, "sections[0].chapter", "invalid_choice"},
+		{`[{"start":"2:00:00"}]`, "sections[0].start", "too_large"},
+		{`[{"start":"59:00","end":"1:00:01"}]`, "sections[0].end", "too_large"},
+		{`[{"chapter":"Outro"}]`, "sections[0].chapter", "unknown_chapter"},
+		{`[{"chapter":"^Demo"}]`, "sections", "too_many"},
+		{`[{"start":"0","end":"10"},{"start":"20","end":"30"}]`, "sections", "too_many"},
+		{`[{"start":"1","stop":"2"}]`, "sections[0].stop", "unknown_field"},
+	} {
+		body := `{"url":"` + testVideoURL + `","type":"video","sections":` + tc.sections + `}`
+		w := httptest.NewRecorder()
+		h.VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d: %s", tc.sections, w.Code, w.Body.String())
+		}
+
+		var resp struct {
+			Error struct {
+				Details api.ValidationDetails `json:"details"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid json response: %v", err)
+		}
+		if f := resp.Error.Details.Fields; len(f) != 1 || f[0].Field != tc.field || f[0].Code != tc.code {
+			t.Errorf("%s: unexpected fields %+v", tc.sections, f)
+		}
+	}
+
+	if n := len(fake.Downloads()); n != 0 {
+		t.Fatalf("expected no downloads, got %d", n)
+	}
+}
+
+func TestVideoDownloadHandlerEmbedOptions(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetDownload(testVideoURL, coretest.Download{Data: []byte("tagged")})
+	h := newTestHandlers(t, fake)
+
+	body := `{"url":"` + testVideoURL + `","type":"audio","embed_thumbnail":true,"embed_chapters":true,"meta_artist":"The Band","meta_album":"Live: 2026"}`
+	w := httptest.NewRecorder()
+	h.VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	calls := fake.Downloads()
+	if len(calls) != 1 {
+		t.Fatalf("expected one download, got %d", len(calls))
+	}
+	wantEmbed := core.EmbedOptions{Thumbnail: true, Chapters: true}
+	wantMeta := core.MetadataOverrides{Artist: "The Band", Album: "Live: 2026"}
+	if calls[0].Embed != wantEmbed || calls[0].Metadata != wantMeta {
+		t.Fatalf("unexpected config %+v", calls[0])
+	}
+}
+
+func TestVideoDownloadHandlerEmbedValidation(t *testing.T) {
+	h := newTestHandlers(t, coretest.NewFake())
+
+	for body, field := range map[string]string{
+		`{"url":"` + testVideoURL + `","type":"audio","embed_info_json":true}`: "embed_info_json",
+		`{"url":"` + testVideoURL + `","type":"audio","meta_title":"a\\"}`:     "meta_title",
+		`{"url":"` + testVideoURL + `","type":"audio","meta_album":"a\nb"}`:    "meta_album",
+	} {
+		w := httptest.NewRecorder()
+		h.VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"field":"`+field+`"`) {
+			t.Fatalf("%s: expected a 400 for %s, got %d: %s", body, field, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestVideoDownloadHandlerRequiresFFmpeg(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo(testVideoURL, testChaptersInfo)
+	fake.SetDownload(testVideoURL, coretest.Download{Data: []byte("media")})
+	h := newHandlers(t, fake, api.Options{HasFFmpeg: func() bool { return false }})
+
+	for _, extra := range []string{
+		`"embed_metadata":true`,
+		`"meta_title":"x"`,
+		`"sections":[{"start":"1:00"}]`,
+		`"split_chapters":true`,
+	} {
+		body := `{"url":"` + testVideoURL + `","type":"video",` + extra + `}`
+		w := httptest.NewRecorder()
+		h.VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(body)))
+
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "ffmpeg") {
+			t.Fatalf("%s: expected 422 mentioning ffmpeg, got %d: %s", extra, w.Code, w.Body.String())
+		}
+	}
+
+	// plain downloads do not need it
+	w := httptest.NewRecorder()
+	h.VideoDownloadHandler(w, httptest.NewRequest("POST", "/api/video/download", strings.NewReader(`{"url":"`+testVideoURL+`","type":"video"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if n := len(fake.Downloads()); n != 1 {
+		t.Fatalf("expected one download, got %d", n)
+	}
+}
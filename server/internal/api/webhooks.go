@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/jobs"
+	"github.com/gabriel-logan/yt-dlp/server/internal/keys"
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+	"github.com/gabriel-logan/yt-dlp/server/internal/webhooks"
+)
+
+// Subscribes a URL to the job events of the API key.
+func (h *Handlers) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var req WebhookRequest
+	if err := validate.JSON(w, r, &req, maxJSONBodyBytes); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	errs := h.checkWebhook(r.Context(), "url", req.URL, "secret", req.Secret)
+	for i, e := range req.Events {
+		if !slices.Contains(jobs.EventTypes, e) {
+			errs = append(errs, validate.FieldError{Field: fmt.Sprintf("events[%d]", i), Code: validate.CodeInvalidChoice, Message: fmt.Sprintf("must be one of %v", jobs.EventTypes)})
+		}
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+
+	sub, err := h.webhooks.Store().Create(webhooks.Subscription{
+		KeyID:  keys.KeyID(r.Context()),
+		URL:    req.URL,
+		Secret: req.Secret,
+		Events: req.Events,
+	})
+	if err != nil {
+		writeError(w, r, "CreateWebhook", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newWebhookResponse(sub))
+}
+
+// Lists the webhook subscriptions of the API key.
+func (h *Handlers) ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	resp := WebhookListResponse{Webhooks: []WebhookResponse{}}
+	for _, sub := range h.webhooks.Store().List(keys.KeyID(r.Context())) {
+		resp.Webhooks = append(resp.Webhooks, newWebhookResponse(sub))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Removes a webhook subscription of the API key.
+func (h *Handlers) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	params := WebhookParams{ID: r.PathValue("id")}
+	if err := validate.Struct(&params); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	ok, err := h.webhooks.Store().Delete(keys.KeyID(r.Context()), params.ID)
+	if err != nil {
+		writeError(w, r, "DeleteWebhook", err)
+		return
+	}
+	if !ok {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "webhook not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Lists the recent webhook deliveries of the API key, newest first, with
+// every attempt made.
+func (h *Handlers) WebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(DeliveriesResponse{Deliveries: h.webhooks.Deliveries(keys.KeyID(r.Context()))})
+}
+
+// Returns the public view of sub, without its secret.
+func newWebhookResponse(sub webhooks.Subscription) WebhookResponse {
+	events := sub.Events
+	if len(events) == 0 {
+		events = jobs.EventTypes
+	}
+
+	return WebhookResponse{ID: sub.ID, URL: sub.URL, Events: events, CreatedAt: sub.CreatedAt}
+}
@@ -0,0 +1,135 @@
+// Package apierror writes API error responses in a single JSON envelope:
+//
+//	{"error": {"code": "not_found", "message": "...", "request_id": "...", "retryable": false, "details": ...}}
+//
+// Requests outside /api that do not ask for JSON get a plain-text body instead.
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Codes shared by every endpoint. Endpoints may add their own, such as the
+// extractor codes of core.ErrorCode.
+const (
+	CodeBadRequest        = "bad_request"
+	CodeValidationFailed  = "validation_failed"
+	CodeUnauthorized      = "unauthorized"
+	CodeForbidden         = "forbidden"
+	CodeNotFound          = "not_found"
+	CodeMethodNotAllowed  = "method_not_allowed"
+	CodeConflict          = "conflict"
+	CodePayloadTooLarge   = "payload_too_large"
+	CodeUnprocessable     = "unprocessable_entity"
+	CodeTooManyRequests   = "too_many_requests"
+	CodeTemporarilyBanned = "temporarily_banned"
+	CodeTimeout           = "request_timeout"
+	CodeInternal          = "internal_error"
+	CodeUnavailable       = "service_unavailable"
+)
+
+// Error is the content of the envelope.
+type Error struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Retryable bool   `json:"retryable"`
+	Details   any    `json:"details,omitempty"`
+}
+
+// Response is the JSON body of every API error.
+type Response struct {
+	Error Error `json:"error"`
+}
+
+// Write sends an error with the given status, code and message.
+func Write(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	WriteError(w, r, status, Error{Code: code, Message: message})
+}
+
+// WriteError sends e with the given status, filling in the request ID.
+func WriteError(w http.ResponseWriter, r *http.Request, status int, e Error) {
+	if !WantsJSON(r) {
+		http.Error(w, e.Message, status)
+		return
+	}
+
+	if e.RequestID == "" {
+		e.RequestID = RequestID(r.Context())
+	}
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(Response{Error: e})
+}
+
+// Reports whether the error for r should be JSON: API paths always get JSON,
+// other paths only when the Accept header asks for it.
+func WantsJSON(r *http.Request) bool {
+	if r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") {
+		return true
+	}
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mt, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && mt == "application/json" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the generic code for an HTTP status.
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusTooManyRequests:
+		return CodeTooManyRequests
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	}
+
+	if status >= 500 {
+		return CodeInternal
+	}
+
+	return CodeBadRequest
+}
+
+type requestIDKey struct{}
+
+// Returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// Returns the request ID stored in ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,106 @@
+package apierror_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+)
+
+func TestWantsJSON(t *testing.T) {
+	tests := []struct {
+		path   string
+		accept string
+		want   bool
+	}{
+		{"/api/v1/video/info", "", true},
+		{"/api", "", true},
+		{"/", "", false},
+		{"/apix", "", false},
+		{"/", "text/html", false},
+		{"/", "text/html, application/json;q=0.9", true},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+
+		if got := apierror.WantsJSON(r); got != tt.want {
+			t.Errorf("WantsJSON(%q, %q) = %v, want %v", tt.path, tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestWriteEnvelope(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/video/info", nil)
+	r = r.WithContext(apierror.WithRequestID(r.Context(), "req-1"))
+
+	w := httptest.NewRecorder()
+	apierror.WriteError(w, r, http.StatusBadRequest, apierror.Error{
+		Code:    apierror.CodeBadRequest,
+		Message: "bad url",
+		Details: map[string]string{"field": "url"},
+	})
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected application/json, got %q", ct)
+	}
+
+	var body struct {
+		Error struct {
+			Code      string            `json:"code"`
+			Message   string            `json:"message"`
+			RequestID string            `json:"request_id"`
+			Retryable bool              `json:"retryable"`
+			Details   map[string]string `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+
+	e := body.Error
+	if e.Code != "bad_request" || e.Message != "bad url" || e.RequestID != "req-1" || e.Details["field"] != "url" {
+		t.Errorf("unexpected envelope %+v", e)
+	}
+}
+
+func TestWritePlainTextOutsideAPI(t *testing.T) {
+	w := httptest.NewRecorder()
+	apierror.Write(w, httptest.NewRequest(http.MethodGet, "/index.html", nil), http.StatusNotFound, apierror.CodeNotFound, "missing")
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("expected plain text, got %q", w.Header().Get("Content-Type"))
+	}
+	if strings.TrimSpace(w.Body.String()) != "missing" {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+}
+
+func TestCodeForStatus(t *testing.T) {
+	tests := map[int]string{
+		http.StatusNotFound:            apierror.CodeNotFound,
+		http.StatusMethodNotAllowed:    apierror.CodeMethodNotAllowed,
+		http.StatusTooManyRequests:     apierror.CodeTooManyRequests,
+		http.StatusBadGateway:          apierror.CodeInternal,
+		http.StatusTeapot:              apierror.CodeBadRequest,
+		http.StatusInternalServerError: apierror.CodeInternal,
+	}
+
+	for status, want := range tests {
+		if got := apierror.CodeForStatus(status); got != want {
+			t.Errorf("CodeForStatus(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
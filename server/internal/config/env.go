@@ -0,0 +1,99 @@
+package config
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Returns the value of the environment variable key, or def when it is unset or blank.
+func EnvString(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+
+	return def
+}
+
+// Returns the environment variable key parsed as an int64, or def when it is unset or invalid.
+func EnvInt64(key string, def int64) int64 {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		log.Printf("WARNING: invalid value %q for %s, using default %d", v, key, def)
+		return def
+	}
+
+	return n
+}
+
+// Returns the environment variable key parsed as a time.Duration (e.g. "90s", "6h"),
+// or def when it is unset or invalid.
+func EnvDuration(key string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("WARNING: invalid value %q for %s, using default %s", v, key, def)
+		return def
+	}
+
+	return d
+}
+
+// Returns the directory for persistent server state (API keys, subscriptions, ...).
+// Defaults to ../data relative to the working directory, next to ../scripts.
+func DataDir() string {
+	if dir := EnvString("DATA_DIR", ""); dir != "" {
+		return dir
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "data"
+	}
+
+	return filepath.Join(cwd, "..", "data")
+}
+
+// Returns the directory holding yt-dlp binaries installed through the update command.
+func YTDlpVersionsDir() string {
+	return EnvString("YT_DLP_VERSIONS_DIR", filepath.Join(DataDir(), "yt-dlp"))
+}
+
+// Returns the path of the API keys file.
+func APIKeysFile() string {
+	return EnvString("API_KEYS_FILE", filepath.Join(DataDir(), "api_keys.json"))
+}
+
+// Returns the secret share links are signed with. Share links are disabled when it is empty.
+func ShareLinkSecret() string {
+	return EnvString("SHARE_LINK_SECRET", "")
+}
+
+// Returns the path of the webhook subscriptions file.
+func WebhooksFile() string {
+	return EnvString("WEBHOOKS_FILE", filepath.Join(DataDir(), "webhooks.json"))
+}
+
+// Returns the private, loopback or link-local networks webhooks may still be
+// sent to, as a comma-separated list of addresses and CIDR prefixes.
+func WebhookAllowedNetworks() string {
+	return EnvString("WEBHOOK_ALLOWED_NETWORKS", "")
+}
+
+// Returns the path of the playlist and channel subscriptions file. Their
+// download archives go to an "archives" directory next to it.
+func SubscriptionsFile() string {
+	return EnvString("SUBSCRIPTIONS_FILE", filepath.Join(DataDir(), "subscriptions.json"))
+}
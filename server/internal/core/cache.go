@@ -0,0 +1,466 @@
+package core
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cacheFileExt    = ".bin"
+	cachePartialExt = ".part"
+	cacheMetaExt    = ".json"
+)
+
+// ErrNotCached is returned by DownloadCache.OpenFile for downloads that are
+// not complete in the cache.
+var ErrNotCached = errors.New("download is not in the cache")
+
+// CacheStatus reports how a download was served by the DownloadCache.
+type CacheStatus string
+
+const (
+	CacheHit       CacheStatus = "HIT"       // served from a completed file on disk
+	CacheMiss      CacheStatus = "MISS"      // started a new upstream download
+	CacheCoalesced CacheStatus = "COALESCED" // joined an identical download already in progress
+)
+
+// DownloadCacheKey identifies a download output independently of how the URL was written.
+type DownloadCacheKey struct {
+	Extractor string // Ex: "youtube", "generic"
+	VideoID   string
+	Format    string // resolved yt-dlp format selector
+	Options   string // container and post-processing options
+}
+
+// Returns the cache key for cfg once the video has been identified.
+func NewDownloadCacheKey(extractor, videoID string, cfg DownloadConfig) (DownloadCacheKey, error) {
+	fmtSel, err := FormatSelector(cfg)
+	if err != nil {
+		return DownloadCacheKey{}, err
+	}
+
+	return DownloadCacheKey{
+		Extractor: extractor,
+		VideoID:   videoID,
+		Format:    fmtSel,
+		Options:   strings.Join(containerArgs(cfg), " "),
+	}, nil
+}
+
+// Hash returns the content address of the key, used as the on-disk file name.
+func (k DownloadCacheKey) Hash() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{k.Extractor, k.VideoID, k.Format, k.Options}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// FillFunc writes the full download to dst.
+type FillFunc func(ctx context.Context, dst io.Writer) error
+
+// DownloadCache stores finished downloads on disk keyed by DownloadCacheKey and
+// coalesces concurrent identical downloads into a single upstream fetch whose
+// output is streamed to every waiting reader as it arrives.
+type DownloadCache struct {
+	Dir         string
+	MaxBytes    int64
+	MaxAge      time.Duration
+	FillTimeout time.Duration
+
+	mu       sync.Mutex
+	lru      *list.List // of *cacheEntry, most recently used first
+	entries  map[string]*list.Element
+	size     int64
+	inflight map[string]*inflightDownload
+}
+
+type cacheEntry struct {
+	hash    string
+	size    int64
+	created time.Time
+}
+
+// Creates the cache directory and indexes files left by a previous run.
+func NewDownloadCache(dir string, maxBytes int64, maxAge time.Duration) (*DownloadCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	c := &DownloadCache{
+		Dir:         dir,
+		MaxBytes:    maxBytes,
+		MaxAge:      maxAge,
+		FillTimeout: 10 * time.Minute,
+		lru:         list.New(),
+		entries:     map[string]*list.Element{},
+		inflight:    map[string]*inflightDownload{},
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %v", err)
+	}
+
+	for _, de := range dirEntries {
+		name := de.Name()
+
+		if strings.HasSuffix(name, cachePartialExt) {
+			_ = os.Remove(filepath.Join(dir, name))
+			continue
+		}
+
+		if de.IsDir() || !strings.HasSuffix(name, cacheFileExt) {
+			continue
+		}
+
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+
+		c.add(&cacheEntry{
+			hash:    strings.TrimSuffix(name, cacheFileExt),
+			size:    info.Size(),
+			created: info.ModTime(),
+		})
+	}
+
+	// metas of downloads that never completed
+	for _, de := range dirEntries {
+		if hash, ok := strings.CutSuffix(de.Name(), cacheMetaExt); ok && c.entries[hash] == nil {
+			_ = os.Remove(filepath.Join(dir, de.Name()))
+		}
+	}
+
+	c.mu.Lock()
+	c.evictLocked()
+	c.mu.Unlock()
+
+	return c, nil
+}
+
+// Open returns a reader for the download identified by key. Completed entries are
+// read from disk; otherwise fill is started once in the background and every
+// concurrent caller for the same key reads its output while it is being written.
+func (c *DownloadCache) Open(ctx context.Context, key DownloadCacheKey, fill FillFunc) (io.ReadCloser, CacheStatus, error) {
+	hash := key.Hash()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[hash]; ok {
+		entry := el.Value.(*cacheEntry)
+
+		if c.MaxAge > 0 && time.Since(entry.created) > c.MaxAge {
+			c.removeLocked(el)
+		} else if f, err := os.Open(c.path(hash)); err == nil {
+			c.lru.MoveToFront(el)
+			return f, CacheHit, nil
+		} else {
+			c.removeLocked(el)
+		}
+	}
+
+	if inf, ok := c.inflight[hash]; ok {
+		r, err := inf.newReader(ctx)
+		return r, CacheCoalesced, err
+	}
+
+	f, err := os.Create(c.path(hash) + cachePartialExt)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create cache file: %v", err)
+	}
+
+	inf := &inflightDownload{path: f.Name(), notify: make(chan struct{})}
+
+	r, err := inf.newReader(ctx)
+	if err != nil {
+		f.Close()
+		_ = os.Remove(f.Name())
+		return nil, "", err
+	}
+
+	c.inflight[hash] = inf
+
+	go c.fill(hash, f, inf, fill)
+
+	return r, CacheMiss, nil
+}
+
+func (c *DownloadCache) fill(hash string, f *os.File, inf *inflightDownload, fill FillFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.FillTimeout)
+	defer cancel()
+
+	err := fill(ctx, &inflightWriter{f: f, inf: inf})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	var size int64
+	if err == nil {
+		var info os.FileInfo
+		if info, err = os.Stat(f.Name()); err == nil {
+			size = info.Size()
+			err = os.Rename(f.Name(), c.path(hash))
+		}
+	}
+
+	if err != nil {
+		log.Printf("ERROR: download cache fill failed for %s: %v", hash, err)
+		_ = os.Remove(f.Name())
+		_ = os.Remove(c.metaPath(hash))
+	}
+
+	c.mu.Lock()
+	delete(c.inflight, hash)
+	if err == nil {
+		c.add(&cacheEntry{hash: hash, size: size, created: time.Now()})
+		c.evictLocked()
+	}
+	c.mu.Unlock()
+
+	inf.finish(err)
+}
+
+// Removes every completed entry from the cache, returning how many were removed.
+func (c *DownloadCache) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.lru.Len()
+	for c.lru.Len() > 0 {
+		c.removeLocked(c.lru.Back())
+	}
+
+	return n
+}
+
+// Returns the number of completed entries and their total size in bytes.
+func (c *DownloadCache) Stats() (entries int, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len(), c.size
+}
+
+func (c *DownloadCache) path(hash string) string {
+	return filepath.Join(c.Dir, hash+cacheFileExt)
+}
+
+func (c *DownloadCache) metaPath(hash string) string {
+	return filepath.Join(c.Dir, hash+cacheMetaExt)
+}
+
+// DownloadMeta describes a cached download to clients fetching it again by
+// its hash, see DownloadCache.OpenFile.
+type DownloadMeta struct {
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	ModTime     time.Time `json:"-"` // when the download completed
+}
+
+// Returns the completed download with the given hash, from
+// DownloadCacheKey.Hash, and the meta saved for it. It returns ErrNotCached
+// for unknown hashes and for downloads still being written.
+func (c *DownloadCache) OpenFile(hash string) (*os.File, DownloadMeta, error) {
+	var meta DownloadMeta
+	if !isCacheHash(hash) {
+		return nil, meta, ErrNotCached
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[hash]
+	if !ok {
+		return nil, meta, ErrNotCached
+	}
+
+	entry := el.Value.(*cacheEntry)
+	if c.MaxAge > 0 && time.Since(entry.created) > c.MaxAge {
+		c.removeLocked(el)
+		return nil, meta, ErrNotCached
+	}
+
+	f, err := os.Open(c.path(hash))
+	if err != nil {
+		c.removeLocked(el)
+		return nil, meta, ErrNotCached
+	}
+	c.lru.MoveToFront(el)
+
+	if data, err := os.ReadFile(c.metaPath(hash)); err == nil {
+		_ = json.Unmarshal(data, &meta)
+	}
+	meta.ModTime = entry.created
+
+	return f, meta, nil
+}
+
+// Saves meta for the download with the given hash; it is removed along with
+// the download.
+func (c *DownloadCache) SetMeta(hash string, meta DownloadMeta) error {
+	if !isCacheHash(hash) {
+		return fmt.Errorf("invalid cache hash %q", hash)
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	tmp := c.metaPath(hash) + cachePartialExt
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write cache meta: %v", err)
+	}
+
+	return os.Rename(tmp, c.metaPath(hash))
+}
+
+func isCacheHash(s string) bool {
+	return len(s) == sha256.Size*2 && strings.Trim(s, "0123456789abcdef") == ""
+}
+
+func (c *DownloadCache) add(entry *cacheEntry) {
+	if el, ok := c.entries[entry.hash]; ok {
+		c.size -= el.Value.(*cacheEntry).size
+		c.lru.Remove(el)
+	}
+
+	c.entries[entry.hash] = c.lru.PushFront(entry)
+	c.size += entry.size
+}
+
+func (c *DownloadCache) removeLocked(el *list.Element) {
+	entry := el.Value.(*cacheEntry)
+
+	c.lru.Remove(el)
+	delete(c.entries, entry.hash)
+	c.size -= entry.size
+
+	// Readers that already opened the file keep their handle on Unix-like systems.
+	if err := os.Remove(c.path(entry.hash)); err != nil && !os.IsNotExist(err) {
+		log.Printf("WARNING: failed to remove cache file %s: %v", entry.hash, err)
+	}
+	_ = os.Remove(c.metaPath(entry.hash))
+}
+
+func (c *DownloadCache) evictLocked() {
+	now := time.Now()
+
+	for el := c.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if c.MaxAge > 0 && now.Sub(el.Value.(*cacheEntry).created) > c.MaxAge {
+			c.removeLocked(el)
+		}
+		el = prev
+	}
+
+	for c.MaxBytes > 0 && c.size > c.MaxBytes && c.lru.Len() > 0 {
+		c.removeLocked(c.lru.Back())
+	}
+}
+
+// inflightDownload tracks a download that is still being written to disk.
+type inflightDownload struct {
+	path string
+
+	mu     sync.Mutex
+	notify chan struct{} // closed and replaced whenever data is written or the download ends
+	done   bool
+	err    error
+}
+
+func (inf *inflightDownload) changed() (<-chan struct{}, bool, error) {
+	inf.mu.Lock()
+	defer inf.mu.Unlock()
+
+	return inf.notify, inf.done, inf.err
+}
+
+func (inf *inflightDownload) signal() {
+	inf.mu.Lock()
+	close(inf.notify)
+	inf.notify = make(chan struct{})
+	inf.mu.Unlock()
+}
+
+func (inf *inflightDownload) finish(err error) {
+	inf.mu.Lock()
+	inf.done = true
+	inf.err = err
+	close(inf.notify)
+	inf.notify = make(chan struct{})
+	inf.mu.Unlock()
+}
+
+func (inf *inflightDownload) newReader(ctx context.Context) (io.ReadCloser, error) {
+	f, err := os.Open(inf.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open in-flight download: %v", err)
+	}
+
+	return &inflightReader{ctx: ctx, f: f, inf: inf}, nil
+}
+
+type inflightWriter struct {
+	f   *os.File
+	inf *inflightDownload
+}
+
+func (w *inflightWriter) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	if n > 0 {
+		w.inf.signal()
+	}
+
+	return n, err
+}
+
+// inflightReader follows a file that is still being written, blocking at the end
+// of the written data until more arrives or the download finishes.
+type inflightReader struct {
+	ctx context.Context
+	f   *os.File
+	inf *inflightDownload
+}
+
+func (r *inflightReader) Read(p []byte) (int, error) {
+	for {
+		// Capture the notification channel before reading so that a write
+		// landing between the read and the wait is never missed.
+		changed, done, doneErr := r.inf.changed()
+
+		n, err := r.f.Read(p)
+		if n > 0 || (err != nil && err != io.EOF) {
+			return n, err
+		}
+
+		if done {
+			if doneErr != nil {
+				return 0, doneErr
+			}
+			return 0, io.EOF
+		}
+
+		select {
+		case <-changed:
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		}
+	}
+}
+
+func (r *inflightReader) Close() error {
+	return r.f.Close()
+}
@@ -0,0 +1,276 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+func cacheKey(id string) core.DownloadCacheKey {
+	return core.DownloadCacheKey{Extractor: "test", VideoID: id, Format: "b", Options: ""}
+}
+
+func readAll(t *testing.T, r io.ReadCloser) string {
+	t.Helper()
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+
+	return string(b)
+}
+
+func TestDownloadCacheMissThenHit(t *testing.T) {
+	c, err := core.NewDownloadCache(t.TempDir(), 1<<20, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var calls int32
+	fill := func(ctx context.Context, dst io.Writer) error {
+		atomic.AddInt32(&calls, 1)
+		_, err := io.WriteString(dst, "STREAMDATA")
+		return err
+	}
+
+	r, status, err := c.Open(context.Background(), cacheKey("a"), fill)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status != core.CacheMiss {
+		t.Fatalf("expected MISS, got %s", status)
+	}
+	if got := readAll(t, r); got != "STREAMDATA" {
+		t.Fatalf("unexpected body: %q", got)
+	}
+
+	r, status, err = c.Open(context.Background(), cacheKey("a"), fill)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status != core.CacheHit {
+		t.Fatalf("expected HIT, got %s", status)
+	}
+	if got := readAll(t, r); got != "STREAMDATA" {
+		t.Fatalf("unexpected body: %q", got)
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected fill to run once, ran %d times", calls)
+	}
+}
+
+func TestDownloadCacheCoalescesConcurrentDownloads(t *testing.T) {
+	c, err := core.NewDownloadCache(t.TempDir(), 1<<20, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	release := make(chan struct{})
+	var calls int32
+	fill := func(ctx context.Context, dst io.Writer) error {
+		atomic.AddInt32(&calls, 1)
+		io.WriteString(dst, "PART1-")
+		<-release
+		_, err := io.WriteString(dst, "PART2")
+		return err
+	}
+
+	const clients = 5
+	readers := make([]io.ReadCloser, clients)
+	for i := range readers {
+		r, status, err := c.Open(context.Background(), cacheKey("b"), fill)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if i > 0 && status != core.CacheCoalesced {
+			t.Fatalf("expected COALESCED for client %d, got %s", i, status)
+		}
+		readers[i] = r
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, clients)
+	for i, r := range readers {
+		wg.Add(1)
+		go func(i int, r io.ReadCloser) {
+			defer wg.Done()
+			defer r.Close()
+			b, _ := io.ReadAll(r)
+			results[i] = string(b)
+		}(i, r)
+	}
+
+	close(release)
+	wg.Wait()
+
+	for i, got := range results {
+		if got != "PART1-PART2" {
+			t.Fatalf("client %d got %q", i, got)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected a single upstream download, got %d", calls)
+	}
+}
+
+func TestDownloadCacheFillErrorReachesReaders(t *testing.T) {
+	c, err := core.NewDownloadCache(t.TempDir(), 1<<20, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fillErr := errors.New("upstream failed")
+	fill := func(ctx context.Context, dst io.Writer) error {
+		io.WriteString(dst, "partial")
+		return fillErr
+	}
+
+	r, _, err := c.Open(context.Background(), cacheKey("c"), fill)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+
+	if _, err := io.ReadAll(r); !errors.Is(err, fillErr) {
+		t.Fatalf("expected fill error, got %v", err)
+	}
+
+	if n, _ := c.Stats(); n != 0 {
+		t.Fatalf("expected failed download not to be cached, got %d entries", n)
+	}
+}
+
+func TestDownloadCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c, err := core.NewDownloadCache(t.TempDir(), 10, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fill := func(ctx context.Context, dst io.Writer) error {
+		_, err := io.WriteString(dst, "123456")
+		return err
+	}
+
+	for _, id := range []string{"x", "y"} {
+		r, _, err := c.Open(context.Background(), cacheKey(id), fill)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		readAll(t, r)
+	}
+
+	n, size := c.Stats()
+	if n != 1 || size != 6 {
+		t.Fatalf("expected 1 entry of 6 bytes after eviction, got %d entries, %d bytes", n, size)
+	}
+
+	r, status, _ := c.Open(context.Background(), cacheKey("y"), fill)
+	readAll(t, r)
+	if status != core.CacheHit {
+		t.Fatalf("expected most recent entry to survive eviction, got %s", status)
+	}
+}
+
+func TestDownloadCacheMaxAge(t *testing.T) {
+	c, err := core.NewDownloadCache(t.TempDir(), 1<<20, time.Nanosecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fill := func(ctx context.Context, dst io.Writer) error {
+		_, err := io.WriteString(dst, "data")
+		return err
+	}
+
+	r, _, _ := c.Open(context.Background(), cacheKey("z"), fill)
+	readAll(t, r)
+
+	time.Sleep(time.Millisecond)
+
+	r, status, _ := c.Open(context.Background(), cacheKey("z"), fill)
+	readAll(t, r)
+	if status != core.CacheMiss {
+		t.Fatalf("expected expired entry to be a MISS, got %s", status)
+	}
+}
+
+func TestNewDownloadCacheKeyDependsOnFormat(t *testing.T) {
+	a, err := core.NewDownloadCacheKey("youtube", "id", core.DownloadConfig{Type: core.Video, Quality: 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b, _ := core.NewDownloadCacheKey("youtube", "id", core.DownloadConfig{Type: core.Video, Quality: 5})
+	if a.Hash() == b.Hash() {
+		t.Fatalf("expected different qualities to produce different keys")
+	}
+
+	c, _ := core.NewDownloadCacheKey("youtube", "id", core.DownloadConfig{URL: "http://other", Type: core.Video, Quality: 4})
+	if a.Hash() != c.Hash() {
+		t.Fatalf("expected the key to ignore the URL spelling")
+	}
+}
+
+func TestDownloadCacheOpenFile(t *testing.T) {
+	dir := t.TempDir()
+	c, err := core.NewDownloadCache(dir, 1<<20, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	key := cacheKey("a").Hash()
+	if _, _, err := c.OpenFile(key); !errors.Is(err, core.ErrNotCached) {
+		t.Fatalf("expected ErrNotCached before the download, got %v", err)
+	}
+	if _, _, err := c.OpenFile("../" + key[3:]); !errors.Is(err, core.ErrNotCached) {
+		t.Fatalf("expected ErrNotCached for an invalid hash, got %v", err)
+	}
+
+	release := make(chan struct{})
+	r, _, err := c.Open(context.Background(), cacheKey("a"), func(ctx context.Context, dst io.Writer) error {
+		<-release
+		_, err := io.WriteString(dst, "STREAMDATA")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.SetMeta(key, core.DownloadMeta{FileName: "a.mp4", ContentType: "video/mp4"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, _, err := c.OpenFile(key); !errors.Is(err, core.ErrNotCached) {
+		t.Fatalf("expected ErrNotCached while downloading, got %v", err)
+	}
+
+	close(release)
+	readAll(t, r)
+
+	f, meta, err := c.OpenFile(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := readAll(t, f); got != "STREAMDATA" {
+		t.Fatalf("unexpected body: %q", got)
+	}
+	if meta.FileName != "a.mp4" || meta.ContentType != "video/mp4" || meta.ModTime.IsZero() {
+		t.Fatalf("unexpected meta %+v", meta)
+	}
+
+	// the meta goes away with the download
+	c.Purge()
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("expected an empty cache directory, got %v (%v)", entries, err)
+	}
+}
@@ -0,0 +1,106 @@
+package core
+
+import "bytes"
+
+// SniffLen is how many leading bytes SniffContainer needs to recognize every
+// container it knows.
+const SniffLen = 512
+
+// Returns the file extension of the media container that head, the start of a
+// download, belongs to. audio picks the audio-only name of containers that
+// have one, Ex: "m4a" instead of "mp4". It returns "" for unknown data.
+func SniffContainer(head []byte, audio bool) string {
+	switch {
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		brand := string(head[8:11])
+		switch {
+		case brand == "3gp" || brand == "3g2":
+			return "3gp"
+		case audio || string(head[8:12]) == "M4A ":
+			return "m4a"
+		}
+		return "mp4"
+
+	case bytes.HasPrefix(head, []byte{0x1a, 0x45, 0xdf, 0xa3}):
+		// EBML header; the DocType tells WebM from other Matroska files
+		if bytes.Contains(head, []byte("webm")) {
+			return "webm"
+		}
+		if audio {
+			return "mka"
+		}
+		return "mkv"
+
+	case bytes.HasPrefix(head, []byte("OggS")):
+		if bytes.Contains(head, []byte("OpusHead")) {
+			return "opus"
+		}
+		return "ogg"
+
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return "flac"
+
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return "wav"
+
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return "mp3"
+
+	case bytes.HasPrefix(head, []byte("FLV")):
+		return "flv"
+
+	case len(head) >= 2 && head[0] == 0xff && head[1]&0xf0 == 0xf0:
+		// ADTS frames have layer bits 00, MPEG audio frames do not
+		if head[1]&0x06 == 0 {
+			return "aac"
+		}
+		return "mp3"
+
+	case isTransportStream(head):
+		return "ts"
+	}
+
+	return ""
+}
+
+// Reports whether head starts with two MPEG-TS packets: 188 bytes each,
+// beginning with the sync byte 0x47.
+func isTransportStream(head []byte) bool {
+	return len(head) > 188 && head[0] == 0x47 && head[188] == 0x47
+}
+
+// containerMIMETypes maps the extensions returned by SniffContainer to their
+// media types; audio variants are listed under "audio/" + ext.
+var containerMIMETypes = map[string]string{
+	"mp4":  "video/mp4",
+	"m4a":  "audio/mp4",
+	"3gp":  "video/3gpp",
+	"webm": "video/webm",
+	"mkv":  "video/x-matroska",
+	"mka":  "audio/x-matroska",
+	"ogg":  "audio/ogg",
+	"opus": "audio/ogg",
+	"flac": "audio/flac",
+	"wav":  "audio/wav",
+	"mp3":  "audio/mpeg",
+	"aac":  "audio/aac",
+	"flv":  "video/x-flv",
+	"ts":   "video/mp2t",
+
+	"audio/webm": "audio/webm",
+	"audio/3gp":  "audio/3gpp",
+}
+
+// Returns the media type of a container extension returned by SniffContainer,
+// or "application/octet-stream" for unknown ones. audio selects the audio
+// type of containers that hold either, Ex: "audio/webm".
+func ContainerMIMEType(ext string, audio bool) string {
+	if t, ok := containerMIMETypes["audio/"+ext]; audio && ok {
+		return t
+	}
+	if t, ok := containerMIMETypes[ext]; ok {
+		return t
+	}
+
+	return "application/octet-stream"
+}
@@ -0,0 +1,63 @@
+package core_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+func TestSniffContainer(t *testing.T) {
+	ts := make([]byte, 400)
+	ts[0], ts[188] = 0x47, 0x47
+
+	for _, tc := range []struct {
+		name  string
+		head  []byte
+		audio bool
+		want  string
+	}{
+		{"mp4", []byte("\x00\x00\x00\x20ftypisom\x00\x00\x02\x00"), false, "mp4"},
+		{"m4a brand", []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x02\x00"), false, "m4a"},
+		{"mp4 audio", []byte("\x00\x00\x00\x18ftypdash\x00\x00\x00\x00"), true, "m4a"},
+		{"3gp", []byte("\x00\x00\x00\x14ftyp3gp5\x00\x00\x00\x00"), false, "3gp"},
+		{"webm", append([]byte{0x1a, 0x45, 0xdf, 0xa3, 0x9f, 0x42, 0x82, 0x84}, "webm"...), true, "webm"},
+		{"mkv", append([]byte{0x1a, 0x45, 0xdf, 0xa3, 0xa3, 0x42, 0x82, 0x88}, "matroska"...), false, "mkv"},
+		{"mka", append([]byte{0x1a, 0x45, 0xdf, 0xa3, 0xa3, 0x42, 0x82, 0x88}, "matroska"...), true, "mka"},
+		{"opus", []byte("OggS\x00\x02" + string(bytes.Repeat([]byte{0}, 22)) + "OpusHead"), true, "opus"},
+		{"vorbis", []byte("OggS\x00\x02\x00\x00\x00\x00\x01vorbis"), true, "ogg"},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), true, "flac"},
+		{"wav", []byte("RIFF\x24\x08\x00\x00WAVEfmt "), true, "wav"},
+		{"mp3 id3", []byte("ID3\x04\x00\x00"), true, "mp3"},
+		{"mp3 frame", []byte{0xff, 0xfb, 0x90, 0x64}, true, "mp3"},
+		{"adts", []byte{0xff, 0xf1, 0x50, 0x80}, true, "aac"},
+		{"flv", []byte("FLV\x01\x05"), false, "flv"},
+		{"mpeg-ts", ts, false, "ts"},
+		{"unknown", []byte("media"), false, ""},
+		{"empty", nil, false, ""},
+	} {
+		if got := core.SniffContainer(tc.head, tc.audio); got != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestContainerMIMEType(t *testing.T) {
+	for _, tc := range []struct {
+		ext   string
+		audio bool
+		want  string
+	}{
+		{"mp4", false, "video/mp4"},
+		{"m4a", true, "audio/mp4"},
+		{"webm", false, "video/webm"},
+		{"webm", true, "audio/webm"},
+		{"mp3", true, "audio/mpeg"},
+		{"mkv", true, "video/x-matroska"},
+		{"", false, "application/octet-stream"},
+	} {
+		if got := core.ContainerMIMEType(tc.ext, tc.audio); got != tc.want {
+			t.Errorf("%q (audio %t): expected %s, got %s", tc.ext, tc.audio, tc.want, got)
+		}
+	}
+}
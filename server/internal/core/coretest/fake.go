@@ -0,0 +1,323 @@
+// Package coretest provides an in-memory core.Extractor for tests.
+package coretest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+// ErrNotScripted is returned for URLs the Fake has no script for.
+var ErrNotScripted = errors.New("coretest: no scripted response")
+
+// Download scripts the result of Fake.Download.
+type Download struct {
+	Data     []byte
+	Progress []string        // sent to DownloadConfig.Progress before any data
+	StartErr error           // returned by Download itself, before a stream exists
+	Err      error           // returned by Read once Data has been consumed
+	Block    <-chan struct{} // when set, the first Read waits until it is closed
+
+	Files []File // written by DownloadChapters, in order
+}
+
+// File is a file written by Fake.DownloadChapters.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Fake is a scripted core.Extractor. Responses are keyed by URL; a response
+// set for the empty URL applies to every URL without its own script.
+// It is safe for concurrent use.
+type Fake struct {
+	mu        sync.Mutex
+	version   string
+	infos     map[string]infoScript
+	downloads map[string]Download
+	entries   map[string]entriesScript
+
+	infoCalls     map[string]int
+	downloadCalls []core.DownloadConfig
+}
+
+type infoScript struct {
+	raw []byte
+	err error
+}
+
+type entriesScript struct {
+	list []core.PlaylistEntry
+	err  error
+}
+
+var _ core.Extractor = (*Fake)(nil)
+
+func NewFake() *Fake {
+	return &Fake{
+		version:   "coretest",
+		infos:     map[string]infoScript{},
+		downloads: map[string]Download{},
+		entries:   map[string]entriesScript{},
+		infoCalls: map[string]int{},
+	}
+}
+
+// SetVersion sets what Version returns.
+func (f *Fake) SetVersion(v string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.version = v
+}
+
+// SetInfo scripts Info for url with rawJSON, as printed by yt-dlp --dump-json.
+func (f *Fake) SetInfo(url, rawJSON string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.infos[url] = infoScript{raw: []byte(rawJSON)}
+}
+
+// SetInfoError makes Info fail with err for url.
+func (f *Fake) SetInfoError(url string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.infos[url] = infoScript{err: err}
+}
+
+// SetDownload scripts Download for url.
+func (f *Fake) SetDownload(url string, d Download) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.downloads[url] = d
+}
+
+// SetEntries scripts ListEntries for url with the items of the playlist.
+func (f *Fake) SetEntries(url string, entries ...core.PlaylistEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.entries[url] = entriesScript{list: entries}
+}
+
+// SetEntriesError makes ListEntries fail with err for url.
+func (f *Fake) SetEntriesError(url string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.entries[url] = entriesScript{err: err}
+}
+
+// InfoCalls returns how many times Info or ListFormats was called for url.
+func (f *Fake) InfoCalls(url string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.infoCalls[url]
+}
+
+// Downloads returns the configs Download was called with, in order.
+func (f *Fake) Downloads() []core.DownloadConfig {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]core.DownloadConfig(nil), f.downloadCalls...)
+}
+
+func (f *Fake) Info(ctx context.Context, url string) (*core.VideoInfo, error) {
+	f.mu.Lock()
+	f.infoCalls[url]++
+	script, ok := lookup(f.infos, url)
+	f.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%w for info of %s", ErrNotScripted, url)
+	case script.err != nil:
+		return nil, script.err
+	default:
+		return core.ParseVideoInfo(script.raw)
+	}
+}
+
+func (f *Fake) ListFormats(ctx context.Context, url string) ([]core.Format, error) {
+	info, err := f.Info(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	return info.Formats, nil
+}
+
+func (f *Fake) Download(ctx context.Context, cfg core.DownloadConfig) (io.ReadCloser, error) {
+	f.mu.Lock()
+	f.downloadCalls = append(f.downloadCalls, cfg)
+	d, ok := lookup(f.downloads, cfg.URL)
+	f.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w for download of %s", ErrNotScripted, cfg.URL)
+	}
+	if d.StartErr != nil {
+		return nil, d.StartErr
+	}
+
+	if cfg.Progress != nil {
+		for _, line := range d.Progress {
+			cfg.Progress(line)
+		}
+	}
+
+	return &stream{ctx: ctx, data: bytes.NewReader(d.Data), err: d.Err, block: d.Block}, nil
+}
+
+func (f *Fake) DownloadChapters(ctx context.Context, cfg core.DownloadConfig, dir string) ([]string, error) {
+	f.mu.Lock()
+	f.downloadCalls = append(f.downloadCalls, cfg)
+	d, ok := lookup(f.downloads, cfg.URL)
+	f.mu.Unlock()
+
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%w for download of %s", ErrNotScripted, cfg.URL)
+	case d.StartErr != nil:
+		return nil, d.StartErr
+	case d.Err != nil:
+		return nil, d.Err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Progress != nil {
+		for _, line := range d.Progress {
+			cfg.Progress(line)
+		}
+	}
+
+	paths := make([]string, 0, len(d.Files))
+	for _, file := range d.Files {
+		path := filepath.Join(dir, file.Name)
+		if err := os.WriteFile(path, file.Data, 0o644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// ListEntries returns the scripted entries of url, honouring opts like yt-dlp
+// does: the first opts.Limit entries, less those recorded in opts.Archive.
+func (f *Fake) ListEntries(ctx context.Context, url string, opts core.ListOptions) ([]core.PlaylistEntry, error) {
+	f.mu.Lock()
+	script, ok := lookup(f.entries, url)
+	f.mu.Unlock()
+
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%w for entries of %s", ErrNotScripted, url)
+	case script.err != nil:
+		return nil, script.err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	archived := map[string]bool{}
+	if opts.Archive != "" {
+		var err error
+		if archived, err = core.ReadArchive(opts.Archive); err != nil {
+			return nil, err
+		}
+	}
+
+	list := script.list
+	if opts.Limit > 0 && len(list) > opts.Limit {
+		list = list[:opts.Limit]
+	}
+
+	entries := []core.PlaylistEntry{}
+	for _, e := range list {
+		if !archived[e.ArchiveID()] {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+func (f *Fake) Version(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.version, ctx.Err()
+}
+
+// Returns the value for key, falling back to the catch-all empty key.
+func lookup[V any](m map[string]V, key string) (V, bool) {
+	if v, ok := m[key]; ok {
+		return v, true
+	}
+
+	v, ok := m[""]
+	return v, ok
+}
+
+// stream is the reader returned by Fake.Download.
+type stream struct {
+	ctx   context.Context
+	data  *bytes.Reader
+	err   error
+	block <-chan struct{}
+
+	closed atomic.Bool
+}
+
+func (s *stream) Read(p []byte) (int, error) {
+	if s.closed.Load() {
+		return 0, errors.New("coretest: read from closed stream")
+	}
+
+	if s.block != nil {
+		select {
+		case <-s.block:
+			s.block = nil
+		case <-s.ctx.Done():
+			return 0, s.ctx.Err()
+		}
+	}
+
+	if err := s.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := s.data.Read(p)
+	if err == io.EOF && s.err != nil {
+		return n, s.err
+	}
+
+	return n, err
+}
+
+func (s *stream) Close() error {
+	s.closed.Store(true)
+	return nil
+}
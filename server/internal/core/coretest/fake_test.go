@@ -0,0 +1,110 @@
+package coretest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/coretest"
+)
+
+func TestFakeInfo(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetInfo("https://a", `{"id":"a","formats":[{"format_id":"18"}]}`)
+	fake.SetInfoError("", errors.New("boom"))
+
+	formats, err := fake.ListFormats(context.Background(), "https://a")
+	if err != nil || len(formats) != 1 || formats[0].FormatID != "18" {
+		t.Fatalf("unexpected formats %+v, err %v", formats, err)
+	}
+
+	if _, err := fake.Info(context.Background(), "https://other"); err == nil || err.Error() != "boom" {
+		t.Fatalf("expected the catch-all error, got %v", err)
+	}
+
+	if n := fake.InfoCalls("https://a"); n != 1 {
+		t.Fatalf("expected 1 call, got %d", n)
+	}
+}
+
+func TestFakeDownload(t *testing.T) {
+	streamErr := errors.New("connection reset")
+
+	fake := coretest.NewFake()
+	fake.SetDownload("https://a", coretest.Download{
+		Data:     []byte("partial"),
+		Progress: []string{"[download]  50.0%", "[download] 100.0%"},
+		Err:      streamErr,
+	})
+
+	var lines []string
+	r, err := fake.Download(context.Background(), core.DownloadConfig{
+		URL:      "https://a",
+		Progress: func(line string) { lines = append(lines, line) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if string(data) != "partial" || !errors.Is(err, streamErr) {
+		t.Fatalf("expected partial data then the scripted error, got %q, %v", data, err)
+	}
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 progress lines, got %v", lines)
+	}
+
+	if _, err := fake.Download(context.Background(), core.DownloadConfig{URL: "https://b"}); !errors.Is(err, coretest.ErrNotScripted) {
+		t.Fatalf("expected ErrNotScripted, got %v", err)
+	}
+}
+
+func TestFakeDownloadChapters(t *testing.T) {
+	fake := coretest.NewFake()
+	fake.SetDownload("", coretest.Download{Files: []coretest.File{{Name: "001 - Intro.mkv", Data: []byte("one")}}})
+
+	dir := t.TempDir()
+	files, err := fake.DownloadChapters(context.Background(), core.DownloadConfig{URL: "https://a", SplitChapters: true}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0] != filepath.Join(dir, "001 - Intro.mkv") {
+		t.Fatalf("unexpected files %v", files)
+	}
+	if data, err := os.ReadFile(files[0]); err != nil || string(data) != "one" {
+		t.Fatalf("unexpected file content %q, %v", data, err)
+	}
+
+	if calls := fake.Downloads(); len(calls) != 1 || !calls[0].SplitChapters {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+}
+
+func TestFakeListEntries(t *testing.T) {
+	a := core.PlaylistEntry{ID: "a", URL: "https://a", IEKey: "Youtube"}
+	b := core.PlaylistEntry{ID: "b", URL: "https://b", IEKey: "Youtube"}
+	c := core.PlaylistEntry{ID: "c", URL: "https://c", IEKey: "Youtube"}
+
+	fake := coretest.NewFake()
+	fake.SetEntries("https://list", a, b, c)
+
+	archive := filepath.Join(t.TempDir(), "archive.txt")
+	if err := core.RecordArchive(archive, a); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := fake.ListEntries(context.Background(), "https://list", core.ListOptions{Archive: archive, Limit: 2})
+	if err != nil || len(entries) != 1 || entries[0].ID != "b" {
+		t.Fatalf("expected only b, got %+v, err %v", entries, err)
+	}
+
+	if _, err := fake.ListEntries(context.Background(), "https://other", core.ListOptions{}); !errors.Is(err, coretest.ErrNotScripted) {
+		t.Fatalf("expected ErrNotScripted, got %v", err)
+	}
+}
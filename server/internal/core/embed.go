@@ -0,0 +1,67 @@
+package core
+
+import "strings"
+
+// EmbedOptions select what yt-dlp writes into the downloaded file.
+type EmbedOptions struct {
+	Metadata  bool // title, artist, date and other tags
+	Thumbnail bool // cover art
+	Chapters  bool // chapter markers
+	InfoJSON  bool // the info JSON as an attachment; mkv/mka only
+}
+
+// MetadataOverrides replace tags taken from the video. They are written with
+// --embed-metadata, which they imply.
+type MetadataOverrides struct {
+	Title  string
+	Artist string
+	Album  string
+}
+
+// Reports whether cfg runs post-processors that need the media in a file.
+func (cfg DownloadConfig) needsFile() bool {
+	return cfg.Embed != (EmbedOptions{}) || cfg.Metadata != (MetadataOverrides{})
+}
+
+// Reports whether cfg asks for post-processing done by ffmpeg: embedding,
+// metadata, sections or split chapters. Merging formats needs it too, but that
+// depends on the formats yt-dlp picks.
+func (cfg DownloadConfig) NeedsFFmpeg() bool {
+	return cfg.needsFile() || len(cfg.Sections) > 0 || cfg.SplitChapters
+}
+
+func embedArgs(cfg DownloadConfig) []string {
+	var args []string
+
+	if cfg.Embed.Metadata || cfg.Metadata != (MetadataOverrides{}) {
+		args = append(args, "--embed-metadata")
+	}
+	if cfg.Embed.Thumbnail {
+		args = append(args, "--embed-thumbnail")
+	}
+	if cfg.Embed.Chapters {
+		args = append(args, "--embed-chapters")
+	}
+	if cfg.Embed.InfoJSON {
+		args = append(args, "--embed-info-json")
+	}
+
+	for _, o := range []struct{ field, value string }{
+		{"meta_title", cfg.Metadata.Title},
+		{"meta_artist", cfg.Metadata.Artist},
+		{"meta_album", cfg.Metadata.Album},
+	} {
+		if o.value != "" {
+			args = append(args, "--parse-metadata", metadataValue(o.value)+":%("+o.field+")s")
+		}
+	}
+
+	return args
+}
+
+// Returns value as the FROM part of --parse-metadata FROM:TO. FROM is an output
+// template, so '%' is doubled, and an unescaped ':' would end it.
+func metadataValue(value string) string {
+	value = strings.ReplaceAll(value, "%", "%%")
+	return strings.ReplaceAll(value, ":", `\:`)
+}
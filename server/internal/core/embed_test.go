@@ -0,0 +1,96 @@
+package core_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+func TestDownloadCacheKeyEmbedOptions(t *testing.T) {
+	key, err := core.NewDownloadCacheKey("Youtube", "abc", core.DownloadConfig{
+		Type:     core.Audio,
+		Embed:    core.EmbedOptions{Thumbnail: true, Chapters: true},
+		Metadata: core.MetadataOverrides{Title: "Live: 100%", Album: "Tour"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `--embed-metadata --embed-thumbnail --embed-chapters --parse-metadata Live\: 100%%:%(meta_title)s --parse-metadata Tour:%(meta_album)s`
+	if key.Options != want {
+		t.Fatalf("unexpected options:\n got %s\nwant %s", key.Options, want)
+	}
+}
+
+func TestDownloadConfigNeedsFFmpeg(t *testing.T) {
+	for _, tc := range []struct {
+		cfg  core.DownloadConfig
+		want bool
+	}{
+		{core.DownloadConfig{}, false},
+		{core.DownloadConfig{Embed: core.EmbedOptions{InfoJSON: true}}, true},
+		{core.DownloadConfig{Metadata: core.MetadataOverrides{Artist: "x"}}, true},
+		{core.DownloadConfig{Sections: []core.Section{{Start: 1}}}, true},
+		{core.DownloadConfig{SplitChapters: true}, true},
+	} {
+		if got := tc.cfg.NeedsFFmpeg(); got != tc.want {
+			t.Errorf("%+v: expected %v, got %v", tc.cfg, tc.want, got)
+		}
+	}
+}
+
+func TestDownloadWithEmbeddingStreamsTheFinishedFile(t *testing.T) {
+	fake := createFakeBin(t, `#!/bin/sh
+while [ $# -gt 0 ]; do
+	if [ "$1" = "-P" ]; then dir="$2"; fi
+	shift
+done
+echo -n "TAGGED" > "$dir/media.mkv"
+echo "[download] 100%" >&2
+echo "$dir/media.mkv"
+`)
+
+	yt := &core.YTCore{BinaryPath: fake}
+
+	var progress []string
+	r, err := yt.Download(context.Background(), core.DownloadConfig{
+		URL:      httpXUrl,
+		Type:     core.Video,
+		Embed:    core.EmbedOptions{Metadata: true},
+		Progress: func(line string) { progress = append(progress, line) },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != "TAGGED" {
+		t.Fatalf("unexpected content %q, %v", data, err)
+	}
+	if len(progress) != 1 || !strings.Contains(progress[0], "100%") {
+		t.Fatalf("unexpected progress %v", progress)
+	}
+
+	dir := filepath.Dir(r.(interface{ Name() string }).Name())
+	r.Close()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected the staging directory to be removed, got %v", err)
+	}
+}
+
+func TestDownloadWithEmbeddingReportsFailure(t *testing.T) {
+	fake := createFakeBin(t, `#!/bin/sh
+echo "ERROR: Postprocessing: ffmpeg not found" >&2
+exit 1
+`)
+
+	yt := &core.YTCore{BinaryPath: fake}
+	if _, err := yt.Download(context.Background(), core.DownloadConfig{URL: httpXUrl, Embed: core.EmbedOptions{Thumbnail: true}}); err == nil {
+		t.Fatal("expected the yt-dlp failure to be returned")
+	}
+}
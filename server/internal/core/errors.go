@@ -0,0 +1,154 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorCode identifies a class of extractor failure. The values are part of
+// the API and must not change.
+type ErrorCode string
+
+const (
+	CodeUnsupportedURL    ErrorCode = "unsupported_url"
+	CodeVideoUnavailable  ErrorCode = "video_unavailable"
+	CodeVideoPrivate      ErrorCode = "video_private"
+	CodeGeoBlocked        ErrorCode = "geo_blocked"
+	CodeLoginRequired     ErrorCode = "login_required"
+	CodeDRMProtected      ErrorCode = "drm_protected"
+	CodeFormatUnavailable ErrorCode = "format_unavailable"
+	CodeRateLimited       ErrorCode = "rate_limited"
+	CodeNetwork           ErrorCode = "network_error"
+	CodeTimeout           ErrorCode = "timeout"
+	CodeExtractorFailed   ErrorCode = "extractor_failed"
+)
+
+// ExtractorError is a classified extractor failure. Message is safe to show
+// to clients; Details holds the raw tool output and belongs in logs only.
+type ExtractorError struct {
+	Code    ErrorCode
+	Message string
+	Details string
+	Err     error
+}
+
+// Sentinels for errors.Is; they match any ExtractorError with the same code.
+var (
+	ErrUnsupportedURL    = &ExtractorError{Code: CodeUnsupportedURL}
+	ErrVideoUnavailable  = &ExtractorError{Code: CodeVideoUnavailable}
+	ErrVideoPrivate      = &ExtractorError{Code: CodeVideoPrivate}
+	ErrGeoBlocked        = &ExtractorError{Code: CodeGeoBlocked}
+	ErrLoginRequired     = &ExtractorError{Code: CodeLoginRequired}
+	ErrDRMProtected      = &ExtractorError{Code: CodeDRMProtected}
+	ErrFormatUnavailable = &ExtractorError{Code: CodeFormatUnavailable}
+	ErrRateLimited       = &ExtractorError{Code: CodeRateLimited}
+	ErrNetwork           = &ExtractorError{Code: CodeNetwork}
+	ErrTimeout           = &ExtractorError{Code: CodeTimeout}
+	ErrExtractorFailed   = &ExtractorError{Code: CodeExtractorFailed}
+)
+
+var errorMessages = map[ErrorCode]string{
+	CodeUnsupportedURL:    "the URL is not supported",
+	CodeVideoUnavailable:  "the video is unavailable or has been removed",
+	CodeVideoPrivate:      "the video is private",
+	CodeGeoBlocked:        "the video is not available in the server's region",
+	CodeLoginRequired:     "the video requires signing in (age-restricted or members-only)",
+	CodeDRMProtected:      "the video is DRM protected",
+	CodeFormatUnavailable: "the requested format is not available",
+	CodeRateLimited:       "the site is rate limiting the server; try again later",
+	CodeNetwork:           "the server could not reach the site",
+	CodeTimeout:           "the site took too long to respond",
+	CodeExtractorFailed:   "the video could not be processed",
+}
+
+// stderrPatterns map yt-dlp error output to codes. The first match wins, so
+// more specific patterns come first. Matching is case-insensitive.
+var stderrPatterns = []struct {
+	code     ErrorCode
+	patterns []string
+}{
+	{CodeDRMProtected, []string{"drm protected", "this video is drm", "drm-protected"}},
+	{CodeVideoPrivate, []string{"private video", "this video is private", "video is private"}},
+	{CodeGeoBlocked, []string{"not available in your country", "geo restrict", "geo-restrict", "blocked it in your country", "not made this video available in your country"}},
+	{CodeLoginRequired, []string{"sign in to confirm your age", "age-restricted", "age restricted", "inappropriate for some users", "login required", "requires login", "this video is only available for registered users", "members-only", "join this channel to get access"}},
+	{CodeRateLimited, []string{"http error 429", "too many requests", "sign in to confirm you're not a bot", "sign in to confirm you’re not a bot", "rate-limit", "rate limit"}},
+	{CodeFormatUnavailable, []string{"requested format is not available", "requested format not available", "no video formats found"}},
+	{CodeUnsupportedURL, []string{"unsupported url", "is not a valid url"}},
+	{CodeVideoUnavailable, []string{"video unavailable", "this video has been removed", "this video is not available", "video is no longer available", "does not exist", "http error 404", "http error 410"}},
+	{CodeNetwork, []string{"unable to download webpage", "urlopen error", "connection reset", "connection refused", "timed out", "temporary failure in name resolution", "name or service not known", "network is unreachable", "ssl:", "http error 5", "unable to connect"}},
+}
+
+// ClassifyError turns a failed yt-dlp run into an *ExtractorError, using its
+// stderr output to find the cause. err is the error returned by the process.
+func ClassifyError(err error, stderr string) *ExtractorError {
+	var existing *ExtractorError
+	if errors.As(err, &existing) {
+		return existing
+	}
+
+	code := CodeExtractorFailed
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		code = CodeTimeout
+	} else {
+		lower := strings.ToLower(stderr)
+
+	match:
+		for _, p := range stderrPatterns {
+			for _, pattern := range p.patterns {
+				if strings.Contains(lower, pattern) {
+					code = p.code
+					break match
+				}
+			}
+		}
+	}
+
+	return &ExtractorError{
+		Code:    code,
+		Message: errorMessages[code],
+		Details: strings.TrimSpace(stderr),
+		Err:     err,
+	}
+}
+
+// Error includes the raw details and is meant for logs; use Message for clients.
+func (e *ExtractorError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = errorMessages[e.Code]
+	}
+
+	s := fmt.Sprintf("yt-dlp error (%s): %s", e.Code, msg)
+	if e.Err != nil {
+		s += fmt.Sprintf(": %v", e.Err)
+	}
+	if e.Details != "" {
+		s += ", details: " + e.Details
+	}
+
+	return s
+}
+
+func (e *ExtractorError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an ExtractorError with the same code, so the
+// package sentinels work with errors.Is.
+func (e *ExtractorError) Is(target error) bool {
+	t, ok := target.(*ExtractorError)
+	return ok && t.Code == e.Code
+}
+
+// Reports whether the same request may succeed if retried later.
+func (e *ExtractorError) Retryable() bool {
+	switch e.Code {
+	case CodeRateLimited, CodeNetwork, CodeTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,89 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+func TestClassifyError(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+
+	tests := []struct {
+		stderr string
+		want   core.ErrorCode
+	}{
+		{"ERROR: Unsupported URL: https://example.com/", core.CodeUnsupportedURL},
+		{"ERROR: [youtube] abc: Video unavailable", core.CodeVideoUnavailable},
+		{"ERROR: [youtube] abc: Private video. Sign in if you've been granted access to this video", core.CodeVideoPrivate},
+		{"ERROR: [youtube] abc: The uploader has not made this video available in your country", core.CodeGeoBlocked},
+		{"ERROR: [youtube] abc: Sign in to confirm your age. This video may be inappropriate for some users.", core.CodeLoginRequired},
+		{"ERROR: [generic] This video is DRM protected", core.CodeDRMProtected},
+		{"ERROR: [youtube] abc: Requested format is not available. Use --list-formats for a list of available formats", core.CodeFormatUnavailable},
+		{"ERROR: unable to download video data: HTTP Error 429: Too Many Requests", core.CodeRateLimited},
+		{"ERROR: [youtube] abc: Sign in to confirm you're not a bot", core.CodeRateLimited},
+		{"ERROR: [youtube] dQw4w9WgXcQ: Sign in to confirm you’re not a bot. Use --cookies-from-browser or --cookies for the authentication. See  https://github.com/yt-dlp/yt-dlp/wiki/FAQ#how-do-i-pass-cookies-to-yt-dlp  for how to manually pass cookies. Also see  https://github.com/yt-dlp/yt-dlp/wiki/Extractors#exporting-youtube-cookies  for tips on effectively exporting YouTube cookies", core.CodeRateLimited},
+		{"ERROR: [youtube] dQw4w9WgXcQ: Sign in to confirm your age. This video may be inappropriate for some users. Use --cookies-from-browser or --cookies for the authentication.", core.CodeLoginRequired},
+		{"ERROR: [youtube] abc: Unable to download webpage: <urlopen error [Errno -3] Temporary failure in name resolution>", core.CodeNetwork},
+		{"ERROR: something nobody has seen before", core.CodeExtractorFailed},
+	}
+
+	for _, tt := range tests {
+		got := core.ClassifyError(exitErr, tt.stderr)
+		if got.Code != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.stderr, tt.want, got.Code)
+		}
+		if got.Message == "" || got.Details != tt.stderr {
+			t.Errorf("%q: expected a message and the raw details, got %+v", tt.stderr, got)
+		}
+	}
+}
+
+func TestExtractorErrorMatchesSentinels(t *testing.T) {
+	err := error(core.ClassifyError(errors.New("exit status 1"), "ERROR: Private video"))
+
+	if !errors.Is(err, core.ErrVideoPrivate) || errors.Is(err, core.ErrGeoBlocked) {
+		t.Fatalf("unexpected sentinel matching for %v", err)
+	}
+
+	timeout := core.ClassifyError(context.DeadlineExceeded, "")
+	if timeout.Code != core.CodeTimeout || !timeout.Retryable() {
+		t.Fatalf("expected a retryable timeout, got %+v", timeout)
+	}
+
+	if core.ClassifyError(errors.New("x"), "Video unavailable").Retryable() {
+		t.Fatal("unavailable videos must not be retryable")
+	}
+}
+
+func TestFetchVideoInfoClassifiesStderr(t *testing.T) {
+	fake := createFakeBin(t, `#!/bin/sh
+echo "ERROR: [youtube] abc: Private video" >&2
+exit 1
+`)
+
+	_, err := (&core.YTCore{BinaryPath: fake}).FetchVideoInfo(context.Background(), httpXUrl)
+	if !errors.Is(err, core.ErrVideoPrivate) {
+		t.Fatalf("expected ErrVideoPrivate, got %v", err)
+	}
+}
+
+func TestDownloadClassifiesStderr(t *testing.T) {
+	fake := createFakeBin(t, `#!/bin/sh
+echo "ERROR: Requested format is not available" >&2
+exit 1
+`)
+
+	r, err := (&core.YTCore{BinaryPath: fake}).Download(context.Background(), core.DownloadConfig{URL: httpXUrl, Type: core.Video})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+
+	buf := make([]byte, 16)
+	if _, err := r.Read(buf); !errors.Is(err, core.ErrFormatUnavailable) {
+		t.Fatalf("expected ErrFormatUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// Extractor fetches video metadata and media. YTCore is the production
+// implementation; coretest.Fake is an in-memory one for tests.
+type Extractor interface {
+	// Info returns the metadata of the video at url.
+	Info(ctx context.Context, url string) (*VideoInfo, error)
+	// ListFormats returns the formats available for the video at url.
+	ListFormats(ctx context.Context, url string) ([]Format, error)
+	// Download streams the media selected by cfg. Reading to the end reports
+	// whether the download succeeded; closing early aborts it.
+	Download(ctx context.Context, cfg DownloadConfig) (io.ReadCloser, error)
+	// DownloadChapters downloads the media selected by cfg into dir, one file
+	// per chapter, and returns the chapter files in order.
+	DownloadChapters(ctx context.Context, cfg DownloadConfig, dir string) ([]string, error)
+	// ListEntries returns the items of the playlist or channel at url that
+	// are not recorded in opts.Archive.
+	ListEntries(ctx context.Context, url string, opts ListOptions) ([]PlaylistEntry, error)
+	// Version returns the version of the underlying tool.
+	Version(ctx context.Context) (string, error)
+}
+
+// ProgressFunc receives the progress lines printed while downloading, without
+// the trailing newline. Ex: "[download]  42.0% of 10.00MiB at 1.00MiB/s ETA 00:06"
+type ProgressFunc func(line string)
+
+var _ Extractor = (*YTCore)(nil)
+
+// Info runs yt-dlp --dump-json, see FetchVideoInfo.
+func (yt *YTCore) Info(ctx context.Context, url string) (*VideoInfo, error) {
+	return yt.FetchVideoInfo(ctx, url)
+}
+
+// ListFormats returns the formats reported by yt-dlp --dump-json.
+func (yt *YTCore) ListFormats(ctx context.Context, url string) ([]Format, error) {
+	info, err := yt.FetchVideoInfo(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	return info.Formats, nil
+}
+
+// Version runs yt-dlp --version, see GetVersion.
+func (yt *YTCore) Version(ctx context.Context) (string, error) {
+	return yt.GetVersion(ctx)
+}
+
+// lineWriter calls fn for every complete line written to it.
+type lineWriter struct {
+	fn ProgressFunc
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	lw.buf = append(lw.buf, p...)
+
+	for {
+		i := bytes.IndexAny(lw.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+
+		if line := string(bytes.TrimSpace(lw.buf[:i])); line != "" {
+			lw.fn(line)
+		}
+		lw.buf = lw.buf[i+1:]
+	}
+
+	return len(p), nil
+}
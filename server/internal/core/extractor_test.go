@@ -0,0 +1,64 @@
+package core_test
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+func TestYTCoreDownloadReportsProgress(t *testing.T) {
+	fake := createFakeBin(t, `#!/bin/sh
+printf '[download]  10.0%% of 1.00MiB\r[download] 100.0%% of 1.00MiB\n' >&2
+echo -n "DATA"
+`)
+
+	yt := &core.YTCore{BinaryPath: fake}
+
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+
+	r, err := yt.Download(context.Background(), core.DownloadConfig{
+		URL:  httpXUrl,
+		Type: core.Audio,
+		Progress: func(line string) {
+			mu.Lock()
+			lines = append(lines, line)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+
+	if data, err := io.ReadAll(r); err != nil || string(data) != "DATA" {
+		t.Fatalf("unexpected output %q, err %v", data, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(lines) != 2 || lines[0] != "[download]  10.0% of 1.00MiB" {
+		t.Fatalf("unexpected progress lines: %q", lines)
+	}
+}
+
+func TestYTCoreListFormats(t *testing.T) {
+	fake := createFakeBin(t, `#!/bin/sh
+echo '{"id":"x","formats":[{"format_id":"140","ext":"m4a"},{"format_id":"22","ext":"mp4"}]}'
+`)
+
+	formats, err := (&core.YTCore{BinaryPath: fake}).ListFormats(context.Background(), httpXUrl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(formats) != 2 || formats[1].FormatID != "22" {
+		t.Fatalf("unexpected formats: %+v", formats)
+	}
+}
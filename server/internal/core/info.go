@@ -0,0 +1,162 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// VideoInfo is the subset of yt-dlp's --dump-json output the server relies on.
+// Raw keeps the original document so it can be returned to clients unchanged.
+type VideoInfo struct {
+	ID          string      `json:"id"`
+	Title       string      `json:"title"`
+	Extractor   string      `json:"extractor_key"`
+	WebpageURL  string      `json:"webpage_url"`
+	Uploader    string      `json:"uploader"`
+	UploadDate  string      `json:"upload_date"` // Ex: "20240131"
+	Duration    float64     `json:"duration"`
+	Thumbnail   string      `json:"thumbnail"`
+	Description string      `json:"description"`
+	Formats     []Format    `json:"formats"`
+	Chapters    []Chapter   `json:"chapters"`
+	Thumbnails  []Thumbnail `json:"thumbnails"` // worst to best, as sorted by yt-dlp
+
+	Raw json.RawMessage `json:"-"`
+}
+
+// Format describes a single downloadable stream of a video.
+type Format struct {
+	FormatID       string  `json:"format_id"`
+	FormatNote     string  `json:"format_note"`
+	Ext            string  `json:"ext"`
+	ACodec         string  `json:"acodec"`
+	VCodec         string  `json:"vcodec"`
+	Width          int     `json:"width"`
+	Height         int     `json:"height"`
+	FPS            float64 `json:"fps"`
+	ABR            float64 `json:"abr"`
+	VBR            float64 `json:"vbr"`
+	TBR            float64 `json:"tbr"`
+	Filesize       int64   `json:"filesize"`
+	FilesizeApprox int64   `json:"filesize_approx"`
+	Resolution     string  `json:"resolution"`
+	DynamicRange   string  `json:"dynamic_range"`
+	Protocol       string  `json:"protocol"`
+}
+
+// Chapter is a titled part of a video, as listed by the uploader.
+type Chapter struct {
+	Title     string  `json:"title"`
+	StartTime float64 `json:"start_time"` // seconds
+	EndTime   float64 `json:"end_time"`
+}
+
+// Thumbnail is one of the preview images of a video. Width and Height are 0
+// when the site does not report them.
+type Thumbnail struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Returns the format with the given format_id.
+func (info *VideoInfo) Format(id string) (Format, bool) {
+	for _, f := range info.Formats {
+		if f.FormatID == id {
+			return f, true
+		}
+	}
+
+	return Format{}, false
+}
+
+// Reports whether the format carries a video stream.
+func (f Format) HasVideo() bool {
+	return f.VCodec != "none"
+}
+
+// Reports whether the format carries an audio stream.
+func (f Format) HasAudio() bool {
+	return f.ACodec != "none"
+}
+
+// Parses the JSON printed by yt-dlp --dump-json.
+func ParseVideoInfo(raw []byte) (*VideoInfo, error) {
+	var info VideoInfo
+
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("error parsing video info: %v", err)
+	}
+
+	info.Raw = json.RawMessage(raw)
+
+	return &info, nil
+}
+
+// Returns the resolution a download of cfg is expected to have, Ex:
+// "1280x720", as reported for the chosen format or the best format within the
+// requested quality. It returns "audio only" for audio and "" when unknown.
+func (info *VideoInfo) ResolutionFor(cfg DownloadConfig) string {
+	if cfg.Type == Audio {
+		return "audio only"
+	}
+
+	if cfg.VideoFormatID != "" {
+		f, _ := info.Format(cfg.VideoFormatID)
+		return f.resolution()
+	}
+
+	maxHeight := videoHeights[min(max(cfg.Quality, 0), len(videoHeights)-1)]
+
+	var best Format
+	for _, f := range info.Formats {
+		if !f.HasVideo() || f.Height == 0 {
+			continue
+		}
+		if cfg.FormatNote != "" && f.FormatNote != cfg.FormatNote {
+			continue
+		}
+		if cfg.FormatNote == "" && f.Height > maxHeight {
+			continue
+		}
+		if f.Height > best.Height {
+			best = f
+		}
+	}
+
+	return best.resolution()
+}
+
+func (f Format) resolution() string {
+	switch {
+	case f.Width > 0 && f.Height > 0:
+		return fmt.Sprintf("%dx%d", f.Width, f.Height)
+	case f.Resolution != "":
+		return f.Resolution
+	}
+
+	return ""
+}
+
+// Returns the exact size in bytes of a download of cfg, or 0 when it is not
+// known in advance. Only a single format sent unmodified has a known size:
+// merged, cut or post-processed downloads are rewritten by ffmpeg.
+func (info *VideoInfo) ExactSize(cfg DownloadConfig) int64 {
+	if cfg.Type != Audio || cfg.AudioFormatID == "" || len(cfg.Sections) > 0 || cfg.SplitChapters || cfg.needsFile() {
+		return 0
+	}
+
+	f, ok := info.Format(cfg.AudioFormatID)
+	if !ok {
+		return 0
+	}
+
+	// fragmented protocols (HLS, DASH segments) may not add up to filesize
+	switch f.Protocol {
+	case "", "http", "https":
+		return f.Filesize
+	}
+
+	return 0
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// InfoCache keeps parsed VideoInfo in memory for a limited time and coalesces
+// concurrent lookups of the same key into a single fetch.
+type InfoCache struct {
+	cache *MemoryCache[*VideoInfo]
+}
+
+// InfoFetchFunc loads the info for a cache key that is missing or expired.
+type InfoFetchFunc func(ctx context.Context) (*VideoInfo, error)
+
+func NewInfoCache(ttl time.Duration, maxEntries int) *InfoCache {
+	return &InfoCache{cache: NewMemoryCache[*VideoInfo](ttl, maxEntries, 0, nil)}
+}
+
+// Get returns the cached info for key, calling fetch at most once for all
+// concurrent callers when it is not cached. The returned time is when the
+// entry expires.
+func (c *InfoCache) Get(ctx context.Context, key string, fetch InfoFetchFunc) (*VideoInfo, time.Time, CacheStatus, error) {
+	return c.cache.Get(ctx, key, fetch)
+}
+
+// Removes the entry for key, reporting whether it was present.
+func (c *InfoCache) Purge(key string) bool {
+	return c.cache.Purge(key)
+}
+
+// Removes every entry, returning how many were removed.
+func (c *InfoCache) PurgeAll() int {
+	return c.cache.PurgeAll()
+}
+
+// Returns the number of cached entries.
+func (c *InfoCache) Len() int {
+	n, _ := c.cache.Stats()
+	return n
+}
@@ -0,0 +1,149 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+func TestInfoCacheHitAfterMiss(t *testing.T) {
+	c := core.NewInfoCache(time.Minute, 10)
+
+	var calls int32
+	fetch := func(ctx context.Context) (*core.VideoInfo, error) {
+		atomic.AddInt32(&calls, 1)
+		return &core.VideoInfo{ID: "abc"}, nil
+	}
+
+	info, _, status, err := c.Get(context.Background(), "k", fetch)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status != core.CacheMiss || info.ID != "abc" {
+		t.Fatalf("expected MISS with info, got %s %+v", status, info)
+	}
+
+	_, expires, status, _ := c.Get(context.Background(), "k", fetch)
+	if status != core.CacheHit {
+		t.Fatalf("expected HIT, got %s", status)
+	}
+	if time.Until(expires) <= 0 {
+		t.Fatalf("expected expiry in the future, got %v", expires)
+	}
+	if calls != 1 {
+		t.Fatalf("expected one fetch, got %d", calls)
+	}
+}
+
+func TestInfoCacheCoalescesConcurrentLookups(t *testing.T) {
+	c := core.NewInfoCache(time.Minute, 10)
+
+	release := make(chan struct{})
+	var calls int32
+	fetch := func(ctx context.Context) (*core.VideoInfo, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &core.VideoInfo{ID: "abc"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, _, err := c.Get(context.Background(), "k", fetch); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("expected concurrent lookups to share one fetch, got %d", calls)
+	}
+}
+
+func TestInfoCacheDoesNotCacheErrors(t *testing.T) {
+	c := core.NewInfoCache(time.Minute, 10)
+
+	_, _, _, err := c.Get(context.Background(), "k", func(ctx context.Context) (*core.VideoInfo, error) {
+		return nil, errors.New("boom")
+	})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if c.Len() != 0 {
+		t.Fatalf("expected failed lookup not to be cached")
+	}
+}
+
+func TestInfoCacheExpiresAndEvicts(t *testing.T) {
+	c := core.NewInfoCache(time.Millisecond, 2)
+
+	fetch := func(ctx context.Context) (*core.VideoInfo, error) {
+		return &core.VideoInfo{}, nil
+	}
+
+	c.Get(context.Background(), "a", fetch)
+	c.Get(context.Background(), "b", fetch)
+	c.Get(context.Background(), "c", fetch)
+
+	if c.Len() != 2 {
+		t.Fatalf("expected size limit of 2 entries, got %d", c.Len())
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, _, status, _ := c.Get(context.Background(), "c", fetch); status != core.CacheMiss {
+		t.Fatalf("expected expired entry to be a MISS, got %s", status)
+	}
+}
+
+func TestInfoCachePurge(t *testing.T) {
+	c := core.NewInfoCache(time.Minute, 10)
+
+	fetch := func(ctx context.Context) (*core.VideoInfo, error) {
+		return &core.VideoInfo{}, nil
+	}
+
+	c.Get(context.Background(), "a", fetch)
+	c.Get(context.Background(), "b", fetch)
+
+	if !c.Purge("a") || c.Purge("a") {
+		t.Fatalf("expected Purge to report presence once")
+	}
+
+	if n := c.PurgeAll(); n != 1 {
+		t.Fatalf("expected PurgeAll to remove 1 entry, got %d", n)
+	}
+}
+
+func TestParseVideoInfo(t *testing.T) {
+	raw := []byte(`{"id":"x1","title":"T","duration":12.5,"formats":[{"format_id":"18","height":360,"filesize":1024}]}`)
+
+	info, err := core.ParseVideoInfo(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if info.ID != "x1" || info.Duration != 12.5 || len(info.Formats) != 1 || info.Formats[0].Filesize != 1024 {
+		t.Fatalf("unexpected parse result: %+v", info)
+	}
+
+	if string(info.Raw) != string(raw) {
+		t.Fatalf("expected raw document to be preserved")
+	}
+
+	if _, err := core.ParseVideoInfo([]byte("not json")); err == nil {
+		t.Fatalf("expected error for invalid json")
+	}
+}
@@ -0,0 +1,67 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+func TestResolutionFor(t *testing.T) {
+	info, err := core.ParseVideoInfo([]byte(`{"id":"x","formats":[
+		{"format_id":"140","vcodec":"none","acodec":"mp4a"},
+		{"format_id":"18","format_note":"360p","width":640,"height":360,"vcodec":"avc1","acodec":"mp4a"},
+		{"format_id":"136","format_note":"720p","width":1280,"height":720,"vcodec":"avc1","acodec":"none"},
+		{"format_id":"137","format_note":"1080p","width":1920,"height":1080,"vcodec":"avc1","acodec":"none"},
+		{"format_id":"hls","format_note":"720p","resolution":"1280x720","vcodec":"avc1","acodec":"mp4a"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		cfg  core.DownloadConfig
+		want string
+	}{
+		{core.DownloadConfig{Type: core.Audio}, "audio only"},
+		{core.DownloadConfig{Type: core.Video, VideoFormatID: "18"}, "640x360"},
+		{core.DownloadConfig{Type: core.Video, Quality: 4}, "1280x720"},
+		{core.DownloadConfig{Type: core.Video, Quality: 99}, "1920x1080"},
+		{core.DownloadConfig{Type: core.Video, FormatNote: "1080p"}, "1920x1080"},
+		{core.DownloadConfig{Type: core.Video, Quality: 0}, ""},
+		{core.DownloadConfig{Type: core.Video, VideoFormatID: "missing"}, ""},
+	} {
+		if got := info.ResolutionFor(tc.cfg); got != tc.want {
+			t.Errorf("%+v: expected %q, got %q", tc.cfg, tc.want, got)
+		}
+	}
+}
+
+func TestExactSize(t *testing.T) {
+	info, err := core.ParseVideoInfo([]byte(`{"id":"x","formats":[
+		{"format_id":"140","vcodec":"none","acodec":"mp4a","protocol":"https","filesize":3000},
+		{"format_id":"hls","vcodec":"none","acodec":"mp4a","protocol":"m3u8_native","filesize":3000},
+		{"format_id":"approx","vcodec":"none","acodec":"mp4a","protocol":"https","filesize_approx":3000},
+		{"format_id":"18","vcodec":"avc1","acodec":"mp4a","protocol":"https","filesize":9000}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		cfg  core.DownloadConfig
+		want int64
+	}{
+		{core.DownloadConfig{Type: core.Audio, AudioFormatID: "140"}, 3000},
+		{core.DownloadConfig{Type: core.Audio, AudioFormatID: "hls"}, 0},
+		{core.DownloadConfig{Type: core.Audio, AudioFormatID: "approx"}, 0},
+		{core.DownloadConfig{Type: core.Audio}, 0},
+		{core.DownloadConfig{Type: core.Audio, AudioFormatID: "140", Sections: []core.Section{{Start: 10}}}, 0},
+		{core.DownloadConfig{Type: core.Audio, AudioFormatID: "140", Embed: core.EmbedOptions{Thumbnail: true}}, 0},
+		// video downloads are merged into mkv, even from a muxed format
+		{core.DownloadConfig{Type: core.Video, VideoFormatID: "18"}, 0},
+	} {
+		if got := info.ExactSize(tc.cfg); got != tc.want {
+			t.Errorf("%+v: expected %d, got %d", tc.cfg, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package core
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// MemoryCache keeps values in memory for a limited time, evicting the least
+// recently used ones beyond its limits, and coalesces concurrent loads of the
+// same key into a single call.
+type MemoryCache[V any] struct {
+	ttl        time.Duration
+	maxEntries int           // no limit when 0
+	maxSize    int64         // only with sizeOf
+	sizeOf     func(V) int64 // nil for no size limit
+
+	mu      sync.Mutex
+	lru     *list.List // of *memoryEntry[V], most recently used first
+	entries map[string]*list.Element
+	size    int64
+	group   singleflight.Group
+}
+
+type memoryEntry[V any] struct {
+	key     string
+	value   V
+	size    int64
+	expires time.Time
+}
+
+// Returns a MemoryCache keeping values for ttl, at most maxEntries of them
+// (any number when 0) and, when sizeOf is set, at most maxSize in total. A
+// value larger than maxSize is returned but not kept.
+func NewMemoryCache[V any](ttl time.Duration, maxEntries int, maxSize int64, sizeOf func(V) int64) *MemoryCache[V] {
+	return &MemoryCache[V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		maxSize:    maxSize,
+		sizeOf:     sizeOf,
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+	}
+}
+
+// Returns the value cached for key, calling load at most once for all
+// concurrent callers when it is missing or expired. The returned time is when
+// the value expires.
+func (c *MemoryCache[V]) Get(ctx context.Context, key string, load func(ctx context.Context) (V, error)) (V, time.Time, CacheStatus, error) {
+	if entry, ok := c.lookup(key); ok {
+		return entry.value, entry.expires, CacheHit, nil
+	}
+
+	leader := false
+	ch := c.group.DoChan(key, func() (any, error) {
+		leader = true
+
+		// The load is shared, so it must not be cancelled by the first caller leaving.
+		value, err := load(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		return c.store(key, value), nil
+	})
+
+	var zero V
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return zero, time.Time{}, "", res.Err
+		}
+
+		status := CacheCoalesced
+		if leader {
+			status = CacheMiss
+		}
+
+		entry := res.Val.(*memoryEntry[V])
+		return entry.value, entry.expires, status, nil
+
+	case <-ctx.Done():
+		return zero, time.Time{}, "", ctx.Err()
+	}
+}
+
+// Removes the value for key, reporting whether it was present.
+func (c *MemoryCache[V]) Purge(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if ok {
+		c.removeLocked(el)
+	}
+
+	return ok
+}
+
+// Removes every value, returning how many were removed.
+func (c *MemoryCache[V]) PurgeAll() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.lru.Len()
+	c.lru.Init()
+	c.entries = map[string]*list.Element{}
+	c.size = 0
+
+	return n
+}
+
+// Returns the number of cached values and their total size.
+func (c *MemoryCache[V]) Stats() (entries int, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len(), c.size
+}
+
+func (c *MemoryCache[V]) lookup(key string) (*memoryEntry[V], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*memoryEntry[V])
+	if time.Now().After(entry.expires) {
+		c.removeLocked(el)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+
+	return entry, true
+}
+
+func (c *MemoryCache[V]) store(key string, value V) *memoryEntry[V] {
+	entry := &memoryEntry[V]{key: key, value: value, expires: time.Now().Add(c.ttl)}
+	if c.sizeOf != nil {
+		entry.size = c.sizeOf(value)
+		if entry.size > c.maxSize {
+			return entry
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.removeLocked(el)
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+	c.size += entry.size
+
+	for (c.maxEntries > 0 && c.lru.Len() > c.maxEntries) || (c.sizeOf != nil && c.size > c.maxSize) {
+		c.removeLocked(c.lru.Back())
+	}
+
+	return entry
+}
+
+func (c *MemoryCache[V]) removeLocked(el *list.Element) {
+	entry := c.lru.Remove(el).(*memoryEntry[V])
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
@@ -0,0 +1,46 @@
+package core_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+func TestMemoryCacheEvictsBySize(t *testing.T) {
+	c := core.NewMemoryCache(time.Minute, 0, 10, func(s string) int64 { return int64(len(s)) })
+	ctx := context.Background()
+
+	put := func(key, value string) core.CacheStatus {
+		_, _, status, err := c.Get(ctx, key, func(ctx context.Context) (string, error) { return value, nil })
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return status
+	}
+
+	put("a", "12345")
+	put("b", "12345")
+	put("a", "")                        // a is now the most recently used
+	put("c", "123")                     // evicts b
+	put("big", strings.Repeat("x", 11)) // returned, but too large to keep
+
+	if n, size := c.Stats(); n != 2 || size != 8 {
+		t.Fatalf("expected 2 values of 8 bytes, got %d of %d", n, size)
+	}
+	if status := put("a", ""); status != core.CacheHit {
+		t.Fatalf("expected a to be kept, got %s", status)
+	}
+	if status := put("b", "12345"); status != core.CacheMiss {
+		t.Fatalf("expected b to be evicted, got %s", status)
+	}
+
+	if n := c.PurgeAll(); n != 2 {
+		t.Fatalf("expected 2 values purged, got %d", n)
+	}
+	if n, size := c.Stats(); n != 0 || size != 0 {
+		t.Fatalf("expected an empty cache, got %d values of %d bytes", n, size)
+	}
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PlaylistEntry is an item of a playlist or channel, as listed by yt-dlp
+// --flat-playlist without extracting the item itself.
+type PlaylistEntry struct {
+	ID    string `json:"id"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	IEKey string `json:"ie_key"` // extractor of the item, Ex: "Youtube"
+}
+
+// Returns the line recording e in a yt-dlp download archive, Ex:
+// "youtube dQw4w9WgXcQ".
+func (e PlaylistEntry) ArchiveID() string {
+	return strings.ToLower(e.IEKey) + " " + e.ID
+}
+
+// ListOptions selects the entries returned by ListEntries.
+type ListOptions struct {
+	Archive string // yt-dlp download archive; entries recorded in it are skipped
+	Limit   int    // only the first Limit items of the list are checked; 0 checks them all
+}
+
+// ListEntries runs yt-dlp --flat-playlist --dump-json and returns the items of
+// the playlist or channel at url that are not in the archive, in list order.
+func (yt *YTCore) ListEntries(ctx context.Context, url string, opts ListOptions) ([]PlaylistEntry, error) {
+	args := []string{"--flat-playlist", "--dump-json"}
+	if opts.Archive != "" {
+		args = append(args, "--download-archive", opts.Archive)
+	}
+	if opts.Limit > 0 {
+		args = append(args, "--playlist-end", strconv.Itoa(opts.Limit))
+	}
+	args = append(args, url)
+
+	cmd := exec.CommandContext(ctx, yt.binaryPath(), args...)
+
+	var out, stderr bytes.Buffer
+
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, runError(ctx, err, stderr.String())
+	}
+
+	return ParsePlaylistEntries(out.Bytes())
+}
+
+// ParsePlaylistEntries parses the JSON lines printed by yt-dlp --flat-playlist
+// --dump-json, skipping entries without an ID.
+func ParsePlaylistEntries(raw []byte) ([]PlaylistEntry, error) {
+	entries := []PlaylistEntry{}
+
+	for line := range bytes.Lines(raw) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var e PlaylistEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("error parsing playlist entry: %v", err)
+		}
+		if e.ID != "" {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+// ReadArchive returns the IDs recorded in the yt-dlp download archive at path.
+// A missing file is an empty archive.
+func ReadArchive(path string) (map[string]bool, error) {
+	ids := map[string]bool{}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ids, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read download archive: %v", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			ids[line] = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read download archive: %v", err)
+	}
+
+	return ids, nil
+}
+
+// RecordArchive adds entries to the yt-dlp download archive at path, so
+// ListEntries skips them from now on.
+func RecordArchive(path string, entries ...PlaylistEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open download archive: %v", err)
+	}
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		buf.WriteString(e.ArchiveID() + "\n")
+	}
+
+	_, err = f.Write(buf.Bytes())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write download archive: %v", err)
+	}
+
+	return nil
+}
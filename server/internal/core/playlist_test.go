@@ -0,0 +1,65 @@
+package core_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+func TestListEntries(t *testing.T) {
+	fake := createFakeBin(t, `#!/bin/sh
+echo "$@" > "$(dirname "$0")/args"
+echo '{"_type":"url","ie_key":"Youtube","id":"aaaaaaaaaaa","url":"https://www.youtube.com/watch?v=aaaaaaaaaaa","title":"A"}'
+echo '{"_type":"url","ie_key":"Youtube","url":"https://www.youtube.com/watch?v=unknown"}'
+echo '{"_type":"url","ie_key":"Youtube","id":"bbbbbbbbbbb","url":"https://www.youtube.com/watch?v=bbbbbbbbbbb","title":"B"}'
+`)
+
+	yt := &core.YTCore{BinaryPath: fake}
+
+	entries, err := yt.ListEntries(context.Background(), httpXUrl, core.ListOptions{Archive: "/data/archive.txt", Limit: 20})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 2 || entries[1].ID != "bbbbbbbbbbb" || entries[1].ArchiveID() != "youtube bbbbbbbbbbb" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(filepath.Dir(fake), "args"))
+	if want := "--flat-playlist --dump-json --download-archive /data/archive.txt --playlist-end 20 " + httpXUrl + "\n"; string(args) != want {
+		t.Fatalf("expected args %q, got %q", want, args)
+	}
+}
+
+func TestListEntriesError(t *testing.T) {
+	fake := createFakeBin(t, `#!/bin/sh
+echo "ERROR: [youtube:tab] @nobody: This channel does not exist." >&2
+exit 1
+`)
+
+	if _, err := (&core.YTCore{BinaryPath: fake}).ListEntries(context.Background(), httpXUrl, core.ListOptions{}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestRecordArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archives", "sub.txt")
+
+	if ids, err := core.ReadArchive(path); err != nil || len(ids) != 0 {
+		t.Fatalf("expected a missing archive to be empty, got %v, %v", ids, err)
+	}
+
+	core.RecordArchive(path, core.PlaylistEntry{ID: "a", IEKey: "Youtube"})
+	core.RecordArchive(path, core.PlaylistEntry{ID: "b", IEKey: "Vimeo"}, core.PlaylistEntry{ID: "c", IEKey: "Youtube"})
+
+	ids, err := core.ReadArchive(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ids) != 3 || !ids["youtube a"] || !ids["vimeo b"] || !ids["youtube c"] {
+		t.Fatalf("unexpected archive %v", ids)
+	}
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/config"
+)
+
+// versionCheckTimeout bounds the --version run used to verify a candidate binary.
+const versionCheckTimeout = 30 * time.Second
+
+// Candidate is a location where the yt-dlp binary was looked for.
+type Candidate struct {
+	Path   string
+	Source string // Ex: "YT_DLP_PATH", "PATH", "executable dir"
+	Err    error  // nil for the candidate that was selected
+}
+
+// ResolveError lists every candidate tried when no usable yt-dlp binary was found.
+type ResolveError struct {
+	Tried []Candidate
+}
+
+func (e *ResolveError) Error() string {
+	var b strings.Builder
+
+	b.WriteString("yt-dlp binary not found; tried:")
+	for _, c := range e.Tried {
+		fmt.Fprintf(&b, "\n  %s (%s): %v", c.Path, c.Source, c.Err)
+	}
+
+	return b.String()
+}
+
+// Returns the yt-dlp file name from YT_DLP_SCRIPT_NAME, defaulting to the
+// platform's usual name.
+func ScriptName() string {
+	if name := strings.TrimSpace(os.Getenv("YT_DLP_SCRIPT_NAME")); name != "" {
+		return name
+	}
+
+	if runtime.GOOS == "windows" {
+		return "yt-dlp.exe"
+	}
+
+	return "yt-dlp"
+}
+
+// Returns the locations checked for yt-dlp, in order: YT_DLP_PATH, the current
+// binary of the versions directory (see BinaryVersions), $PATH, the scripts
+// directory next to the executable (or its parent, matching the repository
+// layout) and finally ../scripts relative to the working directory.
+func defaultCandidates() []Candidate {
+	name := ScriptName()
+
+	var candidates []Candidate
+
+	if p := strings.TrimSpace(os.Getenv("YT_DLP_PATH")); p != "" {
+		candidates = append(candidates, Candidate{Path: p, Source: "YT_DLP_PATH"})
+	}
+
+	if current, _, err := NewBinaryVersions(config.YTDlpVersionsDir()).State(); err != nil {
+		candidates = append(candidates, Candidate{Path: config.YTDlpVersionsDir(), Source: "versions dir", Err: err})
+	} else if current != nil {
+		candidates = append(candidates, Candidate{Path: current.Path, Source: "versions dir"})
+	}
+
+	if p, err := exec.LookPath(name); err == nil {
+		candidates = append(candidates, Candidate{Path: p, Source: "PATH"})
+	} else {
+		candidates = append(candidates, Candidate{Path: name, Source: "PATH", Err: err})
+	}
+
+	if exe, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+
+		exeDir := filepath.Dir(exe)
+		candidates = append(candidates,
+			Candidate{Path: filepath.Join(exeDir, "scripts", name), Source: "executable dir"},
+			Candidate{Path: filepath.Join(exeDir, "..", "scripts", name), Source: "executable dir"},
+		)
+	}
+
+	if cwd, err := os.Getwd(); err == nil {
+		candidates = append(candidates, Candidate{Path: filepath.Join(cwd, "..", "scripts", name), Source: "working dir"})
+	}
+
+	return candidates
+}
+
+// Returns the first candidate that runs, with its version.
+func resolve(candidates []Candidate) (*YTCore, error) {
+	tried := make([]Candidate, 0, len(candidates))
+	seen := map[string]bool{}
+
+	for _, c := range candidates {
+		if c.Err == nil {
+			if seen[filepath.Clean(c.Path)] {
+				continue
+			}
+			seen[filepath.Clean(c.Path)] = true
+
+			version, err := verifyBinary(c.Path)
+			if err == nil {
+				return &YTCore{BinaryPath: c.Path, BinaryVersion: version, Source: c.Source}, nil
+			}
+
+			c.Err = err
+			if c.Source == "YT_DLP_PATH" {
+				log.Printf("WARNING: YT_DLP_PATH %s is not usable: %v", c.Path, err)
+			}
+		}
+
+		tried = append(tried, c)
+	}
+
+	return nil, &ResolveError{Tried: tried}
+}
+
+// Checks that path is an executable file and returns its --version output.
+func verifyBinary(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", errors.New("not found")
+		}
+		return "", err
+	}
+	if info.IsDir() {
+		return "", errors.New("is a directory")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), versionCheckTimeout)
+	defer cancel()
+
+	version, err := (&YTCore{BinaryPath: path}).GetVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if version == "" {
+		return "", errors.New("--version printed nothing")
+	}
+
+	return version, nil
+}
@@ -0,0 +1,106 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+const fakeVersionScript = "#!/bin/sh\necho 2025.01.01\n"
+
+func TestInitYTCorePrefersExplicitPath(t *testing.T) {
+	explicit := createFakeBin(t, "#!/bin/sh\necho 2024.12.31\n")
+	pathDir := filepath.Dir(createFakeBin(t, fakeVersionScript))
+
+	t.Setenv("YT_DLP_SCRIPT_NAME", "fakebin")
+	t.Setenv("YT_DLP_PATH", explicit)
+	t.Setenv("PATH", pathDir)
+
+	// YT_DLP_PATH wins over an installed binary too
+	versionsDir := t.TempDir()
+	t.Setenv("YT_DLP_VERSIONS_DIR", versionsDir)
+	if _, err := core.NewBinaryVersions(versionsDir).Install(context.Background(), strings.NewReader(fakeVersionScript), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	yt, err := core.InitYTCore()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if yt.BinaryPath != explicit || yt.Source != "YT_DLP_PATH" {
+		t.Fatalf("expected %s from YT_DLP_PATH, got %s from %s", explicit, yt.BinaryPath, yt.Source)
+	}
+	if yt.BinaryVersion != "2024.12.31" {
+		t.Fatalf("expected recorded version, got %q", yt.BinaryVersion)
+	}
+}
+
+func TestInitYTCoreFallsBackToPATH(t *testing.T) {
+	pathBin := createFakeBin(t, fakeVersionScript)
+	broken := createFakeBin(t, "#!/bin/sh\nexit 1\n")
+
+	t.Setenv("YT_DLP_SCRIPT_NAME", "fakebin")
+	t.Setenv("YT_DLP_PATH", broken)
+	t.Setenv("PATH", filepath.Dir(pathBin))
+
+	yt, err := core.InitYTCore()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if yt.BinaryPath != pathBin || yt.Source != "PATH" {
+		t.Fatalf("expected %s from PATH, got %s from %s", pathBin, yt.BinaryPath, yt.Source)
+	}
+}
+
+func TestInitYTCoreReportsCandidates(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	t.Setenv("YT_DLP_SCRIPT_NAME", "fakebin-that-does-not-exist")
+	t.Setenv("YT_DLP_PATH", missing)
+	t.Setenv("PATH", t.TempDir())
+
+	_, err := core.InitYTCore()
+
+	var rErr *core.ResolveError
+	if !errors.As(err, &rErr) {
+		t.Fatalf("expected *ResolveError, got %v", err)
+	}
+
+	sources := map[string]bool{}
+	for _, c := range rErr.Tried {
+		if c.Err == nil {
+			t.Fatalf("candidate %s has no error", c.Path)
+		}
+		sources[c.Source] = true
+	}
+
+	for _, want := range []string{"YT_DLP_PATH", "PATH", "executable dir"} {
+		if !sources[want] {
+			t.Fatalf("expected a %s candidate in %+v", want, rErr.Tried)
+		}
+	}
+
+	if !strings.Contains(err.Error(), missing) {
+		t.Fatalf("expected error to mention %s, got %q", missing, err.Error())
+	}
+}
+
+func TestInitYTCoreInRejectsDirectory(t *testing.T) {
+	scriptsDir := t.TempDir()
+	t.Setenv("YT_DLP_SCRIPT_NAME", "fakebin")
+
+	if err := os.Mkdir(filepath.Join(scriptsDir, "fakebin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := core.InitYTCoreIn(scriptsDir); err == nil {
+		t.Fatal("expected an error for a directory")
+	}
+}
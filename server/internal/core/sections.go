@@ -0,0 +1,80 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Section is a part of a video passed to yt-dlp's --download-sections: a time
+// range, or the chapters whose titles match Chapter.
+type Section struct {
+	Start, End float64 // seconds; End 0 means the end of the video
+	Chapter    string  // regular expression; Start and End are ignored when set
+}
+
+// Returns the --download-sections value of s, Ex: "*90-120" or "^Intro$".
+func (s Section) Arg() string {
+	if s.Chapter != "" {
+		return s.Chapter
+	}
+
+	end := "inf"
+	if s.End > 0 {
+		end = strconv.FormatFloat(s.End, 'f', -1, 64)
+	}
+
+	return "*" + strconv.FormatFloat(s.Start, 'f', -1, 64) + "-" + end
+}
+
+// Returns the chapters of the video whose titles match re.
+func (info *VideoInfo) MatchChapters(re *regexp.Regexp) []Chapter {
+	var matched []Chapter
+	for _, c := range info.Chapters {
+		if re.MatchString(c.Title) {
+			matched = append(matched, c)
+		}
+	}
+
+	return matched
+}
+
+// Parses a timestamp in seconds, "MM:SS" or "HH:MM:SS", each optionally with
+// a fraction. Ex: "90", "1:30", "1:02:03.5".
+func ParseTimestamp(s string) (float64, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+
+	var secs float64
+	for i, p := range parts {
+		digits := "0123456789"
+		if i == len(parts)-1 {
+			digits += "." // only the last part may have a fraction
+		}
+		if p == "" || strings.Trim(p, digits) != "" {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+
+		n, err := strconv.ParseFloat(p, 64)
+		if err != nil || (i > 0 && n >= 60) {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+
+		secs = secs*60 + n
+	}
+
+	return secs, nil
+}
+
+// Formats seconds as "M:SS" or "H:MM:SS", dropping fractions.
+func FormatTimestamp(secs float64) string {
+	n := int64(secs)
+	if n >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", n/3600, n/60%60, n%60)
+	}
+
+	return fmt.Sprintf("%d:%02d", n/60, n%60)
+}
@@ -0,0 +1,93 @@
+package core_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	for in, want := range map[string]float64{
+		"90":        90,
+		"0":         0,
+		"1:30":      90,
+		"01:02:03":  3723,
+		"1:02:03.5": 3723.5,
+		" 2.25 ":    2.25,
+	} {
+		got, err := core.ParseTimestamp(in)
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+		} else if got != want {
+			t.Errorf("%q: expected %v, got %v", in, want, got)
+		}
+	}
+
+	for _, in := range []string{"", "-5", "1:60", "1.5:00", "1:2:3:4", "1e3", "inf", "NaN", "1::2", "0x10"} {
+		if _, err := core.ParseTimestamp(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	for secs, want := range map[float64]string{0: "0:00", 90.7: "1:30", 3723: "1:02:03"} {
+		if got := core.FormatTimestamp(secs); got != want {
+			t.Errorf("%v: expected %q, got %q", secs, want, got)
+		}
+	}
+}
+
+func TestSectionArg(t *testing.T) {
+	for _, tc := range []struct {
+		s    core.Section
+		want string
+	}{
+		{core.Section{Start: 90, End: 120.5}, "*90-120.5"},
+		{core.Section{Start: 3600}, "*3600-inf"},
+		{core.Section{Chapter: "^Intro$", Start: 5}, "^Intro$"},
+	} {
+		if got := tc.s.Arg(); got != tc.want {
+			t.Errorf("%+v: expected %q, got %q", tc.s, tc.want, got)
+		}
+	}
+}
+
+func TestMatchChapters(t *testing.T) {
+	info, err := core.ParseVideoInfo([]byte(`{"id":"x","chapters":[{"title":"Intro","start_time":0,"end_time":30},{"title":"Q&A","start_time":30,"end_time":90}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := info.MatchChapters(regexp.MustCompile("(?i)q&a"))
+	if len(got) != 1 || got[0].Title != "Q&A" || got[0].StartTime != 30 || got[0].EndTime != 90 {
+		t.Fatalf("unexpected chapters %+v", got)
+	}
+}
+
+func TestDownloadCacheKeySections(t *testing.T) {
+	cfg := core.DownloadConfig{Type: core.Video, Sections: []core.Section{{Start: 10, End: 40}}}
+
+	plain, err := core.NewDownloadCacheKey("Youtube", "abc", core.DownloadConfig{Type: core.Video})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clip, err := core.NewDownloadCacheKey("Youtube", "abc", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ForceKeyframes = true
+	exact, err := core.NewDownloadCacheKey("Youtube", "abc", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plain.Hash() == clip.Hash() || clip.Hash() == exact.Hash() {
+		t.Fatal("expected sections and keyframe cuts to change the cache key")
+	}
+	if !strings.Contains(exact.Options, "--download-sections *10-40 --force-keyframes-at-cuts") {
+		t.Fatalf("unexpected options %q", exact.Options)
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"os"
+	"os/exec"
 	"runtime"
 )
 
@@ -50,3 +51,9 @@ func Getwd() string {
 func GetCompiler() string {
 	return runtime.Compiler
 }
+
+// Reports whether ffmpeg is in $PATH, where yt-dlp looks for it.
+func HasFFmpeg() bool {
+	_, err := exec.LookPath("ffmpeg")
+	return err == nil
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// PickThumbnail returns the smallest thumbnail at least width pixels wide, or
+// the widest one when none is that wide. A width of 0 picks the best thumbnail.
+// When ext is set, only thumbnails whose URL ends in that extension, Ex:
+// "webp", are considered. Thumbnails without a reported width rank below those
+// with one.
+func (info *VideoInfo) PickThumbnail(width int, ext string) (Thumbnail, bool) {
+	var candidates []Thumbnail
+	for _, t := range info.Thumbnails {
+		if t.URL != "" && (ext == "" || thumbnailExt(t.URL) == ext) {
+			candidates = append(candidates, t)
+		}
+	}
+
+	if len(candidates) == 0 {
+		if ext == "" && info.Thumbnail != "" {
+			return Thumbnail{URL: info.Thumbnail}, true
+		}
+		return Thumbnail{}, false
+	}
+
+	best := candidates[len(candidates)-1]
+	if width <= 0 {
+		return best, true
+	}
+
+	var fit, widest *Thumbnail
+	for i := range candidates {
+		t := &candidates[i]
+		// later entries are preferred by yt-dlp, so ties go to them
+		if t.Width >= width && (fit == nil || t.Width <= fit.Width) {
+			fit = t
+		}
+		if t.Width > 0 && (widest == nil || t.Width >= widest.Width) {
+			widest = t
+		}
+	}
+
+	switch {
+	case fit != nil:
+		return *fit, true
+	case widest != nil:
+		return *widest, true
+	default:
+		return best, true
+	}
+}
+
+// Returns the lower-case extension of the path of rawURL, without the dot.
+func thumbnailExt(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), "."))
+}
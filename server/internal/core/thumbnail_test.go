@@ -0,0 +1,57 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+func TestPickThumbnail(t *testing.T) {
+	info, err := core.ParseVideoInfo([]byte(`{"id":"x","thumbnail":"https://i.example/maxres.jpg","thumbnails":[
+		{"id":"0","url":"https://i.example/default.jpg","width":120,"height":90},
+		{"id":"1","url":"https://i.example/unknown.jpg"},
+		{"id":"2","url":"https://i.example/mq.webp?v=1","width":320,"height":180},
+		{"id":"3","url":"https://i.example/hq.jpg","width":480,"height":360},
+		{"id":"4","url":"https://i.example/hq.webp","width":480,"height":360},
+		{"id":"5","url":"https://i.example/maxres.jpg","width":1280,"height":720}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		width  int
+		ext    string
+		wantID string
+	}{
+		{0, "", "5"},
+		{100, "", "0"},
+		{300, "", "2"},
+		{400, "", "4"}, // ties go to the later, preferred entry
+		{2000, "", "5"},
+		{0, "webp", "4"},
+		{200, "webp", "2"},
+		{2000, "webp", "4"},
+	} {
+		got, ok := info.PickThumbnail(tc.width, tc.ext)
+		if !ok || got.ID != tc.wantID {
+			t.Errorf("width %d, ext %q: expected %s, got %+v", tc.width, tc.ext, tc.wantID, got)
+		}
+	}
+
+	if _, ok := info.PickThumbnail(0, "png"); ok {
+		t.Error("expected no png thumbnail")
+	}
+}
+
+func TestPickThumbnailFallsBackToThumbnailField(t *testing.T) {
+	info := &core.VideoInfo{Thumbnail: "https://i.example/only.jpg"}
+
+	got, ok := info.PickThumbnail(480, "")
+	if !ok || got.URL != "https://i.example/only.jpg" {
+		t.Fatalf("unexpected thumbnail %+v", got)
+	}
+
+	if _, ok := (&core.VideoInfo{}).PickThumbnail(0, ""); ok {
+		t.Fatal("expected no thumbnail")
+	}
+}
@@ -0,0 +1,165 @@
+// Package urls canonicalizes video page URLs and identifies the site and video
+// they refer to, so that differently written links to the same video share
+// caches and history.
+package urls
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// GenericSite is reported for URLs that no registered site recognizes.
+const GenericSite = "generic"
+
+// Site describes how to canonicalize the URLs of one website.
+type Site struct {
+	Name  string   // Ex: "youtube"
+	Hosts []string // host names handled by this site, without port
+
+	// Canonicalize receives a URL of one of Hosts, already stripped of common
+	// tracking parameters, and returns its canonical form and video ID. It
+	// returns an empty ID when u does not point at a single video.
+	Canonicalize func(u *url.URL) (canonical *url.URL, videoID string)
+}
+
+// Resolved is the result of resolving a URL against a Registry.
+type Resolved struct {
+	Site    string // Ex: "youtube", or GenericSite
+	VideoID string // empty when the URL is not a single video of a known site
+	URL     string // canonical URL to hand to yt-dlp
+}
+
+// Key returns a stable identifier for the resolved video, suitable for cache keys.
+func (r Resolved) Key() string {
+	if r.VideoID != "" {
+		return r.Site + ":" + r.VideoID
+	}
+
+	return r.Site + ":" + r.URL
+}
+
+// Registry maps host names to the site that handles them.
+type Registry struct {
+	mu     sync.RWMutex
+	byHost map[string]*Site
+}
+
+func NewRegistry() *Registry {
+	return &Registry{byHost: map[string]*Site{}}
+}
+
+// Register adds s to the registry, replacing any site previously registered for the same hosts.
+func (r *Registry) Register(s Site) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	site := s
+	for _, host := range s.Hosts {
+		r.byHost[strings.ToLower(host)] = &site
+	}
+}
+
+// Returns the names of all registered sites in alphabetical order.
+func (r *Registry) Sites() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := map[string]bool{}
+	var names []string
+	for _, s := range r.byHost {
+		if !seen[s.Name] {
+			seen[s.Name] = true
+			names = append(names, s.Name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Resolve canonicalizes raw and identifies its site and video ID. Inputs that
+// are not absolute http(s) URLs (such as yt-dlp's "ytsearch:" queries) are
+// returned trimmed but otherwise unchanged.
+func (r *Registry) Resolve(raw string) Resolved {
+	raw = strings.TrimSpace(raw)
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Resolved{Site: GenericSite, URL: raw}
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = u.Hostname()
+	}
+
+	stripTrackingParams(u)
+
+	r.mu.RLock()
+	site, ok := r.byHost[u.Hostname()]
+	r.mu.RUnlock()
+
+	if !ok {
+		return Resolved{Site: GenericSite, URL: u.String()}
+	}
+
+	canonical, id := site.Canonicalize(u)
+
+	return Resolved{Site: site.Name, VideoID: id, URL: canonical.String()}
+}
+
+// Default is the registry used by the package-level functions.
+var Default = NewRegistry()
+
+// Register adds s to the Default registry.
+func Register(s Site) {
+	Default.Register(s)
+}
+
+// Resolve resolves raw against the Default registry.
+func Resolve(raw string) Resolved {
+	return Default.Resolve(raw)
+}
+
+// trackingParams are removed from every URL; parameters starting with "utm_" are removed too.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+}
+
+// Removes the tracking parameters from the query of u, leaving the other
+// pairs as they were sent: same order, escaping and separators.
+func stripTrackingParams(u *url.URL) {
+	if u.RawQuery == "" {
+		return
+	}
+
+	pairs := strings.Split(u.RawQuery, "&")
+	kept := pairs[:0:0]
+	for _, pair := range pairs {
+		name, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+
+		name = strings.ToLower(name)
+		if !trackingParams[name] && !strings.HasPrefix(name, "utm_") {
+			kept = append(kept, pair)
+		}
+	}
+
+	if len(kept) < len(pairs) {
+		u.RawQuery = strings.Join(kept, "&")
+	}
+}
@@ -0,0 +1,111 @@
+package urls_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core/urls"
+)
+
+const watchURL = "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+
+func TestResolveYouTubeVariants(t *testing.T) {
+	tests := []string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://youtube.com/watch?v=dQw4w9WgXcQ&list=PL123&index=4",
+		"http://m.youtube.com/watch?v=dQw4w9WgXcQ&feature=share",
+		"https://music.youtube.com/watch?v=dQw4w9WgXcQ&si=abcdef",
+		"https://youtu.be/dQw4w9WgXcQ?si=abcdef&t=42",
+		"https://www.youtube.com/shorts/dQw4w9WgXcQ",
+		"https://www.youtube.com/embed/dQw4w9WgXcQ?autoplay=1",
+		"https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ",
+		"https://www.youtube.com/live/dQw4w9WgXcQ?utm_source=x",
+		"  HTTPS://WWW.YOUTUBE.COM:443/watch?v=dQw4w9WgXcQ#comments  ",
+	}
+
+	for _, raw := range tests {
+		got := urls.Resolve(raw)
+
+		if got.Site != "youtube" || got.VideoID != "dQw4w9WgXcQ" || got.URL != watchURL {
+			t.Errorf("Resolve(%q) = %+v", raw, got)
+		}
+
+		if got.Key() != "youtube:dQw4w9WgXcQ" {
+			t.Errorf("Resolve(%q).Key() = %q", raw, got.Key())
+		}
+	}
+}
+
+func TestResolveYouTubePlaylistKeepsList(t *testing.T) {
+	got := urls.Resolve("https://www.youtube.com/playlist?list=PL123&si=abc")
+
+	if got.Site != "youtube" || got.VideoID != "" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+
+	if got.URL != "https://www.youtube.com/playlist?list=PL123" {
+		t.Fatalf("unexpected canonical URL: %s", got.URL)
+	}
+}
+
+func TestResolveGenericStripsTracking(t *testing.T) {
+	got := urls.Resolve("https://Example.com/video/1?utm_source=a&b=2&fbclid=x&a=1#frag")
+
+	if got.Site != urls.GenericSite || got.VideoID != "" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+
+	if got.URL != "https://example.com/video/1?b=2&a=1" {
+		t.Fatalf("unexpected canonical URL: %s", got.URL)
+	}
+
+	if got.Key() != "generic:https://example.com/video/1?b=2&a=1" {
+		t.Fatalf("unexpected key: %s", got.Key())
+	}
+}
+
+func TestResolveGenericKeepsTheQueryAsSent(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://example.com/v?z=1&flag&a=b%20c&path=a;b":            "https://example.com/v?z=1&flag&a=b%20c&path=a;b",
+		"https://example.com/v?z=1&flag&UTM_Source=x&a=b+c&path=a;b": "https://example.com/v?z=1&flag&a=b+c&path=a;b",
+		"https://example.com/v?fbclid=x":                             "https://example.com/v",
+	} {
+		if got := urls.Resolve(raw); got.URL != want {
+			t.Errorf("Resolve(%q) = %s, want %s", raw, got.URL, want)
+		}
+	}
+}
+
+func TestResolveNonURLPassesThrough(t *testing.T) {
+	got := urls.Resolve(" ytsearch:some song ")
+
+	if got.Site != urls.GenericSite || got.URL != "ytsearch:some song" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+}
+
+func TestRegistryCustomSite(t *testing.T) {
+	r := urls.NewRegistry()
+	r.Register(urls.Site{
+		Name:  "example",
+		Hosts: []string{"videos.example.com"},
+		Canonicalize: func(u *url.URL) (*url.URL, string) {
+			id := u.Query().Get("id")
+			return &url.URL{Scheme: "https", Host: "videos.example.com", Path: "/v/" + id}, id
+		},
+	})
+
+	got := r.Resolve("http://videos.example.com/play?id=42&utm_medium=mail")
+
+	if got.Site != "example" || got.VideoID != "42" || got.URL != "https://videos.example.com/v/42" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+
+	if sites := r.Sites(); len(sites) != 1 || sites[0] != "example" {
+		t.Fatalf("unexpected sites: %v", sites)
+	}
+
+	if got := r.Resolve(watchURL); got.Site != urls.GenericSite {
+		t.Fatalf("expected sites of other registries not to leak, got %+v", got)
+	}
+}
@@ -0,0 +1,90 @@
+package urls
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// YouTubeSite is the site name reported for YouTube URLs.
+const YouTubeSite = "youtube"
+
+var youtubeIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// Path prefixes that are followed by a video ID, as in "/shorts/<id>".
+var youtubeIDPathPrefixes = []string{"/shorts/", "/embed/", "/live/", "/v/", "/e/"}
+
+// Query parameters that change what a YouTube page URL points at; everything else
+// (share tokens like "si", playlist position, start time, ...) is dropped.
+var youtubeKeptParams = map[string]bool{
+	"v":    true,
+	"list": true,
+}
+
+func init() {
+	Register(Site{
+		Name: YouTubeSite,
+		Hosts: []string{
+			"youtube.com",
+			"www.youtube.com",
+			"m.youtube.com",
+			"music.youtube.com",
+			"youtu.be",
+			"youtube-nocookie.com",
+			"www.youtube-nocookie.com",
+		},
+		Canonicalize: canonicalizeYouTube,
+	})
+}
+
+func canonicalizeYouTube(u *url.URL) (*url.URL, string) {
+	if id := youtubeVideoID(u); id != "" {
+		return &url.URL{
+			Scheme:   "https",
+			Host:     "www.youtube.com",
+			Path:     "/watch",
+			RawQuery: url.Values{"v": {id}}.Encode(),
+		}, id
+	}
+
+	// Not a single video (playlist, channel, ...): keep the page but drop noise.
+	q := u.Query()
+	for name := range q {
+		if !youtubeKeptParams[name] {
+			q.Del(name)
+		}
+	}
+
+	host := u.Hostname()
+	if host != "music.youtube.com" {
+		host = "www.youtube.com"
+	}
+
+	return &url.URL{Scheme: "https", Host: host, Path: u.Path, RawQuery: q.Encode()}, ""
+}
+
+func youtubeVideoID(u *url.URL) string {
+	var id string
+
+	switch {
+	case u.Hostname() == "youtu.be":
+		id = strings.Trim(u.Path, "/")
+
+	case u.Path == "/watch":
+		id = u.Query().Get("v")
+
+	default:
+		for _, prefix := range youtubeIDPathPrefixes {
+			if rest, ok := strings.CutPrefix(u.Path, prefix); ok {
+				id, _, _ = strings.Cut(rest, "/")
+				break
+			}
+		}
+	}
+
+	if !youtubeIDPattern.MatchString(id) {
+		return ""
+	}
+
+	return id
+}
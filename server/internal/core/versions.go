@@ -0,0 +1,364 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/jsonfile"
+)
+
+const versionsStateFile = "state.json"
+
+var (
+	// ErrInvalidBinary is returned by Install when the new binary fails verification.
+	ErrInvalidBinary = errors.New("invalid yt-dlp binary")
+	// ErrNoPreviousVersion is returned by Rollback when there is nothing to roll back to.
+	ErrNoPreviousVersion = errors.New("no previous yt-dlp version")
+
+	unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// InstalledBinary is a yt-dlp binary kept in the versions directory.
+type InstalledBinary struct {
+	Name        string    `json:"name"` // directory inside the versions directory
+	Version     string    `json:"version"`
+	SHA256      string    `json:"sha256"`
+	InstalledAt time.Time `json:"installed_at"`
+	Path        string    `json:"path"`
+}
+
+type versionsState struct {
+	Current  *InstalledBinary `json:"current,omitempty"`
+	Previous *InstalledBinary `json:"previous,omitempty"`
+}
+
+// BinaryVersions manages yt-dlp binaries installed from local files, keeping the
+// current one and the one it replaced so an update can be rolled back.
+//
+// Layout: <Dir>/state.json and one <version>-<sha256 prefix>/ directory per binary.
+type BinaryVersions struct {
+	Dir string
+
+	// InUse returns the path of the yt-dlp binary in use, if any. The first
+	// install keeps a copy of it as the previous version to roll back to.
+	InUse func() string
+
+	mu sync.Mutex
+}
+
+func NewBinaryVersions(dir string) *BinaryVersions {
+	return &BinaryVersions{Dir: dir}
+}
+
+// Returns the current and previous binaries; either is nil when not set.
+func (v *BinaryVersions) State() (current, previous *InstalledBinary, err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	st, err := v.load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return st.Current, st.Previous, nil
+}
+
+// Install copies the binary read from src into the versions directory, checks
+// its SHA-256 against wantSHA256 (when not empty), verifies that it runs and
+// makes it current. The binary it replaces becomes the previous version; on the
+// first install, that is the binary returned by InUse.
+func (v *BinaryVersions) Install(ctx context.Context, src io.Reader, wantSHA256 string) (*InstalledBinary, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.installLocked(ctx, src, wantSHA256)
+}
+
+func (v *BinaryVersions) installLocked(ctx context.Context, src io.Reader, wantSHA256 string) (*InstalledBinary, error) {
+	if err := os.MkdirAll(v.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create versions directory: %v", err)
+	}
+
+	installed, staging, err := v.stage(ctx, src, wantSHA256)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	st, err := v.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if st.Current != nil && st.Current.Name == installed.Name {
+		return st.Current, nil
+	}
+
+	if st.Current == nil {
+		st.Current = v.adoptInUse(ctx, installed.Name)
+	}
+
+	if err := v.place(installed, staging); err != nil {
+		return nil, err
+	}
+
+	next := versionsState{Current: installed, Previous: st.Current}
+	if err := v.save(next); err != nil {
+		return nil, err
+	}
+
+	v.prune(next)
+
+	return v.withPath(installed), nil
+}
+
+// Copies the binary read from src into a staging directory inside the
+// versions directory and verifies it. It returns the binary, not yet placed,
+// and the staging directory, which the caller removes.
+func (v *BinaryVersions) stage(ctx context.Context, src io.Reader, wantSHA256 string) (*InstalledBinary, string, error) {
+	staging, err := os.MkdirTemp(v.Dir, ".staging-")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create staging directory: %v", err)
+	}
+
+	stagedPath := filepath.Join(staging, ScriptName())
+
+	sum, err := writeExecutable(stagedPath, src)
+	if err != nil {
+		os.RemoveAll(staging)
+		return nil, "", err
+	}
+
+	if want := strings.ToLower(strings.TrimSpace(wantSHA256)); want != "" && want != sum {
+		os.RemoveAll(staging)
+		return nil, "", fmt.Errorf("%w: sha256 mismatch: expected %s, got %s", ErrInvalidBinary, want, sum)
+	}
+
+	version, err := sandboxedVersion(ctx, stagedPath, staging)
+	if err != nil {
+		os.RemoveAll(staging)
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidBinary, err)
+	}
+
+	return &InstalledBinary{
+		Name:        unsafeNameChars.ReplaceAllString(version, "_") + "-" + sum[:12],
+		Version:     version,
+		SHA256:      sum,
+		InstalledAt: time.Now().UTC(),
+	}, staging, nil
+}
+
+// Moves the binary staged in staging to its own directory.
+func (v *BinaryVersions) place(b *InstalledBinary, staging string) error {
+	dest := filepath.Join(v.Dir, b.Name)
+	os.RemoveAll(dest)
+
+	// The sandbox files (cache, config) are discarded; only the binary is kept.
+	if err := os.Mkdir(dest, 0o755); err != nil {
+		return fmt.Errorf("failed to install yt-dlp: %v", err)
+	}
+	if err := os.Rename(filepath.Join(staging, ScriptName()), filepath.Join(dest, ScriptName())); err != nil {
+		os.RemoveAll(dest)
+		return fmt.Errorf("failed to install yt-dlp: %v", err)
+	}
+
+	return nil
+}
+
+// Copies the binary returned by InUse into the versions directory, so the
+// first install can be rolled back to it. It returns nil when there is none,
+// when it is the binary being installed, or when it cannot be copied.
+func (v *BinaryVersions) adoptInUse(ctx context.Context, installing string) *InstalledBinary {
+	if v.InUse == nil {
+		return nil
+	}
+
+	path := v.InUse()
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Println("WARNING: the yt-dlp binary in use cannot be rolled back to: ", err)
+		return nil
+	}
+	defer f.Close()
+
+	adopted, staging, err := v.stage(ctx, f, "")
+	if err == nil {
+		defer os.RemoveAll(staging)
+		if adopted.Name == installing {
+			return nil
+		}
+		err = v.place(adopted, staging)
+	}
+	if err != nil {
+		log.Println("WARNING: the yt-dlp binary in use cannot be rolled back to: ", err)
+		return nil
+	}
+
+	return adopted
+}
+
+// InstallFile installs the binary at path, see Install.
+func (v *BinaryVersions) InstallFile(ctx context.Context, path, wantSHA256 string) (*InstalledBinary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	return v.Install(ctx, f, wantSHA256)
+}
+
+// Rollback makes the previous binary current again, and the current one previous.
+func (v *BinaryVersions) Rollback() (*InstalledBinary, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	st, err := v.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if st.Previous == nil {
+		return nil, ErrNoPreviousVersion
+	}
+
+	if err := v.save(versionsState{Current: st.Previous, Previous: st.Current}); err != nil {
+		return nil, err
+	}
+
+	return st.Previous, nil
+}
+
+func (v *BinaryVersions) load() (versionsState, error) {
+	var st versionsState
+
+	data, err := os.ReadFile(filepath.Join(v.Dir, versionsStateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, fmt.Errorf("failed to read yt-dlp versions: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("invalid yt-dlp versions file: %v", err)
+	}
+
+	v.withPath(st.Current)
+	v.withPath(st.Previous)
+
+	return st, nil
+}
+
+func (v *BinaryVersions) save(st versionsState) error {
+	if err := jsonfile.Write(filepath.Join(v.Dir, versionsStateFile), st); err != nil {
+		return fmt.Errorf("failed to write yt-dlp versions: %v", err)
+	}
+
+	return nil
+}
+
+// Removes installed binaries that are neither current nor previous.
+func (v *BinaryVersions) prune(st versionsState) {
+	entries, err := os.ReadDir(v.Dir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if (st.Current != nil && st.Current.Name == name) || (st.Previous != nil && st.Previous.Name == name) {
+			continue
+		}
+
+		os.RemoveAll(filepath.Join(v.Dir, name))
+	}
+}
+
+// Fills in b.Path, which is not stored so the directory can be moved.
+func (v *BinaryVersions) withPath(b *InstalledBinary) *InstalledBinary {
+	if b != nil {
+		b.Path = filepath.Join(v.Dir, b.Name, ScriptName())
+	}
+
+	return b
+}
+
+// Writes src to path as an executable file and returns its hex SHA-256.
+func writeExecutable(path string, src io.Reader) (string, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o755)
+	if err != nil {
+		return "", fmt.Errorf("failed to write yt-dlp: %v", err)
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), src)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write yt-dlp: %v", err)
+	}
+
+	if n == 0 {
+		return "", fmt.Errorf("%w: empty file", ErrInvalidBinary)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Runs path --version inside dir with a minimal environment, so a bad binary
+// cannot pick up or overwrite the server's yt-dlp configuration and cache.
+func sandboxedVersion(ctx context.Context, path, dir string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, versionCheckTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, "--ignore-config", "--no-cache-dir", "--version")
+	cmd.Dir = dir
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + dir,
+		"XDG_CONFIG_HOME=" + dir,
+		"XDG_CACHE_HOME=" + dir,
+		"TMPDIR=" + dir,
+	}
+	if root := os.Getenv("SYSTEMROOT"); root != "" {
+		cmd.Env = append(cmd.Env, "SYSTEMROOT="+root)
+	}
+
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("--version failed: %v, details: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	version := strings.TrimSpace(out.String())
+	if version == "" || strings.ContainsAny(version, "\n\r") {
+		return "", fmt.Errorf("unexpected --version output %q", version)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,181 @@
+package core_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+func versionScript(version string) string {
+	return "#!/bin/sh\necho " + version + "\n"
+}
+
+func TestBinaryVersionsInstallAndRollback(t *testing.T) {
+	t.Setenv("YT_DLP_SCRIPT_NAME", "yt-dlp")
+	v := core.NewBinaryVersions(t.TempDir())
+	ctx := context.Background()
+
+	first, err := v.Install(ctx, strings.NewReader(versionScript("2025.01.01")), "")
+	if err != nil {
+		t.Fatalf("install failed: %v", err)
+	}
+	if first.Version != "2025.01.01" {
+		t.Fatalf("expected version 2025.01.01, got %q", first.Version)
+	}
+	if _, err := os.Stat(first.Path); err != nil {
+		t.Fatalf("installed binary missing: %v", err)
+	}
+
+	if _, err := v.Rollback(); !errors.Is(err, core.ErrNoPreviousVersion) {
+		t.Fatalf("expected ErrNoPreviousVersion, got %v", err)
+	}
+
+	second, err := v.Install(ctx, strings.NewReader(versionScript("2025.02.02")), "")
+	if err != nil {
+		t.Fatalf("install failed: %v", err)
+	}
+
+	current, previous, err := v.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Version != second.Version || previous.Version != first.Version {
+		t.Fatalf("unexpected state: current %+v, previous %+v", current, previous)
+	}
+
+	rolledBack, err := v.Rollback()
+	if err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+	if rolledBack.Path != first.Path {
+		t.Fatalf("expected rollback to %s, got %s", first.Path, rolledBack.Path)
+	}
+
+	current, previous, _ = v.State()
+	if current.Version != "2025.01.01" || previous.Version != "2025.02.02" {
+		t.Fatalf("rollback did not swap versions: current %+v, previous %+v", current, previous)
+	}
+}
+
+func TestBinaryVersionsKeepsOnlyCurrentAndPrevious(t *testing.T) {
+	dir := t.TempDir()
+	v := core.NewBinaryVersions(dir)
+
+	for _, version := range []string{"1", "2", "3"} {
+		if _, err := v.Install(context.Background(), strings.NewReader(versionScript(version)), ""); err != nil {
+			t.Fatalf("install %s failed: %v", version, err)
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	dirs := 0
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs++
+		}
+	}
+
+	if dirs != 2 {
+		t.Fatalf("expected 2 installed versions, got %d", dirs)
+	}
+}
+
+func TestBinaryVersionsChecksSHA256(t *testing.T) {
+	script := versionScript("2025.01.01")
+	sum := sha256.Sum256([]byte(script))
+
+	v := core.NewBinaryVersions(t.TempDir())
+
+	_, err := v.Install(context.Background(), strings.NewReader(script), strings.Repeat("0", 64))
+	if !errors.Is(err, core.ErrInvalidBinary) {
+		t.Fatalf("expected ErrInvalidBinary for a wrong checksum, got %v", err)
+	}
+
+	if _, err := v.Install(context.Background(), strings.NewReader(script), hex.EncodeToString(sum[:])); err != nil {
+		t.Fatalf("install with matching checksum failed: %v", err)
+	}
+}
+
+func TestBinaryVersionsRejectsBinaryThatDoesNotRun(t *testing.T) {
+	dir := t.TempDir()
+	v := core.NewBinaryVersions(dir)
+
+	_, err := v.Install(context.Background(), strings.NewReader("#!/bin/sh\nexit 3\n"), "")
+	if !errors.Is(err, core.ErrInvalidBinary) {
+		t.Fatalf("expected ErrInvalidBinary, got %v", err)
+	}
+
+	if current, _, _ := v.State(); current != nil {
+		t.Fatalf("failed install must not change the current version, got %+v", current)
+	}
+
+	if matches, _ := filepath.Glob(filepath.Join(dir, ".staging-*")); len(matches) != 0 {
+		t.Fatalf("staging directories left behind: %v", matches)
+	}
+}
+
+func TestInitYTCoreUsesVersionsDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("YT_DLP_VERSIONS_DIR", dir)
+	t.Setenv("YT_DLP_SCRIPT_NAME", "yt-dlp")
+	t.Setenv("YT_DLP_PATH", "")
+
+	installed, err := core.NewBinaryVersions(dir).Install(context.Background(), strings.NewReader(versionScript("2030.01.01")), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	yt, err := core.InitYTCore()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if path, version, source := yt.Binary(); path != installed.Path || version != "2030.01.01" || source != "versions dir" {
+		t.Fatalf("expected installed binary, got %s %s from %s", path, version, source)
+	}
+}
+
+func TestBinaryVersionsKeepsTheBinaryInUseOnFirstInstall(t *testing.T) {
+	t.Setenv("YT_DLP_SCRIPT_NAME", "yt-dlp")
+	dir := t.TempDir()
+	v := core.NewBinaryVersions(dir)
+	ctx := context.Background()
+
+	inUse := filepath.Join(t.TempDir(), "yt-dlp")
+	if err := os.WriteFile(inUse, []byte(versionScript("2024.12.12")), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	v.InUse = func() string { return inUse }
+
+	// a rejected install leaves the versions directory alone
+	if _, err := v.Install(ctx, strings.NewReader("#!/bin/sh\nexit 3\n"), ""); !errors.Is(err, core.ErrInvalidBinary) {
+		t.Fatalf("expected ErrInvalidBinary, got %v", err)
+	}
+	if current, previous, _ := v.State(); current != nil || previous != nil {
+		t.Fatalf("expected nothing installed, got current %+v, previous %+v", current, previous)
+	}
+
+	if _, err := v.Install(ctx, strings.NewReader(versionScript("2025.01.01")), ""); err != nil {
+		t.Fatalf("install failed: %v", err)
+	}
+
+	current, previous, _ := v.State()
+	if current.Version != "2025.01.01" || previous == nil || previous.Version != "2024.12.12" || previous.Path == inUse {
+		t.Fatalf("expected a copy of the binary in use as previous, got current %+v, previous %+v", current, previous)
+	}
+
+	// only the first install adopts it
+	if _, err := v.Install(ctx, strings.NewReader(versionScript("2025.02.02")), ""); err != nil {
+		t.Fatalf("install failed: %v", err)
+	}
+	if _, previous, _ := v.State(); previous.Version != "2025.01.01" {
+		t.Fatalf("expected the replaced install as previous, got %+v", previous)
+	}
+}
@@ -3,11 +3,16 @@ package core
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 )
 
 type DownloadType int
@@ -26,32 +31,118 @@ type DownloadConfig struct {
 	Quality    int    // Ex: 0, 5, 6, 7, etc.
 	FormatNote string // Ex: "720p60", "1080p60", 480p", etc.
 	IsYouTube  bool   // Only true for YouTube URLs; used to enable audio+video merge safely.
+
+	// Exact format_ids from the video info; they take precedence over Quality
+	// and FormatNote. A video-only VideoFormatID without AudioFormatID is
+	// merged with the best audio.
+	VideoFormatID string
+	AudioFormatID string
+
+	// Sections limits the download to parts of the video. ForceKeyframes
+	// re-encodes around the cuts so they fall exactly on the requested times
+	// instead of the nearest keyframes; it is slower.
+	Sections       []Section
+	ForceKeyframes bool
+
+	// SplitChapters writes one file per chapter; only DownloadChapters honours it.
+	SplitChapters bool
+
+	// Embed writes tags, artwork, chapters or the info JSON into the file, and
+	// Metadata overrides tags. Both need ffmpeg, and make Download wait for
+	// yt-dlp to finish a file before streaming it.
+	Embed    EmbedOptions
+	Metadata MetadataOverrides
+
+	Progress ProgressFunc // optional; receives yt-dlp's progress lines
 }
 
+// YTCore runs yt-dlp. Once it is shared between goroutines, read the binary
+// with Binary and replace it with SetBinary rather than using the fields.
 type YTCore struct {
-	BinaryPath string
+	BinaryPath    string
+	BinaryVersion string // yt-dlp --version output, recorded when the binary was located
+	Source        string // where BinaryPath was found, see Candidate.Source
+
+	mu sync.RWMutex
 }
 
+// Locates a working yt-dlp binary: YT_DLP_PATH, then the versions directory,
+// then $PATH, then the scripts directory next to the executable, then
+// ../scripts relative to the working directory. The error lists every
+// candidate tried, and an installed or $PATH binary that is passed over is
+// logged.
 func InitYTCore() (*YTCore, error) {
-	cwd := Getwd()
+	candidates := defaultCandidates()
 
-	ytDlpScriptName := os.Getenv("YT_DLP_SCRIPT_NAME")
+	yt, err := resolve(candidates)
+	if err != nil {
+		return nil, err
+	}
 
-	binPath := filepath.Join(cwd, "..", "scripts", ytDlpScriptName)
+	for _, c := range candidates {
+		if c.Err != nil || filepath.Clean(c.Path) == filepath.Clean(yt.BinaryPath) {
+			continue
+		}
 
-	if _, err := os.Stat(binPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("%s binary not found at path: %s", ytDlpScriptName, binPath)
+		switch {
+		case c.Source == "versions dir" && yt.Source == "YT_DLP_PATH":
+			log.Printf("WARNING: the yt-dlp installed in the versions directory (%s) is not used while YT_DLP_PATH is set", c.Path)
+		case c.Source == "PATH" && yt.Source == "versions dir":
+			log.Printf("WARNING: %s in $PATH is not used while yt-dlp %s is installed in the versions directory", c.Path, yt.BinaryVersion)
+		}
 	}
 
-	return &YTCore{
-		BinaryPath: binPath,
-	}, nil
+	return yt, nil
 }
 
-func (yt *YTCore) GetVideoInfo(url string) (string, error) {
-	args := []string{"--dump-json", url}
+// Uses the yt-dlp binary named by YT_DLP_SCRIPT_NAME inside scriptsDir.
+func InitYTCoreIn(scriptsDir string) (*YTCore, error) {
+	return resolve([]Candidate{{Path: filepath.Join(scriptsDir, ScriptName()), Source: "scripts dir"}})
+}
+
+// Returns the binary in use, where it came from and its recorded version.
+func (yt *YTCore) Binary() (path, version, source string) {
+	yt.mu.RLock()
+	defer yt.mu.RUnlock()
+
+	return yt.BinaryPath, yt.BinaryVersion, yt.Source
+}
+
+// Replaces the binary used by commands started from now on; running commands
+// keep the old one.
+func (yt *YTCore) SetBinary(path, version, source string) {
+	yt.mu.Lock()
+	defer yt.mu.Unlock()
 
-	cmd := exec.Command(yt.BinaryPath, args...)
+	yt.BinaryPath, yt.BinaryVersion, yt.Source = path, version, source
+}
+
+func (yt *YTCore) binaryPath() string {
+	yt.mu.RLock()
+	defer yt.mu.RUnlock()
+
+	return yt.BinaryPath
+}
+
+// GetVersion runs yt-dlp --version.
+func (yt *YTCore) GetVersion(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, yt.binaryPath(), "--version")
+
+	var out, stderr bytes.Buffer
+
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("error getting yt-dlp version: %v, details: %s", err, stderr.String())
+	}
+
+	return strings.TrimSpace(out.String()), nil
+}
+
+// FetchVideoInfo runs yt-dlp --dump-json and parses its output.
+func (yt *YTCore) FetchVideoInfo(ctx context.Context, url string) (*VideoInfo, error) {
+	cmd := exec.CommandContext(ctx, yt.binaryPath(), "--dump-json", url)
 
 	var out, stderr bytes.Buffer
 
@@ -59,20 +150,183 @@ func (yt *YTCore) GetVideoInfo(url string) (string, error) {
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("error getting video info: %v, details: %s", err, stderr.String())
+		return nil, runError(ctx, err, stderr.String())
+	}
+
+	return ParseVideoInfo(out.Bytes())
+}
+
+// Download starts yt-dlp and returns its output stream. Reading past the end
+// of the stream reports the process exit status, and closing the stream early
+// kills the process.
+func (yt *YTCore) Download(ctx context.Context, cfg DownloadConfig) (io.ReadCloser, error) {
+	if cfg.needsFile() {
+		return yt.downloadFile(ctx, cfg)
+	}
+
+	stdout, cmd, stderr, err := yt.startDownload(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &processReader{ReadCloser: stdout, ctx: ctx, cmd: cmd, stderr: stderr}, nil
+}
+
+// chapterTemplate names the files written by DownloadChapters, relative to its
+// directory. The section number keeps them in order when sorted by name.
+const chapterTemplate = "chapters/%(section_number)03d - %(section_title).150B.%(ext)s"
+
+// DownloadChapters downloads the media selected by cfg into dir, split into one
+// file per chapter, and returns the paths of the chapter files in order.
+func (yt *YTCore) DownloadChapters(ctx context.Context, cfg DownloadConfig, dir string) ([]string, error) {
+	args, err := downloadArgs(cfg, "-P", dir, "-o", "source.%(ext)s", "-o", "chapter:"+chapterTemplate, "--split-chapters")
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, yt.binaryPath(), args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if cfg.Progress != nil {
+		// progress goes to stdout when the media is written to files
+		lw := &lineWriter{fn: cfg.Progress}
+		cmd.Stdout = lw
+		cmd.Stderr = io.MultiWriter(&stderr, lw)
+	}
+
+	if err := cmd.Run(); err != nil {
+		return nil, runError(ctx, err, stderr.String())
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "chapters", "*"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("yt-dlp wrote no chapter files, details: %s", stderr.String())
 	}
+	sort.Strings(files)
 
-	return out.String(), nil
+	return files, nil
 }
 
-func (yt *YTCore) DownloadBinaryCtx(ctx context.Context, cfg DownloadConfig) (io.ReadCloser, *exec.Cmd, error) {
+// downloadFile runs yt-dlp into a temporary directory, for post-processors
+// that cannot work on a stream, and returns the finished file. Closing it
+// removes the directory.
+func (yt *YTCore) downloadFile(ctx context.Context, cfg DownloadConfig) (io.ReadCloser, error) {
+	dir, err := os.MkdirTemp("", "yt-dlp-download-*")
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := yt.runToFile(ctx, cfg, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return &tempFile{File: f, dir: dir}, nil
+}
+
+func (yt *YTCore) runToFile(ctx context.Context, cfg DownloadConfig, dir string) (*os.File, error) {
+	// --print implies --quiet, leaving the final path as the only output
+	args, err := downloadArgs(cfg, "-P", dir, "-o", "media.%(ext)s", "--print", "after_move:filepath")
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, yt.binaryPath(), args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if cfg.Progress != nil {
+		cmd.Stderr = io.MultiWriter(&stderr, &lineWriter{fn: cfg.Progress})
+	}
+
+	if err := cmd.Run(); err != nil {
+		return nil, runError(ctx, err, stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	path := filepath.Clean(strings.TrimSpace(lines[len(lines)-1]))
+	if path == "." || filepath.Dir(path) != filepath.Clean(dir) {
+		return nil, fmt.Errorf("yt-dlp did not report the downloaded file, details: %s", stderr.String())
+	}
+
+	return os.Open(path)
+}
+
+// tempFile is a file in a directory of its own, removed on Close.
+type tempFile struct {
+	*os.File
+	dir string
+}
+
+func (t *tempFile) Close() error {
+	err := t.File.Close()
+	os.RemoveAll(t.dir)
+
+	return err
+}
+
+// downloadArgs returns the yt-dlp arguments downloading cfg, with the output
+// options in output.
+func downloadArgs(cfg DownloadConfig, output ...string) ([]string, error) {
+	fmtSel, err := FormatSelector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	args := []string{
 		"--no-part",
 		"--no-continue",
 		"--concurrent-fragments", fmt.Sprintf("%d", GetNumCPU()),
 		"--downloader-args", "ffmpeg:-threads=0",
-		"-o",
-		"-",
+	}
+	args = append(args, output...)
+
+	if cfg.Progress != nil {
+		args = append(args, "--progress", "--newline")
+	}
+
+	args = append(args, containerArgs(cfg)...)
+	args = append(args, "-f", fmtSel, cfg.URL)
+
+	return args, nil
+}
+
+func (yt *YTCore) startDownload(ctx context.Context, cfg DownloadConfig) (io.ReadCloser, *exec.Cmd, *bytes.Buffer, error) {
+	args, err := downloadArgs(cfg, "-o", "-")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, yt.binaryPath(), args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if cfg.Progress != nil {
+		cmd.Stderr = io.MultiWriter(&stderr, &lineWriter{fn: cfg.Progress})
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create stdout pipe: %v", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to start yt-dlp: %v, details: %s", err, stderr.String())
+	}
+
+	return stdout, cmd, &stderr, nil
+}
+
+// FormatSelector returns the yt-dlp "-f" expression used for cfg.
+func FormatSelector(cfg DownloadConfig) (string, error) {
+	if cfg.VideoFormatID != "" || cfg.AudioFormatID != "" {
+		return exactFormatSelector(cfg)
 	}
 
 	var fmtSel string
@@ -139,27 +393,101 @@ func (yt *YTCore) DownloadBinaryCtx(ctx context.Context, cfg DownloadConfig) (io
 			h := videoHeights[idx]
 			fmtSel = fmt.Sprintf("b[height<=?%d]/bv*[height<=?%d]+ba/b", h, h)
 		}
-		args = append(args, "--merge-output-format", "mkv")
 
 	default:
-		return nil, nil, fmt.Errorf("unknown download type")
+		return "", fmt.Errorf("unknown download type")
 	}
 
-	args = append(args, "-f", fmtSel, cfg.URL)
+	return fmtSel, nil
+}
 
-	cmd := exec.CommandContext(ctx, yt.BinaryPath, args...)
+// Returns the selector for explicit format_ids. yt-dlp drops the second audio
+// stream when a muxed format is merged with audio, so "v+ba" is safe for both
+// muxed and video-only formats; a muxed format with an explicit audio format
+// is rejected by the API before it gets here.
+func exactFormatSelector(cfg DownloadConfig) (string, error) {
+	switch cfg.Type {
+	case Audio:
+		if cfg.VideoFormatID != "" {
+			return "", fmt.Errorf("a video format cannot be selected for an audio download")
+		}
+		return cfg.AudioFormatID, nil
 
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	case Video:
+		switch {
+		case cfg.VideoFormatID == "":
+			return fmt.Sprintf("bv+%s/bv*+%s", cfg.AudioFormatID, cfg.AudioFormatID), nil
+		case cfg.AudioFormatID == "":
+			return cfg.VideoFormatID + "+ba/" + cfg.VideoFormatID, nil
+		default:
+			return cfg.VideoFormatID + "+" + cfg.AudioFormatID, nil
+		}
+	}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create stdout pipe: %v", err)
+	return "", fmt.Errorf("unknown download type")
+}
+
+// containerArgs returns the output container, section and post-processing
+// arguments for cfg. They are part of the download cache key.
+func containerArgs(cfg DownloadConfig) []string {
+	var args []string
+	if cfg.Type == Video {
+		args = append(args, "--merge-output-format", "mkv")
 	}
 
-	if err := cmd.Start(); err != nil {
-		return nil, nil, fmt.Errorf("failed to start yt-dlp: %v, details: %s", err, stderr.String())
+	for _, s := range cfg.Sections {
+		args = append(args, "--download-sections", s.Arg())
+	}
+	if len(cfg.Sections) > 0 && cfg.ForceKeyframes {
+		args = append(args, "--force-keyframes-at-cuts")
+	}
+
+	args = append(args, embedArgs(cfg)...)
+
+	return args
+}
+
+type processReader struct {
+	io.ReadCloser
+	ctx    context.Context
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+	waited bool
+}
+
+func (p *processReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if err == io.EOF && !p.waited {
+		p.waited = true
+		if waitErr := p.cmd.Wait(); waitErr != nil {
+			return n, runError(p.ctx, waitErr, p.stderr.String())
+		}
+	}
+
+	return n, err
+}
+
+func (p *processReader) Close() error {
+	if p.waited {
+		return nil
+	}
+
+	p.waited = true
+	_ = p.cmd.Process.Kill()
+	_ = p.cmd.Wait()
+
+	return nil
+}
+
+// Returns the error for a failed yt-dlp run: the context error when the caller
+// gave up, otherwise an *ExtractorError classified from stderr.
+func runError(ctx context.Context, err error, stderr string) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if errors.Is(ctxErr, context.Canceled) {
+			return ctxErr
+		}
+		err = ctxErr
 	}
 
-	return stdout, cmd, nil
+	return ClassifyError(err, stderr)
 }
@@ -51,24 +51,24 @@ func TestInitYTCore(t *testing.T) {
 	}
 }
 
-func TestGetVideoInfo(t *testing.T) {
+func TestFetchVideoInfo(t *testing.T) {
 	fake := createFakeBin(t, `#!/bin/sh
-echo '{"title":"OK"}'
+echo '{"id":"x","title":"OK"}'
 `)
 
 	yt := &core.YTCore{BinaryPath: fake}
 
-	out, err := yt.GetVideoInfo(httpXUrl)
+	info, err := yt.FetchVideoInfo(context.Background(), httpXUrl)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	if out != "{\"title\":\"OK\"}\n" {
-		t.Fatalf("unexpected output: %s", out)
+	if info.Title != "OK" {
+		t.Fatalf("unexpected title: %s", info.Title)
 	}
 }
 
-func TestGetVideoInfoError(t *testing.T) {
+func TestFetchVideoInfoError(t *testing.T) {
 	fake := createFakeBin(t, `#!/bin/sh
 echo "error" >&2
 exit 1
@@ -76,13 +76,13 @@ exit 1
 
 	yt := &core.YTCore{BinaryPath: fake}
 
-	_, err := yt.GetVideoInfo(httpXUrl)
+	_, err := yt.FetchVideoInfo(context.Background(), httpXUrl)
 	if err == nil {
 		t.Fatalf("expected error")
 	}
 }
 
-func TestDownloadBinaryCtx(t *testing.T) {
+func TestDownloadStreamsOutput(t *testing.T) {
 	fake := createFakeBin(t, `#!/bin/sh
 echo -n "STREAMDATA"
 `)
@@ -95,20 +95,104 @@ echo -n "STREAMDATA"
 		Quality: 1,
 	}
 
-	ctx := context.Background()
-	r, cmd, err := yt.DownloadBinaryCtx(ctx, cfg)
+	r, err := yt.Download(context.Background(), cfg)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	defer r.Close()
 
 	buf := new(bytes.Buffer)
-	buf.ReadFrom(r)
+	if _, err := buf.ReadFrom(r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	if buf.String() != "STREAMDATA" {
 		t.Fatalf("unexpected: %s", buf.String())
 	}
+}
+
+func TestDownloadReportsProcessError(t *testing.T) {
+	fake := createFakeBin(t, `#!/bin/sh
+echo -n "PARTIAL"
+echo "boom" >&2
+exit 3
+`)
+
+	yt := &core.YTCore{BinaryPath: fake}
+
+	r, err := yt.Download(context.Background(), core.DownloadConfig{URL: httpXUrl, Type: core.Audio})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(r); err == nil {
+		t.Fatalf("expected process exit error")
+	}
+
+	if buf.String() != "PARTIAL" {
+		t.Fatalf("unexpected: %s", buf.String())
+	}
+}
+
+func TestDownloadChapters(t *testing.T) {
+	fake := createFakeBin(t, `#!/bin/sh
+while [ $# -gt 0 ]; do
+	if [ "$1" = "-P" ]; then dir="$2"; fi
+	shift
+done
+mkdir -p "$dir/chapters"
+echo -n source > "$dir/source.mkv"
+echo -n two > "$dir/chapters/002 - Verse.mkv"
+echo -n one > "$dir/chapters/001 - Intro.mkv"
+`)
+
+	yt := &core.YTCore{BinaryPath: fake}
+	dir := t.TempDir()
+
+	files, err := yt.DownloadChapters(context.Background(), core.DownloadConfig{URL: httpXUrl, Type: core.Video, SplitChapters: true}, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{filepath.Join(dir, "chapters", "001 - Intro.mkv"), filepath.Join(dir, "chapters", "002 - Verse.mkv")}
+	if len(files) != 2 || files[0] != want[0] || files[1] != want[1] {
+		t.Fatalf("unexpected files %v", files)
+	}
+}
+
+func TestDownloadChaptersWithoutChapters(t *testing.T) {
+	fake := createFakeBin(t, `#!/bin/sh
+exit 0
+`)
+
+	yt := &core.YTCore{BinaryPath: fake}
+	if _, err := yt.DownloadChapters(context.Background(), core.DownloadConfig{URL: httpXUrl, Type: core.Audio}, t.TempDir()); err == nil {
+		t.Fatal("expected an error when no chapter files are written")
+	}
+}
+
+func TestFormatSelectorExactIDs(t *testing.T) {
+	for _, tc := range []struct {
+		cfg  core.DownloadConfig
+		want string
+	}{
+		{core.DownloadConfig{Type: core.Video, VideoFormatID: "137", AudioFormatID: "140"}, "137+140"},
+		{core.DownloadConfig{Type: core.Video, VideoFormatID: "137", Quality: 3, FormatNote: "720p"}, "137+ba/137"},
+		{core.DownloadConfig{Type: core.Video, AudioFormatID: "140"}, "bv+140/bv*+140"},
+		{core.DownloadConfig{Type: core.Audio, AudioFormatID: "251"}, "251"},
+	} {
+		got, err := core.FormatSelector(tc.cfg)
+		if err != nil {
+			t.Fatalf("%+v: %v", tc.cfg, err)
+		}
+		if got != tc.want {
+			t.Errorf("%+v: expected %q, got %q", tc.cfg, tc.want, got)
+		}
+	}
 
-	if cmd.Process == nil {
-		t.Fatalf("cmd was not started")
+	if _, err := core.FormatSelector(core.DownloadConfig{Type: core.Audio, VideoFormatID: "137"}); err == nil {
+		t.Fatal("expected an error for a video format in an audio download")
 	}
 }
@@ -0,0 +1,64 @@
+package filename
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Returns an attachment Content-Disposition for name, as described in RFC 6266:
+// an ASCII filename for old clients, where other characters become '_', and
+// the full UTF-8 name in filename* when it differs.
+func ContentDisposition(name string) string {
+	fallback := asciiFallback(name)
+	v := `attachment; filename="` + fallback + `"`
+	if fallback != name {
+		v += "; filename*=UTF-8''" + encodeExtValue(name)
+	}
+
+	return v
+}
+
+func asciiFallback(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= utf8.RuneSelf, r < ' ', r == 0x7f:
+			b.WriteByte('_')
+		case r == '"', r == '\\':
+			// cannot appear in sanitized names, but must not end the quoted string
+			b.WriteByte('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// Percent-encodes s as an RFC 8187 ext-value, keeping only attr-chars.
+func encodeExtValue(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&15])
+	}
+
+	return b.String()
+}
+
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
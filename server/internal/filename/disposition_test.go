@@ -0,0 +1,27 @@
+package filename_test
+
+import (
+	"mime"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/filename"
+)
+
+func TestContentDisposition(t *testing.T) {
+	for name, want := range map[string]string{
+		"video.mp4":     `attachment; filename="video.mp4"`,
+		"Café & co.m4a": `attachment; filename="Caf_ & co.m4a"; filename*=UTF-8''Caf%C3%A9%20&%20co.m4a`,
+		"日本 [abc].webm": `attachment; filename="__ [abc].webm"; filename*=UTF-8''%E6%97%A5%E6%9C%AC%20%5Babc%5D.webm`,
+	} {
+		got := filename.ContentDisposition(name)
+		if got != want {
+			t.Errorf("%q: expected %s, got %s", name, want, got)
+		}
+
+		// clients decoding filename* must get the original name back
+		_, params, err := mime.ParseMediaType(got)
+		if err != nil || params["filename"] != name {
+			t.Errorf("%q: parsed back as %q: %v", name, params["filename"], err)
+		}
+	}
+}
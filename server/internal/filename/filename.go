@@ -0,0 +1,173 @@
+// Package filename names downloads after their video, with output templates
+// in the style of yt-dlp's -o option, Ex: "%(title)s [%(id)s].%(ext)s". Names
+// are sanitized so they are valid on every common filesystem, and sent in an
+// RFC 6266 Content-Disposition header.
+package filename
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultTemplate matches the default of yt-dlp.
+const DefaultTemplate = "%(title)s [%(id)s].%(ext)s"
+
+// MaxBytes bounds the length of a file name, below the 255 bytes most
+// filesystems allow.
+const MaxBytes = 200
+
+// Fields lists the names a template can use.
+var Fields = []string{"id", "title", "uploader", "upload_date", "extractor", "resolution", "type", "ext"}
+
+// Template is a parsed output template. Fields are written "%(name)s" and a
+// literal '%' is written "%%". Missing values render as "NA", like in yt-dlp.
+type Template struct {
+	parts []part
+}
+
+type part struct {
+	text  string
+	field bool
+}
+
+// Parses an output template, rejecting unknown fields.
+func Parse(s string) (*Template, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("filename template is empty")
+	}
+
+	t := &Template{}
+
+	for s != "" {
+		i := strings.IndexByte(s, '%')
+		if i < 0 {
+			t.parts = append(t.parts, part{text: s})
+			break
+		}
+		if i > 0 {
+			t.parts = append(t.parts, part{text: s[:i]})
+		}
+		s = s[i:]
+
+		if strings.HasPrefix(s, "%%") {
+			t.parts = append(t.parts, part{text: "%"})
+			s = s[2:]
+			continue
+		}
+
+		end := strings.Index(s, ")s")
+		if !strings.HasPrefix(s, "%(") || end < 0 {
+			return nil, fmt.Errorf("filename template: expected %%(field)s at %q", s)
+		}
+
+		name := s[2:end]
+		if !slices.Contains(Fields, name) {
+			return nil, fmt.Errorf("filename template: unknown field %q, expected one of %s", name, strings.Join(Fields, ", "))
+		}
+
+		t.parts = append(t.parts, part{text: name, field: true})
+		s = s[end+2:]
+	}
+
+	return t, nil
+}
+
+// Renders the template with fields and sanitizes the result. The name always
+// ends in "." + fields["ext"], even when the template leaves %(ext)s out.
+func (t *Template) Execute(fields map[string]string) string {
+	var b strings.Builder
+	for _, p := range t.parts {
+		switch {
+		case !p.field:
+			b.WriteString(p.text)
+		case fields[p.text] == "":
+			b.WriteString("NA")
+		default:
+			b.WriteString(fields[p.text])
+		}
+	}
+
+	name, ext := b.String(), fields["ext"]
+	if ext != "" {
+		name = strings.TrimSuffix(name, "."+ext)
+	}
+
+	return Sanitize(name, ext)
+}
+
+// windowsReserved are device names Windows refuses as file names, with any extension.
+var windowsReserved = []string{"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9"}
+
+// Returns base + "." + ext made safe for Windows, macOS and Linux: characters
+// they reject are replaced by '_', whitespace runs become a single space,
+// trailing dots and spaces are dropped, reserved device names are prefixed
+// with '_' and the name is cut to MaxBytes without splitting characters.
+func Sanitize(base, ext string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range base {
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		case r == utf8.RuneError, unicode.IsControl(r), strings.ContainsRune(`<>:"/\|?*`, r):
+			r = '_'
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+
+	suffix := ""
+	if ext = sanitizeExt(ext); ext != "" {
+		suffix = "." + ext
+	}
+
+	name := b.String()
+	if len(name)+len(suffix) > MaxBytes {
+		name = truncate(name, MaxBytes-len(suffix))
+	}
+
+	name = strings.TrimLeft(strings.TrimRight(name, ". "), " ")
+	if name == "" {
+		name = "download"
+	}
+
+	stem, _, _ := strings.Cut(name, ".")
+	if slices.Contains(windowsReserved, strings.ToUpper(strings.TrimSpace(stem))) {
+		name = "_" + name
+	}
+
+	return name + suffix
+}
+
+// Keeps the ASCII letters and digits of an extension, in lower case.
+func sanitizeExt(ext string) string {
+	return strings.Map(func(r rune) rune {
+		if r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, ext)
+}
+
+// Cuts s to at most n bytes, on a character boundary.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
@@ -0,0 +1,76 @@
+package filename_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/filename"
+)
+
+func TestTemplateExecute(t *testing.T) {
+	fields := map[string]string{
+		"id":          "dQw4w9WgXcQ",
+		"title":       "Never Gonna Give You Up: 100% <Remastered>",
+		"uploader":    "Rick Astley",
+		"upload_date": "20091025",
+		"resolution":  "1920x1080",
+		"ext":         "webm",
+	}
+
+	for tmpl, want := range map[string]string{
+		filename.DefaultTemplate:                         "Never Gonna Give You Up_ 100% _Remastered_ [dQw4w9WgXcQ].webm",
+		"%(uploader)s - %(title)s":                       "Rick Astley - Never Gonna Give You Up_ 100% _Remastered_.webm",
+		"%(upload_date)s %(resolution)s 50%% %(album)s.": "",
+		"%(extractor)s/%(id)s.%(ext)s":                   "NA_dQw4w9WgXcQ.webm",
+		"%(id)s.mp4":                                     "dQw4w9WgXcQ.mp4.webm",
+	} {
+		tpl, err := filename.Parse(tmpl)
+		if want == "" {
+			if err == nil {
+				t.Errorf("%q: expected an unknown field error", tmpl)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tmpl, err)
+		}
+
+		if got := tpl.Execute(fields); got != want {
+			t.Errorf("%q: expected %q, got %q", tmpl, want, got)
+		}
+	}
+}
+
+func TestParseRejectsMalformedTemplates(t *testing.T) {
+	for _, tmpl := range []string{"", "  ", "%(title", "%d", "100%", "%(title)d"} {
+		if _, err := filename.Parse(tmpl); err == nil {
+			t.Errorf("%q: expected an error", tmpl)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	for _, tc := range []struct{ base, ext, want string }{
+		{"a/b\\c:d*e?f\"g<h>i|j", "mp4", "a_b_c_d_e_f_g_h_i_j.mp4"},
+		{"  tabs\tand\n\nlines  ", "m4a", "tabs and lines.m4a"},
+		{"trailing dots...", "MP4", "trailing dots.mp4"},
+		{"CON", "mp4", "_CON.mp4"},
+		{"lpt1.backup", "mkv", "_lpt1.backup.mkv"},
+		{"console", "mp4", "console.mp4"},
+		{"...", "", "download"},
+		{"日本語のタイトル", "webm", "日本語のタイトル.webm"},
+		{"x", "m/p4", "x.mp4"},
+	} {
+		if got := filename.Sanitize(tc.base, tc.ext); got != tc.want {
+			t.Errorf("Sanitize(%q, %q): expected %q, got %q", tc.base, tc.ext, tc.want, got)
+		}
+	}
+}
+
+func TestSanitizeTruncatesOnCharacterBoundary(t *testing.T) {
+	got := filename.Sanitize(strings.Repeat("é", 300), "webm")
+
+	if len(got) > filename.MaxBytes || !strings.HasSuffix(got, "é.webm") {
+		t.Fatalf("unexpected name %q (%d bytes)", got, len(got))
+	}
+}
@@ -0,0 +1,283 @@
+// Package jobs runs downloads in the background. Jobs are kept in memory:
+// queued and running jobs are lost when the server stops, and only the most
+// recent finished jobs are remembered.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Status is the state of a job.
+type Status string
+
+const (
+	Queued    Status = "queued"
+	Running   Status = "running"
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+)
+
+// Reports whether a job in this state will not change anymore.
+func (s Status) Done() bool {
+	return s == Succeeded || s == Failed
+}
+
+// ErrQueueFull is returned by Submit when too many jobs are waiting.
+var ErrQueueFull = errors.New("too many jobs are queued")
+
+// ErrClosed is returned by Submit once the queue is closed.
+var ErrClosed = errors.New("the job queue is closed")
+
+// Job is a background download.
+type Job struct {
+	ID      string          `json:"id"`
+	KeyID   string          `json:"-"` // the API key that submitted it
+	Source  string          `json:"source,omitempty" doc:"what submitted the job, Ex: a subscription; empty for API requests"`
+	Request json.RawMessage `json:"request" doc:"the download request"`
+	Status  Status          `json:"status" enum:"queued,running,succeeded,failed"`
+	Error   string          `json:"error,omitempty"`
+	File    string          `json:"file,omitempty" doc:"name of the downloaded file, once succeeded"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	// Receives the events of this job, besides the webhooks of its key.
+	WebhookURL    string `json:"webhook_url,omitempty"`
+	WebhookSecret string `json:"-"`
+}
+
+// EventType names a change in the state of a job.
+type EventType string
+
+const (
+	EventQueued    EventType = "job.queued"
+	EventStarted   EventType = "job.started"
+	EventSucceeded EventType = "job.succeeded"
+	EventFailed    EventType = "job.failed"
+)
+
+// EventTypes lists every event type, in the order a job goes through them.
+var EventTypes = []EventType{EventQueued, EventStarted, EventSucceeded, EventFailed}
+
+// Event reports a job entering a new state.
+type Event struct {
+	Type EventType
+	Job  Job // the job as of the event
+	Time time.Time
+}
+
+// RunFunc performs a job and returns the name of the file it produced.
+type RunFunc func(ctx context.Context, job Job) (string, error)
+
+// Queue runs submitted jobs on a fixed number of workers, in order.
+type Queue struct {
+	run      RunFunc
+	pending  chan string
+	keepDone int
+	ctx      context.Context
+	cancel   context.CancelFunc
+	workers  sync.WaitGroup
+
+	mu        sync.Mutex
+	closed    bool
+	jobs      map[string]*Job
+	done      []string // IDs of finished jobs, oldest first
+	listeners []func(Event)
+	forget    []func(Job)
+}
+
+// Returns a Queue running jobs with run on workers goroutines. At most
+// maxPending jobs wait at a time, and the last keepDone finished jobs are
+// remembered.
+func NewQueue(workers, maxPending, keepDone int, run RunFunc) *Queue {
+	q := &Queue{
+		run:      run,
+		pending:  make(chan string, maxPending),
+		keepDone: keepDone,
+		jobs:     map[string]*Job{},
+	}
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+
+	for i := 0; i < workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+
+	return q
+}
+
+// Stops the queue: running jobs are cancelled, queued jobs fail without
+// running, and Close returns once the workers have exited.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		q.cancel()
+		close(q.pending)
+	}
+	q.mu.Unlock()
+
+	q.workers.Wait()
+}
+
+// Registers fn to be called with every event, in order. fn runs on the
+// goroutine changing the job, so it must not block.
+func (q *Queue) Subscribe(fn func(Event)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.listeners = append(q.listeners, fn)
+}
+
+// Registers fn to be called with every finished job the queue forgets, so
+// what it produced can be removed. fn runs without the queue locked.
+func (q *Queue) OnForget(fn func(Job)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.forget = append(q.forget, fn)
+}
+
+// Queues job, filling in its ID, status and creation time, and returns it.
+func (q *Queue) Submit(job Job) (Job, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Job{}, fmt.Errorf("failed to generate job id: %v", err)
+	}
+
+	job.ID = hex.EncodeToString(id)
+	job.Status = Queued
+	job.CreatedAt = time.Now().UTC()
+	job.StartedAt, job.FinishedAt, job.Error, job.File = nil, nil, "", ""
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return Job{}, ErrClosed
+	}
+
+	select {
+	case q.pending <- job.ID:
+	default:
+		return Job{}, ErrQueueFull
+	}
+
+	q.jobs[job.ID] = &job
+	q.emitLocked(EventQueued, job)
+
+	return job, nil
+}
+
+// Returns the job with the given ID.
+func (q *Queue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+
+	return *job, true
+}
+
+// Returns the jobs submitted with keyID, newest first.
+func (q *Queue) List(keyID string) []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	list := []Job{}
+	for _, job := range q.jobs {
+		if job.KeyID == keyID {
+			list = append(list, *job)
+		}
+	}
+
+	slices.SortFunc(list, func(a, b Job) int { return b.CreatedAt.Compare(a.CreatedAt) })
+
+	return list
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+
+	for id := range q.pending {
+		q.mu.Lock()
+		job := q.jobs[id]
+		now := time.Now().UTC()
+		job.Status, job.StartedAt = Running, &now
+		snapshot := *job
+		q.emitLocked(EventStarted, snapshot)
+		q.mu.Unlock()
+
+		var file string
+		err := q.ctx.Err()
+		if err == nil {
+			file, err = q.safeRun(snapshot)
+		}
+
+		q.mu.Lock()
+		finished := time.Now().UTC()
+		job.FinishedAt = &finished
+		event := EventSucceeded
+		if err != nil {
+			job.Status, job.Error, event = Failed, err.Error(), EventFailed
+		} else {
+			job.Status, job.File = Succeeded, file
+		}
+		q.emitLocked(event, *job)
+		forgotten := q.finishLocked(id)
+		forget := slices.Clone(q.forget)
+		q.mu.Unlock()
+
+		for _, old := range forgotten {
+			for _, fn := range forget {
+				fn(old)
+			}
+		}
+	}
+}
+
+// Runs the job, turning a panic into an error so the worker survives it.
+func (q *Queue) safeRun(job Job) (file string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("PANIC in job %s: %v", job.ID, p)
+			err = fmt.Errorf("internal error")
+		}
+	}()
+
+	return q.run(q.ctx, job)
+}
+
+// Remembers id as finished, forgetting the oldest finished jobs beyond
+// keepDone, and returns the jobs it forgot.
+func (q *Queue) finishLocked(id string) []Job {
+	var forgotten []Job
+	q.done = append(q.done, id)
+	for len(q.done) > q.keepDone {
+		forgotten = append(forgotten, *q.jobs[q.done[0]])
+		delete(q.jobs, q.done[0])
+		q.done = q.done[1:]
+	}
+
+	return forgotten
+}
+
+func (q *Queue) emitLocked(t EventType, job Job) {
+	ev := Event{Type: t, Job: job, Time: time.Now().UTC()}
+	for _, fn := range q.listeners {
+		fn(ev)
+	}
+}
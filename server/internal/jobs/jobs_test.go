@@ -0,0 +1,184 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/jobs"
+)
+
+// Collects the events of a queue and waits for them.
+type recorder struct {
+	mu     sync.Mutex
+	events []jobs.Event
+	ch     chan struct{}
+}
+
+func newRecorder(q *jobs.Queue) *recorder {
+	r := &recorder{ch: make(chan struct{}, 100)}
+	q.Subscribe(func(ev jobs.Event) {
+		r.mu.Lock()
+		r.events = append(r.events, ev)
+		r.mu.Unlock()
+		r.ch <- struct{}{}
+	})
+
+	return r
+}
+
+func (r *recorder) wait(t *testing.T, n int) []jobs.Event {
+	t.Helper()
+
+	for {
+		r.mu.Lock()
+		events := append([]jobs.Event(nil), r.events...)
+		r.mu.Unlock()
+		if len(events) >= n {
+			return events
+		}
+
+		select {
+		case <-r.ch:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %d events, got %d", n, len(events))
+		}
+	}
+}
+
+func TestQueueRunsJobs(t *testing.T) {
+	q := jobs.NewQueue(1, 10, 10, func(ctx context.Context, job jobs.Job) (string, error) {
+		if string(job.Request) == `"fail"` {
+			return "", errors.New("boom")
+		}
+		return "video.mp4", nil
+	})
+	t.Cleanup(q.Close)
+	rec := newRecorder(q)
+
+	ok, err := q.Submit(jobs.Job{KeyID: "k1", Request: []byte(`"ok"`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok.ID == "" || ok.Status != jobs.Queued {
+		t.Fatalf("unexpected job %+v", ok)
+	}
+	bad, _ := q.Submit(jobs.Job{KeyID: "k1", Request: []byte(`"fail"`)})
+
+	byJob := map[string][]jobs.EventType{}
+	for _, ev := range rec.wait(t, 6) {
+		byJob[ev.Job.ID] = append(byJob[ev.Job.ID], ev.Type)
+	}
+	if got := byJob[ok.ID]; !slices.Equal(got, []jobs.EventType{jobs.EventQueued, jobs.EventStarted, jobs.EventSucceeded}) {
+		t.Fatalf("unexpected events %v", got)
+	}
+	if got := byJob[bad.ID]; !slices.Equal(got, []jobs.EventType{jobs.EventQueued, jobs.EventStarted, jobs.EventFailed}) {
+		t.Fatalf("unexpected events %v", got)
+	}
+
+	if job, _ := q.Get(ok.ID); job.Status != jobs.Succeeded || job.File != "video.mp4" || job.FinishedAt == nil {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	if job, _ := q.Get(bad.ID); job.Status != jobs.Failed || job.Error != "boom" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	if list := q.List("k1"); len(list) != 2 {
+		t.Fatalf("expected 2 jobs for k1, got %d", len(list))
+	}
+	if list := q.List("k2"); len(list) != 0 {
+		t.Fatalf("expected no jobs for k2, got %d", len(list))
+	}
+}
+
+func TestQueueRejectsWhenFull(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	q := jobs.NewQueue(0, 1, 10, func(ctx context.Context, job jobs.Job) (string, error) {
+		<-release
+		return "", nil
+	})
+	t.Cleanup(q.Close)
+
+	if _, err := q.Submit(jobs.Job{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := q.Submit(jobs.Job{}); !errors.Is(err, jobs.ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+}
+
+func TestQueueForgetsOldJobs(t *testing.T) {
+	q := jobs.NewQueue(1, 10, 2, func(ctx context.Context, job jobs.Job) (string, error) {
+		return "", nil
+	})
+	t.Cleanup(q.Close)
+	rec := newRecorder(q)
+	forgotten := make(chan jobs.Job, 3)
+	q.OnForget(func(job jobs.Job) { forgotten <- job })
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		job, _ := q.Submit(jobs.Job{})
+		ids = append(ids, job.ID)
+	}
+	rec.wait(t, 9)
+
+	if _, ok := q.Get(ids[0]); ok {
+		t.Fatalf("expected the oldest job to be forgotten")
+	}
+	select {
+	case job := <-forgotten:
+		if job.ID != ids[0] || job.Status != jobs.Succeeded {
+			t.Fatalf("unexpected forgotten job %+v", job)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the forgotten job to be reported")
+	}
+	if _, ok := q.Get(ids[2]); !ok {
+		t.Fatalf("expected the newest job to be kept")
+	}
+}
+
+func TestQueueSurvivesPanics(t *testing.T) {
+	q := jobs.NewQueue(1, 10, 10, func(ctx context.Context, job jobs.Job) (string, error) {
+		panic("oops")
+	})
+	t.Cleanup(q.Close)
+	rec := newRecorder(q)
+
+	job, _ := q.Submit(jobs.Job{})
+	rec.wait(t, 3)
+
+	if got, _ := q.Get(job.ID); got.Status != jobs.Failed {
+		t.Fatalf("expected the job to fail, got %+v", got)
+	}
+}
+
+func TestQueueCloseStopsWorkers(t *testing.T) {
+	started := make(chan struct{})
+	q := jobs.NewQueue(1, 10, 10, func(ctx context.Context, job jobs.Job) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	running, _ := q.Submit(jobs.Job{})
+	<-started
+	queued, _ := q.Submit(jobs.Job{})
+
+	q.Close()
+
+	for _, id := range []string{running.ID, queued.ID} {
+		if job, _ := q.Get(id); job.Status != jobs.Failed {
+			t.Fatalf("expected the job to fail once the queue is closed, got %+v", job)
+		}
+	}
+	if _, err := q.Submit(jobs.Job{}); !errors.Is(err, jobs.ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
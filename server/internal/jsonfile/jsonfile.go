@@ -0,0 +1,45 @@
+// Package jsonfile saves the JSON files the server keeps its state in.
+package jsonfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Writes v as indented JSON to path, readable only by its owner. It writes to
+// a temporary file in the same directory, creating the directory if needed,
+// and renames it over path, so readers never see a partial file.
+func Write(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
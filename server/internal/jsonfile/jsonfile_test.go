@@ -0,0 +1,51 @@
+package jsonfile_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/jsonfile"
+)
+
+func TestWriteReplacesTheFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state", "store.json")
+
+	for _, v := range []map[string]int{{"a": 1}, {"b": 2}} {
+		if err := jsonfile.Write(path, v); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]int
+	if err := json.Unmarshal(data, &got); err != nil || len(got) != 1 || got["b"] != 2 {
+		t.Fatalf("unexpected content %s (%v)", data, err)
+	}
+
+	if info, _ := os.Stat(path); runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Fatalf("expected no temporary files left, got %d entries", len(entries))
+	}
+}
+
+func TestWriteLeavesTheFileOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	os.WriteFile(path, []byte("[]\n"), 0o600)
+
+	if err := jsonfile.Write(path, func() {}); err == nil {
+		t.Fatalf("expected an error for a value that is not JSON")
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "[]\n" {
+		t.Fatalf("expected the file to be kept, got %q", data)
+	}
+}
@@ -0,0 +1,20 @@
+package keys
+
+import "context"
+
+// ClientKeyID identifies requests made with the client key, VITE_X_API_KEY,
+// which is not in the store.
+const ClientKeyID = "client"
+
+type keyIDKey struct{}
+
+// Returns a copy of ctx carrying the ID of the key the request was made with.
+func WithKeyID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, keyIDKey{}, id)
+}
+
+// Returns the key ID stored in ctx, or "" when the request carried no key.
+func KeyID(ctx context.Context) string {
+	id, _ := ctx.Value(keyIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,211 @@
+// Package keys manages API keys stored in a JSON file. Only a SHA-256 hash of
+// each key is stored; the key itself is shown once when it is created.
+package keys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/jsonfile"
+)
+
+const secretPrefix = "ytk_"
+
+// Key is a stored API key.
+type Key struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Hash      string     `json:"hash"`   // hex SHA-256 of the secret
+	Prefix    string     `json:"prefix"` // first characters of the secret, to recognize it
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Reports whether the key has been revoked.
+func (k Key) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// Store is a file-backed set of API keys. It reloads the file when it changes
+// on disk, so keys managed offline take effect without a restart.
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	keys    []Key
+	modTime time.Time
+}
+
+// Opens the store at path. A missing file is treated as an empty store.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	if err := s.reloadLocked(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Returns the path of the backing file.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Create generates a new key, saves it and returns it along with its secret.
+func (s *Store) Create(name string) (Key, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reloadLocked(); err != nil {
+		return Key{}, "", err
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return Key{}, "", fmt.Errorf("failed to generate key: %v", err)
+	}
+	secret := secretPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return Key{}, "", fmt.Errorf("failed to generate key id: %v", err)
+	}
+
+	key := Key{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Hash:      hashSecret(secret),
+		Prefix:    secret[:len(secretPrefix)+4],
+		CreatedAt: time.Now().UTC(),
+	}
+
+	s.keys = append(s.keys, key)
+
+	if err := s.saveLocked(); err != nil {
+		s.keys = s.keys[:len(s.keys)-1]
+		return Key{}, "", err
+	}
+
+	return key, secret, nil
+}
+
+// Returns all keys, including revoked ones.
+func (s *Store) List() ([]Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reloadLocked(); err != nil {
+		return nil, err
+	}
+
+	return append([]Key(nil), s.keys...), nil
+}
+
+// Revoke marks the key with the given ID as revoked.
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reloadLocked(); err != nil {
+		return err
+	}
+
+	for i := range s.keys {
+		if s.keys[i].ID != id {
+			continue
+		}
+
+		if s.keys[i].Revoked() {
+			return fmt.Errorf("key %s is already revoked", id)
+		}
+
+		now := time.Now().UTC()
+		s.keys[i].RevokedAt = &now
+
+		return s.saveLocked()
+	}
+
+	return fmt.Errorf("key %s not found", id)
+}
+
+// Verify returns the active key matching secret.
+func (s *Store) Verify(secret string) (Key, bool) {
+	if secret == "" {
+		return Key{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reloadLocked(); err != nil {
+		return Key{}, false
+	}
+
+	hash := []byte(hashSecret(secret))
+
+	for _, k := range s.keys {
+		if !k.Revoked() && subtle.ConstantTimeCompare(hash, []byte(k.Hash)) == 1 {
+			return k, true
+		}
+	}
+
+	return Key{}, false
+}
+
+// Re-reads the file when its modification time changed since the last load.
+func (s *Store) reloadLocked() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.keys, s.modTime = nil, time.Time{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat keys file: %v", err)
+	}
+
+	if info.ModTime().Equal(s.modTime) && s.keys != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read keys file: %v", err)
+	}
+
+	var keys []Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("invalid keys file %s: %v", s.path, err)
+	}
+
+	s.keys, s.modTime = keys, info.ModTime()
+
+	return nil
+}
+
+// Saves the keys, readable only by the server, and remembers the new
+// modification time so the write is not mistaken for an offline change.
+func (s *Store) saveLocked() error {
+	if err := jsonfile.Write(s.path, s.keys); err != nil {
+		return fmt.Errorf("failed to write keys file: %v", err)
+	}
+
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+
+	return nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,94 @@
+package keys_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/keys"
+)
+
+func TestOpenMissingFileIsEmpty(t *testing.T) {
+	store, err := keys.Open(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	list, err := store.List()
+	if err != nil || len(list) != 0 {
+		t.Fatalf("expected empty store, got %v, %v", list, err)
+	}
+}
+
+func TestCreateVerifyRevoke(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "api_keys.json")
+
+	store, err := keys.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	key, secret, err := store.Create("ci")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.HasPrefix(secret, key.Prefix) {
+		t.Fatalf("expected secret %q to start with prefix %q", secret, key.Prefix)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), secret) {
+		t.Fatalf("expected the secret not to be stored in plain text")
+	}
+
+	if got, ok := store.Verify(secret); !ok || got.ID != key.ID {
+		t.Fatalf("expected secret to verify as key %s", key.ID)
+	}
+
+	if _, ok := store.Verify("wrong"); ok {
+		t.Fatalf("expected wrong secret to be rejected")
+	}
+
+	if err := store.Revoke(key.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := store.Verify(secret); ok {
+		t.Fatalf("expected revoked key to be rejected")
+	}
+
+	if err := store.Revoke(key.ID); err == nil {
+		t.Fatalf("expected error revoking twice")
+	}
+
+	if err := store.Revoke("missing"); err == nil {
+		t.Fatalf("expected error revoking unknown key")
+	}
+}
+
+func TestStoreSeesChangesFromOtherProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_keys.json")
+
+	server, _ := keys.Open(path)
+	cli, _ := keys.Open(path)
+
+	_, secret, err := cli.Create("offline")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := server.Verify(secret); !ok {
+		t.Fatalf("expected key created by another store to be picked up")
+	}
+}
+
+func TestOpenInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_keys.json")
+	os.WriteFile(path, []byte("{not json"), 0o600)
+
+	if _, err := keys.Open(path); err == nil {
+		t.Fatalf("expected error for invalid file")
+	}
+}
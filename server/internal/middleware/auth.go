@@ -1,29 +1,88 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
 	"os"
 	"strings"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/config"
+	"github.com/gabriel-logan/yt-dlp/server/internal/keys"
 )
 
+// publicPaths are served without an API key.
+var publicPaths = map[string]bool{
+	"/api/hello":        true,
+	"/api/v1/hello":     true,
+	"/api/openapi.json": true,
+	"/api/docs":         true,
+}
+
+// sharedDownloadPrefix is the only route a share link grants access to, in
+// place of an API key. Its handler verifies the link.
+const sharedDownloadPrefix = "/api/v1/share/"
+
 func Auth(next http.Handler) http.Handler {
 	apiKeyFromEnv := os.Getenv("VITE_X_API_KEY")
+	// The regular API key is bundled into the SPA, so admin routes need their own secret.
+	adminKeyFromEnv := os.Getenv("ADMIN_API_KEY")
+
+	// Additional keys managed with the "keys" server command.
+	keyStore, err := keys.Open(config.APIKeysFile())
+	if err != nil {
+		log.Println("WARNING: API keys file ignored: ", err)
+	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/api/hello" {
+		if publicPaths[r.URL.Path] {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		if strings.HasPrefix(r.URL.Path, "/api") {
-			apiKeyFromHeader := r.Header.Get("X-API-KEY")
+		if strings.HasPrefix(r.URL.Path, "/api/admin") || strings.HasPrefix(r.URL.Path, "/api/v1/admin") {
+			if adminKeyFromEnv == "" || r.Header.Get("X-API-KEY") != adminKeyFromEnv {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if strings.HasPrefix(r.URL.Path, sharedDownloadPrefix) &&
+			(r.Method == http.MethodGet || r.Method == http.MethodHead) && r.Header.Get("X-API-KEY") == "" {
+			// the handler verifies the link, answering why it cannot be used
+			next.ServeHTTP(w, r)
+			return
+		}
 
-			if apiKeyFromHeader != apiKeyFromEnv {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		if strings.HasPrefix(r.URL.Path, "/api") {
+			keyID, ok := verifyKey(keyStore, apiKeyFromEnv, r.Header.Get("X-API-KEY"))
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
 				return
 			}
+
+			// jobs and webhooks belong to the key that created them
+			r = r.WithContext(keys.WithKeyID(r.Context(), keyID))
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// Returns the ID of the key matching secret: keys.ClientKeyID for the client
+// key, or the ID of a stored key.
+func verifyKey(store *keys.Store, clientKey, secret string) (string, bool) {
+	if secret == clientKey {
+		return keys.ClientKeyID, true
+	}
+
+	if store == nil {
+		return "", false
+	}
+
+	key, ok := store.Verify(secret)
+	return key.ID, ok
+}
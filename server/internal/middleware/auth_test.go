@@ -1,12 +1,18 @@
 package middleware_test
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/keys"
 	"github.com/gabriel-logan/yt-dlp/server/internal/middleware"
+	"github.com/gabriel-logan/yt-dlp/server/internal/share"
 )
 
 func TestAuthAllowsHelloWithoutApiKey(t *testing.T) {
@@ -109,3 +115,151 @@ func TestAuthNonApiPathPassThrough(t *testing.T) {
 		t.Fatalf("expected next handler to be called for non-/api path")
 	}
 }
+
+func TestAuthAdminRequiresAdminKey(t *testing.T) {
+	t.Setenv("VITE_X_API_KEY", "secret")
+	t.Setenv("ADMIN_API_KEY", "admin-secret")
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler := middleware.Auth(next)
+
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"secret", http.StatusUnauthorized},
+		{"admin-secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodDelete, "/api/admin/cache/info", nil)
+		req.Header.Set("X-API-KEY", tt.key)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Fatalf("key %q: expected status %d, got %d", tt.key, tt.want, rr.Code)
+		}
+	}
+}
+
+func TestAuthAdminDisabledWithoutAdminKey(t *testing.T) {
+	t.Setenv("VITE_X_API_KEY", "")
+	t.Setenv("ADMIN_API_KEY", "")
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/admin/cache/info", nil)
+	rr := httptest.NewRecorder()
+
+	middleware.Auth(next).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected admin routes to be disabled without ADMIN_API_KEY, got %d", rr.Code)
+	}
+}
+
+func TestAuthAcceptsStoredKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_keys.json")
+	t.Setenv("API_KEYS_FILE", path)
+	t.Setenv("VITE_X_API_KEY", "secret")
+
+	store, _ := keys.Open(path)
+	_, stored, err := store.Create("script")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/other", nil)
+	req.Header.Set("X-API-KEY", stored)
+	rr := httptest.NewRecorder()
+
+	middleware.Auth(next).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected stored key to be accepted, got %d", rr.Code)
+	}
+}
+
+func TestAuthUnauthorizedUsesJSONEnvelope(t *testing.T) {
+	_ = os.Setenv("VITE_X_API_KEY", "secret")
+
+	handler := middleware.Auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/video/info", nil))
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", rr.Code)
+	}
+
+	var body apierror.Response
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected a json envelope, got %q", rr.Body.String())
+	}
+	if body.Error.Code != apierror.CodeUnauthorized {
+		t.Fatalf("unexpected code %q", body.Error.Code)
+	}
+}
+
+func TestAuthAllowsOpenAPIWithoutApiKey(t *testing.T) {
+	_ = os.Setenv("VITE_X_API_KEY", "secret")
+
+	handler := middleware.Auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for /api/openapi.json, got %d", rr.Code)
+	}
+}
+
+func TestAuthLeavesShareLinksToTheirRoute(t *testing.T) {
+	t.Setenv("VITE_X_API_KEY", "secret")
+
+	token, _, err := share.NewSigner("share-secret").Sign(share.Claims{Expires: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handler := middleware.Auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		// the handler verifies the link
+		{http.MethodGet, "/api/v1/share/" + token, http.StatusOK},
+		{http.MethodHead, "/api/v1/share/" + token, http.StatusOK},
+		{http.MethodGet, "/api/v1/share/not-a-token", http.StatusOK},
+		{http.MethodPost, "/api/v1/share/" + token, http.StatusUnauthorized},
+		{http.MethodPost, "/api/v1/share", http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/downloads/" + token, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if rr.Code != tt.want {
+			t.Errorf("%s %.40s: expected status %d, got %d", tt.method, tt.path, tt.want, rr.Code)
+		}
+	}
+}
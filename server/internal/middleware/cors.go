@@ -14,6 +14,7 @@ func CORS(next http.Handler) http.Handler {
 			w.Header().Set("Access-Control-Allow-Origin", clientURL)
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-KEY")
+			w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition")
 
 			if r.Method == http.MethodOptions {
 				w.WriteHeader(http.StatusNoContent)
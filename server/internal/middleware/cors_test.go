@@ -44,6 +44,10 @@ func TestCORSDevelopmentHeadersAndNextCalled(t *testing.T) {
 		t.Fatalf("expected Access-Control-Allow-Headers header, got %q", got)
 	}
 
+	if got := rr.Header().Get("Access-Control-Expose-Headers"); got != "Content-Disposition" {
+		t.Fatalf("expected Access-Control-Expose-Headers header, got %q", got)
+	}
+
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected status 200 from next handler, got %d", rr.Code)
 	}
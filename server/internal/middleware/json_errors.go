@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+)
+
+// maxCapturedErrorBody bounds the plain-text message kept from a rewritten error.
+const maxCapturedErrorBody = 1024
+
+// JSONErrors rewrites plain-text error responses of API requests, such as the
+// mux's own 404 and 405 replies, into the apierror JSON envelope. Handlers that
+// already answer with JSON are passed through untouched.
+func JSONErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !apierror.WantsJSON(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		jw := &jsonErrorWriter{ResponseWriter: w}
+		next.ServeHTTP(jw, r)
+
+		if jw.status != 0 {
+			msg := strings.TrimSpace(jw.body.String())
+			if msg == "" {
+				msg = http.StatusText(jw.status)
+			}
+
+			apierror.Write(w, r, jw.status, apierror.CodeForStatus(jw.status), msg)
+		}
+	})
+}
+
+type jsonErrorWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	status      int // set when a non-JSON error is being captured
+	body        bytes.Buffer
+}
+
+func (w *jsonErrorWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if statusCode >= 400 && !isJSONContentType(w.Header().Get("Content-Type")) {
+		w.status = statusCode
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *jsonErrorWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.status != 0 {
+		if room := maxCapturedErrorBody - w.body.Len(); room > 0 {
+			w.body.Write(p[:min(len(p), room)])
+		}
+		return len(p), nil
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *jsonErrorWriter) Flush() {
+	if w.status != 0 {
+		return
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *jsonErrorWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func isJSONContentType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	return err == nil && (mt == "application/json" || strings.HasSuffix(mt, "+json"))
+}
@@ -0,0 +1,72 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/middleware"
+)
+
+func TestJSONErrorsRewritesPlainTextAPIErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/thing", func(w http.ResponseWriter, r *http.Request) {})
+
+	handler := middleware.RequestID(middleware.JSONErrors(mux))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/thing", nil))
+
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+
+	var body apierror.Response
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected a json envelope, got %q", rr.Body.String())
+	}
+	if body.Error.Code != apierror.CodeMethodNotAllowed {
+		t.Errorf("unexpected code %q", body.Error.Code)
+	}
+	if body.Error.RequestID == "" || body.Error.RequestID != rr.Header().Get(middleware.RequestIDHeader) {
+		t.Errorf("expected the request id in the envelope, got %q", body.Error.RequestID)
+	}
+}
+
+func TestJSONErrorsKeepsJSONAndSuccessResponses(t *testing.T) {
+	handler := middleware.JSONErrors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`{"custom":true}`))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/x?fail=1", nil))
+	if rr.Code != http.StatusBadGateway || rr.Body.String() != `{"custom":true}` {
+		t.Fatalf("json error was modified: %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/x", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "ok" {
+		t.Fatalf("success response was modified: %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestJSONErrorsLeavesNonAPIPathsAlone(t *testing.T) {
+	handler := middleware.JSONErrors(http.NotFoundHandler())
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("expected plain text, got %q", rr.Header().Get("Content-Type"))
+	}
+}
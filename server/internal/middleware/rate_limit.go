@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
 	"golang.org/x/time/rate"
 )
 
@@ -19,10 +20,18 @@ var RateLimits = map[string]MethodLimit{
 		Limiter: rate.NewLimiter(10, 20),
 		BanTime: 10,
 	},
+	"HEAD": {
+		Limiter: rate.NewLimiter(10, 20),
+		BanTime: 10,
+	},
 	"POST": {
 		Limiter: rate.NewLimiter(3, 6),
 		BanTime: 30,
 	},
+	"DELETE": {
+		Limiter: rate.NewLimiter(3, 6),
+		BanTime: 30,
+	},
 }
 
 type clientState struct {
@@ -88,21 +97,25 @@ func RateLimit(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ip, _, err := net.SplitHostPort(r.RemoteAddr)
 		if err != nil {
-			http.Error(w, "Invalid IP address", http.StatusBadRequest)
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid IP address")
 			return
 		}
 
 		method := r.Method
 		cfg, exists := RateLimits[method]
 		if !exists {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed")
 			return
 		}
 
 		client := getClient(ip)
 
 		if isBanned(client) {
-			http.Error(w, "Too Many Requests (temp ban)", http.StatusTooManyRequests)
+			apierror.WriteError(w, r, http.StatusTooManyRequests, apierror.Error{
+				Code:      apierror.CodeTemporarilyBanned,
+				Message:   "Too Many Requests (temp ban)",
+				Retryable: true,
+			})
 			return
 		}
 
@@ -110,7 +123,11 @@ func RateLimit(next http.Handler) http.Handler {
 
 		if !limiter.Allow() {
 			banClient(client, cfg.BanTime)
-			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			apierror.WriteError(w, r, http.StatusTooManyRequests, apierror.Error{
+				Code:      apierror.CodeTooManyRequests,
+				Message:   "Too Many Requests",
+				Retryable: true,
+			})
 			return
 		}
 
@@ -110,6 +110,22 @@ func TestRateLimitMethodNotAllowed(t *testing.T) {
 	}
 }
 
+func TestRateLimitAllowsHead(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middleware.RateLimit(next)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodHead, exampleUrl, nil)
+	req.RemoteAddr = "10.0.0.5:10001"
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for HEAD, got %d", rr.Code)
+	}
+}
+
 func TestRateLimitInvalidIP(t *testing.T) {
 	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -3,6 +3,8 @@ package middleware
 import (
 	"log"
 	"net/http"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
 )
 
 func Recover(next http.Handler) http.Handler {
@@ -11,7 +13,7 @@ func Recover(next http.Handler) http.Handler {
 			if err := recover(); err != nil {
 				log.Printf("Recovered from panic: %v", err)
 
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Internal Server Error")
 			}
 		}()
 
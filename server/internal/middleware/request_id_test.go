@@ -0,0 +1,57 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
+	"github.com/gabriel-logan/yt-dlp/server/internal/middleware"
+)
+
+func TestRequestIDGeneratesID(t *testing.T) {
+	var seen string
+	handler := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = apierror.RequestID(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if seen == "" {
+		t.Fatal("expected a request id in the context")
+	}
+	if got := rr.Header().Get(middleware.RequestIDHeader); got != seen {
+		t.Fatalf("expected response header %q, got %q", seen, got)
+	}
+}
+
+func TestRequestIDReusesValidHeader(t *testing.T) {
+	var seen string
+	handler := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = apierror.RequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(middleware.RequestIDHeader, "proxy-abc-123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if seen != "proxy-abc-123" {
+		t.Fatalf("expected the incoming id to be reused, got %q", seen)
+	}
+}
+
+func TestRequestIDReplacesInvalidHeader(t *testing.T) {
+	var seen string
+	handler := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = apierror.RequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(middleware.RequestIDHeader, "bad id\twith spaces")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if seen == "" || seen == "bad id\twith spaces" {
+		t.Fatalf("expected a generated id, got %q", seen)
+	}
+}
@@ -1,12 +1,49 @@
 package middleware
 
 import (
+	"encoding/json"
 	"net/http"
 	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
 )
 
+const timeoutMessage = "Request timed out"
+
 func Timeout(duration time.Duration) Middleware {
+	return TimeoutExcept(duration, nil)
+}
+
+// TimeoutExcept is Timeout for the requests skip reports false for. The
+// response is buffered until the handler returns, so requests streaming a
+// file or a download must skip it.
+func TimeoutExcept(duration time.Duration, skip func(r *http.Request) bool) Middleware {
 	return func(next http.Handler) http.Handler {
-		return http.TimeoutHandler(next, duration, "Request timed out")
+		plain := http.TimeoutHandler(next, duration, timeoutMessage)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skip != nil && skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !apierror.WantsJSON(r) {
+				plain.ServeHTTP(w, r)
+				return
+			}
+
+			// The timeout body is fixed per handler, so API requests get one
+			// carrying their request ID. Headers set here only survive when the
+			// handler does not set its own, which is the case on timeout.
+			body, _ := json.Marshal(apierror.Response{Error: apierror.Error{
+				Code:      apierror.CodeTimeout,
+				Message:   timeoutMessage,
+				RequestID: apierror.RequestID(r.Context()),
+				Retryable: true,
+			}})
+
+			w.Header().Set("Content-Type", "application/json")
+			http.TimeoutHandler(next, duration, string(body)).ServeHTTP(w, r)
+		})
 	}
 }
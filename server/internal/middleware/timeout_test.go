@@ -1,11 +1,13 @@
 package middleware_test
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
 	"github.com/gabriel-logan/yt-dlp/server/internal/middleware"
 )
 
@@ -53,3 +55,45 @@ func TestTimeoutPassesThroughBeforeDeadline(t *testing.T) {
 		t.Fatalf("expected body 'ok', got %q", body)
 	}
 }
+
+func TestTimeoutJSONEnvelopeForAPI(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	})
+
+	rr := httptest.NewRecorder()
+	middleware.Timeout(10*time.Millisecond)(handler).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/video/info", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected application/json, got %q", ct)
+	}
+
+	var body apierror.Response
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid json body %q: %v", rr.Body.String(), err)
+	}
+	if body.Error.Code != apierror.CodeTimeout || !body.Error.Retryable {
+		t.Fatalf("unexpected envelope %+v", body.Error)
+	}
+}
+
+func TestTimeoutExceptSkipsRequests(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("ok"))
+	})
+	skip := func(r *http.Request) bool { return r.URL.Path == "/stream" }
+	wrapped := middleware.TimeoutExcept(10*time.Millisecond, skip)(handler)
+
+	for path, want := range map[string]int{"/stream": http.StatusOK, "/other": http.StatusServiceUnavailable} {
+		rr := httptest.NewRecorder()
+		wrapped.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if rr.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/openapi"
+)
+
+type item struct {
+	Name string `json:"name"`
+}
+
+type sample struct {
+	ID       string            `json:"id"`
+	Kind     string            `json:"kind" enum:"a,b"`
+	Count    int64             `json:"count,omitempty" doc:"how many"`
+	Ratio    float64           `json:"ratio"`
+	At       time.Time         `json:"at"`
+	Items    []item            `json:"items"`
+	Parent   *item             `json:"parent"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Ignored  string            `json:"-"`
+	internal string
+}
+
+type forwarded struct {
+	Title string `json:"title"`
+	Main  item   `json:"main"`
+}
+
+func TestGeneratorSchema(t *testing.T) {
+	doc := openapi.New("test", "1")
+	gen := openapi.NewGenerator(doc)
+
+	ref := gen.Schema(sample{})
+	if ref.Ref != "#/components/schemas/sample" {
+		t.Fatalf("expected a component ref, got %+v", ref)
+	}
+
+	s := doc.Resolve(ref)
+	if s.Type != "object" || s.AdditionalProperties != false {
+		t.Fatalf("unexpected object schema %+v", s)
+	}
+	if _, ok := s.Properties["Ignored"]; ok {
+		t.Error("json:\"-\" fields must be skipped")
+	}
+	if _, ok := s.Properties["internal"]; ok {
+		t.Error("unexported fields must be skipped")
+	}
+
+	want := []string{"id", "kind", "ratio", "at", "items"}
+	if !slices.Equal(s.Required, want) {
+		t.Errorf("required = %v, want %v", s.Required, want)
+	}
+
+	if p := s.Properties["kind"]; !slices.Equal(p.Enum, []string{"a", "b"}) {
+		t.Errorf("unexpected enum %v", p.Enum)
+	}
+	if p := s.Properties["count"]; p.Type != "integer" || p.Format != "int64" || p.Description != "how many" {
+		t.Errorf("unexpected count schema %+v", p)
+	}
+	if p := s.Properties["at"]; p.Type != "string" || p.Format != "date-time" {
+		t.Errorf("unexpected time schema %+v", p)
+	}
+	if p := s.Properties["items"]; p.Type != "array" || p.Items.Ref != "#/components/schemas/item" {
+		t.Errorf("unexpected items schema %+v", p)
+	}
+	if p := s.Properties["parent"]; !p.Nullable || len(p.AllOf) != 1 {
+		t.Errorf("pointer to struct should be a nullable allOf, got %+v", p)
+	}
+}
+
+func TestGeneratorNameAndOpen(t *testing.T) {
+	doc := openapi.New("test", "1")
+	gen := openapi.NewGenerator(doc)
+	gen.Name(forwarded{}, "Forwarded")
+	gen.Open(forwarded{})
+
+	s := doc.Resolve(gen.Schema(forwarded{}))
+	if s == nil {
+		t.Fatal("expected the component to be registered under its name")
+	}
+	if s.AdditionalProperties != nil || s.Required != nil {
+		t.Fatalf("open schemas allow extra and missing properties, got %+v", s)
+	}
+
+	if err := doc.Validate(s, []byte(`{"title":null,"main":null,"other":[1,2]}`)); err != nil {
+		t.Fatalf("open schema rejected a forwarded document: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	doc := openapi.New("test", "1")
+	schema := openapi.NewGenerator(doc).Schema(sample{})
+
+	valid := sample{ID: "x", Kind: "a", At: time.Now(), Items: []item{{Name: "n"}}}
+	data, _ := json.Marshal(valid)
+	if err := doc.Validate(schema, data); err != nil {
+		t.Fatalf("valid document rejected: %v", err)
+	}
+
+	invalid := []string{
+		`{"kind":"a","ratio":1,"at":"2024-01-01T00:00:00Z","items":[]}`,                           // missing id
+		`{"id":"x","kind":"c","ratio":1,"at":"2024-01-01T00:00:00Z","items":[]}`,                  // enum
+		`{"id":"x","kind":"a","ratio":"1","at":"2024-01-01T00:00:00Z","items":[]}`,                // type
+		`{"id":"x","kind":"a","ratio":1,"at":"2024-01-01T00:00:00Z","items":[{}]}`,                // nested required
+		`{"id":"x","kind":"a","ratio":1,"at":"2024-01-01T00:00:00Z","items":[],"extra":true}`,     // undeclared
+		`{"id":"x","kind":"a","ratio":1,"at":"2024-01-01T00:00:00Z","items":[],"count":1.5}`,      // integer
+		`{"id":"x","kind":"a","ratio":1,"at":"2024-01-01T00:00:00Z","items":[],"labels":{"k":1}}`, // map values
+	}
+
+	for _, body := range invalid {
+		if err := doc.Validate(schema, []byte(body)); err == nil {
+			t.Errorf("expected %s to be rejected", body)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+)
+
+const schemaRefPrefix = "#/components/schemas/"
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// Generator derives schemas from Go types following encoding/json rules.
+// Named struct types become components referenced with $ref.
+//
+// Struct tags refine a field: the rules of the validate package become
+// constraints and `doc:"..."` sets its description. Fields that are neither
+// omitempty nor pointers are required, as are fields validated as required.
+type Generator struct {
+	doc   *Document
+	names map[reflect.Type]string
+	open  map[reflect.Type]bool
+}
+
+// Returns a generator that adds components to doc.
+func NewGenerator(doc *Document) *Generator {
+	return &Generator{doc: doc, names: map[reflect.Type]string{}, open: map[reflect.Type]bool{}}
+}
+
+// Name sets the component name of v's type; by default it is the Go type name.
+func (g *Generator) Name(v any, name string) {
+	g.names[typeOf(v)] = name
+}
+
+// Open marks v's type as a document produced by another tool and forwarded
+// as-is: extra properties are allowed, none are required and any may be null.
+func (g *Generator) Open(v any) {
+	g.open[typeOf(v)] = true
+}
+
+// Schema returns the schema of v's type, registering any components it needs.
+func (g *Generator) Schema(v any) *Schema {
+	return g.schema(typeOf(v))
+}
+
+func (g *Generator) schema(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t, nullable = t.Elem(), true
+	}
+
+	if t.Kind() == reflect.Struct && t != timeType && g.componentName(t) == "" {
+		s := g.structSchema(t)
+		s.Nullable = nullable
+		return s
+	}
+
+	if t.Kind() == reflect.Struct && t != timeType {
+		name := g.componentName(t)
+		if _, ok := g.doc.Components.Schemas[name]; !ok {
+			// reserve the name first so recursive types terminate
+			g.doc.Components.Schemas[name] = &Schema{}
+			*g.doc.Components.Schemas[name] = *g.structSchema(t)
+		}
+
+		ref := &Schema{Ref: schemaRefPrefix + name}
+		if nullable {
+			// siblings of $ref are ignored in 3.0, so nullable refs need a wrapper
+			return &Schema{Nullable: true, AllOf: []*Schema{ref}}
+		}
+		return ref
+	}
+
+	s := g.valueSchema(t)
+	s.Nullable = nullable
+
+	return s
+}
+
+func (g *Generator) valueSchema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType || t.Kind() == reflect.Interface:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	}
+
+	return &Schema{}
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(s, t)
+
+	if !g.open[t] {
+		s.AdditionalProperties = false
+		return s
+	}
+
+	s.Required = nil
+	for name, p := range s.Properties {
+		if p.Ref != "" {
+			p = &Schema{AllOf: []*Schema{p}}
+			s.Properties[name] = p
+		}
+		p.Nullable = true
+	}
+
+	return s
+}
+
+func (g *Generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := g.fieldSchema(f)
+		s.Properties[name] = fs
+
+		omitempty := strings.Contains(","+opts+",", ",omitempty,") || strings.Contains(","+opts+",", ",omitzero,")
+		if (!omitempty && f.Type.Kind() != reflect.Pointer) || fieldRules(f).Required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// Returns the schema of a struct field, with its validation rules and description.
+func (g *Generator) fieldSchema(f reflect.StructField) *Schema {
+	s := g.schema(f.Type)
+
+	rules := fieldRules(f)
+	s.Enum = rules.Enum
+	s.Minimum, s.Maximum = rules.Min, rules.Max
+	s.MinLength, s.MaxLength = rules.MinLen, rules.MaxLen
+	s.MaxItems = rules.MaxItems
+	switch {
+	case rules.Hex:
+		s.Pattern = "^[0-9a-fA-F]*$"
+	case rules.Token:
+		s.Pattern = "^[A-Za-z0-9._=-]*$"
+	}
+
+	if doc := f.Tag.Get("doc"); doc != "" {
+		s.Description = doc
+	}
+
+	return s
+}
+
+// Parameters returns the query and path parameters of v's struct type, one
+// per field with a `query` or `path` tag.
+func (g *Generator) Parameters(v any) []Parameter {
+	t := typeOf(v)
+
+	var params []Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name, in := f.Tag.Get("query"), "query"
+		if name == "" {
+			name, in = f.Tag.Get("path"), "path"
+		}
+		if name == "" {
+			continue
+		}
+
+		s := g.fieldSchema(f)
+		desc := s.Description
+		s.Description = ""
+
+		// path parameters are always required
+		params = append(params, Parameter{Name: name, In: in, Description: desc, Required: in == "path" || fieldRules(f).Required, Schema: s})
+	}
+
+	return params
+}
+
+func fieldRules(f reflect.StructField) validate.Rules {
+	rules, err := validate.FieldRules(f)
+	if err != nil {
+		panic("openapi: " + err.Error())
+	}
+
+	return rules
+}
+
+func (g *Generator) componentName(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	return t.Name()
+}
+
+func typeOf(v any) reflect.Type {
+	if t, ok := v.(reflect.Type); ok {
+		return t
+	}
+
+	return reflect.TypeOf(v)
+}
+
+func refName(ref string) string {
+	return strings.TrimPrefix(ref, schemaRefPrefix)
+}
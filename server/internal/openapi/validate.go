@@ -0,0 +1,145 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validate checks that data, a JSON document, matches s. It covers what the
+// generator emits: types, required and undeclared properties, enums, ranges,
+// lengths, item counts, patterns and nullability.
+func (d *Document) Validate(s *Schema, data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	return d.validate(s, v, "$")
+}
+
+func (d *Document) validate(s *Schema, v any, at string) error {
+	if s == nil {
+		return nil
+	}
+
+	if s.Ref != "" {
+		resolved := d.Resolve(s)
+		if resolved == nil {
+			return fmt.Errorf("%s: unknown schema %s", at, s.Ref)
+		}
+		return d.validate(resolved, v, at)
+	}
+
+	if v == nil {
+		if s.Nullable || (s.Type == "" && len(s.AllOf) == 0) {
+			return nil
+		}
+		return fmt.Errorf("%s: must not be null", at)
+	}
+
+	for _, sub := range s.AllOf {
+		if err := d.validate(sub, v, at); err != nil {
+			return err
+		}
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", at, v)
+		}
+		return d.validateObject(s, obj, at)
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", at, v)
+		}
+		if s.MaxItems != nil && len(arr) > *s.MaxItems {
+			return fmt.Errorf("%s: %d items is more than %d", at, len(arr), *s.MaxItems)
+		}
+		for i, item := range arr {
+			if err := d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string, got %T", at, v)
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return fmt.Errorf("%s: %q is not one of %v", at, str, s.Enum)
+		}
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err != nil || !re.MatchString(str) {
+				return fmt.Errorf("%s: %q does not match %s", at, str, s.Pattern)
+			}
+		}
+		n := utf8.RuneCountInString(strings.TrimSpace(str))
+		if (s.MinLength != nil && n < *s.MinLength) || (s.MaxLength != nil && n > *s.MaxLength) {
+			return fmt.Errorf("%s: length %d is out of range", at, n)
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: expected integer, got %v", at, v)
+		}
+		if (s.Minimum != nil && int64(n) < *s.Minimum) || (s.Maximum != nil && int64(n) > *s.Maximum) {
+			return fmt.Errorf("%s: %v is out of range", at, n)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: expected number, got %T", at, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", at, v)
+		}
+	}
+
+	return nil
+}
+
+func (d *Document) validateObject(s *Schema, obj map[string]any, at string) error {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("%s: missing required property %q", at, name)
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		path := at + "." + k
+
+		if ps, ok := s.Properties[k]; ok {
+			if err := d.validate(ps, obj[k], path); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch extra := s.AdditionalProperties.(type) {
+		case bool:
+			if !extra {
+				return fmt.Errorf("%s: property is not in the schema", path)
+			}
+		case *Schema:
+			if err := d.validate(extra, obj[k], path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+// Package share mints and checks signed links that grant access to a single
+// download without an API key. A token carries its own claims, signed with
+// HMAC-SHA256, so the server only keeps state for links with a use limit.
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalid     = errors.New("share link is invalid")
+	ErrExpired     = errors.New("share link has expired")
+	ErrWrongClient = errors.New("share link is bound to another client")
+	ErrUsedUp      = errors.New("share link has no uses left")
+)
+
+// Claims is the content of a share link.
+type Claims struct {
+	ID       string          `json:"jti"`
+	Data     json.RawMessage `json:"data"`          // what the link grants, Ex: a download request
+	Expires  int64           `json:"exp"`           // Unix time
+	MaxUses  int             `json:"max,omitempty"` // 0 for unlimited
+	ClientIP string          `json:"ip,omitempty"`  // only this client may use the link
+}
+
+// Returns the time the link expires.
+func (c Claims) ExpiresAt() time.Time {
+	return time.Unix(c.Expires, 0)
+}
+
+// Signer signs and verifies tokens with a secret, and counts the uses of
+// limited links. Counts are kept in memory, so they start over on restart.
+type Signer struct {
+	secret []byte
+
+	mu   sync.Mutex
+	uses map[string]*usage
+}
+
+type usage struct {
+	count   int
+	expires time.Time
+}
+
+// Returns a Signer using secret, or nil when secret is empty, which disables
+// share links.
+func NewSigner(secret string) *Signer {
+	if secret == "" {
+		return nil
+	}
+
+	return &Signer{secret: []byte(secret), uses: map[string]*usage{}}
+}
+
+// Fills in the ID of c and returns its token: the claims as base64url JSON,
+// a '.', and their base64url signature.
+func (s *Signer) Sign(c Claims) (string, Claims, error) {
+	id := make([]byte, 9)
+	if _, err := rand.Read(id); err != nil {
+		return "", Claims{}, fmt.Errorf("failed to generate share link id: %v", err)
+	}
+	c.ID = hex.EncodeToString(id)
+
+	if c.ClientIP != "" {
+		ip := net.ParseIP(c.ClientIP)
+		if ip == nil {
+			return "", Claims{}, fmt.Errorf("invalid client IP %q", c.ClientIP)
+		}
+		c.ClientIP = ip.String()
+	}
+
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", Claims{}, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(encoded)), c, nil
+}
+
+// Checks the signature and expiry of token and that clientIP may use it, and
+// returns its claims. It does not count a use; see Redeem.
+func (s *Signer) Verify(token, clientIP string, now time.Time) (Claims, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return Claims{}, ErrInvalid
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.sign(encoded)) {
+		return Claims{}, ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Claims{}, ErrInvalid
+	}
+
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.ID == "" {
+		return Claims{}, ErrInvalid
+	}
+
+	if !now.Before(c.ExpiresAt()) {
+		return Claims{}, ErrExpired
+	}
+
+	if c.ClientIP != "" {
+		ip := net.ParseIP(clientIP)
+		if ip == nil || ip.String() != c.ClientIP {
+			return Claims{}, ErrWrongClient
+		}
+	}
+
+	return c, nil
+}
+
+// Counts a use of the link c, failing with ErrUsedUp once MaxUses is reached.
+func (s *Signer) Redeem(c Claims, now time.Time) error {
+	if c.MaxUses <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, u := range s.uses {
+		if !now.Before(u.expires) {
+			delete(s.uses, id)
+		}
+	}
+
+	u, ok := s.uses[c.ID]
+	if !ok {
+		u = &usage{expires: c.ExpiresAt()}
+		s.uses[c.ID] = u
+	}
+
+	if u.count >= c.MaxUses {
+		return ErrUsedUp
+	}
+	u.count++
+
+	return nil
+}
+
+func (s *Signer) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+
+	return mac.Sum(nil)
+}
+
+// Returns the IP address of the client that sent r, or "" when unknown.
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+
+	return ip
+}
@@ -0,0 +1,103 @@
+package share_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/share"
+)
+
+var now = time.Unix(1_700_000_000, 0)
+
+func sign(t *testing.T, s *share.Signer, c share.Claims) string {
+	t.Helper()
+
+	token, _, err := s.Sign(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return token
+}
+
+func TestNewSignerWithoutSecretIsDisabled(t *testing.T) {
+	if s := share.NewSigner(""); s != nil {
+		t.Fatalf("expected nil signer, got %v", s)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	s := share.NewSigner("secret")
+	token := sign(t, s, share.Claims{Data: json.RawMessage(`{"url":"https://example.com/v"}`), Expires: now.Add(time.Hour).Unix()})
+
+	c, err := s.Verify(token, "203.0.113.7", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.ID == "" || string(c.Data) != `{"url":"https://example.com/v"}` {
+		t.Fatalf("unexpected claims %+v", c)
+	}
+
+	if _, err := s.Verify(token, "", now.Add(time.Hour)); !errors.Is(err, share.ErrExpired) {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+
+	if _, err := share.NewSigner("other").Verify(token, "", now); !errors.Is(err, share.ErrInvalid) {
+		t.Fatalf("expected another secret to be rejected, got %v", err)
+	}
+}
+
+func TestVerifyRejectsTamperedTokens(t *testing.T) {
+	s := share.NewSigner("secret")
+	token := sign(t, s, share.Claims{Expires: now.Add(time.Hour).Unix()})
+	payload, sig, _ := strings.Cut(token, ".")
+
+	for _, bad := range []string{"", "abc", payload, payload + ".", "e30." + sig, payload + "x." + sig, payload + "." + sig + "x"} {
+		if _, err := s.Verify(bad, "", now); !errors.Is(err, share.ErrInvalid) {
+			t.Errorf("%q: expected ErrInvalid, got %v", bad, err)
+		}
+	}
+}
+
+func TestVerifyClientIP(t *testing.T) {
+	s := share.NewSigner("secret")
+	token := sign(t, s, share.Claims{Expires: now.Add(time.Hour).Unix(), ClientIP: "2001:db8::0001"})
+
+	if _, err := s.Verify(token, "2001:db8::1", now); err != nil {
+		t.Fatalf("expected the bound client to be accepted, got %v", err)
+	}
+
+	for _, ip := range []string{"2001:db8::2", "", "bogus"} {
+		if _, err := s.Verify(token, ip, now); !errors.Is(err, share.ErrWrongClient) {
+			t.Errorf("%q: expected ErrWrongClient, got %v", ip, err)
+		}
+	}
+
+	if _, _, err := s.Sign(share.Claims{Expires: now.Unix(), ClientIP: "not an ip"}); err == nil {
+		t.Fatalf("expected an invalid client IP to be rejected")
+	}
+}
+
+func TestRedeemCountsUses(t *testing.T) {
+	s := share.NewSigner("secret")
+
+	_, limited, _ := s.Sign(share.Claims{Expires: now.Add(time.Hour).Unix(), MaxUses: 2})
+	for i := 0; i < 2; i++ {
+		if err := s.Redeem(limited, now); err != nil {
+			t.Fatalf("use %d: unexpected error: %v", i+1, err)
+		}
+	}
+	if err := s.Redeem(limited, now); !errors.Is(err, share.ErrUsedUp) {
+		t.Fatalf("expected ErrUsedUp, got %v", err)
+	}
+
+	_, unlimited, _ := s.Sign(share.Claims{Expires: now.Add(time.Hour).Unix()})
+	for i := 0; i < 5; i++ {
+		if err := s.Redeem(unlimited, now); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
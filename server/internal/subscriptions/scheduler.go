@@ -0,0 +1,152 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrRunning is returned by Scheduler.RunNow while the subscription is being
+// checked.
+var ErrRunning = errors.New("the subscription is already being checked")
+
+// ErrClosed is returned by Scheduler.RunNow once the scheduler is closed.
+var ErrClosed = errors.New("the scheduler is closed")
+
+// PollFunc checks sub for items missing from the download archive at
+// archive and queues them; they are recorded in the archive once downloaded.
+// It returns the
+// number of new items found and the IDs of the jobs queued, even when it
+// fails part way.
+type PollFunc func(ctx context.Context, sub Subscription, archive string) (found int, jobIDs []string, err error)
+
+// Scheduler checks the subscriptions of a Store when they are due.
+type Scheduler struct {
+	store   *Store
+	poll    PollFunc
+	timeout time.Duration
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+	checks sync.WaitGroup // the ticker goroutine and the running checks
+}
+
+// Returns a Scheduler checking the subscriptions in store with poll. It looks
+// for due subscriptions every tick; each check may take up to timeout.
+func NewScheduler(store *Store, poll PollFunc, tick, timeout time.Duration) *Scheduler {
+	s := &Scheduler{store: store, poll: poll, timeout: timeout}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	s.checks.Add(1)
+	go s.tick(tick)
+
+	return s
+}
+
+// Stops looking for due subscriptions, cancels the running checks and waits
+// for them to be recorded.
+func (s *Scheduler) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		s.cancel()
+	}
+	s.mu.Unlock()
+
+	s.checks.Wait()
+}
+
+// Returns the subscription store.
+func (s *Scheduler) Store() *Store {
+	return s.store
+}
+
+// Checks the subscription id of keyID in the background, without waiting for
+// it to be due, and returns it. It reports false when the subscription does
+// not exist, and ErrRunning while it is already being checked.
+func (s *Scheduler) RunNow(keyID, id string) (Subscription, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return Subscription{}, false, ErrClosed
+	}
+
+	sub, ok, err := s.store.start(keyID, id)
+	if !ok || err != nil {
+		return Subscription{}, ok, err
+	}
+
+	s.checks.Add(1)
+	go s.run(sub)
+
+	return sub, true, nil
+}
+
+func (s *Scheduler) tick(every time.Duration) {
+	defer s.checks.Done()
+
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.runDue(now)
+		}
+	}
+}
+
+func (s *Scheduler) runDue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	for _, sub := range s.store.startDue(now) {
+		s.checks.Add(1)
+		go s.run(sub)
+	}
+}
+
+// Checks sub, which the store already marked as running, and records the run.
+func (s *Scheduler) run(sub Subscription) {
+	defer s.checks.Done()
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+
+	run := Run{StartedAt: time.Now().UTC(), Status: RunSucceeded, Jobs: []string{}}
+
+	found, jobIDs, err := s.safePoll(ctx, sub)
+	run.FinishedAt = time.Now().UTC()
+	run.Found = found
+	run.Jobs = append(run.Jobs, jobIDs...)
+	if err != nil {
+		run.Status, run.Error = RunFailed, err.Error()
+		log.Printf("Subscription error: %s: %v", sub.ID, err)
+	}
+
+	s.store.finish(sub.ID, run)
+}
+
+// Polls sub, turning a panic into an error so the schedule survives it.
+func (s *Scheduler) safePoll(ctx context.Context, sub Subscription) (found int, jobIDs []string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("PANIC in subscription %s: %v", sub.ID, p)
+			err = fmt.Errorf("internal error")
+		}
+	}()
+
+	return s.poll(ctx, sub, s.store.ArchivePath(sub.ID))
+}
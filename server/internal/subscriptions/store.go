@@ -0,0 +1,317 @@
+// Package subscriptions watches playlists and channels on a schedule so new
+// items are downloaded as they appear. Subscriptions are kept in a JSON file,
+// and the items already downloaded for each one in a yt-dlp download archive
+// in a directory next to it.
+package subscriptions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/jsonfile"
+)
+
+// Subscription polls a playlist or channel every Interval seconds.
+type Subscription struct {
+	ID       string          `json:"id"`
+	KeyID    string          `json:"-"` // the API key that owns it and its jobs
+	URL      string          `json:"url" doc:"the playlist or channel"`
+	Download json.RawMessage `json:"download" doc:"the download request queued for every new item, with the URL of the item"`
+	Interval int             `json:"interval" doc:"seconds between two checks"`
+	Limit    int             `json:"limit,omitempty" doc:"only the first limit items of the list are checked; all when 0"`
+	Paused   bool            `json:"paused,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	NextRunAt time.Time `json:"next_run_at"`
+	Running   bool      `json:"running"`
+	LastRun   *Run      `json:"last_run,omitempty"`
+}
+
+// RunStatus is the outcome of a check.
+type RunStatus string
+
+const (
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+)
+
+// Run reports a check of a subscription.
+type Run struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Status     RunStatus `json:"status" enum:"succeeded,failed"`
+	Error      string    `json:"error,omitempty"`
+	Found      int       `json:"found" doc:"new items found"`
+	Jobs       []string  `json:"jobs" doc:"IDs of the jobs queued for them"`
+}
+
+// record is a subscription as saved in the store file.
+type record struct {
+	KeyID string `json:"key_id"`
+	Subscription
+}
+
+// Store holds the playlist subscriptions of every API key and their schedule,
+// in a JSON file or in memory when its path is empty. The download archive of
+// each subscription is a separate file.
+type Store struct {
+	path       string
+	archiveDir string
+
+	mu   sync.Mutex
+	subs []*Subscription
+}
+
+// Loads the subscriptions saved at path, none when the file does not exist
+// yet, with their download archives in an "archives" directory next to it.
+// A store kept in memory puts its archives in a temporary directory. Checks
+// that were running when the file was saved are not resumed.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+	if path == "" {
+		return s, nil
+	}
+	s.archiveDir = filepath.Join(filepath.Dir(path), "archives")
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subscriptions file: %v", err)
+	}
+
+	var records []record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("invalid subscriptions file %s: %v", path, err)
+	}
+	for _, r := range records {
+		sub := r.Subscription
+		sub.KeyID, sub.Running = r.KeyID, false
+		s.subs = append(s.subs, &sub)
+	}
+
+	return s, nil
+}
+
+// Saves sub with a new ID and an empty run history, and returns it. Its first
+// check is due at once unless it is paused.
+func (s *Store) Create(sub Subscription) (Subscription, error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return Subscription{}, fmt.Errorf("failed to generate subscription id: %v", err)
+	}
+	sub.ID = hex.EncodeToString(id)
+	sub.CreatedAt = time.Now().UTC()
+	sub.NextRunAt = sub.CreatedAt
+	sub.Running, sub.LastRun = false, nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subs = append(s.subs, &sub)
+	if err := s.saveLocked(); err != nil {
+		s.subs = s.subs[:len(s.subs)-1]
+		return Subscription{}, err
+	}
+
+	return sub, nil
+}
+
+// Returns the subscription id of keyID.
+func (s *Store) Get(keyID, id string) (Subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := s.findLocked(keyID, id)
+	if sub == nil {
+		return Subscription{}, false
+	}
+
+	return *sub, true
+}
+
+// Returns the subscriptions of keyID, oldest first.
+func (s *Store) List(keyID string) []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []Subscription{}
+	for _, sub := range s.subs {
+		if sub.KeyID == keyID {
+			list = append(list, *sub)
+		}
+	}
+
+	return list
+}
+
+// Replaces the settings of the subscription id of keyID with those of
+// update, keeping its ID, creation time and runs, and returns it. A new
+// interval applies from the last check.
+func (s *Store) Update(keyID, id string, update Subscription) (Subscription, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := s.findLocked(keyID, id)
+	if sub == nil {
+		return Subscription{}, false, nil
+	}
+
+	old := *sub
+	sub.URL, sub.Download, sub.Limit, sub.Paused = update.URL, update.Download, update.Limit, update.Paused
+	if update.Interval != sub.Interval {
+		sub.Interval = update.Interval
+		sub.NextRunAt = lastRunStart(sub).Add(time.Duration(sub.Interval) * time.Second)
+	}
+
+	if err := s.saveLocked(); err != nil {
+		*sub = old
+		return Subscription{}, false, err
+	}
+
+	return *sub, true, nil
+}
+
+// Removes the subscription id of keyID and its archive, reporting whether it
+// existed.
+func (s *Store) Delete(keyID, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.subs, func(sub *Subscription) bool { return sub.ID == id && sub.KeyID == keyID })
+	if i < 0 {
+		return false, nil
+	}
+
+	removed := s.subs[i]
+	s.subs = slices.Delete(s.subs, i, i+1)
+	if err := s.saveLocked(); err != nil {
+		s.subs = slices.Insert(s.subs, i, removed)
+		return false, err
+	}
+
+	if s.archiveDir != "" {
+		os.Remove(filepath.Join(s.archiveDir, id+".txt"))
+	}
+
+	return true, nil
+}
+
+// Returns the path of the download archive of the subscription id. The
+// temporary directory of a store kept in memory is created the first time.
+func (s *Store) ArchivePath(id string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.archiveDir == "" {
+		dir, err := os.MkdirTemp("", "yt-dlp-server-archives-")
+		if err != nil {
+			dir = filepath.Join(os.TempDir(), "yt-dlp-server-archives")
+		}
+		s.archiveDir = dir
+	}
+
+	return filepath.Join(s.archiveDir, id+".txt")
+}
+
+// Marks the subscriptions due at now as running and returns them. Paused
+// and running subscriptions are never due.
+func (s *Store) startDue(now time.Time) []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []Subscription
+	for _, sub := range s.subs {
+		if !sub.Paused && !sub.Running && !sub.NextRunAt.After(now) {
+			sub.Running = true
+			due = append(due, *sub)
+		}
+	}
+
+	return due
+}
+
+// Marks the subscription id of keyID as running and returns it, reporting
+// false when it does not exist and an error when it is already running.
+func (s *Store) start(keyID, id string) (Subscription, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := s.findLocked(keyID, id)
+	if sub == nil {
+		return Subscription{}, false, nil
+	}
+	if sub.Running {
+		return Subscription{}, true, ErrRunning
+	}
+
+	sub.Running = true
+	return *sub, true, nil
+}
+
+// Records run as the last check of the subscription id and schedules the
+// next one.
+func (s *Store) finish(id string, run Run) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.subs, func(sub *Subscription) bool { return sub.ID == id })
+	if i < 0 {
+		return // deleted while running
+	}
+
+	sub := s.subs[i]
+	sub.Running, sub.LastRun = false, &run
+	sub.NextRunAt = run.StartedAt.Add(time.Duration(sub.Interval) * time.Second)
+
+	if err := s.saveLocked(); err != nil {
+		log.Println("Subscription error: ", err)
+	}
+}
+
+func (s *Store) findLocked(keyID, id string) *Subscription {
+	for _, sub := range s.subs {
+		if sub.ID == id && sub.KeyID == keyID {
+			return sub
+		}
+	}
+
+	return nil
+}
+
+// Returns when the last check of sub started, or its creation time.
+func lastRunStart(sub *Subscription) time.Time {
+	if sub.LastRun != nil {
+		return sub.LastRun.StartedAt
+	}
+
+	return sub.CreatedAt
+}
+
+// Saves every subscription with the key that owns it, unless the store is
+// kept in memory.
+func (s *Store) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+
+	records := make([]record, len(s.subs))
+	for i, sub := range s.subs {
+		records[i] = record{KeyID: sub.KeyID, Subscription: *sub}
+	}
+
+	if err := jsonfile.Write(s.path, records); err != nil {
+		return fmt.Errorf("failed to write subscriptions file: %v", err)
+	}
+
+	return nil
+}
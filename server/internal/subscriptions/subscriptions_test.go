@@ -0,0 +1,200 @@
+package subscriptions_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/subscriptions"
+)
+
+// Waits until the subscription has finished a check, and returns it.
+func waitRun(t *testing.T, store *subscriptions.Store, keyID, id string, runs int32, count *atomic.Int32) subscriptions.Subscription {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sub, ok := store.Get(keyID, id)
+		if !ok {
+			t.Fatalf("subscription %s not found", id)
+		}
+		if count.Load() >= runs && !sub.Running && sub.LastRun != nil {
+			return sub
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d checks, got %d: %+v", runs, count.Load(), sub)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSchedulerChecksDueSubscriptions(t *testing.T) {
+	store, _ := subscriptions.Open("")
+
+	var polls atomic.Int32
+	poll := func(ctx context.Context, sub subscriptions.Subscription, archive string) (int, []string, error) {
+		if polls.Add(1) == 2 {
+			return 3, []string{"j1"}, errors.New("too many jobs are queued")
+		}
+		return 2, []string{"j1", "j2"}, nil
+	}
+	s := subscriptions.NewScheduler(store, poll, 5*time.Millisecond, time.Minute)
+	t.Cleanup(s.Close)
+
+	sub, err := store.Create(subscriptions.Subscription{KeyID: "k1", URL: "https://example.com/list", Interval: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the first check is due at once
+	sub = waitRun(t, store, "k1", sub.ID, 1, &polls)
+	if run := sub.LastRun; run.Status != subscriptions.RunSucceeded || run.Found != 2 || len(run.Jobs) != 2 {
+		t.Fatalf("unexpected run %+v", run)
+	}
+	if want := sub.LastRun.StartedAt.Add(time.Second); !sub.NextRunAt.Equal(want) {
+		t.Fatalf("expected the next check at %s, got %s", want, sub.NextRunAt)
+	}
+
+	// the next one an interval later
+	sub = waitRun(t, store, "k1", sub.ID, 2, &polls)
+	if run := sub.LastRun; run.Status != subscriptions.RunFailed || run.Error == "" || len(run.Jobs) != 1 {
+		t.Fatalf("expected a failed run keeping its jobs, got %+v", run)
+	}
+}
+
+func TestSchedulerSkipsPausedSubscriptions(t *testing.T) {
+	store, _ := subscriptions.Open("")
+
+	var polls atomic.Int32
+	poll := func(ctx context.Context, sub subscriptions.Subscription, archive string) (int, []string, error) {
+		polls.Add(1)
+		return 0, nil, nil
+	}
+	s := subscriptions.NewScheduler(store, poll, 5*time.Millisecond, time.Minute)
+	t.Cleanup(s.Close)
+
+	sub, _ := store.Create(subscriptions.Subscription{KeyID: "k1", URL: "https://example.com/list", Interval: 3600, Paused: true})
+	time.Sleep(30 * time.Millisecond)
+	if n := polls.Load(); n != 0 {
+		t.Fatalf("expected a paused subscription not to be checked, got %d checks", n)
+	}
+
+	// unless asked to
+	if _, ok, err := s.RunNow("k2", sub.ID); ok || err != nil {
+		t.Fatalf("expected another key not to run the subscription, got %v, %v", ok, err)
+	}
+	if _, ok, err := s.RunNow("k1", sub.ID); !ok || err != nil {
+		t.Fatalf("unexpected result %v, %v", ok, err)
+	}
+	if sub = waitRun(t, store, "k1", sub.ID, 1, &polls); sub.LastRun.Status != subscriptions.RunSucceeded || sub.LastRun.Jobs == nil {
+		t.Fatalf("unexpected run %+v", sub.LastRun)
+	}
+}
+
+func TestRunNowRejectsConcurrentChecks(t *testing.T) {
+	store, _ := subscriptions.Open("")
+
+	release := make(chan struct{})
+	var polls atomic.Int32
+	poll := func(ctx context.Context, sub subscriptions.Subscription, archive string) (int, []string, error) {
+		<-release
+		polls.Add(1)
+		return 0, nil, nil
+	}
+	s := subscriptions.NewScheduler(store, poll, time.Hour, time.Minute)
+	t.Cleanup(s.Close)
+
+	sub, _ := store.Create(subscriptions.Subscription{KeyID: "k1", URL: "https://example.com/list", Interval: 3600})
+	if got, _, err := s.RunNow("k1", sub.ID); err != nil || !got.Running {
+		t.Fatalf("expected the check to start, got %+v, %v", got, err)
+	}
+	if _, _, err := s.RunNow("k1", sub.ID); !errors.Is(err, subscriptions.ErrRunning) {
+		t.Fatalf("expected ErrRunning, got %v", err)
+	}
+
+	close(release)
+	waitRun(t, store, "k1", sub.ID, 1, &polls)
+}
+
+func TestSchedulerCloseCancelsChecks(t *testing.T) {
+	store, _ := subscriptions.Open("")
+
+	started := make(chan struct{})
+	poll := func(ctx context.Context, sub subscriptions.Subscription, archive string) (int, []string, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, nil, ctx.Err()
+	}
+	s := subscriptions.NewScheduler(store, poll, time.Hour, time.Minute)
+
+	sub, _ := store.Create(subscriptions.Subscription{KeyID: "k1", URL: "https://example.com/list", Interval: 3600})
+	if _, _, err := s.RunNow("k1", sub.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-started
+
+	s.Close()
+
+	if sub, _ = store.Get("k1", sub.ID); sub.Running || sub.LastRun == nil || sub.LastRun.Status != subscriptions.RunFailed {
+		t.Fatalf("expected the cancelled check to be recorded, got %+v", sub)
+	}
+	if _, _, err := s.RunNow("k1", sub.ID); !errors.Is(err, subscriptions.ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestStorePersists(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "subscriptions.json")
+
+	store, err := subscriptions.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sub, err := store.Create(subscriptions.Subscription{KeyID: "k1", URL: "https://example.com/list", Download: []byte(`{"type":"audio"}`), Interval: 3600})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := store.ArchivePath(sub.ID); got != filepath.Join(dir, "archives", sub.ID+".txt") {
+		t.Fatalf("unexpected archive path %s", got)
+	}
+
+	updated, ok, err := store.Update("k1", sub.ID, subscriptions.Subscription{URL: "https://example.com/other", Download: []byte(`{"type":"video"}`), Interval: 60, Paused: true})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result %v, %v", ok, err)
+	}
+	if !updated.NextRunAt.Equal(sub.CreatedAt.Add(time.Minute)) || updated.ID != sub.ID {
+		t.Fatalf("expected the new interval to apply from the creation, got %+v", updated)
+	}
+
+	reopened, err := subscriptions.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	list := reopened.List("k1")
+	if len(list) != 1 || list[0].URL != "https://example.com/other" || !list[0].Paused {
+		t.Fatalf("unexpected subscriptions %+v", list)
+	}
+	var download bytes.Buffer
+	if json.Compact(&download, list[0].Download); download.String() != `{"type":"video"}` {
+		t.Fatalf("unexpected download %s", list[0].Download)
+	}
+	if list := reopened.List("k2"); len(list) != 0 {
+		t.Fatalf("expected no subscriptions for another key, got %+v", list)
+	}
+
+	if ok, _ := reopened.Delete("k2", sub.ID); ok {
+		t.Fatalf("expected another key not to delete the subscription")
+	}
+	if ok, err := reopened.Delete("k1", sub.ID); !ok || err != nil {
+		t.Fatalf("expected the subscription to be deleted, got %v, %v", ok, err)
+	}
+	if _, ok := reopened.Get("k1", sub.ID); ok {
+		t.Fatalf("expected the subscription to be gone")
+	}
+}
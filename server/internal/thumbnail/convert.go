@@ -0,0 +1,55 @@
+package thumbnail
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	// Decoders for the formats thumbnails come in
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+
+	"golang.org/x/image/draw"
+)
+
+// MaxPixels bounds the decoded size of an image, so a small file can't claim
+// huge dimensions and exhaust memory.
+const MaxPixels = 4096 * 4096
+
+// JPEGQuality is used when re-encoding thumbnails.
+const JPEGQuality = 85
+
+// Decodes a JPEG, PNG, GIF or WebP image and encodes it as JPEG, scaled down
+// to at most width pixels wide when width > 0. Smaller images are not enlarged.
+func ToJPEG(data []byte, width int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode thumbnail: %v", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, fmt.Errorf("decode thumbnail: unsupported size %dx%d", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode thumbnail: %v", err)
+	}
+
+	img := src
+	if b := src.Bounds(); width > 0 && b.Dx() > width {
+		height := max(1, b.Dy()*width/b.Dx())
+		dst := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+		img = dst
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: JPEGQuality}); err != nil {
+		return nil, fmt.Errorf("encode thumbnail: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,32 @@
+package thumbnail_test
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/thumbnail"
+)
+
+func TestToJPEG(t *testing.T) {
+	src := testPNG(t, 40, 30)
+
+	for width, want := range map[int]image.Point{0: {40, 30}, 20: {20, 15}, 100: {40, 30}} {
+		data, err := thumbnail.ToJPEG(src, width)
+		if err != nil {
+			t.Fatalf("width %d: %v", width, err)
+		}
+
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || format != "jpeg" {
+			t.Fatalf("width %d: unexpected output %s: %v", width, format, err)
+		}
+		if cfg.Width != want.X || cfg.Height != want.Y {
+			t.Errorf("width %d: expected %v, got %dx%d", width, want, cfg.Width, cfg.Height)
+		}
+	}
+
+	if _, err := thumbnail.ToJPEG([]byte("not an image"), 0); err == nil {
+		t.Fatal("expected an error for invalid data")
+	}
+}
@@ -0,0 +1,140 @@
+// Package thumbnail fetches video thumbnails on behalf of clients, so browsers
+// never contact the upstream CDN, and keeps the results in a bounded in-memory
+// cache. Images can be re-encoded as JPEG and scaled down on the way.
+package thumbnail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+)
+
+// MaxUpstreamBytes bounds the size of a fetched image.
+const MaxUpstreamBytes = 8 << 20
+
+// ErrUpstream is wrapped by errors fetching the image from its origin.
+var ErrUpstream = errors.New("thumbnail could not be fetched")
+
+// Request names an upstream image and how to serve it.
+type Request struct {
+	URL   string
+	JPEG  bool // re-encode as JPEG
+	Width int  // with JPEG, scale down to at most this many pixels wide; 0 keeps the size
+}
+
+func (r Request) key() string {
+	return fmt.Sprintf("%s\x00%t\x00%d", r.URL, r.JPEG, r.Width)
+}
+
+// Image is a served thumbnail.
+type Image struct {
+	Data        []byte
+	ContentType string
+}
+
+// Proxy fetches and caches thumbnails. Concurrent requests for the same image
+// share a single fetch.
+type Proxy struct {
+	client *http.Client
+	cache  *core.MemoryCache[*Image]
+}
+
+// Returns a Proxy caching up to maxBytes of images for ttl each. A nil client
+// uses one with a 15 second timeout.
+func NewProxy(client *http.Client, ttl time.Duration, maxBytes int64) *Proxy {
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+
+	return &Proxy{
+		client: client,
+		cache:  core.NewMemoryCache(ttl, 0, maxBytes, func(img *Image) int64 { return int64(len(img.Data)) }),
+	}
+}
+
+// Get returns the image for req from the cache, or fetches and converts it.
+func (p *Proxy) Get(ctx context.Context, req Request) (*Image, core.CacheStatus, error) {
+	img, _, status, err := p.cache.Get(ctx, req.key(), func(ctx context.Context) (*Image, error) {
+		return p.load(ctx, req)
+	})
+
+	return img, status, err
+}
+
+func (p *Proxy) load(ctx context.Context, req Request) (*Image, error) {
+	img, err := p.fetch(ctx, req.URL)
+	if err != nil || !req.JPEG {
+		return img, err
+	}
+
+	data, err := ToJPEG(img.Data, req.Width)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Image{Data: data, ContentType: "image/jpeg"}, nil
+}
+
+func (p *Proxy) fetch(ctx context.Context, rawURL string) (*Image, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("%w: unsupported URL %q", ErrUpstream, rawURL)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s answered %s", ErrUpstream, u.Host, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxUpstreamBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	if len(data) > MaxUpstreamBytes {
+		return nil, fmt.Errorf("%w: image is larger than %d bytes", ErrUpstream, MaxUpstreamBytes)
+	}
+
+	// CDNs often send a generic type, so trust the bytes instead
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("%w: %s is not an image", ErrUpstream, contentType)
+	}
+
+	return &Image{Data: data, ContentType: contentType}, nil
+}
+
+// Returns the number of cached images and their total size.
+func (p *Proxy) Stats() (entries int, size int64) {
+	return p.cache.Stats()
+}
+
+// Returns the ETag of img, derived from its size and content.
+func (img *Image) ETag() string {
+	h := fnv.New32a()
+	h.Write(img.Data)
+
+	return `"` + strconv.Itoa(len(img.Data)) + "-" + strconv.FormatUint(uint64(h.Sum32()), 16) + `"`
+}
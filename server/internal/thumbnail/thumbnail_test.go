@@ -0,0 +1,125 @@
+package thumbnail_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/core"
+	"github.com/gabriel-logan/yt-dlp/server/internal/thumbnail"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func newUpstream(t *testing.T, body []byte) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/missing.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &hits
+}
+
+func TestProxyCachesImages(t *testing.T) {
+	data := testPNG(t, 64, 36)
+	srv, hits := newUpstream(t, data)
+	p := thumbnail.NewProxy(srv.Client(), time.Minute, 1<<20)
+
+	req := thumbnail.Request{URL: srv.URL + "/hq.png"}
+	img, status, err := p.Get(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != core.CacheMiss || img.ContentType != "image/png" || !bytes.Equal(img.Data, data) {
+		t.Fatalf("unexpected first response %s %s", status, img.ContentType)
+	}
+
+	if _, status, err = p.Get(context.Background(), req); err != nil || status != core.CacheHit {
+		t.Fatalf("expected a cache hit, got %s %v", status, err)
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("expected one upstream request, got %d", hits.Load())
+	}
+}
+
+func TestProxyConvertsToJPEG(t *testing.T) {
+	srv, _ := newUpstream(t, testPNG(t, 64, 36))
+	p := thumbnail.NewProxy(srv.Client(), time.Minute, 1<<20)
+
+	img, _, err := p.Get(context.Background(), thumbnail.Request{URL: srv.URL + "/hq.png", JPEG: true, Width: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ContentType != "image/jpeg" {
+		t.Fatalf("unexpected content type %q", img.ContentType)
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	if err != nil || format != "jpeg" || cfg.Width != 32 || cfg.Height != 18 {
+		t.Fatalf("unexpected image %s %dx%d: %v", format, cfg.Width, cfg.Height, err)
+	}
+}
+
+func TestProxyRejectsBadUpstreams(t *testing.T) {
+	srv, _ := newUpstream(t, []byte("<html>not an image</html>"))
+	p := thumbnail.NewProxy(srv.Client(), time.Minute, 1<<20)
+
+	for _, url := range []string{srv.URL + "/missing.jpg", srv.URL + "/page.jpg", "file:///etc/passwd"} {
+		if _, _, err := p.Get(context.Background(), thumbnail.Request{URL: url}); !errors.Is(err, thumbnail.ErrUpstream) {
+			t.Errorf("%s: expected ErrUpstream, got %v", url, err)
+		}
+	}
+}
+
+func TestProxyEvictsLeastRecentlyUsed(t *testing.T) {
+	data := testPNG(t, 16, 16)
+	srv, hits := newUpstream(t, data)
+	p := thumbnail.NewProxy(srv.Client(), time.Minute, int64(len(data))*2)
+
+	for _, path := range []string{"/a", "/b", "/a", "/c", "/a"} {
+		if _, _, err := p.Get(context.Background(), thumbnail.Request{URL: srv.URL + path}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, size := p.Stats(); n != 2 || size != int64(len(data))*2 {
+		t.Fatalf("unexpected cache stats %d, %d", n, size)
+	}
+	// /b was evicted by /c, while /a stayed in use
+	if hits.Load() != 3 {
+		t.Fatalf("expected 3 upstream requests, got %d", hits.Load())
+	}
+}
@@ -0,0 +1,199 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// ErrMalformed is wrapped by the errors of bodies that are not a JSON object.
+var ErrMalformed = errors.New("malformed request")
+
+// JSON decodes the JSON object in r's body into dst, a pointer to a struct,
+// and validates it. The body is limited to maxBytes; a larger one fails with
+// *http.MaxBytesError. Unknown properties, values of the wrong type and broken
+// rules are all reported together as Errors.
+func JSON(w http.ResponseWriter, r *http.Request, dst any, maxBytes int64) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+	if err != nil {
+		return err
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return fmt.Errorf("%w: request body is required", ErrMalformed)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return fmt.Errorf("%w: request body must be a JSON object: %v", ErrMalformed, err)
+	}
+
+	v := reflect.ValueOf(dst).Elem()
+	failed := map[string]bool{}
+
+	errs := decodeObject(raw, v, "", failed)
+	errs = append(errs, checkStruct(v, "", failed)...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// decodeObject fills the struct v from the properties in raw, naming fields
+// after prefix. Fields that fail to decode are added to failed.
+func decodeObject(raw map[string]json.RawMessage, v reflect.Value, prefix string, failed map[string]bool) Errors {
+	t := v.Type()
+
+	var errs Errors
+	known := map[string]bool{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := FieldName(f)
+		if !f.IsExported() || name == "" {
+			continue
+		}
+		known[name] = true
+
+		value, ok := raw[name]
+		if !ok {
+			continue
+		}
+
+		path := prefix + name
+
+		if isStructSlice(f.Type) {
+			var items []map[string]json.RawMessage
+			if err := json.Unmarshal(value, &items); err != nil {
+				failed[path] = true
+				errs = append(errs, FieldError{Field: path, Code: CodeInvalidType, Message: "must be an array of objects"})
+				continue
+			}
+
+			s := reflect.MakeSlice(f.Type, len(items), len(items))
+			for j, item := range items {
+				errs = append(errs, decodeObject(item, s.Index(j), fmt.Sprintf("%s[%d].", path, j), failed)...)
+			}
+			v.Field(i).Set(s)
+			continue
+		}
+
+		if isObject(f.Type) {
+			var obj map[string]json.RawMessage
+			if err := json.Unmarshal(value, &obj); err != nil || obj == nil {
+				failed[path] = true
+				errs = append(errs, FieldError{Field: path, Code: CodeInvalidType, Message: "must be an object"})
+				continue
+			}
+
+			errs = append(errs, decodeObject(obj, v.Field(i), path+".", failed)...)
+			continue
+		}
+
+		if err := json.Unmarshal(value, v.Field(i).Addr().Interface()); err != nil {
+			failed[path] = true
+			errs = append(errs, FieldError{Field: path, Code: CodeInvalidType, Message: "must be " + typeName(f.Type)})
+		}
+	}
+
+	for _, name := range sortedKeys(raw) {
+		if !known[name] {
+			errs = append(errs, FieldError{Field: prefix + name, Code: CodeUnknownField, Message: "is not a known field"})
+		}
+	}
+
+	return errs
+}
+
+// Query fills the fields of dst, a pointer to a struct, from the query
+// parameters named by their `query` tags and validates it. Unknown parameters
+// are ignored.
+func Query(values url.Values, dst any) error {
+	v := reflect.ValueOf(dst).Elem()
+	t := v.Type()
+
+	var errs Errors
+	failed := map[string]bool{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("query")
+		if name == "" || !values.Has(name) {
+			continue
+		}
+
+		if err := setString(v.Field(i), values.Get(name)); err != nil {
+			failed[name] = true
+			errs = append(errs, FieldError{Field: name, Code: CodeInvalidType, Message: "must be " + typeName(f.Type)})
+		}
+	}
+
+	errs = append(errs, checkStruct(v, "", failed)...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func setString(field reflect.Value, s string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported query field type %s", field.Type())
+	}
+
+	return nil
+}
+
+// Returns how a type is described in messages, Ex: "an integer".
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
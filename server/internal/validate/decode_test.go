@@ -0,0 +1,156 @@
+package validate_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+)
+
+type query struct {
+	URL   string `query:"url" validate:"required,maxlen=10"`
+	Limit int    `query:"limit" validate:"max=50"`
+	Full  bool   `query:"full"`
+}
+
+func decodeJSON(body string, maxBytes int64) (request, error) {
+	var req request
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	err := validate.JSON(httptest.NewRecorder(), r, &req, maxBytes)
+
+	return req, err
+}
+
+func TestJSONDecodesValidBody(t *testing.T) {
+	req, err := decodeJSON(`{"name":"abc","kind":"b","count":3}`, 1024)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if req.Name != "abc" || req.Kind != "b" || req.Count != 3 {
+		t.Fatalf("unexpected result %+v", req)
+	}
+}
+
+func TestJSONReportsUnknownAndTypeErrorsTogether(t *testing.T) {
+	_, err := decodeJSON(`{"name":"","count":"3","zeta":1,"alpha":2}`, 1024)
+
+	want := map[string]string{
+		"name":  validate.CodeRequired,
+		"count": validate.CodeInvalidType,
+		"alpha": validate.CodeUnknownField,
+		"zeta":  validate.CodeUnknownField,
+	}
+	if got := fieldCodes(t, err); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+type part struct {
+	From string `json:"from" validate:"required,maxlen=5"`
+}
+
+type playlist struct {
+	Parts []part `json:"parts" validate:"required,maxitems=2"`
+}
+
+func TestJSONChecksItems(t *testing.T) {
+	var p playlist
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"parts":[{"from":"1:00"},{"from":"","to":1}]}`))
+	err := validate.JSON(httptest.NewRecorder(), r, &p, 1024)
+
+	want := map[string]string{
+		"parts[1].from": validate.CodeRequired,
+		"parts[1].to":   validate.CodeUnknownField,
+	}
+	if got := fieldCodes(t, err); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if len(p.Parts) != 2 || p.Parts[0].From != "1:00" {
+		t.Fatalf("unexpected result %+v", p)
+	}
+
+	for body, want := range map[string]map[string]string{
+		`{"parts":[]}`: {"parts": validate.CodeRequired},
+		`{"parts":[{"from":"a"},{"from":"b"},{}]}`: {"parts": validate.CodeTooMany, "parts[2].from": validate.CodeRequired},
+		`{"parts":[1]}`: {"parts": validate.CodeInvalidType},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		err := validate.JSON(httptest.NewRecorder(), r, &playlist{}, 1024)
+		if got := fieldCodes(t, err); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", body, got, want)
+		}
+	}
+}
+
+type share struct {
+	Part    part `json:"part"`
+	Expires int  `json:"expires" validate:"max=60"`
+}
+
+func TestJSONChecksNestedObjects(t *testing.T) {
+	var sh share
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"part":{"from":"1:00"},"expires":30}`))
+	if err := validate.JSON(httptest.NewRecorder(), r, &sh, 1024); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if sh.Part.From != "1:00" || sh.Expires != 30 {
+		t.Fatalf("unexpected result %+v", sh)
+	}
+
+	for body, want := range map[string]map[string]string{
+		`{"part":{"from":"","to":1}}`: {"part.from": validate.CodeRequired, "part.to": validate.CodeUnknownField},
+		`{"part":{},"expires":90}`:    {"part.from": validate.CodeRequired, "expires": validate.CodeTooLarge},
+		`{"expires":1}`:               {"part.from": validate.CodeRequired},
+		`{"part":"1:00"}`:             {"part": validate.CodeInvalidType},
+		`{"part":null}`:               {"part": validate.CodeInvalidType},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		err := validate.JSON(httptest.NewRecorder(), r, &share{}, 1024)
+		if got := fieldCodes(t, err); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", body, got, want)
+		}
+	}
+}
+
+func TestJSONMalformedBodies(t *testing.T) {
+	for _, body := range []string{``, `   `, `not json`, `[1,2]`, `{"name":"abc"} {}`} {
+		if _, err := decodeJSON(body, 1024); !errors.Is(err, validate.ErrMalformed) {
+			t.Errorf("%q: expected ErrMalformed, got %v", body, err)
+		}
+	}
+}
+
+func TestJSONLimitsBodySize(t *testing.T) {
+	_, err := decodeJSON(`{"name":"`+strings.Repeat("a", 100)+`"}`, 16)
+
+	var maxErr *http.MaxBytesError
+	if !errors.As(err, &maxErr) {
+		t.Fatalf("expected *http.MaxBytesError, got %v", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	var q query
+	if err := validate.Query(url.Values{"url": {"x"}, "limit": {"5"}, "full": {"true"}, "other": {"1"}}, &q); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if q.URL != "x" || q.Limit != 5 || !q.Full {
+		t.Fatalf("unexpected result %+v", q)
+	}
+
+	err := validate.Query(url.Values{"limit": {"many"}, "full": {"maybe"}}, &query{})
+
+	want := map[string]string{
+		"url":   validate.CodeRequired,
+		"limit": validate.CodeInvalidType,
+		"full":  validate.CodeInvalidType,
+	}
+	if got := fieldCodes(t, err); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,291 @@
+// Package validate decodes request input into structs and checks it against
+// the rules declared in their struct tags, reporting every failing field at
+// once.
+//
+// Rules go in a `validate` tag, separated by commas:
+//
+//	required   the value must not be blank
+//	min=N      numbers must be >= N
+//	max=N      numbers must be <= N
+//	minlen=N   strings must have at least N characters, after trimming spaces
+//	maxlen=N   strings must have at most N characters, after trimming spaces
+//	len=N      strings must have exactly N characters
+//	hex        strings must only contain hexadecimal digits
+//	token      strings must only contain letters, digits, '.', '_', '-' and '='
+//	maxitems=N slices must have at most N items
+//
+// An `enum:"a,b"` tag restricts a string to the listed values. Rules other
+// than required are skipped for empty strings. Fields are named after their
+// json tag, or their query or path tag for URL parameters. The items of slices
+// of structs and nested structs are checked too, and named like
+// "sections[0].start" or "download.url".
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Codes of FieldError.
+const (
+	CodeRequired      = "required"
+	CodeTooShort      = "too_short"
+	CodeTooLong       = "too_long"
+	CodeTooMany       = "too_many"
+	CodeTooSmall      = "too_small"
+	CodeTooLarge      = "too_large"
+	CodeInvalidChoice = "invalid_choice"
+	CodeInvalidFormat = "invalid_format"
+	CodeInvalidType   = "invalid_type"
+	CodeUnknownField  = "unknown_field"
+)
+
+// FieldError describes why one field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors lists every rejected field of a request.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+// Rules are the parsed validation tags of a field.
+type Rules struct {
+	Required       bool
+	Min, Max       *int64
+	MinLen, MaxLen *int
+	MaxItems       *int
+	Hex            bool
+	Token          bool
+	Enum           []string
+}
+
+// Returns the rules declared on f.
+func FieldRules(f reflect.StructField) (Rules, error) {
+	var rules Rules
+
+	if enum := f.Tag.Get("enum"); enum != "" {
+		rules.Enum = strings.Split(enum, ",")
+	}
+
+	tag := f.Tag.Get("validate")
+	if tag == "" {
+		return rules, nil
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+		switch name {
+		case "required":
+			rules.Required = true
+		case "hex":
+			rules.Hex = true
+		case "token":
+			rules.Token = true
+		case "min", "max":
+			n, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return rules, fmt.Errorf("field %s: invalid rule %q", f.Name, rule)
+			}
+			if name == "min" {
+				rules.Min = &n
+			} else {
+				rules.Max = &n
+			}
+		case "minlen", "maxlen", "len":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				return rules, fmt.Errorf("field %s: invalid rule %q", f.Name, rule)
+			}
+			if name != "maxlen" {
+				rules.MinLen = &n
+			}
+			if name != "minlen" {
+				rules.MaxLen = &n
+			}
+		case "maxitems":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				return rules, fmt.Errorf("field %s: invalid rule %q", f.Name, rule)
+			}
+			rules.MaxItems = &n
+		default:
+			return rules, fmt.Errorf("field %s: unknown rule %q", f.Name, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+// Struct checks the fields of v, a struct or a pointer to one, and returns
+// Errors when any of them breaks its rules.
+func Struct(v any) error {
+	if errs := checkStruct(reflect.Indirect(reflect.ValueOf(v)), "", nil); len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// checkStruct names fields after prefix and skips those in skip, which already
+// failed to decode.
+func checkStruct(v reflect.Value, prefix string, skip map[string]bool) Errors {
+	var errs Errors
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := FieldName(f)
+		if name == "" || skip[prefix+name] {
+			continue
+		}
+		name = prefix + name
+
+		rules, err := FieldRules(f)
+		if err != nil {
+			panic("validate: " + err.Error())
+		}
+
+		if isObject(f.Type) {
+			// a missing object reports each of its required fields instead
+			errs = append(errs, checkStruct(v.Field(i), name+".", skip)...)
+			continue
+		}
+
+		if fe, ok := checkField(name, v.Field(i), rules); !ok {
+			errs = append(errs, fe)
+		}
+
+		if isStructSlice(f.Type) {
+			items := v.Field(i)
+			for j := 0; j < items.Len(); j++ {
+				errs = append(errs, checkStruct(items.Index(j), fmt.Sprintf("%s[%d].", name, j), skip)...)
+			}
+		}
+	}
+
+	return errs
+}
+
+func checkField(name string, v reflect.Value, rules Rules) (FieldError, bool) {
+	fail := func(code, format string, args ...any) (FieldError, bool) {
+		return FieldError{Field: name, Code: code, Message: fmt.Sprintf(format, args...)}, false
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s := strings.TrimSpace(v.String())
+		if s == "" {
+			if rules.Required {
+				return fail(CodeRequired, "is required")
+			}
+			return FieldError{}, true
+		}
+
+		n := utf8.RuneCountInString(s)
+		switch {
+		case rules.MinLen != nil && rules.MaxLen != nil && *rules.MinLen == *rules.MaxLen && n != *rules.MinLen:
+			return fail(CodeInvalidFormat, "must be exactly %d characters", *rules.MinLen)
+		case rules.MinLen != nil && n < *rules.MinLen:
+			return fail(CodeTooShort, "must be at least %d characters", *rules.MinLen)
+		case rules.MaxLen != nil && n > *rules.MaxLen:
+			return fail(CodeTooLong, "must be at most %d characters", *rules.MaxLen)
+		case rules.Hex && strings.Trim(s, "0123456789abcdefABCDEF") != "":
+			return fail(CodeInvalidFormat, "must be hexadecimal")
+		case rules.Token && !isToken(s):
+			return fail(CodeInvalidFormat, "must only contain letters, digits, '.', '_', '-' and '='")
+		case len(rules.Enum) > 0 && !slices.Contains(rules.Enum, v.String()):
+			return fail(CodeInvalidChoice, "must be one of %s", strings.Join(rules.Enum, ", "))
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		switch {
+		case rules.Min != nil && n < *rules.Min:
+			return fail(CodeTooSmall, "must be at least %d", *rules.Min)
+		case rules.Max != nil && n > *rules.Max:
+			return fail(CodeTooLarge, "must be at most %d", *rules.Max)
+		}
+
+	case reflect.Slice:
+		switch {
+		case rules.Required && v.Len() == 0:
+			return fail(CodeRequired, "is required")
+		case rules.MaxItems != nil && v.Len() > *rules.MaxItems:
+			return fail(CodeTooMany, "must have at most %d items", *rules.MaxItems)
+		}
+
+	default:
+		if rules.Required && v.IsZero() {
+			return fail(CodeRequired, "is required")
+		}
+	}
+
+	return FieldError{}, true
+}
+
+// Returns the name f is known by in requests: its json tag, then its query
+// or path tag, then the Go name. It returns "" for fields excluded with json:"-".
+func FieldName(f reflect.StructField) string {
+	if tag := f.Tag.Get("json"); tag != "" {
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+
+	if q := f.Tag.Get("query"); q != "" {
+		return q
+	}
+
+	if p := f.Tag.Get("path"); p != "" {
+		return p
+	}
+
+	return f.Name
+}
+
+func isStructSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct
+}
+
+// Reports whether t is a nested object: a struct other than time.Time.
+func isObject(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != reflect.TypeFor[time.Time]()
+}
+
+func isToken(s string) bool {
+	for _, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '.', c == '_', c == '-', c == '=':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,91 @@
+package validate_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/validate"
+)
+
+type request struct {
+	Name   string `json:"name" validate:"required,minlen=2,maxlen=5"`
+	Kind   string `json:"kind,omitempty" enum:"a,b"`
+	Count  int    `json:"count" validate:"min=0,max=10"`
+	Digest string `json:"digest" validate:"len=4,hex"`
+	ID     string `json:"id" validate:"token"`
+	Skip   string `json:"-" validate:"required"`
+}
+
+func fieldCodes(t *testing.T, err error) map[string]string {
+	t.Helper()
+
+	var errs validate.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validate.Errors, got %v", err)
+	}
+
+	codes := map[string]string{}
+	for _, fe := range errs {
+		codes[fe.Field] = fe.Code
+	}
+
+	return codes
+}
+
+func TestStructValid(t *testing.T) {
+	if err := validate.Struct(request{Name: "abc", Kind: "a", Count: 10, Digest: "beEF", ID: "dash-video=1998000"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// rules other than required do not apply to empty strings
+	if err := validate.Struct(&request{Name: "ab"}); err != nil {
+		t.Fatalf("expected no error for empty optional fields, got %v", err)
+	}
+}
+
+func TestStructReportsEveryField(t *testing.T) {
+	err := validate.Struct(request{Name: "  ", Kind: "c", Count: -1, Digest: "zzzz"})
+
+	want := map[string]string{
+		"name":   validate.CodeRequired,
+		"kind":   validate.CodeInvalidChoice,
+		"count":  validate.CodeTooSmall,
+		"digest": validate.CodeInvalidFormat,
+	}
+	if got := fieldCodes(t, err); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestStructLengths(t *testing.T) {
+	tests := []struct {
+		req  request
+		code string
+	}{
+		{request{Name: "a"}, validate.CodeTooShort},
+		{request{Name: "abcdef"}, validate.CodeTooLong},
+		{request{Name: "abc", Count: 11}, validate.CodeTooLarge},
+		{request{Name: "abc", Digest: "abc"}, validate.CodeInvalidFormat},
+		{request{Name: "abc", ID: "137+140"}, validate.CodeInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		codes := fieldCodes(t, validate.Struct(tt.req))
+		if len(codes) != 1 {
+			t.Errorf("%+v: expected one error, got %v", tt.req, codes)
+		}
+		for _, code := range codes {
+			if code != tt.code {
+				t.Errorf("%+v: expected %q, got %q", tt.req, tt.code, code)
+			}
+		}
+	}
+}
+
+func TestFieldRulesRejectsUnknownRules(t *testing.T) {
+	f := reflect.StructField{Name: "X", Tag: `validate:"required,bogus"`}
+	if _, err := validate.FieldRules(f); err == nil {
+		t.Fatal("expected an error for an unknown rule")
+	}
+}
@@ -0,0 +1,25 @@
+//go:build embedspa
+
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// The client build is copied here before compiling with -tags embedspa:
+//
+//	cp -r ../client/dist internal/web/dist
+//
+//go:embed all:dist
+var embeddedDist embed.FS
+
+// EmbeddedDist returns the client build compiled into the binary.
+func EmbeddedDist() (fs.FS, bool) {
+	dist, err := fs.Sub(embeddedDist, "dist")
+	if err != nil {
+		return nil, false
+	}
+
+	return dist, true
+}
@@ -0,0 +1,11 @@
+//go:build !embedspa
+
+package web
+
+import "io/fs"
+
+// EmbeddedDist reports false: this binary was built without -tags embedspa,
+// so the client build is served from disk.
+func EmbeddedDist() (fs.FS, bool) {
+	return nil, false
+}
@@ -0,0 +1,15 @@
+//go:build !embedspa
+
+package web_test
+
+import (
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/web"
+)
+
+func TestEmbeddedDistDisabledWithoutBuildTag(t *testing.T) {
+	if _, ok := web.EmbeddedDist(); ok {
+		t.Fatalf("expected no embedded client without -tags embedspa")
+	}
+}
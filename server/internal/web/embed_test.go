@@ -0,0 +1,21 @@
+//go:build embedspa
+
+package web_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/web"
+)
+
+func TestEmbeddedDistContainsIndex(t *testing.T) {
+	dist, ok := web.EmbeddedDist()
+	if !ok {
+		t.Fatalf("expected embedded client with -tags embedspa")
+	}
+
+	if _, err := fs.Stat(dist, "index.html"); err != nil {
+		t.Fatalf("expected index.html in embedded client: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// disabledHeaderValue turns a security header off when used as its environment value.
+const disabledHeaderValue = "off"
+
+// SecurityHeaders are added to every SPA response. Empty fields are omitted.
+type SecurityHeaders struct {
+	ContentSecurityPolicy string // without frame-ancestors, which comes from FrameAncestors
+	ReferrerPolicy        string
+	FrameAncestors        string // Ex: "'none'", "'self' https://intranet.example.com"
+}
+
+// DefaultSecurityHeaders returns a policy suited to the bundled client. When the
+// client talks to an API on another origin (VITE_API_BASE_URL), that origin is
+// allowed in connect-src.
+func DefaultSecurityHeaders() SecurityHeaders {
+	connectSrc := "'self'"
+	if u, err := url.Parse(os.Getenv("VITE_API_BASE_URL")); err == nil && u.Scheme != "" && u.Host != "" {
+		connectSrc += " " + u.Scheme + "://" + u.Host
+	}
+
+	return SecurityHeaders{
+		ContentSecurityPolicy: strings.Join([]string{
+			"default-src 'self'",
+			"script-src 'self'",
+			"style-src 'self' 'unsafe-inline'",
+			"img-src 'self' data: blob:", // thumbnails are proxied by the API
+			"connect-src " + connectSrc,
+			"object-src 'none'",
+			"base-uri 'self'",
+			"form-action 'self'",
+		}, "; "),
+		ReferrerPolicy: "no-referrer",
+		FrameAncestors: "'none'",
+	}
+}
+
+// SecurityHeadersFromEnv returns DefaultSecurityHeaders overridden by SPA_CSP,
+// SPA_REFERRER_POLICY and SPA_FRAME_ANCESTORS. A value of "off" omits the header.
+func SecurityHeadersFromEnv() SecurityHeaders {
+	h := DefaultSecurityHeaders()
+
+	override := func(field *string, key string) {
+		v := strings.TrimSpace(os.Getenv(key))
+		switch v {
+		case "":
+		case disabledHeaderValue:
+			*field = ""
+		default:
+			*field = v
+		}
+	}
+
+	override(&h.ContentSecurityPolicy, "SPA_CSP")
+	override(&h.ReferrerPolicy, "SPA_REFERRER_POLICY")
+	override(&h.FrameAncestors, "SPA_FRAME_ANCESTORS")
+
+	return h
+}
+
+func (s SecurityHeaders) apply(h http.Header) {
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	csp := s.ContentSecurityPolicy
+	if s.FrameAncestors != "" {
+		if csp != "" {
+			csp += "; "
+		}
+		csp += "frame-ancestors " + s.FrameAncestors
+
+		// Legacy equivalent for browsers without frame-ancestors support.
+		switch s.FrameAncestors {
+		case "'none'":
+			h.Set("X-Frame-Options", "DENY")
+		case "'self'":
+			h.Set("X-Frame-Options", "SAMEORIGIN")
+		}
+	}
+
+	if csp != "" {
+		h.Set("Content-Security-Policy", csp)
+	}
+
+	if s.ReferrerPolicy != "" {
+		h.Set("Referrer-Policy", s.ReferrerPolicy)
+	}
+}
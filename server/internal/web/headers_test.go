@@ -0,0 +1,40 @@
+package web_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/web"
+)
+
+func TestDefaultSecurityHeadersAllowsAPIOrigin(t *testing.T) {
+	t.Setenv("VITE_API_BASE_URL", "https://api.example.com/base")
+
+	h := web.DefaultSecurityHeaders()
+
+	if !strings.Contains(h.ContentSecurityPolicy, "connect-src 'self' https://api.example.com") {
+		t.Fatalf("expected API origin in connect-src, got %q", h.ContentSecurityPolicy)
+	}
+	if h.FrameAncestors != "'none'" {
+		t.Fatalf("expected frame-ancestors 'none', got %q", h.FrameAncestors)
+	}
+}
+
+func TestSecurityHeadersFromEnv(t *testing.T) {
+	t.Setenv("VITE_API_BASE_URL", "")
+	t.Setenv("SPA_CSP", "off")
+	t.Setenv("SPA_REFERRER_POLICY", "strict-origin")
+	t.Setenv("SPA_FRAME_ANCESTORS", "'self'")
+
+	h := web.SecurityHeadersFromEnv()
+
+	if h.ContentSecurityPolicy != "" {
+		t.Fatalf("expected CSP to be disabled, got %q", h.ContentSecurityPolicy)
+	}
+	if h.ReferrerPolicy != "strict-origin" {
+		t.Fatalf("expected Referrer-Policy override, got %q", h.ReferrerPolicy)
+	}
+	if h.FrameAncestors != "'self'" {
+		t.Fatalf("expected frame-ancestors override, got %q", h.FrameAncestors)
+	}
+}
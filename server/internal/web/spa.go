@@ -1,43 +1,215 @@
 package web
 
 import (
-	"log"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/apierror"
 )
 
-func RegisterSPA(mux *http.ServeMux, distPath string) {
-	distAbs, err := filepath.Abs(distPath)
-	if err != nil {
-		log.Fatalf("failed to get absolute path of dist directory: %v", err)
-	}
+const (
+	indexFileName = "index.html"
+
+	// Vite emits content-hashed file names under assets/, so they never change.
+	immutableCacheControl  = "public, max-age=31536000, immutable"
+	revalidateCacheControl = "no-cache"
+)
+
+// precompressedVariants are tried in order of preference when the client accepts them.
+var precompressedVariants = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// RegisterSPA serves the client build in fsys, falling back to index.html for
+// client-side routes. fsys is either a directory on disk (os.DirFS) or the
+// build embedded into the binary (see EmbeddedDist). headers are added to every
+// response of the SPA handler.
+func RegisterSPA(mux *http.ServeMux, fsys fs.FS, headers SecurityHeaders) {
+	etags := &etagCache{}
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		// block API routes from SPA handler
 		if strings.HasPrefix(r.URL.Path, "/api") {
-			http.NotFound(w, r)
+			apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "no such API route")
 			return
 		}
 
-		reqPath := filepath.Clean(r.URL.Path)
+		headers.apply(w.Header())
 
-		absPath := filepath.Join(distAbs, reqPath)
+		// cleaning a rooted path removes any ".." that would escape fsys
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" {
+			name = indexFileName
+		}
 
-		// ensure the requested path is within the dist directory
-		if !strings.HasPrefix(absPath, distAbs) {
+		if !fs.ValidPath(name) {
 			http.Error(w, "invalid path", http.StatusBadRequest)
 			return
 		}
 
 		// check if file exists and is not a directory
-		if info, err := os.Stat(absPath); err == nil && !info.IsDir() {
-			http.ServeFile(w, r, absPath)
+		if info, err := fs.Stat(fsys, name); err == nil && !info.IsDir() {
+			cacheControl := revalidateCacheControl
+			if strings.HasPrefix(name, "assets/") {
+				cacheControl = immutableCacheControl
+			}
+
+			serveFile(w, r, fsys, name, cacheControl, etags)
+			return
+		}
+
+		// A missing script or stylesheet must fail loudly instead of
+		// returning index.html, so stale clients notice after a deploy.
+		if isAssetPath(name) {
+			http.NotFound(w, r)
 			return
 		}
 
 		// fallback to index.html
-		http.ServeFile(w, r, filepath.Join(distAbs, "index.html"))
+		serveFile(w, r, fsys, indexFileName, revalidateCacheControl, etags)
 	})
 }
+
+// assetExtensions are the file types of a client build. Other paths with a
+// dot, such as /watch/v1.2, are client-side routes.
+var assetExtensions = map[string]bool{
+	".js": true, ".mjs": true, ".css": true, ".map": true, ".wasm": true, ".json": true, ".webmanifest": true,
+	".ico": true, ".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".avif": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".otf": true, ".txt": true, ".xml": true,
+}
+
+// Reports whether name looks like a static file rather than a client-side route.
+func isAssetPath(name string) bool {
+	return strings.HasPrefix(name, "assets/") || assetExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// serveFile serves name from fsys, using a precompressed variant when one exists
+// and the client accepts it.
+func serveFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name, cacheControl string, etags *etagCache) {
+	servedName, encoding, hasVariants := name, "", false
+
+	for _, v := range precompressedVariants {
+		info, err := fs.Stat(fsys, name+v.ext)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		hasVariants = true
+		if encoding == "" && acceptsEncoding(r.Header.Get("Accept-Encoding"), v.encoding) {
+			servedName, encoding = name+v.ext, v.encoding
+		}
+	}
+
+	f, err := fsys.Open(servedName)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	content, err := readSeeker(f)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	etag, err := etags.get(servedName, info, content)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h := w.Header()
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		h.Set("Content-Type", ctype)
+	}
+	if hasVariants {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+	}
+	h.Set("Cache-Control", cacheControl)
+	h.Set("ETag", etag)
+
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// Reports whether an Accept-Encoding header allows encoding (a q=0 entry rejects it).
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		token, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(token), encoding) {
+			continue
+		}
+
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+func readSeeker(f fs.File) (io.ReadSeeker, error) {
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(data), nil
+}
+
+// etagCache remembers content hashes so each file is hashed once per version.
+// Embedded files have no modification time, so an ETag is the only validator.
+type etagCache struct {
+	m sync.Map // "name|modtime|size" -> etag
+}
+
+func (c *etagCache) get(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	key := fmt.Sprintf("%s|%d|%d", name, info.ModTime().UnixNano(), info.Size())
+	if etag, ok := c.m.Load(key); ok {
+		return etag.(string), nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := fmt.Sprintf("\"%s\"", hex.EncodeToString(h.Sum(nil)[:12]))
+	c.m.Store(key, etag)
+
+	return etag, nil
+}
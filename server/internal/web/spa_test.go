@@ -6,7 +6,9 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/gabriel-logan/yt-dlp/server/internal/web"
 )
@@ -29,7 +31,7 @@ func TestRegisterSPAServesStaticFile(t *testing.T) {
 	}
 
 	mux := http.NewServeMux()
-	web.RegisterSPA(mux, dist)
+	web.RegisterSPA(mux, os.DirFS(dist), web.DefaultSecurityHeaders())
 
 	req := httptest.NewRequest(http.MethodGet, "/"+aboutHtmlFileName, nil)
 	rr := httptest.NewRecorder()
@@ -51,9 +53,9 @@ func TestRegisterSPAFallbackToIndex(t *testing.T) {
 	}
 
 	mux := http.NewServeMux()
-	web.RegisterSPA(mux, dist)
+	web.RegisterSPA(mux, os.DirFS(dist), web.DefaultSecurityHeaders())
 
-	req := httptest.NewRequest(http.MethodGet, "/missing.js", nil)
+	req := httptest.NewRequest(http.MethodGet, "/settings/profile", nil)
 	rr := httptest.NewRecorder()
 	mux.ServeHTTP(rr, req)
 
@@ -72,7 +74,7 @@ func TestRegisterSPABlocksAPIPaths(t *testing.T) {
 	}
 
 	mux := http.NewServeMux()
-	web.RegisterSPA(mux, dist)
+	web.RegisterSPA(mux, os.DirFS(dist), web.DefaultSecurityHeaders())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
 	rr := httptest.NewRecorder()
@@ -95,7 +97,7 @@ func TestRegisterSPADirectoryRequestFallsBackToIndex(t *testing.T) {
 	}
 
 	mux := http.NewServeMux()
-	web.RegisterSPA(mux, dist)
+	web.RegisterSPA(mux, os.DirFS(dist), web.DefaultSecurityHeaders())
 
 	req := httptest.NewRequest(http.MethodGet, "/assets", nil)
 	rr := httptest.NewRecorder()
@@ -108,3 +110,202 @@ func TestRegisterSPADirectoryRequestFallsBackToIndex(t *testing.T) {
 		t.Fatalf("expected index.html body %q, got %q", string(indexContent), rr.Body.String())
 	}
 }
+
+func TestRegisterSPAServesFromInMemoryFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		indexHtmlFileName:    {Data: []byte(indexHtmlContent)},
+		"assets/app-1234.js": {Data: []byte("console.log(1)")},
+	}
+
+	mux := http.NewServeMux()
+	web.RegisterSPA(mux, fsys, web.DefaultSecurityHeaders())
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", indexHtmlContent},
+		{"/assets/app-1234.js", "console.log(1)"},
+		{"/some/client/route", indexHtmlContent},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: "+expected200Got, tt.path, rr.Code)
+		}
+		if rr.Body.String() != tt.want {
+			t.Fatalf("%s: expected body %q, got %q", tt.path, tt.want, rr.Body.String())
+		}
+	}
+}
+
+func TestRegisterSPAMissingAssetReturns404(t *testing.T) {
+	fsys := fstest.MapFS{
+		indexHtmlFileName: {Data: []byte(indexHtmlContent)},
+	}
+
+	mux := http.NewServeMux()
+	web.RegisterSPA(mux, fsys, web.DefaultSecurityHeaders())
+
+	for _, p := range []string{"/missing.js", "/assets/app-old.css", "/assets/chunk", "/favicon.ICO"} {
+		req := httptest.NewRequest(http.MethodGet, p, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", p, rr.Code)
+		}
+	}
+
+	// client routes may contain a dot
+	for _, p := range []string{"/watch/v1.2", "/user/jane.doe", "/page.html"} {
+		req := httptest.NewRequest(http.MethodGet, p, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK || rr.Body.String() != indexHtmlContent {
+			t.Fatalf("%s: expected index.html, got %d %q", p, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestRegisterSPACacheControl(t *testing.T) {
+	fsys := fstest.MapFS{
+		indexHtmlFileName:    {Data: []byte(indexHtmlContent)},
+		"favicon.svg":        {Data: []byte("<svg/>")},
+		"assets/app-1234.js": {Data: []byte("console.log(1)")},
+	}
+
+	mux := http.NewServeMux()
+	web.RegisterSPA(mux, fsys, web.DefaultSecurityHeaders())
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", "no-cache"},
+		{"/client/route", "no-cache"},
+		{"/favicon.svg", "no-cache"},
+		{"/assets/app-1234.js", "public, max-age=31536000, immutable"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if got := rr.Header().Get("Cache-Control"); got != tt.want {
+			t.Fatalf("%s: expected Cache-Control %q, got %q", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestRegisterSPAServesPrecompressedVariants(t *testing.T) {
+	fsys := fstest.MapFS{
+		indexHtmlFileName:       {Data: []byte(indexHtmlContent)},
+		"assets/app-1234.js":    {Data: []byte("plain")},
+		"assets/app-1234.js.br": {Data: []byte("brotli")},
+		"assets/app-1234.js.gz": {Data: []byte("gzip")},
+	}
+
+	mux := http.NewServeMux()
+	web.RegisterSPA(mux, fsys, web.DefaultSecurityHeaders())
+
+	tests := []struct {
+		acceptEncoding string
+		wantBody       string
+		wantEncoding   string
+	}{
+		{"", "plain", ""},
+		{"gzip, deflate", "gzip", "gzip"},
+		{"gzip, br", "brotli", "br"},
+		{"br;q=0, gzip", "gzip", "gzip"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/assets/app-1234.js", nil)
+		if tt.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%q: "+expected200Got, tt.acceptEncoding, rr.Code)
+		}
+		if rr.Body.String() != tt.wantBody {
+			t.Fatalf("%q: expected body %q, got %q", tt.acceptEncoding, tt.wantBody, rr.Body.String())
+		}
+		if got := rr.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+			t.Fatalf("%q: expected Content-Encoding %q, got %q", tt.acceptEncoding, tt.wantEncoding, got)
+		}
+		if got := rr.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Fatalf("%q: expected Vary Accept-Encoding, got %q", tt.acceptEncoding, got)
+		}
+		if got := rr.Header().Get("Content-Type"); !strings.Contains(got, "javascript") {
+			t.Fatalf("%q: expected a JavaScript Content-Type, got %q", tt.acceptEncoding, got)
+		}
+	}
+}
+
+func TestRegisterSPAETagRevalidation(t *testing.T) {
+	fsys := fstest.MapFS{
+		indexHtmlFileName: {Data: []byte(indexHtmlContent)},
+	}
+
+	mux := http.NewServeMux()
+	web.RegisterSPA(mux, fsys, web.DefaultSecurityHeaders())
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag header")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", rr.Code)
+	}
+}
+
+func TestRegisterSPASecurityHeaders(t *testing.T) {
+	fsys := fstest.MapFS{
+		indexHtmlFileName: {Data: []byte(indexHtmlContent)},
+	}
+
+	mux := http.NewServeMux()
+	web.RegisterSPA(mux, fsys, web.SecurityHeaders{
+		ContentSecurityPolicy: "default-src 'self'",
+		ReferrerPolicy:        "same-origin",
+		FrameAncestors:        "'none'",
+	})
+
+	for _, p := range []string{"/", "/missing.js"} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, p, nil))
+
+		h := rr.Header()
+		if got := h.Get("Content-Security-Policy"); got != "default-src 'self'; frame-ancestors 'none'" {
+			t.Fatalf("%s: unexpected Content-Security-Policy %q", p, got)
+		}
+		if got := h.Get("Referrer-Policy"); got != "same-origin" {
+			t.Fatalf("%s: unexpected Referrer-Policy %q", p, got)
+		}
+		if got := h.Get("X-Frame-Options"); got != "DENY" {
+			t.Fatalf("%s: unexpected X-Frame-Options %q", p, got)
+		}
+		if got := h.Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Fatalf("%s: unexpected X-Content-Type-Options %q", p, got)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/jobs"
+	"github.com/gabriel-logan/yt-dlp/server/internal/jsonfile"
+)
+
+// Subscription sends the job events of an API key to a URL.
+type Subscription struct {
+	ID        string           `json:"id"`
+	KeyID     string           `json:"key_id"`
+	URL       string           `json:"url"`
+	Secret    string           `json:"secret"`
+	Events    []jobs.EventType `json:"events,omitempty"` // every event when empty
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// Reports whether s wants events of type t.
+func (s Subscription) Wants(t jobs.EventType) bool {
+	return len(s.Events) == 0 || slices.Contains(s.Events, t)
+}
+
+// Store holds the webhook subscriptions of every API key, in a JSON file
+// readable only by the server since it holds the signing secrets, or in
+// memory when its path is empty.
+type Store struct {
+	path string
+
+	mu   sync.Mutex
+	subs []Subscription
+}
+
+// Loads the webhook subscriptions saved at path, or starts with none when the
+// file does not exist yet.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhooks file: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &s.subs); err != nil {
+		return nil, fmt.Errorf("invalid webhooks file %s: %v", path, err)
+	}
+
+	return s, nil
+}
+
+// Registers sub with a new ID and returns it; its URL must already have been
+// checked by the caller.
+func (s *Store) Create(sub Subscription) (Subscription, error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return Subscription{}, fmt.Errorf("failed to generate webhook id: %v", err)
+	}
+	sub.ID = hex.EncodeToString(id)
+	sub.CreatedAt = time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subs = append(s.subs, sub)
+	if err := s.saveLocked(); err != nil {
+		s.subs = s.subs[:len(s.subs)-1]
+		return Subscription{}, err
+	}
+
+	return sub, nil
+}
+
+// Returns the subscriptions of keyID.
+func (s *Store) List(keyID string) []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []Subscription{}
+	for _, sub := range s.subs {
+		if sub.KeyID == keyID {
+			list = append(list, sub)
+		}
+	}
+
+	return list
+}
+
+// Removes the subscription id of keyID, reporting whether it existed.
+func (s *Store) Delete(keyID, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.subs, func(sub Subscription) bool { return sub.ID == id && sub.KeyID == keyID })
+	if i < 0 {
+		return false, nil
+	}
+
+	removed := s.subs[i]
+	s.subs = slices.Delete(s.subs, i, i+1)
+	if err := s.saveLocked(); err != nil {
+		s.subs = slices.Insert(s.subs, i, removed)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Saves the subscriptions, signing secrets included, unless the store is
+// kept in memory.
+func (s *Store) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+
+	if err := jsonfile.Write(s.path, s.subs); err != nil {
+		return fmt.Errorf("failed to write webhooks file: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,393 @@
+// Package webhooks posts job events to URLs chosen by clients, so they are
+// told when a download is ready instead of polling. Every request is signed
+// with HMAC-SHA256 using the secret of its subscription, and failed
+// deliveries are retried with exponential backoff. Receivers on private,
+// loopback and link-local addresses are refused, both when a URL is
+// registered and when it is dialed, unless their network is allowed.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/jobs"
+)
+
+// Headers sent with every delivery.
+const (
+	SignatureHeader = "X-Webhook-Signature" // "sha256=" + hex HMAC of the timestamp, '.' and the body
+	TimestampHeader = "X-Webhook-Timestamp" // Unix time of the attempt
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery" // ID of the delivery, the same for every attempt
+)
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	ID        string         `json:"id"`
+	Event     jobs.EventType `json:"event"`
+	CreatedAt time.Time      `json:"created_at"`
+	Job       jobs.Job       `json:"job"`
+}
+
+// Returns the signature of a request body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Reports whether signature, the value of SignatureHeader, matches the body
+// and the timestamp header, for receivers written in Go.
+func Verify(secret, signature, timestamp string, body []byte) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body)))
+}
+
+// DeliveryStatus is the outcome of a delivery.
+type DeliveryStatus string
+
+const (
+	Pending   DeliveryStatus = "pending" // being sent, or waiting for a retry
+	Delivered DeliveryStatus = "delivered"
+	Failed    DeliveryStatus = "failed" // gave up
+)
+
+// Delivery is an event sent to one URL, and its attempts so far.
+type Delivery struct {
+	ID             string         `json:"id"`
+	KeyID          string         `json:"-"`
+	SubscriptionID string         `json:"subscription_id,omitempty" doc:"empty for the webhook_url of the job"`
+	JobID          string         `json:"job_id"`
+	Event          jobs.EventType `json:"event"`
+	URL            string         `json:"url"`
+	Status         DeliveryStatus `json:"status" enum:"pending,delivered,failed"`
+	Attempts       []Attempt      `json:"attempts"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// Attempt is one request of a delivery.
+type Attempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty" doc:"status answered by the receiver; 0 when it could not be reached"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// ErrForbiddenAddress is reported for receivers on a private, loopback or
+// link-local address outside Options.Allow.
+var ErrForbiddenAddress = errors.New("webhooks cannot be sent to private, loopback or link-local addresses")
+
+// sharedAddressSpace is the carrier-grade NAT range, RFC 6598.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Options configures a Dispatcher. Zero values select the defaults.
+type Options struct {
+	Client      *http.Client   // default: 10 second timeout, no redirects, dialing only allowed addresses
+	Allow       []netip.Prefix // private, loopback or link-local networks receivers may still use
+	MaxAttempts int            // default: 6
+	Backoff     time.Duration  // wait before the first retry, doubled for each next one; default: 10 seconds
+	MaxBackoff  time.Duration  // default: 10 minutes
+	LogSize     int            // deliveries kept in the log; default: 500
+}
+
+// Dispatcher sends job events to the subscriptions of the job's key and to
+// the webhook of the job itself, keeping a log of recent deliveries.
+type Dispatcher struct {
+	store *Store
+	opts  Options
+
+	mu  sync.Mutex
+	log []*Delivery // oldest first
+}
+
+// Returns a Dispatcher sending to the subscriptions in store.
+func NewDispatcher(store *Store, opts Options) *Dispatcher {
+	d := &Dispatcher{store: store}
+
+	if opts.Client == nil {
+		dialer := &net.Dialer{Timeout: 10 * time.Second, Control: d.checkDial}
+		opts.Client = &http.Client{
+			Timeout: 10 * time.Second,
+			// no proxy: it would be dialed instead of the receiver
+			Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 10 * time.Second},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 6
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 10 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 10 * time.Minute
+	}
+	if opts.LogSize <= 0 {
+		opts.LogSize = 500
+	}
+
+	d.opts = opts
+	return d
+}
+
+// Returns the subscription store.
+func (d *Dispatcher) Store() *Store {
+	return d.store
+}
+
+// Sends ev to everyone who asked for it, in the background. It has the
+// signature of a jobs.Queue listener.
+func (d *Dispatcher) Notify(ev jobs.Event) {
+	type target struct{ subID, url, secret string }
+
+	var targets []target
+	if ev.Job.WebhookURL != "" {
+		targets = append(targets, target{url: ev.Job.WebhookURL, secret: ev.Job.WebhookSecret})
+	}
+	for _, sub := range d.store.List(ev.Job.KeyID) {
+		if sub.Wants(ev.Type) {
+			targets = append(targets, target{subID: sub.ID, url: sub.URL, secret: sub.Secret})
+		}
+	}
+
+	for _, t := range targets {
+		id, err := newID()
+		if err != nil {
+			log.Println("Webhook error: ", err)
+			continue
+		}
+
+		body, err := json.Marshal(Payload{ID: id, Event: ev.Type, CreatedAt: ev.Time, Job: ev.Job})
+		if err != nil {
+			log.Println("Webhook error: ", err)
+			continue
+		}
+
+		del := &Delivery{
+			ID:             id,
+			KeyID:          ev.Job.KeyID,
+			SubscriptionID: t.subID,
+			JobID:          ev.Job.ID,
+			Event:          ev.Type,
+			URL:            t.url,
+			Status:         Pending,
+			CreatedAt:      time.Now().UTC(),
+		}
+		d.record(del)
+
+		go d.deliver(del, t.secret, body)
+	}
+}
+
+// Returns the logged deliveries of keyID, newest first.
+func (d *Dispatcher) Deliveries(keyID string) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	list := []Delivery{}
+	for i := len(d.log) - 1; i >= 0; i-- {
+		if del := d.log[i]; del.KeyID == keyID {
+			c := *del
+			c.Attempts = append([]Attempt(nil), del.Attempts...)
+			list = append(list, c)
+		}
+	}
+
+	return list
+}
+
+func (d *Dispatcher) record(del *Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.log = append(d.log, del)
+	if over := len(d.log) - d.opts.LogSize; over > 0 {
+		d.log = append(d.log[:0:0], d.log[over:]...)
+	}
+}
+
+// Posts body until the receiver accepts it, it answers with a status not
+// worth retrying, or the attempts run out.
+func (d *Dispatcher) deliver(del *Delivery, secret string, body []byte) {
+	wait := d.opts.Backoff
+
+	for n := 1; ; n++ {
+		attempt, retry := d.attempt(del, secret, body)
+
+		d.mu.Lock()
+		del.Attempts = append(del.Attempts, attempt)
+		switch {
+		case attempt.Error == "":
+			del.Status = Delivered
+		case !retry || n >= d.opts.MaxAttempts:
+			del.Status = Failed
+		}
+		status := del.Status
+		d.mu.Unlock()
+
+		if status != Pending {
+			if status == Failed {
+				log.Printf("Webhook error: delivery %s to %s failed after %d attempts: %s", del.ID, del.URL, n, attempt.Error)
+			}
+			return
+		}
+
+		time.Sleep(wait)
+		wait = min(wait*2, d.opts.MaxBackoff)
+	}
+}
+
+// Sends one request, reporting whether a failure is worth retrying.
+func (d *Dispatcher) attempt(del *Delivery, secret string, body []byte) (Attempt, bool) {
+	start := time.Now()
+	a := Attempt{At: start.UTC()}
+
+	req, err := http.NewRequest(http.MethodPost, del.URL, bytes.NewReader(body))
+	if err != nil {
+		a.Error = err.Error()
+		return a, false
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "yt-dlp-server-webhooks")
+	req.Header.Set(EventHeader, string(del.Event))
+	req.Header.Set(DeliveryHeader, del.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(start.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(secret, start.Unix(), body))
+
+	resp, err := d.opts.Client.Do(req)
+	a.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		a.Error = err.Error()
+		return a, !errors.Is(err, ErrForbiddenAddress)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	a.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return a, false
+	}
+
+	a.Error = "receiver answered " + resp.Status
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout
+
+	return a, retry
+}
+
+func newID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate delivery id: %v", err)
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// Reports whether rawURL can receive webhooks: an absolute http or https URL.
+func ValidURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Reports why rawURL cannot receive webhooks: it must be a valid URL whose
+// host resolves to allowed addresses only. The addresses are checked again
+// when dialed, as DNS may answer differently by then.
+func (d *Dispatcher) CheckURL(ctx context.Context, rawURL string) error {
+	if !ValidURL(rawURL) {
+		return errors.New("must be an absolute http or https URL")
+	}
+
+	u, _ := url.Parse(rawURL)
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("host %s could not be resolved", u.Hostname())
+	}
+
+	for _, addr := range addrs {
+		if !d.allowed(addr) {
+			return ErrForbiddenAddress
+		}
+	}
+
+	return nil
+}
+
+// Refuses connections to addresses that are not allowed. It is the Control
+// function of the dialer of the default client.
+func (d *Dispatcher) checkDial(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid receiver address %s: %v", address, err)
+	}
+	if !d.allowed(ap.Addr()) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+// Reports whether a receiver at addr may be reached: public unicast
+// addresses, and those in Options.Allow.
+func (d *Dispatcher) allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range d.opts.Allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// Parses a comma-separated list of networks, Ex: "127.0.0.1/32,10.1.0.0/16".
+// A bare address allows only itself.
+func ParseNetworks(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(item); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		p, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %v", item, err)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+
+	return prefixes, nil
+}
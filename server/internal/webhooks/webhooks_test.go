@@ -0,0 +1,306 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gabriel-logan/yt-dlp/server/internal/jobs"
+	"github.com/gabriel-logan/yt-dlp/server/internal/webhooks"
+)
+
+// receiver records the verified payloads posted to it, answering with the
+// statuses in replies first and 204 afterwards.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	replies  []int
+	payloads []webhooks.Payload
+	received chan struct{}
+}
+
+func newReceiver(t *testing.T, secret string, replies ...int) *receiver {
+	rc := &receiver{replies: replies, received: make(chan struct{}, 100)}
+
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !webhooks.Verify(secret, r.Header.Get(webhooks.SignatureHeader), r.Header.Get(webhooks.TimestampHeader), body) {
+			t.Errorf("bad signature %q", r.Header.Get(webhooks.SignatureHeader))
+		}
+
+		var p webhooks.Payload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		if r.Header.Get(webhooks.EventHeader) != string(p.Event) || r.Header.Get(webhooks.DeliveryHeader) != p.ID {
+			t.Errorf("headers do not match the payload: %v", r.Header)
+		}
+
+		rc.mu.Lock()
+		status := http.StatusNoContent
+		if len(rc.replies) > 0 {
+			status, rc.replies = rc.replies[0], rc.replies[1:]
+		}
+		if status < 300 {
+			rc.payloads = append(rc.payloads, p)
+		}
+		rc.mu.Unlock()
+
+		w.WriteHeader(status)
+		rc.received <- struct{}{}
+	}))
+	t.Cleanup(rc.Close)
+
+	return rc
+}
+
+// Waits for n requests.
+func (rc *receiver) wait(t *testing.T, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		select {
+		case <-rc.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for request %d", i+1)
+		}
+	}
+}
+
+// Waits until every delivery of keyID has finished.
+func waitDeliveries(t *testing.T, d *webhooks.Dispatcher, keyID string, n int) []webhooks.Delivery {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		list := d.Deliveries(keyID)
+		done := len(list) == n
+		for _, del := range list {
+			done = done && del.Status != webhooks.Pending
+		}
+		if done {
+			return list
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d deliveries, got %+v", n, list)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newDispatcher(t *testing.T) *webhooks.Dispatcher {
+	t.Helper()
+
+	store, err := webhooks.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return webhooks.NewDispatcher(store, webhooks.Options{Backoff: time.Millisecond, MaxAttempts: 3, Allow: loopback})
+}
+
+// loopback allows the receivers of the tests, which listen on 127.0.0.1.
+var loopback = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+
+func event(t jobs.EventType, job jobs.Job) jobs.Event {
+	return jobs.Event{Type: t, Job: job, Time: time.Now().UTC()}
+}
+
+func TestDispatcherSignsAndDelivers(t *testing.T) {
+	rc := newReceiver(t, "sub-secret")
+	d := newDispatcher(t)
+
+	sub, err := d.Store().Create(webhooks.Subscription{KeyID: "k1", URL: rc.URL, Secret: "sub-secret", Events: []jobs.EventType{jobs.EventSucceeded}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	job := jobs.Job{ID: "j1", KeyID: "k1", Status: jobs.Succeeded, File: "video.mp4"}
+	d.Notify(event(jobs.EventQueued, job)) // not wanted
+	d.Notify(event(jobs.EventSucceeded, job))
+	rc.wait(t, 1)
+
+	list := waitDeliveries(t, d, "k1", 1)
+	if del := list[0]; del.Status != webhooks.Delivered || del.SubscriptionID != sub.ID || len(del.Attempts) != 1 || del.Attempts[0].StatusCode != 204 {
+		t.Fatalf("unexpected delivery %+v", del)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if p := rc.payloads[0]; p.Event != jobs.EventSucceeded || p.Job.ID != "j1" || p.Job.File != "video.mp4" {
+		t.Fatalf("unexpected payload %+v", p)
+	}
+
+	if got := d.Deliveries("k2"); len(got) != 0 {
+		t.Fatalf("expected no deliveries for another key, got %v", got)
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	rc := newReceiver(t, "job-secret", http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	d := newDispatcher(t)
+
+	d.Notify(event(jobs.EventFailed, jobs.Job{ID: "j1", KeyID: "k1", WebhookURL: rc.URL, WebhookSecret: "job-secret"}))
+	rc.wait(t, 3)
+
+	del := waitDeliveries(t, d, "k1", 1)[0]
+	if del.Status != webhooks.Delivered || len(del.Attempts) != 3 {
+		t.Fatalf("expected delivery on the third attempt, got %+v", del)
+	}
+	if del.Attempts[0].StatusCode != 503 || del.Attempts[0].Error == "" || del.Attempts[2].Error != "" {
+		t.Fatalf("unexpected attempts %+v", del.Attempts)
+	}
+	if gap := del.Attempts[2].At.Sub(del.Attempts[1].At); gap < 2*time.Millisecond {
+		t.Fatalf("expected the backoff to double, got %s", gap)
+	}
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	down := newReceiver(t, "s", 500, 500, 500, 500)
+	rejecting := newReceiver(t, "s", http.StatusGone)
+	d := newDispatcher(t)
+
+	d.Store().Create(webhooks.Subscription{KeyID: "k1", URL: down.URL, Secret: "s"})
+	d.Store().Create(webhooks.Subscription{KeyID: "k1", URL: rejecting.URL, Secret: "s"})
+
+	d.Notify(event(jobs.EventStarted, jobs.Job{ID: "j1", KeyID: "k1"}))
+
+	for _, del := range waitDeliveries(t, d, "k1", 2) {
+		want := 3 // MaxAttempts
+		if del.URL == rejecting.URL {
+			want = 1 // client errors are not retried
+		}
+		if del.Status != webhooks.Failed || len(del.Attempts) != want {
+			t.Errorf("%s: expected failure after %d attempts, got %+v", del.URL, want, del)
+		}
+	}
+}
+
+func TestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "webhooks.json")
+
+	store, err := webhooks.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sub, err := store.Create(webhooks.Subscription{KeyID: "k1", URL: "https://example.com/hook", Secret: "s"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store.Create(webhooks.Subscription{KeyID: "k2", URL: "https://example.com/other", Secret: "s"})
+
+	reopened, err := webhooks.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if list := reopened.List("k1"); len(list) != 1 || list[0].ID != sub.ID || list[0].Secret != "s" {
+		t.Fatalf("unexpected subscriptions %+v", list)
+	}
+
+	if ok, _ := reopened.Delete("k2", sub.ID); ok {
+		t.Fatalf("expected another key not to delete the subscription")
+	}
+	if ok, err := reopened.Delete("k1", sub.ID); !ok || err != nil {
+		t.Fatalf("expected the subscription to be deleted, got %v, %v", ok, err)
+	}
+	if list := reopened.List("k1"); len(list) != 0 {
+		t.Fatalf("expected no subscriptions, got %+v", list)
+	}
+}
+
+func TestValidURL(t *testing.T) {
+	for url, want := range map[string]bool{
+		"https://example.com/hook": true,
+		"http://10.0.0.2:9000/":    true,
+		"ftp://example.com":        false,
+		"/relative":                false,
+		"https://":                 false,
+	} {
+		if got := webhooks.ValidURL(url); got != want {
+			t.Errorf("ValidURL(%q) = %t, want %t", url, got, want)
+		}
+	}
+}
+
+func TestDispatcherRefusesPrivateAddresses(t *testing.T) {
+	rc := newReceiver(t, "s")
+	store, _ := webhooks.Open("")
+	d := webhooks.NewDispatcher(store, webhooks.Options{Backoff: time.Millisecond, MaxAttempts: 3})
+
+	d.Notify(event(jobs.EventFailed, jobs.Job{ID: "j1", KeyID: "k1", WebhookURL: rc.URL, WebhookSecret: "s"}))
+
+	del := waitDeliveries(t, d, "k1", 1)[0]
+	if del.Status != webhooks.Failed || len(del.Attempts) != 1 || del.Attempts[0].StatusCode != 0 {
+		t.Fatalf("expected the dial to be refused without retries, got %+v", del)
+	}
+	if len(rc.received) != 0 {
+		t.Fatalf("expected the receiver not to be reached")
+	}
+}
+
+func TestDispatcherDoesNotFollowRedirects(t *testing.T) {
+	target := newReceiver(t, "s")
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	t.Cleanup(redirect.Close)
+	d := newDispatcher(t)
+
+	d.Notify(event(jobs.EventFailed, jobs.Job{ID: "j1", KeyID: "k1", WebhookURL: redirect.URL, WebhookSecret: "s"}))
+
+	del := waitDeliveries(t, d, "k1", 1)[0]
+	if del.Status != webhooks.Failed || del.Attempts[0].StatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("expected the redirect to fail the delivery, got %+v", del)
+	}
+	if len(target.received) != 0 {
+		t.Fatalf("expected the redirect not to be followed")
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	store, _ := webhooks.Open("")
+	d := webhooks.NewDispatcher(store, webhooks.Options{Allow: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")}})
+
+	for url, want := range map[string]error{
+		"https://203.0.113.10/hook":                nil,
+		"http://10.1.2.3:9000/hook":                nil, // allowed
+		"http://10.2.0.1/hook":                     webhooks.ErrForbiddenAddress,
+		"http://127.0.0.1:8080/":                   webhooks.ErrForbiddenAddress,
+		"http://[::1]/":                            webhooks.ErrForbiddenAddress,
+		"http://169.254.169.254/latest/meta-data/": webhooks.ErrForbiddenAddress,
+		"http://192.168.1.1/":                      webhooks.ErrForbiddenAddress,
+		"http://100.64.0.1/":                       webhooks.ErrForbiddenAddress,
+		"http://[::ffff:127.0.0.1]/":               webhooks.ErrForbiddenAddress,
+		"http://0.0.0.0/":                          webhooks.ErrForbiddenAddress,
+	} {
+		if err := d.CheckURL(context.Background(), url); !errors.Is(err, want) || (want == nil) != (err == nil) {
+			t.Errorf("CheckURL(%q) = %v, want %v", url, err, want)
+		}
+	}
+
+	if err := d.CheckURL(context.Background(), "ftp://example.com"); err == nil {
+		t.Errorf("expected an invalid URL to be refused")
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	nets, err := webhooks.ParseNetworks(" 127.0.0.1, 10.1.2.3/16 ,,::1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "[127.0.0.1/32 10.1.0.0/16 ::1/128]"; fmt.Sprint(nets) != want {
+		t.Fatalf("expected %s, got %v", want, nets)
+	}
+
+	if _, err := webhooks.ParseNetworks("localhost"); err == nil {
+		t.Fatalf("expected an error for a host name")
+	}
+}